@@ -0,0 +1,266 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package blockstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// Extension of the block files.
+const blockFileExt = ".iltag"
+
+// Size of the block file names.
+const blockFileNameSize = 16 + len(blockFileExt)
+
+/*
+DirStore is a Store that keeps each block in its own file. The layout of the
+directory is:
+
+	<dir>/<escaped chain name>/<block id as 16 hexadecimal digits>.iltag
+
+The chain names are escaped with url.PathEscape() and their dots are escaped as
+%2E, thus any non empty name can be used. On case insensitive file systems,
+chain names that differ only by case share the same directory.
+
+Blocks are written to temporary files that are renamed when complete, thus a
+crash never leaves a partial block behind.
+*/
+type DirStore struct {
+	dir     string
+	factory tags.ILTagFactory
+}
+
+/*
+Creates a new DirStore that uses the given directory, creating it if required.
+The factory is used to decode the blocks. If nil, a non strict
+StandardTagFactory is used.
+*/
+func NewDirStore(dir string, factory tags.ILTagFactory) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	return &DirStore{dir: dir, factory: factory}, nil
+}
+
+// Returns the name of the directory of the chain.
+func escapeChain(chain string) string {
+	return strings.ReplaceAll(url.PathEscape(chain), ".", "%2E")
+}
+
+// Returns the path of the directory of the chain.
+func (s *DirStore) chainDir(chain string) string {
+	return filepath.Join(s.dir, escapeChain(chain))
+}
+
+// Returns the path of the block file.
+func (s *DirStore) blockFile(ref Ref) string {
+	return filepath.Join(s.chainDir(ref.Chain), fmt.Sprintf("%016x%s", ref.Block, blockFileExt))
+}
+
+// Returns the block ID encoded in the file name.
+func parseBlockFileName(name string) (uint64, bool) {
+	if len(name) != blockFileNameSize || !strings.HasSuffix(name, blockFileExt) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(name[:16], 16, 64)
+	return id, err == nil
+}
+
+// Implementation of Store.
+func (s *DirStore) Put(ref Ref, block tags.ILTag) error {
+	if err := checkChain(ref.Chain); err != nil {
+		return err
+	}
+	b, err := tags.ILTagToBytes(block)
+	if err != nil {
+		return err
+	}
+	dir := s.chainDir(ref.Chain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.blockFile(ref))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Reads and decodes the block file.
+func (s *DirStore) read(ref Ref) (tags.ILTag, error) {
+	b, err := os.ReadFile(s.blockFile(ref))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, notFound(ref)
+		}
+		return nil, err
+	}
+	tag, err := tags.ILTagFromBytes(s.factory, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	return tag, nil
+}
+
+// Implementation of Store.
+func (s *DirStore) Get(ref Ref) (tags.ILTag, error) {
+	if err := checkChain(ref.Chain); err != nil {
+		return nil, err
+	}
+	return s.read(ref)
+}
+
+// Implementation of Store.
+func (s *DirStore) Has(ref Ref) (bool, error) {
+	if err := checkChain(ref.Chain); err != nil {
+		return false, err
+	}
+	_, err := os.Stat(s.blockFile(ref))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Returns true if the directory contains at least one block file.
+func hasBlocks(dir string) (bool, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	for {
+		names, err := f.Readdirnames(16)
+		for _, name := range names {
+			if _, ok := parseBlockFileName(name); ok {
+				return true, nil
+			}
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// Implementation of Store.
+func (s *DirStore) Chains() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	chains := []string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		chain, err := url.PathUnescape(e.Name())
+		if err != nil || chain == "" || escapeChain(chain) != e.Name() {
+			continue
+		}
+		found, err := hasBlocks(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			chains = append(chains, chain)
+		}
+	}
+	sort.Strings(chains)
+	return chains, nil
+}
+
+// Implementation of Store.
+func (s *DirStore) Range(chain string, r *impl.RangeTag) ([]Block, error) {
+	if err := checkChain(chain); err != nil {
+		return nil, err
+	}
+	blocks := []Block{}
+	first, last, empty := rangeIds(r)
+	if empty {
+		return blocks, nil
+	}
+	entries, err := os.ReadDir(s.chainDir(chain))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return blocks, nil
+		}
+		return nil, err
+	}
+	// The entries are sorted by name, thus by block ID
+	for _, e := range entries {
+		id, ok := parseBlockFileName(e.Name())
+		if !ok || e.IsDir() || id < first || id > last {
+			continue
+		}
+		ref := Ref{Chain: chain, Block: id}
+		tag, err := s.read(ref)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, Block{Ref: ref, Tag: tag})
+	}
+	return blocks, nil
+}
@@ -0,0 +1,159 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package blockstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirStore(t *testing.T) {
+	store, err := NewDirStore(filepath.Join(t.TempDir(), "store"), nil)
+	require.Nil(t, err)
+	testStore(t, store)
+}
+
+func TestNewDirStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.Nil(t, os.WriteFile(file, nil, 0644))
+	_, err := NewDirStore(file, nil)
+	assert.Error(t, err)
+}
+
+func TestEscapeChain(t *testing.T) {
+	assert.Equal(t, "chain", escapeChain("chain"))
+	assert.Equal(t, "%2E", escapeChain("."))
+	assert.Equal(t, "%2E%2E", escapeChain(".."))
+	assert.Equal(t, "a%2Fb%2Ec", escapeChain("a/b.c"))
+	assert.Equal(t, "a%25b", escapeChain("a%b"))
+}
+
+func TestParseBlockFileName(t *testing.T) {
+	id, ok := parseBlockFileName("00000000000000ff.iltag")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0xff), id)
+	_, ok = parseBlockFileName("00000000000000ff.tmp")
+	assert.False(t, ok)
+	_, ok = parseBlockFileName("0000000000000ff.iltag")
+	assert.False(t, ok)
+	_, ok = parseBlockFileName("000000000000000x.iltag")
+	assert.False(t, ok)
+}
+
+func TestDirStoreLayout(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDirStore(dir, nil)
+	require.Nil(t, err)
+
+	for _, chain := range []string{".", "..", "a/b"} {
+		require.Nil(t, store.Put(Ref{Chain: chain, Block: 0x10}, impl.MustParseTag("u8(1)")))
+	}
+	_, err = os.Stat(filepath.Join(dir, "%2E%2E", "0000000000000010.iltag"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(dir, "a%2Fb", "0000000000000010.iltag"))
+	assert.Nil(t, err)
+
+	// Unrelated entries are ignored
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "%zz"), 0755))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "a.b"), 0755))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "empty"), 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "empty", ".tmp-1"), nil, 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "a%2Fb", ".tmp-1"), nil, 0644))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "a%2Fb", "0000000000000011.iltag"), 0755))
+	chains, err := store.Chains()
+	require.Nil(t, err)
+	assert.Equal(t, []string{".", "..", "a/b"}, chains)
+	blocks, err := store.Range("a/b", newRange(0, 100))
+	require.Nil(t, err)
+	assert.Equal(t, []uint64{0x10}, blockIds(blocks))
+
+	// The store can be reopened
+	store, err = NewDirStore(dir, nil)
+	require.Nil(t, err)
+	tag, err := store.Get(Ref{Chain: "..", Block: 0x10})
+	require.Nil(t, err)
+	assert.Equal(t, uint8(1), tag.(*impl.UInt8Tag).Payload)
+}
+
+func TestDirStoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDirStore(dir, impl.NewStandardTagFactory(true))
+	require.Nil(t, err)
+
+	// Corrupted blocks
+	ref := Ref{Chain: "a", Block: 1}
+	require.Nil(t, store.Put(ref, impl.MustParseTag("u8(1)")))
+	require.Nil(t, os.WriteFile(store.blockFile(ref), []byte{0x03, 0x01, 0x02}, 0644))
+	_, err = store.Get(ref)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	assert.Contains(t, err.Error(), "a#1: ")
+	_, err = store.Range("a", newRange(0, 2))
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+
+	// Unsupported tags
+	raw := tags.NewRawTag(1000)
+	require.Nil(t, store.Put(ref, raw))
+	_, err = store.Get(ref)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+
+	// A directory in place of a block
+	ref = Ref{Chain: "a", Block: 2}
+	require.Nil(t, os.Mkdir(store.blockFile(ref), 0755))
+	_, err = store.Get(ref)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+	assert.Error(t, store.Put(ref, impl.MustParseTag("null")))
+	entries, err := os.ReadDir(store.chainDir("a"))
+	require.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	// A file in place of a chain
+	require.Nil(t, os.WriteFile(store.chainDir("b"), nil, 0644))
+	assert.Error(t, store.Put(Ref{Chain: "b", Block: 1}, impl.MustParseTag("null")))
+	_, err = store.Range("b", newRange(0, 1))
+	assert.Error(t, err)
+	_, err = store.Has(Ref{Chain: "b", Block: 1})
+	assert.Error(t, err)
+
+	// Missing store directory
+	require.Nil(t, os.RemoveAll(dir))
+	_, err = store.Chains()
+	assert.Error(t, err)
+}
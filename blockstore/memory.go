@@ -0,0 +1,155 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package blockstore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+MemoryStore is a Store that keeps the serialized blocks in memory.
+*/
+type MemoryStore struct {
+	factory tags.ILTagFactory
+	mutex   sync.RWMutex
+	chains  map[string]map[uint64][]byte
+}
+
+/*
+Creates a new empty MemoryStore. The factory is used to decode the blocks. If
+nil, a non strict StandardTagFactory is used.
+*/
+func NewMemoryStore(factory tags.ILTagFactory) *MemoryStore {
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	return &MemoryStore{factory: factory, chains: make(map[string]map[uint64][]byte)}
+}
+
+// Implementation of Store.
+func (s *MemoryStore) Put(ref Ref, block tags.ILTag) error {
+	if err := checkChain(ref.Chain); err != nil {
+		return err
+	}
+	b, err := tags.ILTagToBytes(block)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	chain := s.chains[ref.Chain]
+	if chain == nil {
+		chain = make(map[uint64][]byte)
+		s.chains[ref.Chain] = chain
+	}
+	chain[ref.Block] = b
+	return nil
+}
+
+// Returns the serialized block, if it exists.
+func (s *MemoryStore) get(ref Ref) ([]byte, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	b, ok := s.chains[ref.Chain][ref.Block]
+	return b, ok
+}
+
+// Implementation of Store.
+func (s *MemoryStore) Get(ref Ref) (tags.ILTag, error) {
+	if err := checkChain(ref.Chain); err != nil {
+		return nil, err
+	}
+	b, ok := s.get(ref)
+	if !ok {
+		return nil, notFound(ref)
+	}
+	return tags.ILTagFromBytes(s.factory, b)
+}
+
+// Implementation of Store.
+func (s *MemoryStore) Has(ref Ref) (bool, error) {
+	if err := checkChain(ref.Chain); err != nil {
+		return false, err
+	}
+	_, ok := s.get(ref)
+	return ok, nil
+}
+
+// Implementation of Store.
+func (s *MemoryStore) Chains() ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	chains := make([]string, 0, len(s.chains))
+	for name := range s.chains {
+		chains = append(chains, name)
+	}
+	sort.Strings(chains)
+	return chains, nil
+}
+
+// Implementation of Store.
+func (s *MemoryStore) Range(chain string, r *impl.RangeTag) ([]Block, error) {
+	if err := checkChain(chain); err != nil {
+		return nil, err
+	}
+	blocks := []Block{}
+	first, last, empty := rangeIds(r)
+	if empty {
+		return blocks, nil
+	}
+	var found []uint64
+	var data [][]byte
+	s.mutex.RLock()
+	for id := first; ; id++ {
+		if b, ok := s.chains[chain][id]; ok {
+			found = append(found, id)
+			data = append(data, b)
+		}
+		if id == last {
+			break
+		}
+	}
+	s.mutex.RUnlock()
+	for i, id := range found {
+		tag, err := tags.ILTagFromBytes(s.factory, data[i])
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, Block{Ref: Ref{Chain: chain, Block: id}, Tag: tag})
+	}
+	return blocks, nil
+}
@@ -0,0 +1,146 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package blockstore
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+var (
+	// The block does not exist in the store.
+	ErrNotFound = fmt.Errorf("block not found")
+	// The chain name cannot be used by the store.
+	ErrInvalidChainName = fmt.Errorf("invalid chain name")
+)
+
+/*
+Ref identifies a block by its chain name and block ID.
+*/
+type Ref struct {
+	Chain string
+	Block uint64
+}
+
+// Returns the Ref stored in the given ChainNameBlockRefTag.
+func RefOf(tag *ext.ChainNameBlockRefTag) Ref {
+	return Ref{Chain: tag.ChainName(), Block: tag.BlockId()}
+}
+
+/*
+Creates a new ChainNameBlockRefTag with the given ID that holds this reference.
+The optional inner tag IDs are the same of ext.NewChainNameBlockRefTag().
+*/
+func (r Ref) Tag(id tags.TagID, innerTagIds ...tags.TagID) *ext.ChainNameBlockRefTag {
+	t := ext.NewChainNameBlockRefTag(id, innerTagIds...)
+	t.SetChainName(r.Chain)
+	t.SetBlockId(r.Block)
+	return t
+}
+
+// Implementation of fmt.Stringer. The format is <chain>#<block>.
+func (r Ref) String() string {
+	return fmt.Sprintf("%s#%d", r.Chain, r.Block)
+}
+
+/*
+Block is a block returned by a range query.
+*/
+type Block struct {
+	Ref Ref
+	Tag tags.ILTag
+}
+
+/*
+Store is a local store of chain blocks. The blocks are stored in their
+serialized form, thus changing a tag after Put() does not change the stored
+block.
+
+Empty chain names are rejected with ErrInvalidChainName by all operations.
+The implementations are safe to use from multiple goroutines.
+*/
+type Store interface {
+	// Stores the block, replacing the previous block with the same Ref.
+	Put(ref Ref, block tags.ILTag) error
+	// Returns the block. It fails with ErrNotFound if it does not exist.
+	Get(ref Ref) (tags.ILTag, error)
+	// Returns true if the block exists.
+	Has(ref Ref) (bool, error)
+	// Returns the names of the chains with at least one block, in order.
+	Chains() ([]string, error)
+	/*
+		Returns the existing blocks of the chain with the IDs in the given
+		range, ordered by their IDs. Missing blocks are skipped.
+	*/
+	Range(chain string, r *impl.RangeTag) ([]Block, error)
+}
+
+/*
+Returns the block referenced by the ChainNameBlockRefTag. It fails with
+ErrNotFound if the store does not have it.
+*/
+func Resolve(store Store, ref *ext.ChainNameBlockRefTag) (tags.ILTag, error) {
+	return store.Get(RefOf(ref))
+}
+
+// Verifies the chain name.
+func checkChain(chain string) error {
+	if chain == "" {
+		return ErrInvalidChainName
+	}
+	return nil
+}
+
+// Returns the error returned for missing blocks.
+func notFound(ref Ref) error {
+	return fmt.Errorf("%s: %w", ref, ErrNotFound)
+}
+
+/*
+Returns the IDs of the blocks in the range. The range ends at the largest block
+ID if it would overflow.
+*/
+func rangeIds(r *impl.RangeTag) (first uint64, last uint64, empty bool) {
+	if r.Count == 0 {
+		return 0, 0, true
+	}
+	last = r.Start + uint64(r.Count) - 1
+	if last < r.Start {
+		last = math.MaxUint64
+	}
+	return r.Start, last, false
+}
@@ -0,0 +1,201 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package blockstore
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a RangeTag with the given values.
+func newRange(start uint64, count uint16) *impl.RangeTag {
+	r := impl.NewStdRangeTag()
+	r.Start = start
+	r.Count = count
+	return r
+}
+
+// Returns the IDs of the blocks.
+func blockIds(blocks []Block) []uint64 {
+	ids := []uint64{}
+	for _, b := range blocks {
+		ids = append(ids, b.Ref.Block)
+	}
+	return ids
+}
+
+func TestRef(t *testing.T) {
+	ref := Ref{Chain: "chain", Block: 10}
+	assert.Equal(t, "chain#10", ref.String())
+
+	tag := ref.Tag(1000)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+	assert.Equal(t, "chain", tag.ChainName())
+	assert.Equal(t, uint64(10), tag.BlockId())
+	assert.Equal(t, ref, RefOf(tag))
+}
+
+func TestRangeIds(t *testing.T) {
+	first, last, empty := rangeIds(newRange(10, 0))
+	assert.True(t, empty)
+
+	first, last, empty = rangeIds(newRange(10, 5))
+	assert.False(t, empty)
+	assert.Equal(t, uint64(10), first)
+	assert.Equal(t, uint64(14), last)
+
+	first, last, empty = rangeIds(newRange(math.MaxUint64-1, 5))
+	assert.False(t, empty)
+	assert.Equal(t, uint64(math.MaxUint64-1), first)
+	assert.Equal(t, uint64(math.MaxUint64), last)
+}
+
+/*
+Verifies the behavior shared by all implementations of Store. The store must
+be empty.
+*/
+func testStore(t *testing.T, store Store) {
+	chains, err := store.Chains()
+	require.Nil(t, err)
+	assert.Empty(t, chains)
+
+	// Empty chain names
+	empty := Ref{Block: 1}
+	assert.ErrorIs(t, store.Put(empty, impl.NewStdNullTag()), ErrInvalidChainName)
+	_, err = store.Get(empty)
+	assert.ErrorIs(t, err, ErrInvalidChainName)
+	_, err = store.Has(empty)
+	assert.ErrorIs(t, err, ErrInvalidChainName)
+	_, err = store.Range("", newRange(0, 1))
+	assert.ErrorIs(t, err, ErrInvalidChainName)
+
+	// Missing blocks
+	ref := Ref{Chain: "b", Block: 1}
+	_, err = store.Get(ref)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "b#1")
+	found, err := store.Has(ref)
+	require.Nil(t, err)
+	assert.False(t, found)
+	blocks, err := store.Range("b", newRange(0, 10))
+	require.Nil(t, err)
+	assert.Empty(t, blocks)
+
+	// Put and Get
+	block := impl.NewStdStringTag()
+	block.Payload = "block 1"
+	require.Nil(t, store.Put(ref, block))
+	block.Payload = "changed"
+	found, err = store.Has(ref)
+	require.Nil(t, err)
+	assert.True(t, found)
+	tag, err := store.Get(ref)
+	require.Nil(t, err)
+	assert.Equal(t, "block 1", tag.(*impl.StringTag).Payload)
+
+	// Replace
+	require.Nil(t, store.Put(ref, impl.MustParseTag(`str("block 1b")`)))
+	tag, err = store.Get(ref)
+	require.Nil(t, err)
+	assert.Equal(t, "block 1b", tag.(*impl.StringTag).Payload)
+
+	// Serialization errors
+	assert.Error(t, store.Put(Ref{Chain: "b", Block: 2}, &failingTag{}))
+	found, err = store.Has(Ref{Chain: "b", Block: 2})
+	require.Nil(t, err)
+	assert.False(t, found)
+
+	// Chains
+	for _, id := range []uint64{0, 3, 4, 7, math.MaxUint64 - 1, math.MaxUint64} {
+		require.Nil(t, store.Put(Ref{Chain: "a", Block: id}, impl.MustParseTag("u64(1)")))
+	}
+	require.Nil(t, store.Put(Ref{Chain: "c.d/e f", Block: 5}, impl.MustParseTag("null")))
+	chains, err = store.Chains()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c.d/e f"}, chains)
+
+	// Range
+	blocks, err = store.Range("a", newRange(2, 6))
+	require.Nil(t, err)
+	assert.Equal(t, []uint64{3, 4, 7}, blockIds(blocks))
+	assert.Equal(t, Ref{Chain: "a", Block: 3}, blocks[0].Ref)
+	assert.Equal(t, uint64(1), blocks[0].Tag.(*impl.UInt64Tag).Payload)
+	blocks, err = store.Range("a", newRange(0, 1))
+	require.Nil(t, err)
+	assert.Equal(t, []uint64{0}, blockIds(blocks))
+	blocks, err = store.Range("a", newRange(3, 0))
+	require.Nil(t, err)
+	assert.Empty(t, blocks)
+	blocks, err = store.Range("a", newRange(8, 100))
+	require.Nil(t, err)
+	assert.Empty(t, blocks)
+	blocks, err = store.Range("a", newRange(math.MaxUint64-1, 10))
+	require.Nil(t, err)
+	assert.Equal(t, []uint64{math.MaxUint64 - 1, math.MaxUint64}, blockIds(blocks))
+	blocks, err = store.Range("c.d/e f", newRange(0, math.MaxUint16))
+	require.Nil(t, err)
+	assert.Equal(t, []uint64{5}, blockIds(blocks))
+	blocks, err = store.Range("x", newRange(0, 10))
+	require.Nil(t, err)
+	assert.Empty(t, blocks)
+
+	// Resolve
+	tag, err = Resolve(store, Ref{Chain: "b", Block: 1}.Tag(1000))
+	require.Nil(t, err)
+	assert.Equal(t, "block 1b", tag.(*impl.StringTag).Payload)
+	_, err = Resolve(store, Ref{Chain: "b", Block: 2}.Tag(1000))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// A tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
@@ -0,0 +1,138 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package chainverify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// The block does not have the layout expected by the extractor.
+var ErrBadBlock = fmt.Errorf("bad block layout")
+
+/*
+Extractor extracts the linkage information from the blocks.
+*/
+type Extractor interface {
+	/*
+		Returns the reference of the block and the hash of the previous block.
+		The hash is empty for the first block of the chain.
+	*/
+	Extract(block tags.ILTag) (*ext.ChainNameBlockRefTag, []byte, error)
+}
+
+/*
+ExtractorFunc is a function that implements Extractor.
+*/
+type ExtractorFunc func(block tags.ILTag) (*ext.ChainNameBlockRefTag, []byte, error)
+
+// Implementation of Extractor.
+func (f ExtractorFunc) Extract(block tags.ILTag) (*ext.ChainNameBlockRefTag, []byte, error) {
+	return f(block)
+}
+
+/*
+SequenceExtractor is an Extractor for blocks stored as ILTagSequenceTags or
+ILTagArrayTags of any ID. The reference of the block is the ChainNameBlockRefTag
+at RefIndex and the previous hash is the payload of the byte array at
+PrevHashIndex. A NullTag at PrevHashIndex is the same as an empty hash.
+
+The references decoded as other types, like RawTags created by a factory that
+does not know the ChainNameBlockRefTag, are converted into
+ChainNameBlockRefTags with the inner tag IDs in InnerTagIds.
+*/
+type SequenceExtractor struct {
+	RefIndex      int
+	PrevHashIndex int
+	// Optional inner tag IDs of the references. See ext.NewChainNameBlockRefTag().
+	InnerTagIds []tags.TagID
+}
+
+// Returns the elements of the block.
+func blockElements(block tags.ILTag) ([]tags.ILTag, error) {
+	switch b := block.(type) {
+	case *impl.ILTagSequenceTag:
+		return b.Payload, nil
+	case *impl.ILTagArrayTag:
+		return b.Payload, nil
+	default:
+		return nil, fmt.Errorf("%w: tag %d is not a sequence", ErrBadBlock, block.Id())
+	}
+}
+
+// Returns the element at the given index.
+func element(list []tags.ILTag, index int) (tags.ILTag, error) {
+	if index < 0 || index >= len(list) || tags.IsILTagNil(list[index]) {
+		return nil, fmt.Errorf("%w: element %d not found", ErrBadBlock, index)
+	}
+	return list[index], nil
+}
+
+// Implementation of Extractor.
+func (e *SequenceExtractor) Extract(block tags.ILTag) (*ext.ChainNameBlockRefTag, []byte, error) {
+	list, err := blockElements(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := element(list, e.RefIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, ok := r.(*ext.ChainNameBlockRefTag)
+	if !ok {
+		ref = ext.NewChainNameBlockRefTag(r.Id(), e.InnerTagIds...)
+		b, err := tags.ILTagToBytes(r)
+		if err == nil {
+			err = tags.ILTagDeserializeInto(nil, bytes.NewReader(b), ref)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: element %d: %v", ErrBadBlock, e.RefIndex, err)
+		}
+	}
+	p, err := element(list, e.PrevHashIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch prev := p.(type) {
+	case *tags.RawTag:
+		return ref, prev.Payload, nil
+	case *impl.NullTag:
+		return ref, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: element %d is not a byte array", ErrBadBlock, e.PrevHashIndex)
+	}
+}
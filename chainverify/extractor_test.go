@@ -0,0 +1,128 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package chainverify
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractorFunc(t *testing.T) {
+	ref := ext.NewChainNameBlockRefTag(1000)
+	var f Extractor = ExtractorFunc(func(block tags.ILTag) (*ext.ChainNameBlockRefTag, []byte, error) {
+		return ref, []byte{1}, nil
+	})
+	r, h, err := f.Extract(nil)
+	require.Nil(t, err)
+	assert.Same(t, ref, r)
+	assert.Equal(t, []byte{1}, h)
+}
+
+func TestSequenceExtractor(t *testing.T) {
+	e := &SequenceExtractor{RefIndex: 0, PrevHashIndex: 1}
+
+	// Sequence with a ChainNameBlockRefTag
+	block := newBlock("chain", 10, []byte{1, 2, 3}, "data")
+	ref, h, err := e.Extract(block)
+	require.Nil(t, err)
+	assert.Same(t, block.Payload[0], ref)
+	assert.Equal(t, []byte{1, 2, 3}, h)
+
+	// Array with a null hash
+	array := impl.NewStdILTagArrayTag()
+	array.Payload = []tags.ILTag{block.Payload[0], impl.NewStdNullTag()}
+	ref, h, err = e.Extract(array)
+	require.Nil(t, err)
+	assert.Equal(t, "chain", ref.ChainName())
+	assert.Nil(t, h)
+
+	// Decoded by a factory that does not know the reference tag
+	tag, err := tags.ILTagFromBytes(impl.NewStandardTagFactory(false), mustBytes(t, block))
+	require.Nil(t, err)
+	assert.IsType(t, &tags.RawTag{}, tag.(*impl.ILTagSequenceTag).Payload[0])
+	ref, h, err = e.Extract(tag)
+	require.Nil(t, err)
+	assert.Equal(t, tags.TagID(1000), ref.Id())
+	assert.Equal(t, "chain", ref.ChainName())
+	assert.Equal(t, uint64(10), ref.BlockId())
+	assert.Equal(t, []byte{1, 2, 3}, h)
+
+	// Custom inner tag IDs
+	custom := ext.NewChainNameBlockRefTag(1001, 1002, 1003)
+	custom.SetChainName("custom")
+	custom.SetBlockId(5)
+	seq := impl.NewStdILTagSequenceTag()
+	raw := tags.NewRawTag(1001)
+	var payload bytes.Buffer
+	require.Nil(t, custom.SerializeValue(&payload))
+	raw.Payload = payload.Bytes()
+	seq.Payload = []tags.ILTag{impl.NewStdNullTag(), raw}
+	ce := &SequenceExtractor{RefIndex: 1, PrevHashIndex: 0, InnerTagIds: []tags.TagID{1002, 1003}}
+	ref, h, err = ce.Extract(seq)
+	require.Nil(t, err)
+	assert.Equal(t, "custom", ref.ChainName())
+	assert.Equal(t, uint64(5), ref.BlockId())
+	assert.Nil(t, h)
+	_, _, err = e.Extract(seq)
+	assert.ErrorIs(t, err, ErrBadBlock)
+
+	// Bad layouts
+	for _, s := range []string{
+		`str("block")`,
+		`seq[]`,
+		`seq[null]`,
+		`seq[null, null]`,
+		`seq[str("x"), null]`,
+		`#1000 bytes(0x01)`,
+	} {
+		_, _, err = e.Extract(impl.MustParseTag(s))
+		assert.ErrorIs(t, err, ErrBadBlock, s)
+	}
+	seq = impl.NewStdILTagSequenceTag()
+	seq.Payload = []tags.ILTag{nil, impl.NewStdNullTag()}
+	_, _, err = e.Extract(seq)
+	assert.ErrorIs(t, err, ErrBadBlock)
+	seq.Payload = []tags.ILTag{block.Payload[0], impl.NewStdStringTag()}
+	_, _, err = e.Extract(seq)
+	assert.ErrorIs(t, err, ErrBadBlock)
+	_, _, err = (&SequenceExtractor{RefIndex: -1}).Extract(block)
+	assert.ErrorIs(t, err, ErrBadBlock)
+	_, _, err = (&SequenceExtractor{PrevHashIndex: 5}).Extract(block)
+	assert.ErrorIs(t, err, ErrBadBlock)
+}
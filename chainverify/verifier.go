@@ -0,0 +1,215 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package chainverify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+var (
+	// The previous hash of the block does not match the hash of the previous block.
+	ErrBrokenLink = fmt.Errorf("broken hash link")
+	// The block does not follow the previous block in the same chain.
+	ErrBadSequence = fmt.Errorf("unexpected block reference")
+)
+
+/*
+LinkError reports the block that does not link to the previous block.
+*/
+type LinkError struct {
+	// Index of the block in the verified sequence.
+	Index int
+	// Reference of the block.
+	Ref *ext.ChainNameBlockRefTag
+	// ErrBrokenLink or ErrBadSequence.
+	Err error
+}
+
+// Implementation of error.
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("block %d (%s#%d): %v", e.Index, e.Ref.ChainName(),
+		e.Ref.BlockId(), e.Err)
+}
+
+// Returns the cause of the error.
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Verifier verifies the linkage of a sequence of blocks of the same chain. The
+first block is accepted as is, unless the verifier is anchored to a known block
+with Anchor(). Each following block must have the next block ID and must carry
+the hash of the block before it.
+
+A Verifier must not be used by multiple goroutines at the same time.
+*/
+type Verifier struct {
+	hash      crypto.Hash
+	extractor Extractor
+	count     int
+	last      *ext.ChainNameBlockRefTag
+	lastHash  []byte
+}
+
+/*
+Creates a new Verifier that uses the given hash function and extractor. It
+fails with ErrUnsupportedHash if the hash function is not available.
+*/
+func NewVerifier(h crypto.Hash, extractor Extractor) (*Verifier, error) {
+	if err := checkHash(h); err != nil {
+		return nil, err
+	}
+	if extractor == nil {
+		panic("The extractor cannot be nil.")
+	}
+	return &Verifier{hash: h, extractor: extractor}, nil
+}
+
+/*
+Anchors the verifier to a known block, thus the next block must follow it. It
+allows the verification of a chain in parts.
+*/
+func (v *Verifier) Anchor(ref *ext.ChainNameBlockRefTag, hash []byte) {
+	v.last = ref
+	v.lastHash = hash
+}
+
+/*
+Returns the reference and the hash of the last block verified or anchored. The
+reference is nil if there is none.
+*/
+func (v *Verifier) Last() (*ext.ChainNameBlockRefTag, []byte) {
+	return v.last, v.lastHash
+}
+
+// Returns the number of blocks verified so far.
+func (v *Verifier) Count() int {
+	return v.count
+}
+
+/*
+Verifies the next block. It returns a LinkError if the block does not link to
+the previous one. Errors returned by the extractor are reported with the index
+of the block. The verifier does not advance on errors.
+*/
+func (v *Verifier) Add(block tags.ILTag) error {
+	ref, prevHash, err := v.extractor.Extract(block)
+	if err != nil {
+		return fmt.Errorf("block %d: %w", v.count, err)
+	}
+	if v.last != nil {
+		if ref.ChainName() != v.last.ChainName() || ref.BlockId() != v.last.BlockId()+1 {
+			return &LinkError{Index: v.count, Ref: ref,
+				Err: fmt.Errorf("%w: expected %s#%d", ErrBadSequence, v.last.ChainName(),
+					v.last.BlockId()+1)}
+		}
+		if !bytes.Equal(prevHash, v.lastHash) {
+			return &LinkError{Index: v.count, Ref: ref,
+				Err: fmt.Errorf("%w: expected %x, found %x", ErrBrokenLink, v.lastHash, prevHash)}
+		}
+	}
+	hash, err := Hash(v.hash, block)
+	if err != nil {
+		return fmt.Errorf("block %d: %w", v.count, err)
+	}
+	v.last = ref
+	v.lastHash = hash
+	v.count++
+	return nil
+}
+
+/*
+Reads the blocks from the reader and verifies them. The blocks are decoded with
+the given factory or, if it is nil, with a non strict StandardTagFactory. It
+returns nil when the reader reaches the end of the stream between two blocks.
+*/
+func (v *Verifier) Verify(reader io.Reader, factory tags.ILTagFactory) error {
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	cr := countingReader{reader: bufio.NewReader(reader)}
+	for {
+		offset := cr.offset
+		block, err := tags.ILTagDeserialize(factory, &cr)
+		if err != nil {
+			if err == io.EOF {
+				if cr.offset == offset {
+					return nil
+				}
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("block %d at offset %d: %w", v.count, offset, err)
+		}
+		if err := v.Add(block); err != nil {
+			return err
+		}
+	}
+}
+
+/*
+Verifies the given blocks with a new Verifier. It is a shortcut for
+NewVerifier() followed by Add() for each block.
+*/
+func VerifyBlocks(h crypto.Hash, extractor Extractor, blocks ...tags.ILTag) error {
+	v, err := NewVerifier(h, extractor)
+	if err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if err := v.Add(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Implementation of io.Reader that counts the bytes read.
+type countingReader struct {
+	reader io.Reader
+	offset int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.offset += int64(n)
+	return n, err
+}
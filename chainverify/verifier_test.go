@@ -0,0 +1,271 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package chainverify
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testExtractor = &SequenceExtractor{RefIndex: 0, PrevHashIndex: 1}
+
+// Creates a block with the layout seq[ref, bytes(prev), str(data)].
+func newBlock(chain string, id uint64, prev []byte, data string) *impl.ILTagSequenceTag {
+	ref := ext.NewChainNameBlockRefTag(1000)
+	ref.SetChainName(chain)
+	ref.SetBlockId(id)
+	prevTag := impl.NewStdBytesTag()
+	prevTag.Payload = prev
+	dataTag := impl.NewStdStringTag()
+	dataTag.Payload = data
+	block := impl.NewStdILTagSequenceTag()
+	block.Payload = []tags.ILTag{ref, prevTag, dataTag}
+	return block
+}
+
+// Creates count linked blocks starting at the given ID.
+func newChain(t *testing.T, h crypto.Hash, chain string, first uint64, count int) []tags.ILTag {
+	var blocks []tags.ILTag
+	var prev []byte
+	for i := 0; i < count; i++ {
+		block := newBlock(chain, first+uint64(i), prev, "data")
+		blocks = append(blocks, block)
+		var err error
+		prev, err = Hash(h, block)
+		require.Nil(t, err)
+	}
+	return blocks
+}
+
+// Returns the serialization of the tags.
+func mustBytes(t *testing.T, list ...tags.ILTag) []byte {
+	var b bytes.Buffer
+	require.Nil(t, tags.ILTagSerializeTags(&b, list...))
+	return b.Bytes()
+}
+
+// A tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
+
+func TestLinkError(t *testing.T) {
+	ref := ext.NewChainNameBlockRefTag(1000)
+	ref.SetChainName("chain")
+	ref.SetBlockId(3)
+	err := &LinkError{Index: 2, Ref: ref, Err: ErrBrokenLink}
+	assert.Equal(t, "block 2 (chain#3): broken hash link", err.Error())
+	assert.ErrorIs(t, err, ErrBrokenLink)
+}
+
+func TestNewVerifier(t *testing.T) {
+	v, err := NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	assert.Equal(t, 0, v.Count())
+	ref, h := v.Last()
+	assert.Nil(t, ref)
+	assert.Nil(t, h)
+
+	_, err = NewVerifier(crypto.MD5SHA1, testExtractor)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	assert.Panics(t, func() { NewVerifier(crypto.SHA256, nil) })
+}
+
+func TestVerifierAdd(t *testing.T) {
+	for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA512} {
+		blocks := newChain(t, h, "chain", 5, 4)
+		v, err := NewVerifier(h, testExtractor)
+		require.Nil(t, err)
+		for _, b := range blocks {
+			require.Nil(t, v.Add(b))
+		}
+		assert.Equal(t, 4, v.Count())
+		ref, last := v.Last()
+		assert.Equal(t, uint64(8), ref.BlockId())
+		exp, err := Hash(h, blocks[3])
+		require.Nil(t, err)
+		assert.Equal(t, exp, last)
+		assert.Nil(t, VerifyBlocks(h, testExtractor, blocks...))
+	}
+	assert.Nil(t, VerifyBlocks(crypto.SHA256, testExtractor))
+	assert.ErrorIs(t, VerifyBlocks(crypto.MD4, testExtractor), ErrUnsupportedHash)
+}
+
+func TestVerifierBrokenLinks(t *testing.T) {
+	blocks := newChain(t, crypto.SHA256, "chain", 0, 5)
+
+	// Changed block
+	blocks[2].(*impl.ILTagSequenceTag).Payload[2].(*impl.StringTag).Payload = "changed"
+	err := VerifyBlocks(crypto.SHA256, testExtractor, blocks...)
+	var linkErr *LinkError
+	require.ErrorAs(t, err, &linkErr)
+	assert.ErrorIs(t, err, ErrBrokenLink)
+	assert.Equal(t, 3, linkErr.Index)
+	assert.Equal(t, uint64(3), linkErr.Ref.BlockId())
+	assert.Equal(t, "chain", linkErr.Ref.ChainName())
+
+	// Wrong hash function
+	blocks = newChain(t, crypto.SHA256, "chain", 0, 2)
+	err = VerifyBlocks(crypto.SHA512, testExtractor, blocks...)
+	assert.ErrorIs(t, err, ErrBrokenLink)
+
+	// Missing block
+	blocks = newChain(t, crypto.SHA256, "chain", 0, 5)
+	err = VerifyBlocks(crypto.SHA256, testExtractor, blocks[0], blocks[1], blocks[3])
+	require.ErrorAs(t, err, &linkErr)
+	assert.ErrorIs(t, err, ErrBadSequence)
+	assert.Equal(t, 2, linkErr.Index)
+	assert.Equal(t, "block 2 (chain#3): unexpected block reference: expected chain#2", err.Error())
+
+	// Other chain
+	other := newBlock("other", 1, nil, "data")
+	err = VerifyBlocks(crypto.SHA256, testExtractor, blocks[0], other)
+	assert.ErrorIs(t, err, ErrBadSequence)
+
+	// The verifier does not advance on errors
+	v, err := NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	require.Nil(t, v.Add(blocks[0]))
+	assert.ErrorIs(t, v.Add(blocks[2]), ErrBadSequence)
+	assert.Equal(t, 1, v.Count())
+	assert.Nil(t, v.Add(blocks[1]))
+
+	// Extractor errors
+	err = v.Add(impl.MustParseTag("null"))
+	assert.ErrorIs(t, err, ErrBadBlock)
+	assert.Contains(t, err.Error(), "block 2: ")
+}
+
+func TestVerifierAnchor(t *testing.T) {
+	blocks := newChain(t, crypto.SHA256, "chain", 0, 5)
+	h, err := Hash(crypto.SHA256, blocks[2])
+	require.Nil(t, err)
+
+	ref := ext.NewChainNameBlockRefTag(1000)
+	ref.SetChainName("chain")
+	ref.SetBlockId(2)
+	v, err := NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	v.Anchor(ref, h)
+	last, lastHash := v.Last()
+	assert.Same(t, ref, last)
+	assert.Equal(t, h, lastHash)
+	assert.Nil(t, v.Add(blocks[3]))
+	assert.Equal(t, 1, v.Count())
+
+	v.Anchor(ref, []byte{1, 2, 3})
+	assert.ErrorIs(t, v.Add(blocks[3]), ErrBrokenLink)
+}
+
+func TestVerifierHashError(t *testing.T) {
+	ref := ext.NewChainNameBlockRefTag(1000)
+	ref.SetChainName("chain")
+	extractor := ExtractorFunc(func(block tags.ILTag) (*ext.ChainNameBlockRefTag, []byte, error) {
+		return ref, nil, nil
+	})
+	v, err := NewVerifier(crypto.SHA256, extractor)
+	require.Nil(t, err)
+	err = v.Add(&failingTag{})
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, 0, v.Count())
+}
+
+func TestVerifierVerify(t *testing.T) {
+	factory := impl.NewStandardTagFactory(false)
+	blocks := newChain(t, crypto.SHA256, "chain", 0, 5)
+	data := mustBytes(t, blocks...)
+
+	v, err := NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	assert.Nil(t, v.Verify(bytes.NewReader(data), factory))
+	assert.Equal(t, 5, v.Count())
+
+	// Default factory
+	v, err = NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	assert.Nil(t, v.Verify(bytes.NewReader(data), nil))
+	assert.Equal(t, 5, v.Count())
+	v, err = NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	err = v.Verify(bytes.NewReader([]byte{0x00}), nil)
+	assert.Error(t, err)
+	assert.Equal(t, 0, v.Count())
+
+	// Empty stream
+	v, err = NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	assert.Nil(t, v.Verify(bytes.NewReader(nil), factory))
+
+	// Truncated stream
+	v, err = NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	err = v.Verify(bytes.NewReader(data[:len(data)-1]), factory)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), "block 4 at offset ")
+	assert.Equal(t, 4, v.Count())
+
+	// Broken link
+	blocks[1] = newBlock("chain", 1, []byte{1}, "data")
+	v, err = NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	err = v.Verify(bytes.NewReader(mustBytes(t, blocks...)), factory)
+	assert.ErrorIs(t, err, ErrBrokenLink)
+	assert.Equal(t, 1, v.Count())
+
+	// Bad tags
+	v, err = NewVerifier(crypto.SHA256, testExtractor)
+	require.Nil(t, err)
+	err = v.Verify(bytes.NewReader([]byte{0x01, 0x02}), factory)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "block 0 at offset 0: ")
+}
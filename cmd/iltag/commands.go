@@ -0,0 +1,307 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/interlockledger/go-iltags/schema"
+	"github.com/interlockledger/go-iltags/tagjson"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// Implementation of the dump command.
+func runDump(env *env, args []string) error {
+	fs := newFlagSet(env, "dump")
+	text := fs.Bool("text", false, "writes the tags in the text notation instead of the hex dump")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	factory := newFactory(*strict)
+	return forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		if !*text {
+			return tags.Dump(r, factory, env.stdout)
+		}
+		return forEachTag(newTagReader(r, factory), func(_ int, tag tags.ILTag) error {
+			_, err := fmt.Fprintln(env.stdout, impl.FormatTag(tag))
+			return err
+		})
+	})
+}
+
+// Implementation of the json command.
+func runJSON(env *env, args []string) error {
+	fs := newFlagSet(env, "json")
+	compact := fs.Bool("compact", false, "writes each tag in a single line")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	factory := newFactory(*strict)
+	return forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		return forEachTag(newTagReader(r, factory), func(_ int, tag tags.ILTag) error {
+			var data []byte
+			var err error
+			if *compact {
+				data, err = tagjson.Marshal(tag)
+			} else {
+				data, err = tagjson.MarshalIndent(tag, "", "  ")
+			}
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(env.stdout, "%s\n", data)
+			return err
+		})
+	})
+}
+
+// Implementation of the fromjson command.
+func runFromJSON(env *env, args []string) error {
+	fs := newFlagSet(env, "fromjson")
+	output := fs.String("o", "", "output file (default: standard output)")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	w, closeOutput, err := createOutput(env, *output)
+	if err != nil {
+		return err
+	}
+	factory := newFactory(*strict)
+	err = forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		dec := json.NewDecoder(r)
+		for index := 0; ; index++ {
+			var doc json.RawMessage
+			if err := dec.Decode(&doc); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("document %d: %w", index, err)
+			}
+			tag, err := tagjson.Unmarshal(doc, factory)
+			if err != nil {
+				return fmt.Errorf("document %d: %w", index, err)
+			}
+			if err := tags.ILTagSeralize(tag, w); err != nil {
+				return err
+			}
+		}
+	})
+	if closeErr := closeOutput(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Returns the depth of the tag tree. A tag without children has depth 1.
+func depth(tag tags.ILTag) int {
+	max := 0
+	walkTags(tag, func(path tags.Path, tag tags.ILTag) error {
+		if len(path) >= max {
+			max = len(path) + 1
+		}
+		return nil
+	})
+	return max
+}
+
+// Implementation of the validate command.
+func runValidate(env *env, args []string) error {
+	fs := newFlagSet(env, "validate")
+	maxSize := fs.Uint64("max-size", tags.MAX_TAG_SIZE, "maximum size of the payload of the tags")
+	maxDepth := fs.Int("max-depth", 64, "maximum nesting of the containers (0 means no limit)")
+	maxTags := fs.Int("max-tags", 0, "maximum number of tags in each file (0 means no limit)")
+	schemaFile := fs.String("schema", "", "schema used to decode and validate the tags")
+	as := fs.String("as", "", "name of the schema definition of all tags")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	quiet := fs.Bool("q", false, "reports only the failures")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var s *schema.Schema
+	factory := newFactory(*strict)
+	if *schemaFile != "" {
+		data, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			return err
+		}
+		if s, err = schema.ParseString(string(data)); err != nil {
+			return fmt.Errorf("%s: %w", *schemaFile, err)
+		}
+		factory = s.NewFactory(*strict)
+	} else if *as != "" {
+		fmt.Fprintln(env.stderr, "iltag validate: -as requires -schema")
+		return errUsage
+	}
+	failed := false
+	err := forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		tr := newTagReader(r, factory)
+		tr.maxSize = *maxSize
+		tr.maxDepth = *maxDepth
+		err := forEachTag(tr, func(index int, tag tags.ILTag) error {
+			if *maxTags > 0 && index >= *maxTags {
+				return fmt.Errorf("more than %d tags", *maxTags)
+			}
+			// Containers defined by the schema are only known after the
+			// deserialization
+			if d := depth(tag); *maxDepth > 0 && d > *maxDepth {
+				return fmt.Errorf("tag %d: depth %d exceeds the limit of %d",
+					index, d, *maxDepth)
+			}
+			if s == nil {
+				return nil
+			}
+			var err error
+			if *as != "" {
+				err = s.ValidateAs(*as, tag)
+			} else {
+				err = s.Validate(tag)
+			}
+			if err != nil {
+				return fmt.Errorf("tag %d: %w", index, err)
+			}
+			return nil
+		})
+		if err != nil {
+			failed = true
+			fmt.Fprintf(env.stdout, "%s: %v\n", name, err)
+		} else if !*quiet {
+			fmt.Fprintf(env.stdout, "%s: %d tags OK\n", name, tr.index)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+/*
+Writes the tags with the given ID. If recursive is true, the contents of the
+containers are also searched.
+*/
+func extractTags(w io.Writer, tag tags.ILTag, id tags.TagID, recursive bool) error {
+	return walkTags(tag, func(path tags.Path, tag tags.ILTag) error {
+		if !tags.IsILTagNil(tag) && tag.Id() == id {
+			if err := tags.ILTagSeralize(tag, w); err != nil {
+				return err
+			}
+			return tags.SkipChildren
+		}
+		if !recursive {
+			return tags.SkipChildren
+		}
+		return nil
+	})
+}
+
+// Implementation of the extract command.
+func runExtract(env *env, args []string) error {
+	fs := newFlagSet(env, "extract")
+	idText := fs.String("id", "", "ID of the tags to be extracted (required)")
+	recursive := fs.Bool("r", false, "also searches the contents of the containers")
+	output := fs.String("o", "", "output file (default: standard output)")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(*idText, 0, 64)
+	if err != nil {
+		fmt.Fprintf(env.stderr, "iltag extract: invalid tag ID %q\n", *idText)
+		return errUsage
+	}
+	w, closeOutput, err := createOutput(env, *output)
+	if err != nil {
+		return err
+	}
+	factory := newFactory(*strict)
+	err = forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		return forEachTag(newTagReader(r, factory), func(_ int, tag tags.ILTag) error {
+			return extractTags(w, tag, tags.TagID(id), *recursive)
+		})
+	})
+	if closeErr := closeOutput(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Hash functions supported by the hash command.
+var hashFunctions = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+/*
+Implementation of the hash command. The hash is computed over the serialization
+of the decoded tag, thus equivalent encodings of the same tag have the same
+hash.
+*/
+func runHash(env *env, args []string) error {
+	fs := newFlagSet(env, "hash")
+	alg := fs.String("alg", "sha256", "hash algorithm (sha256 or sha512)")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	newHash, ok := hashFunctions[*alg]
+	if !ok {
+		fmt.Fprintf(env.stderr, "iltag hash: unsupported algorithm %q\n", *alg)
+		return errUsage
+	}
+	factory := newFactory(*strict)
+	return forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		return forEachTag(newTagReader(r, factory), func(index int, tag tags.ILTag) error {
+			h := newHash()
+			if err := tags.ILTagSeralize(tag, h); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintf(env.stdout, "%s  %s#%d\n",
+				hex.EncodeToString(h.Sum(nil)), name, index)
+			return err
+		})
+	})
+}
@@ -0,0 +1,313 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Sample stream used by the tests.
+var sampleTags = []string{
+	`dict{"a": u8(5), "b": array[#1000 bytes(0xCAFE), seq[u8(6)]]}`,
+	`str("hi")`,
+	`#1000 bytes(0xBEEF)`,
+}
+
+func TestRunDump(t *testing.T) {
+	data := sample(sampleTags...)
+	code, stdout, stderr := runCmd(data, "dump")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "00000000  1e                       tag 30 (Dictionary)\n")
+	assert.Contains(t, stdout, "  value: u8(5)\n")
+	assert.Contains(t, stdout, "tag 1000\n")
+
+	code, stdout, _ = runCmd(data, "dump", "-text")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, strings.Join(sampleTags, "\n")+"\n", stdout)
+
+	// Files
+	name := writeTemp(t, "a.iltag", data)
+	code, stdout2, _ := runCmd(nil, "dump", "-text", name, name)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, stdout+stdout, stdout2)
+
+	// Errors
+	code, stdout, stderr = runCmd(data[:3], "dump")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "error: offset 2:")
+	assert.Contains(t, stderr, "iltag dump: -: offset 2:")
+
+	code, _, stderr = runCmd(data, "dump", "-text", "-strict")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "unsupported tag ID")
+
+	code, _, _ = runCmd(data, "dump", "-x")
+	assert.Equal(t, 2, code)
+}
+
+func TestRunJSON(t *testing.T) {
+	data := sample(sampleTags...)
+	code, stdout, stderr := runCmd(data, "json")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "{\n  \"tagId\": 30,\n  \"type\": \"dictionary\",")
+
+	code, compact, _ := runCmd(data, "json", "-compact")
+	assert.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(compact), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, `{"tagId":17,"type":"string","value":"hi"}`, lines[1])
+
+	// Round trip
+	code, back, stderr := runCmd([]byte(stdout), "fromjson")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, data, []byte(back))
+	code, back, _ = runCmd([]byte(compact), "fromjson")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, data, []byte(back))
+
+	// Errors
+	code, _, stderr = runCmd(data[:3], "json")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag json: -: tag 0 at offset 0: unexpected EOF")
+	code, _, _ = runCmd(data, "json", "-x")
+	assert.Equal(t, 2, code)
+	code, _, _ = runCmd(data, "json", "-strict")
+	assert.Equal(t, 1, code)
+}
+
+func TestRunFromJSON(t *testing.T) {
+	in := `{"tagId":17,"type":"string","value":"hi"} {"tagId":0,"type":"null"}`
+	code, stdout, stderr := runCmd([]byte(in), "fromjson")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, sample(`str("hi")`, "null"), []byte(stdout))
+
+	// Output file
+	name := filepath.Join(t.TempDir(), "out.iltag")
+	code, stdout, _ = runCmd([]byte(in), "fromjson", "-o", name)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout)
+	data, err := os.ReadFile(name)
+	require.Nil(t, err)
+	assert.Equal(t, sample(`str("hi")`, "null"), data)
+
+	// Errors
+	code, _, stderr = runCmd([]byte(in+" {"), "fromjson")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag fromjson: -: document 2:")
+	code, _, stderr = runCmd([]byte(`{"tagId":17,"type":"string","value":1}`), "fromjson")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag fromjson: -: document 0:")
+	code, _, _ = runCmd([]byte(`{"tagId":1000,"type":"raw","value":""}`), "fromjson", "-strict")
+	assert.Equal(t, 1, code)
+	code, _, _ = runCmd([]byte(in), "fromjson", "-o", filepath.Join(name, "x"))
+	assert.Equal(t, 1, code)
+	code, _, _ = runCmd([]byte(in), "fromjson", "-x")
+	assert.Equal(t, 2, code)
+}
+
+func TestRunValidate(t *testing.T) {
+	data := sample(sampleTags...)
+	code, stdout, _ := runCmd(data, "validate")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "-: 3 tags OK\n", stdout)
+
+	code, stdout, _ = runCmd(data, "validate", "-q")
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout)
+
+	// Limits
+	code, stdout, _ = runCmd(data, "validate", "-max-tags", "2")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "-: more than 2 tags\n", stdout)
+	code, stdout, _ = runCmd(data, "validate", "-max-depth", "3")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "-: tag 0 at offset 0: offset 22: maximum nesting depth exceeded (3 levels)\n",
+		stdout)
+	code, stdout, _ = runCmd(data, "validate", "-max-depth", "4")
+	assert.Equal(t, 0, code)
+	code, stdout, _ = runCmd(data, "validate", "-max-size", "8")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "-: tag 0 at offset 0: payload with 22 bytes exceeds the limit of 8 bytes")
+
+	// Limits are enforced while decoding
+	deep := []byte{0x16, 0x00}
+	for i := 1; i < 100; i++ {
+		var b bytes.Buffer
+		b.WriteByte(0x16)
+		require.Nil(t, serialization.WriteILInt(&b, uint64(len(deep))))
+		b.Write(deep)
+		deep = b.Bytes()
+	}
+	code, stdout, _ = runCmd(deep, "validate")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "maximum nesting depth exceeded (64 levels)\n")
+	code, _, _ = runCmd(deep, "validate", "-max-depth", "0")
+	assert.Equal(t, 0, code)
+	// The inner tag claims 503 bytes
+	code, stdout, _ = runCmd([]byte{0x16, 0x04, 0x10, 0xf8, 0xff, 0x00}, "validate", "-max-size", "100")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "-: tag 0 at offset 0: offset 2: the given tag is too large to be handled by this library\n", stdout)
+	code, stdout, _ = runCmd(data, "validate", "-strict")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "unsupported tag ID")
+
+	// Several files
+	good := writeTemp(t, "good", data)
+	bad := writeTemp(t, "bad", data[:5])
+	code, stdout, _ = runCmd(nil, "validate", good, bad, good)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, good+": 3 tags OK\n"+bad+": tag 0 at offset 0: unexpected EOF\n"+
+		good+": 3 tags OK\n", stdout)
+	code, _, stderr := runCmd(nil, "validate", filepath.Join(bad, "x"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag validate:")
+
+	// Schema
+	schemaFile := writeTemp(t, "s.ilschema", []byte(`
+		tag Blob 1000 bytes
+		tag Text 1001 string
+	`))
+	code, stdout, _ = runCmd(data, "validate", "-schema", schemaFile)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "-: 3 tags OK\n", stdout)
+	code, stdout, _ = runCmd(data, "validate", "-schema", schemaFile, "-strict")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "-: 3 tags OK\n", stdout)
+	code, stdout, _ = runCmd(sample(`#1001 str("a")`, `#1000 bytes(0x00)`), "validate",
+		"-schema", schemaFile, "-as", "Text")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "-: tag 1: $: expected Text (1001), found Blob (1000): type mismatch\n", stdout)
+	code, stdout, _ = runCmd([]byte{0xf9, 0x02, 0xf1, 0x01, 0xff}, "validate", "-schema", schemaFile)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "-: tag 0 at offset 0:")
+
+	code, _, stderr = runCmd(data, "validate", "-schema", filepath.Join(bad, "x"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag validate:")
+	badSchema := writeTemp(t, "bad.ilschema", []byte("tag"))
+	code, _, stderr = runCmd(data, "validate", "-schema", badSchema)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag validate: "+badSchema+":")
+	code, _, stderr = runCmd(data, "validate", "-as", "Text")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "-as requires -schema")
+	code, _, _ = runCmd(data, "validate", "-x")
+	assert.Equal(t, 2, code)
+}
+
+func TestRunExtract(t *testing.T) {
+	data := sample(sampleTags...)
+	code, stdout, stderr := runCmd(data, "extract", "-id", "1000")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, sample(`#1000 bytes(0xBEEF)`), []byte(stdout))
+
+	code, stdout, _ = runCmd(data, "extract", "--id", "0x3E8", "-r")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, sample(`#1000 bytes(0xCAFE)`, `#1000 bytes(0xBEEF)`), []byte(stdout))
+
+	code, stdout, _ = runCmd(data, "extract", "-id", "3", "-r")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, sample("u8(5)", "u8(6)"), []byte(stdout))
+
+	code, stdout, _ = runCmd(data, "extract", "-id", "30", "-r")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, sample(sampleTags[0]), []byte(stdout))
+
+	// The values of string dictionaries are not tags
+	code, stdout, _ = runCmd(sample(`seq[strdict{"a": "x"}, str("y")]`), "extract", "-id", "17", "-r")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, sample(`str("y")`), []byte(stdout))
+
+	// Output file
+	name := filepath.Join(t.TempDir(), "out.iltag")
+	code, _, _ = runCmd(data, "extract", "-id", "17", "-o", name)
+	assert.Equal(t, 0, code)
+	out, err := os.ReadFile(name)
+	require.Nil(t, err)
+	assert.Equal(t, sample(`str("hi")`), out)
+
+	// Errors
+	code, _, stderr = runCmd(data, "extract")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `invalid tag ID ""`)
+	code, _, _ = runCmd(data, "extract", "-id", "x")
+	assert.Equal(t, 2, code)
+	code, _, _ = runCmd(data, "extract", "-x")
+	assert.Equal(t, 2, code)
+	code, _, _ = runCmd(data, "extract", "-id", "1", "-o", filepath.Join(name, "x"))
+	assert.Equal(t, 1, code)
+	code, _, _ = runCmd(data[:3], "extract", "-id", "1")
+	assert.Equal(t, 1, code)
+}
+
+func TestRunHash(t *testing.T) {
+	data := sample(sampleTags...)
+	var expected bytes.Buffer
+	for i, s := range sampleTags {
+		h := sha256.Sum256(impl.MustParseTagBytes(s))
+		expected.WriteString(hex.EncodeToString(h[:]) + "  -#" + string(rune('0'+i)) + "\n")
+	}
+	code, stdout, stderr := runCmd(data, "hash")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, expected.String(), stdout)
+
+	code, stdout, _ = runCmd(data, "hash", "-alg", "sha512")
+	assert.Equal(t, 0, code)
+	assert.Len(t, strings.Fields(stdout)[0], 128)
+
+	// The name of the file is reported
+	name := writeTemp(t, "a.iltag", sample(sampleTags[2]))
+	code, stdout, _ = runCmd(nil, "hash", name)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, strings.Split(expected.String(), "\n")[2][:64]+"  "+name+"#0\n", stdout)
+
+	// Errors
+	code, _, stderr = runCmd(data, "hash", "-alg", "md5")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unsupported algorithm "md5"`)
+	code, _, _ = runCmd(data, "hash", "-x")
+	assert.Equal(t, 2, code)
+	code, _, _ = runCmd(data[:3], "hash")
+	assert.Equal(t, 1, code)
+}
@@ -0,0 +1,238 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/interlockledger/go-iltags/tagstream"
+)
+
+// Name used to represent the standard input.
+const stdinName = "-"
+
+/*
+Calls f for each input. An empty list or the name "-" selects the standard
+input. The errors returned by f are prefixed by the name of the input.
+*/
+func forEachInput(env *env, names []string, f func(name string, r io.Reader) error) error {
+	if len(names) == 0 {
+		names = []string{stdinName}
+	}
+	for _, name := range names {
+		var err error
+		if name == stdinName {
+			err = f(name, env.stdin)
+		} else if file, openErr := os.Open(name); openErr != nil {
+			return openErr
+		} else {
+			err = f(name, file)
+			file.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+/*
+Creates the output file. An empty name or "-" selects the standard output. The
+returned function must be called to close the file.
+*/
+func createOutput(env *env, name string) (io.Writer, func() error, error) {
+	if name == "" || name == stdinName {
+		return env.stdout, func() error { return nil }, nil
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+// Returns the factory used to decode the inputs.
+func newFactory(strict bool) tags.ILTagFactory {
+	return impl.NewStandardTagFactory(strict)
+}
+
+/*
+Reads a stream of tags. It keeps track of the offset and the index of each tag
+in order to report the position of errors.
+
+When maxDepth is set, each tag is copied and verified by a tagstream.Parser
+before being deserialized, thus the limits also apply to the inner tags and
+malicious inputs are rejected before any tag is created. The offsets reported
+by the parser are relative to the start of the tag.
+*/
+type tagReader struct {
+	reader  *bufio.Reader
+	factory tags.ILTagFactory
+	// Maximum size of the payload of the tags.
+	maxSize uint64
+	// Maximum depth of the tags. A tag without children has depth 1. 0 means
+	// no limit.
+	maxDepth int
+	// Offset of the next byte.
+	offset int64
+	// Index of the next tag.
+	index int
+}
+
+// Creates a new tagReader.
+func newTagReader(r io.Reader, factory tags.ILTagFactory) *tagReader {
+	return &tagReader{reader: bufio.NewReader(r), factory: factory,
+		maxSize: tags.MAX_TAG_SIZE}
+}
+
+// Implementation of io.Reader.
+func (r *tagReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+/*
+Verifies the size of the next tag before it is decoded. It returns the total
+size of the tag or -1 if it is implicit. Broken headers are ignored here
+because they are reported by the deserialization.
+*/
+func (r *tagReader) checkSize() (int64, error) {
+	id, n, size, err := tags.PeekTagHeader(r.reader)
+	if err != nil || id.Implicit() {
+		return -1, nil
+	}
+	if size > r.maxSize {
+		return 0, fmt.Errorf("payload with %d bytes exceeds the limit of %d bytes: %w",
+			size, r.maxSize, tags.ErrTagTooLarge)
+	}
+	return int64(n) + int64(size), nil
+}
+
+/*
+Verifies the limits of the serialized tag with a tagstream.Parser. The depth
+of each tag is the depth of its parent plus 1.
+*/
+func (r *tagReader) checkLimits(raw []byte) error {
+	// The parser stops one level deeper, thus the handler reports the depth
+	// of the leaves and of the containers in the same way
+	p := tagstream.Parser{MaxTagSize: r.maxSize, MaxDepth: r.maxDepth + 1}
+	return p.Parse(bytes.NewReader(raw), func(e *tagstream.Event) error {
+		if e.Depth >= r.maxDepth {
+			return fmt.Errorf("offset %d: %w (%d levels)", e.Offset,
+				tagstream.ErrTooDeep, r.maxDepth)
+		}
+		return nil
+	})
+}
+
+// Decodes the next tag whose total size, if known, is given.
+func (r *tagReader) decode(size int64) (tags.ILTag, error) {
+	if r.maxDepth <= 0 || size < 0 {
+		return tags.ILTagDeserialize(r.factory, r)
+	}
+	var raw bytes.Buffer
+	if _, err := io.CopyN(&raw, r, size); err != nil {
+		return nil, err
+	}
+	if err := r.checkLimits(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	return tags.ILTagFromBytes(r.factory, raw.Bytes())
+}
+
+/*
+Reads the next tag. It returns io.EOF if there are no more tags. Errors report
+the index and the offset of the tag that could not be read.
+*/
+func (r *tagReader) Next() (tags.ILTag, error) {
+	if _, err := r.reader.Peek(1); err != nil {
+		return nil, err
+	}
+	start := r.offset
+	size, err := r.checkSize()
+	var tag tags.ILTag
+	if err == nil {
+		tag, err = r.decode(size)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tag %d at offset %d: %w", r.index, start, err)
+	}
+	r.index++
+	return tag, nil
+}
+
+/*
+Calls f for each tag found in the reader. The index is the position of the tag
+in the stream.
+*/
+func forEachTag(r *tagReader, f func(index int, tag tags.ILTag) error) error {
+	for {
+		index := r.index
+		tag, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(index, tag); err != nil {
+			return err
+		}
+	}
+}
+
+/*
+Walks the tag tree with tags.WalkFunc(). The values of the StringDictionaryTags
+are not visited since they are strings, not tags.
+*/
+func walkTags(tag tags.ILTag, f func(path tags.Path, tag tags.ILTag) error) error {
+	return tags.WalkFunc(tag, func(path tags.Path, tag tags.ILTag) error {
+		if err := f(path, tag); err != nil {
+			return err
+		}
+		if _, ok := tag.(*impl.StringDictionaryTag); ok {
+			return tags.SkipChildren
+		}
+		return nil
+	})
+}
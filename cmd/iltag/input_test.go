@@ -0,0 +1,190 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/interlockledger/go-iltags/tagstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEachInput(t *testing.T) {
+	a := writeTemp(t, "a", []byte("A"))
+	b := writeTemp(t, "b", []byte("B"))
+	e := &env{stdin: bytes.NewReader([]byte("S"))}
+
+	var read []string
+	collect := func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		read = append(read, fmt.Sprintf("%s=%s", filepath.Base(name), data))
+		return err
+	}
+	require.Nil(t, forEachInput(e, nil, collect))
+	assert.Equal(t, []string{"-=S"}, read)
+
+	read = nil
+	require.Nil(t, forEachInput(e, []string{a, "-", b}, collect))
+	assert.Equal(t, []string{"a=A", "-=", "b=B"}, read)
+
+	err := forEachInput(e, []string{a, b}, func(name string, r io.Reader) error {
+		return io.ErrUnexpectedEOF
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, a+": unexpected EOF", err.Error())
+
+	err = forEachInput(e, []string{filepath.Join(t.TempDir(), "x")}, collect)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCreateOutput(t *testing.T) {
+	var stdout bytes.Buffer
+	e := &env{stdout: &stdout}
+	for _, name := range []string{"", "-"} {
+		w, closeOutput, err := createOutput(e, name)
+		require.Nil(t, err)
+		assert.Same(t, &stdout, w)
+		assert.Nil(t, closeOutput())
+	}
+
+	name := filepath.Join(t.TempDir(), "out")
+	w, closeOutput, err := createOutput(e, name)
+	require.Nil(t, err)
+	w.Write([]byte("x"))
+	require.Nil(t, closeOutput())
+	data, err := os.ReadFile(name)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("x"), data)
+
+	_, _, err = createOutput(e, filepath.Join(name, "x"))
+	assert.Error(t, err)
+}
+
+func TestTagReader(t *testing.T) {
+	data := sample(`str("a")`, "null", `#1000 bytes(0x01)`, "ilint(1000)")
+	r := newTagReader(bytes.NewReader(data), newFactory(false))
+	var found []string
+	require.Nil(t, forEachTag(r, func(index int, tag tags.ILTag) error {
+		found = append(found, fmt.Sprintf("%d:%s", index, impl.FormatTag(tag)))
+		return nil
+	}))
+	assert.Equal(t, []string{`0:str("a")`, "1:null", "2:#1000 bytes(0x01)",
+		"3:ilint(1000)"}, found)
+	assert.Equal(t, int64(len(data)), r.offset)
+	assert.Equal(t, 4, r.index)
+	tag, err := r.Next()
+	assert.Nil(t, tag)
+	assert.Equal(t, io.EOF, err)
+
+	// Callback error
+	r = newTagReader(bytes.NewReader(data), newFactory(false))
+	assert.ErrorIs(t, forEachTag(r, func(index int, tag tags.ILTag) error {
+		return io.ErrShortWrite
+	}), io.ErrShortWrite)
+
+	// Strict factory
+	r = newTagReader(bytes.NewReader(data), newFactory(true))
+	err = forEachTag(r, func(index int, tag tags.ILTag) error { return nil })
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+	assert.Contains(t, err.Error(), "tag 2 at offset 4:")
+
+	// Truncated
+	for i := 1; i < 4; i++ {
+		r = newTagReader(bytes.NewReader(data[:4+i]), newFactory(false))
+		err = forEachTag(r, func(index int, tag tags.ILTag) error { return nil })
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Contains(t, err.Error(), "tag 2 at offset 4:")
+	}
+
+	// Size limit
+	r = newTagReader(bytes.NewReader(data), newFactory(false))
+	r.maxSize = 0
+	err = forEachTag(r, func(index int, tag tags.ILTag) error { return nil })
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	assert.Contains(t, err.Error(), "tag 0 at offset 0:")
+	r = newTagReader(bytes.NewReader(sample("null", "ilint(1000)")), newFactory(false))
+	r.maxSize = 0
+	assert.Nil(t, forEachTag(r, func(index int, tag tags.ILTag) error { return nil }))
+
+	// Depth limit
+	r = newTagReader(bytes.NewReader(data), newFactory(false))
+	r.maxDepth = 1
+	assert.Nil(t, forEachTag(r, func(index int, tag tags.ILTag) error { return nil }))
+	r = newTagReader(bytes.NewReader(sample("null", "seq[u8(1)]")), newFactory(false))
+	r.maxDepth = 1
+	err = forEachTag(r, func(index int, tag tags.ILTag) error { return nil })
+	assert.ErrorIs(t, err, tagstream.ErrTooDeep)
+	assert.Contains(t, err.Error(), "tag 1 at offset 1: offset 2:")
+
+	// Read error
+	r = newTagReader(iotest.ErrReader(io.ErrClosedPipe), newFactory(false))
+	_, err = r.Next()
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestWalkTags(t *testing.T) {
+	var visited []string
+	err := walkTags(impl.MustParseTag(`array[null, seq[u8(1)], dict{"a": u8(2)}, strdict{"b": "c"}]`),
+		func(path tags.Path, tag tags.ILTag) error {
+			visited = append(visited, fmt.Sprintf("%s=%d", path, tag.Id()))
+			return nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"$=21", "$[0]=0", "$[1]=22", "$[1][0]=3", "$[2]=30", "$[2].a=3",
+		"$[3]=31"}, visited)
+
+	// The errors of f are returned
+	visited = nil
+	err = walkTags(impl.MustParseTag(`array[strdict{"b": "c"}, u8(1)]`),
+		func(path tags.Path, tag tags.ILTag) error {
+			visited = append(visited, path.String())
+			if len(path) > 0 {
+				return tags.SkipAll
+			}
+			return nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"$", "$[0]"}, visited)
+	assert.ErrorIs(t, walkTags(impl.MustParseTag("null"), func(tags.Path, tags.ILTag) error {
+		return io.ErrClosedPipe
+	}), io.ErrClosedPipe)
+}
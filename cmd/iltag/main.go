@@ -0,0 +1,219 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+Iltag inspects and converts files that contain serialized ILTags. Each file may
+contain any number of tags stored one after the other.
+
+Usage:
+
+	iltag <command> [flags] [file...]
+
+The files default to the standard input, which can also be selected by the name
+"-". The commands are:
+
+	dump       writes an annotated hex dump of the tags
+	json       converts the tags to JSON
+	fromjson   converts JSON documents back to tags
+	validate   decodes the tags and checks them against the limits and an
+	           optional schema
+	stat       writes the histogram of the tag IDs and the distribution of the
+	           tag sizes
+	extract    writes only the tags with the given ID
+	hash       writes the hash of the canonical serialization of each tag
+
+Run "iltag help <command>" to see the flags of each command.
+
+The tags are decoded by the standard tag factory. Tags with unknown IDs are
+handled as raw tags unless the -strict flag is used. Since all outputs are
+generated from the decoded tags, they are always written in the canonical
+serialization of this library.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+/*
+Environment of the commands. It allows the tests to replace the standard input
+and outputs.
+*/
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+/*
+Definition of a command.
+*/
+type command struct {
+	// Short description of the command.
+	summary string
+	// Description of the positional arguments.
+	args string
+	// Executes the command.
+	run func(env *env, args []string) error
+}
+
+// Available commands. It is initialized by init() to avoid a reference cycle.
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"dump":     {"writes an annotated hex dump of the tags", "[file...]", runDump},
+		"json":     {"converts the tags to JSON", "[file...]", runJSON},
+		"fromjson": {"converts JSON documents back to tags", "[file...]", runFromJSON},
+		"validate": {"decodes the tags and checks them", "[file...]", runValidate},
+		"stat":     {"writes statistics about the tags", "[file...]", runStat},
+		"extract":  {"writes only the tags with the given ID", "-id <id> [file...]", runExtract},
+		"hash":     {"writes the hash of each tag", "[file...]", runHash},
+		"help":     {"shows the help of a command", "[command]", runHelp},
+	}
+}
+
+// Reports an invalid command line. It exits with status 2.
+var errUsage = errors.New("invalid usage")
+
+// Reports that the command already reported its failures. It exits with status 1.
+var errFailed = errors.New("failed")
+
+/*
+Creates the flag set of a command. The usage is written to the standard error.
+*/
+func newFlagSet(env *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: iltag %s [flags] %s\n\n%s.\n", name,
+			commands[name].args, commands[name].summary)
+		if hasFlags(fs) {
+			fmt.Fprintln(env.stderr, "\nflags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// Returns true if the flag set has flags.
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+/*
+Parses the flags of a command. It returns errUsage if the flags are invalid.
+*/
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// Writes the usage of the program.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: iltag <command> [flags] [file...]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// Implementation of the help command.
+func runHelp(env *env, args []string) error {
+	fs := newFlagSet(env, "help")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	switch len(args) {
+	case 0:
+		usage(env.stdout)
+		return nil
+	case 1:
+		if cmd, ok := commands[args[0]]; ok {
+			// The -h flag makes the command write its usage and stop
+			helpEnv := *env
+			helpEnv.stderr = env.stdout
+			cmd.run(&helpEnv, []string{"-h"})
+			return nil
+		}
+	}
+	return errUsage
+}
+
+/*
+Runs the program with the given arguments and returns the exit status.
+*/
+func run(env *env, args []string) int {
+	if len(args) == 0 {
+		usage(env.stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.stderr, "iltag: unknown command %q\n", args[0])
+		usage(env.stderr)
+		return 2
+	}
+	err := cmd.run(env, args[1:])
+	switch {
+	case err == nil, err == flag.ErrHelp:
+		return 0
+	case err == errUsage:
+		return 2
+	case err == errFailed:
+		return 1
+	default:
+		fmt.Fprintf(env.stderr, "iltag %s: %v\n", args[0], err)
+		return 1
+	}
+}
+
+func main() {
+	os.Exit(run(&env{os.Stdin, os.Stdout, os.Stderr}, os.Args[1:]))
+}
@@ -0,0 +1,115 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs the program and returns the exit status and the outputs.
+func runCmd(stdin []byte, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(&env{bytes.NewReader(stdin), &stdout, &stderr}, args)
+	return code, stdout.String(), stderr.String()
+}
+
+// Returns the serialization of the given tags in the text notation.
+func sample(tags ...string) []byte {
+	var b []byte
+	for _, s := range tags {
+		b = append(b, impl.MustParseTagBytes(s)...)
+	}
+	return b
+}
+
+// Writes a temporary file and returns its name.
+func writeTemp(t *testing.T, name string, data []byte) string {
+	name = filepath.Join(t.TempDir(), name)
+	require.Nil(t, os.WriteFile(name, data, 0644))
+	return name
+}
+
+func TestRun(t *testing.T) {
+	code, stdout, stderr := runCmd(nil)
+	assert.Equal(t, 2, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "usage: iltag <command>")
+
+	code, _, stderr = runCmd(nil, "bogus")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "bogus"`)
+
+	code, _, stderr = runCmd(nil, "dump", "-bogus")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "flag provided but not defined: -bogus")
+	assert.Contains(t, stderr, "usage: iltag dump [flags] [file...]")
+
+	code, _, stderr = runCmd(nil, "dump", "-h")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stderr, "usage: iltag dump [flags] [file...]")
+
+	code, _, stderr = runCmd(nil, "dump", "missing-file")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag dump: open missing-file")
+}
+
+func TestRunHelp(t *testing.T) {
+	code, stdout, _ := runCmd(nil, "help")
+	assert.Equal(t, 0, code)
+	for name, cmd := range commands {
+		assert.Contains(t, stdout, name)
+		assert.Contains(t, stdout, cmd.summary)
+	}
+
+	code, stdout, _ = runCmd(nil, "help", "extract")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "usage: iltag extract [flags] -id <id> [file...]")
+	assert.Contains(t, stdout, "-id string")
+
+	code, stdout, _ = runCmd(nil, "help", "help")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "usage: iltag help [flags] [command]")
+	assert.NotContains(t, stdout, "flags:")
+
+	code, _, _ = runCmd(nil, "help", "bogus")
+	assert.Equal(t, 2, code)
+	code, _, _ = runCmd(nil, "help", "-x")
+	assert.Equal(t, 2, code)
+}
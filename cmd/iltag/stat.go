@@ -0,0 +1,154 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// Statistics of a tag ID.
+type idStats struct {
+	count uint64
+	bytes uint64
+}
+
+/*
+Statistics collected by the stat command. The sizes of the top level tags are
+grouped by the number of bits required to represent them.
+*/
+type tagStats struct {
+	ids   map[tags.TagID]*idStats
+	total uint64
+	top   uint64
+	bytes uint64
+	sizes [65]uint64
+}
+
+// Creates a new tagStats.
+func newTagStats() *tagStats {
+	return &tagStats{ids: make(map[tags.TagID]*idStats)}
+}
+
+// Adds a top level tag and its children to the statistics.
+func (s *tagStats) add(tag tags.ILTag) {
+	walkTags(tag, func(path tags.Path, tag tags.ILTag) error {
+		if tags.IsILTagNil(tag) {
+			return nil
+		}
+		size := tags.ILTagSize(tag)
+		st := s.ids[tag.Id()]
+		if st == nil {
+			st = &idStats{}
+			s.ids[tag.Id()] = st
+		}
+		st.count++
+		st.bytes += size
+		s.total++
+		if len(path) == 0 {
+			s.top++
+			s.bytes += size
+			s.sizes[bits.Len64(size)]++
+		}
+		return nil
+	})
+}
+
+// Writes the statistics as three tables separated by blank lines.
+func (s *tagStats) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "tags\t%d\n", s.total)
+	fmt.Fprintf(tw, "top level tags\t%d\n", s.top)
+	fmt.Fprintf(tw, "bytes\t%d\n", s.bytes)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	ids := make([]tags.TagID, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "id\tname\tcount\tbytes")
+	for _, id := range ids {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", id, id.StdName(), s.ids[id].count,
+			s.ids[id].bytes)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "top level size\tcount")
+	for k, count := range s.sizes {
+		if count == 0 {
+			continue
+		}
+		min := uint64(1) << (k - 1)
+		max := min<<1 - 1
+		fmt.Fprintf(tw, "%d-%d\t%d\n", min, max, count)
+	}
+	return tw.Flush()
+}
+
+/*
+Implementation of the stat command. The statistics of all inputs are combined.
+The contents of the containers are included in the histogram of IDs,
+except for the keys of the dictionaries and the values of the string
+dictionaries.
+*/
+func runStat(env *env, args []string) error {
+	fs := newFlagSet(env, "stat")
+	strict := fs.Bool("strict", false, "rejects unknown tag IDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	stats := newTagStats()
+	factory := newFactory(*strict)
+	err := forEachInput(env, fs.Args(), func(name string, r io.Reader) error {
+		return forEachTag(newTagReader(r, factory), func(_ int, tag tags.ILTag) error {
+			stats.add(tag)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return stats.write(env.stdout)
+}
@@ -0,0 +1,107 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/interlockledger/go-iltags/tagtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagStats(t *testing.T) {
+	s := newTagStats()
+	s.add(nil)
+	s.add(impl.MustParseTag(`array[u8(1), seq[u8(2), null]]`))
+	s.add(impl.MustParseTag(`#1000 bytes(0xCAFE)`))
+	assert.Equal(t, uint64(6), s.total)
+	assert.Equal(t, uint64(2), s.top)
+	assert.Equal(t, uint64(10+6), s.bytes)
+	assert.Equal(t, idStats{2, 4}, *s.ids[3])
+	assert.Equal(t, idStats{1, 1}, *s.ids[0])
+	assert.Equal(t, idStats{1, 5}, *s.ids[22])
+	assert.Equal(t, idStats{1, 10}, *s.ids[21])
+	assert.Equal(t, idStats{1, 6}, *s.ids[1000])
+	assert.Equal(t, uint64(1), s.sizes[3])
+	assert.Equal(t, uint64(1), s.sizes[4])
+
+	w := tagtest.NewLimitedWriter(1000, true)
+	assert.Nil(t, s.write(w))
+	assert.Equal(t, `tags            6
+top level tags  2
+bytes           16
+
+id    name           count  bytes
+0     Null           1      1
+3     UInt8          2      4
+21    ILTagArray     1      10
+22    ILTagSequence  1      5
+1000                 1      6
+
+top level size  count
+4-7             1
+8-15            1
+`, w.W.String())
+
+	for _, n := range []int{0, 40, 200} {
+		assert.Error(t, s.write(tagtest.NewLimitedWriter(n, false)))
+	}
+
+	// The values of string dictionaries are not counted
+	s = newTagStats()
+	s.add(impl.MustParseTag(`strdict{"a": "b", "c": "d"}`))
+	assert.Equal(t, uint64(1), s.total)
+	assert.Nil(t, s.ids[tags.IL_STRING_TAG_ID])
+}
+
+func TestRunStat(t *testing.T) {
+	data := sample(sampleTags...)
+	code, stdout, stderr := runCmd(data, "stat")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "tags            8\ntop level tags  3\n")
+
+	name := writeTemp(t, "a", data)
+	code, stdout, _ = runCmd(nil, "stat", name, name)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "tags            16\ntop level tags  6\n")
+
+	code, _, stderr = runCmd(data[:3], "stat")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "iltag stat: -: tag 0 at offset 0:")
+	code, _, _ = runCmd(data, "stat", "-strict")
+	assert.Equal(t, 1, code)
+	code, _, _ = runCmd(data, "stat", "-x")
+	assert.Equal(t, 2, code)
+}
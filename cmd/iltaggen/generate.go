@@ -0,0 +1,509 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Import paths used by the generated code.
+const (
+	tagsImport    = "github.com/interlockledger/go-iltags/tags"
+	directImport  = "github.com/interlockledger/go-iltags/tags/direct"
+	extImport     = "github.com/interlockledger/go-iltags/tags/ext"
+	tagtestImport = "github.com/interlockledger/go-iltags/tagtest"
+)
+
+// Header of all generated files.
+const generatedHeader = "// Code generated by iltaggen. DO NOT EDIT.\n\n"
+
+/*
+Source code generator. It accumulates the generated code in a buffer.
+*/
+type generator struct {
+	buf bytes.Buffer
+}
+
+// Appends formatted code to the buffer.
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// Returns true if the import path belongs to the standard library.
+func isStdImport(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+/*
+Writes the header and the import block of the file. The standard packages are
+placed in their own group.
+*/
+func (g *generator) header(pkg string, imports map[string]string) {
+	g.buf.WriteString(generatedHeader)
+	g.printf("package %s\n\n", pkg)
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := imports[names[i]], imports[names[j]]
+		if isStdImport(a) != isStdImport(b) {
+			return isStdImport(a)
+		}
+		return a < b
+	})
+	g.printf("import (\n")
+	std := true
+	for _, name := range names {
+		path := imports[name]
+		if std && !isStdImport(path) {
+			std = false
+			g.printf("\n")
+		}
+		if path[strings.LastIndex(path, "/")+1:] == name {
+			g.printf("\t%q\n", path)
+		} else {
+			g.printf("\t%s %q\n", name, path)
+		}
+	}
+	g.printf(")\n\n")
+}
+
+// Returns the formatted source code.
+func (g *generator) format() ([]byte, error) {
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated code: %w\n%s", err, g.buf.String())
+	}
+	return src, nil
+}
+
+/*
+Converts a CamelCase name into UPPER_SNAKE_CASE.
+*/
+func upperSnake(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(prev)) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// Returns the name of the tag type of a payload.
+func tagTypeName(p *payloadDef) string {
+	return p.name + "Tag"
+}
+
+// Returns the name of the constant with the default tag ID of a payload.
+func tagIdConstName(p *payloadDef) string {
+	return upperSnake(p.name) + "_TAG_ID"
+}
+
+// Returns the expression that represents the tag ID of the field.
+func idExpr(id uint64) string {
+	return fmt.Sprintf("tags.TagID(%d)", id)
+}
+
+// Returns the expression that computes the size of a primitive field.
+func primitiveSizeExpr(f *fieldDef) string {
+	v := "p." + f.name
+	if f.standard() {
+		switch f.prim {
+		case stringType:
+			return fmt.Sprintf("direct.StdStringTagSize(%s)", v)
+		case bytesType:
+			return fmt.Sprintf("direct.RawTagSize(tags.IL_BYTES_TAG_ID, %s)", v)
+		case ilintType:
+			return fmt.Sprintf("direct.StdILIntTagSize(%s)", v)
+		case signedType:
+			return fmt.Sprintf("direct.StdSignedILIntTagSize(%s)", v)
+		default:
+			return "direct." + f.prim.stdSizeConst
+		}
+	}
+	id := idExpr(f.id)
+	switch f.prim {
+	case stringType:
+		return fmt.Sprintf("direct.StringTagSize(%s, %s)", id, v)
+	case bytesType:
+		return fmt.Sprintf("direct.RawTagSize(%s, %s)", id, v)
+	case ilintType, signedType:
+		return fmt.Sprintf("direct.Explicit%sTagSize(%s, %s)", f.prim.name, id, v)
+	default:
+		return fmt.Sprintf("direct.Explicit%sTagSize(%s)", f.prim.name, id)
+	}
+}
+
+// Returns the name of the direct function that serializes/deserializes the field.
+func primitiveFuncName(op string, f *fieldDef) string {
+	switch {
+	case f.standard():
+		return op + "Std" + f.prim.name + "Tag"
+	case f.prim == bytesType:
+		return op + "RawTag"
+	default:
+		return op + f.prim.name + "Tag"
+	}
+}
+
+// Returns the expression used to pass a tag field to the tags functions.
+func tagRef(f *fieldDef) string {
+	if f.kind == tagPointerField {
+		return "p." + f.name
+	}
+	return "&p." + f.name
+}
+
+/*
+Splits the fields in groups of consecutive fields of the same kind. Tag
+fields and pointers to tags are kept in the same group unless split is set.
+*/
+func groupFields(fields []*fieldDef, split bool) [][]*fieldDef {
+	var groups [][]*fieldDef
+	same := func(a, b *fieldDef) bool {
+		if a.kind == primitiveField || b.kind == primitiveField {
+			return false
+		}
+		return !split || (a.kind == tagField && b.kind == tagField)
+	}
+	for _, f := range fields {
+		n := len(groups)
+		if n > 0 && same(groups[n-1][0], f) {
+			groups[n-1] = append(groups[n-1], f)
+		} else {
+			groups = append(groups, []*fieldDef{f})
+		}
+	}
+	return groups
+}
+
+// Returns the list of references to the tags in the group.
+func tagRefs(group []*fieldDef) string {
+	refs := make([]string, len(group))
+	for i, f := range group {
+		refs[i] = tagRef(f)
+	}
+	return strings.Join(refs, ", ")
+}
+
+/*
+Writes the method that computes the size of the payload.
+*/
+func (g *generator) sizeMethod(p *payloadDef, method string) {
+	g.printf("func (p *%s) %s() uint64 {\n", p.name, method)
+	var terms []string
+	for _, group := range groupFields(p.fields, false) {
+		if group[0].kind == primitiveField {
+			terms = append(terms, primitiveSizeExpr(group[0]))
+		} else {
+			terms = append(terms, fmt.Sprintf("tags.ILTagSequenceSize(%s)", tagRefs(group)))
+		}
+	}
+	if len(terms) == 0 {
+		terms = append(terms, "0")
+	}
+	g.printf("\treturn %s\n}\n\n", strings.Join(terms, " +\n\t\t"))
+}
+
+// Returns true if the field is a pointer to a tag that cannot be nil.
+func (f *fieldDef) required() bool {
+	return f.kind == tagPointerField && !f.omitNull
+}
+
+/*
+Writes the method that serializes the payload. Nil pointers to tags without
+omitnull are rejected before anything is written.
+*/
+func (g *generator) serializeMethod(p *payloadDef, method string) {
+	g.printf("func (p *%s) %s(writer io.Writer) error {\n", p.name, method)
+	for _, f := range p.fields {
+		if f.required() {
+			g.printf("\tif p.%s == nil {\n", f.name)
+			g.printf("\t\treturn fmt.Errorf(\"the field %s.%s is nil: %%w\", tags.ErrBadTagFormat)\n\t}\n",
+				p.name, f.name)
+		}
+	}
+	for _, group := range groupFields(p.fields, false) {
+		f := group[0]
+		if f.kind == primitiveField {
+			if f.standard() {
+				g.printf("\tif err := direct.%s(p.%s, writer); err != nil {\n",
+					primitiveFuncName("Serialize", f), f.name)
+			} else {
+				g.printf("\tif err := direct.%s(%s, p.%s, writer); err != nil {\n",
+					primitiveFuncName("Serialize", f), idExpr(f.id), f.name)
+			}
+		} else {
+			g.printf("\tif err := tags.ILTagSerializeTags(writer, %s); err != nil {\n",
+				tagRefs(group))
+		}
+		g.printf("\t\treturn err\n\t}\n")
+	}
+	g.printf("\treturn nil\n}\n\n")
+}
+
+/*
+Writes the statements that deserialize the fields from the reader r.
+*/
+func (g *generator) deserializeFields(p *payloadDef) {
+	if len(p.fields) == 0 {
+		return
+	}
+	g.printf("\tvar err error\n")
+	for _, f := range p.fields {
+		if f.kind == tagPointerField && f.omitNull {
+			g.printf("\tvar isNull bool\n")
+			break
+		}
+	}
+	for _, group := range groupFields(p.fields, true) {
+		f := group[0]
+		switch f.kind {
+		case primitiveField:
+			if f.standard() {
+				g.printf("\tif p.%s, err = direct.%s(r); err != nil {\n",
+					f.name, primitiveFuncName("Deserialize", f))
+			} else {
+				g.printf("\tif p.%s, err = direct.%s(%s, r); err != nil {\n",
+					f.name, primitiveFuncName("Deserialize", f), idExpr(f.id))
+			}
+			g.printf("\t\treturn err\n\t}\n")
+		case tagField:
+			g.printf("\tif err = tags.ILTagDeserializeTagsInto(factory, r, %s); err != nil {\n",
+				tagRefs(group))
+			g.printf("\t\treturn err\n\t}\n")
+		case tagPointerField:
+			g.printf("\tp.%s = new(%s)\n", f.name, f.tagType)
+			g.printf("\tp.%s.SetId(%s)\n", f.name, idExpr(f.id))
+			if f.omitNull {
+				g.printf("\tif isNull, err = tags.ILTagDeserializeIntoOrNull(factory, r, p.%s); err != nil {\n",
+					f.name)
+				g.printf("\t\treturn err\n")
+				g.printf("\t} else if isNull {\n\t\tp.%s = nil\n\t}\n", f.name)
+			} else {
+				g.printf("\tif err = tags.ILTagDeserializeInto(factory, r, p.%s); err != nil {\n",
+					f.name)
+				g.printf("\t\treturn err\n\t}\n")
+			}
+		}
+	}
+}
+
+/*
+Writes the statements that set the IDs of the tag fields of the payload
+referenced by the given expression. Pointers to tags without omitnull are created
+as well.
+*/
+func (g *generator) initTagIds(p *payloadDef, expr string) {
+	for _, f := range p.fields {
+		if f.required() {
+			g.printf("\t%s.%s = new(%s)\n", expr, f.name, f.tagType)
+		}
+		if (f.kind == tagField && f.hasId) || f.required() {
+			g.printf("\t%s.%s.SetId(%s)\n", expr, f.name, idExpr(f.id))
+		}
+	}
+}
+
+/*
+Writes the ILTagPayload implementation and the tag of a plain payload.
+*/
+func (g *generator) plainPayload(p *payloadDef) {
+	tagName := tagTypeName(p)
+	g.printf("// Implementation of ILTagPayload.ValueSize().\n")
+	g.sizeMethod(p, "ValueSize")
+	g.printf("// Implementation of ILTagPayload.SerializeValue().\n")
+	g.serializeMethod(p, "SerializeValue")
+	g.printf("// Implementation of ILTagPayload.DeserializeValue().\n")
+	g.printf("func (p *%s) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {\n",
+		p.name)
+	g.printf("\tif valueSize < 0 {\n\t\treturn tags.ErrBadTagFormat\n\t}\n")
+	g.printf("\tr := &io.LimitedReader{R: reader, N: int64(valueSize)}\n")
+	g.deserializeFields(p)
+	g.printf("\tif r.N != 0 {\n\t\treturn tags.ErrBadTagFormat\n\t}\n")
+	g.printf("\treturn nil\n}\n\n")
+
+	g.printf("/*\n%s is the tag that holds the %s payload.\n*/\n", tagName, p.name)
+	g.printf("type %s struct {\n\ttags.ILTagHeaderImpl\n\t%s\n}\n\n", tagName, p.name)
+	g.printf("/*\nCreates a new %s. It panics if the ID is reserved for implicit tags.\n*/\n",
+		tagName)
+	g.printf("func New%s(id tags.TagID) *%s {\n", tagName, tagName)
+	g.printf("\tif id.Implicit() {\n\t\tpanic(\"This tag cannot have an implicit tag id.\")\n\t}\n")
+	g.printf("\tvar t %s\n\tt.SetId(id)\n", tagName)
+	g.initTagIds(p, "t."+p.name)
+	g.printf("\treturn &t\n}\n\n")
+}
+
+/*
+Writes the VersionedPayloadData implementation and the tag of a versioned
+payload.
+*/
+func (g *generator) versionedPayload(p *payloadDef) {
+	tagName := tagTypeName(p)
+	g.printf("// Implementation of VersionedPayloadData.Version().\n")
+	g.printf("func (p *%s) Version() uint16 {\n\treturn %d\n}\n\n", p.name, p.version)
+	g.printf("// Implementation of VersionedPayloadData.SupportedVersion().\n")
+	g.printf("func (p *%s) SupportedVersion(version uint16) bool {\n\treturn version == %d\n}\n\n",
+		p.name, p.version)
+	g.printf("// Implementation of VersionedPayloadData.Size().\n")
+	g.sizeMethod(p, "Size")
+	g.printf("// Implementation of VersionedPayloadData.Serialize().\n")
+	g.serializeMethod(p, "Serialize")
+	g.printf("// Implementation of VersionedPayloadData.Deserialize().\n")
+	g.printf("func (p *%s) Deserialize(version uint16, factory tags.ILTagFactory, valueSize int, r *io.LimitedReader) error {\n",
+		p.name)
+	g.deserializeFields(p)
+	g.printf("\treturn nil\n}\n\n")
+
+	wrapper := "VersionedPayloadTag"
+	if p.il2 {
+		wrapper = "IL2VersionedPayloadTag"
+	}
+	g.printf("/*\n%s is the tag that holds the %s payload.\n*/\n", tagName, p.name)
+	g.printf("type %s = ext.%s[*%s]\n\n", tagName, wrapper, p.name)
+	g.printf("/*\nCreates a new %s. It panics if the ID is reserved for implicit tags.\n*/\n",
+		tagName)
+	g.printf("func New%s(id tags.TagID) *%s {\n", tagName, tagName)
+	g.printf("\tdata := &%s{}\n", p.name)
+	g.initTagIds(p, "data")
+	g.printf("\treturn ext.New%s(id, data)\n}\n\n", wrapper)
+}
+
+/*
+Generates the source code of the payloads of the given package.
+*/
+func generateCode(pkg *packageDef) ([]byte, error) {
+	imports := map[string]string{"io": "io", "tags": tagsImport}
+	for _, p := range pkg.payloads {
+		for name, path := range p.imports {
+			imports[name] = path
+		}
+		for _, f := range p.fields {
+			if f.kind == primitiveField {
+				imports["direct"] = directImport
+			}
+			if f.required() {
+				imports["fmt"] = "fmt"
+			}
+		}
+		if p.versioned {
+			imports["ext"] = extImport
+		}
+	}
+	var g generator
+	g.header(pkg.name, imports)
+	g.printf("const (\n")
+	for _, p := range pkg.payloads {
+		g.printf("\t// Default tag ID of %s.\n", tagTypeName(p))
+		g.printf("\t%s tags.TagID = %d\n", tagIdConstName(p), p.id)
+	}
+	g.printf(")\n\n")
+	for _, p := range pkg.payloads {
+		if p.versioned {
+			g.versionedPayload(p)
+		} else {
+			g.plainPayload(p)
+		}
+	}
+	return g.format()
+}
+
+/*
+Generates the round trip tests of the payloads of the given package.
+*/
+func generateTests(pkg *packageDef) ([]byte, error) {
+	imports := map[string]string{
+		"bytes":   "bytes",
+		"testing": "testing",
+		"tags":    tagsImport,
+		"tagtest": tagtestImport,
+		"assert":  "github.com/stretchr/testify/assert",
+		"require": "github.com/stretchr/testify/require",
+	}
+	for _, p := range pkg.payloads {
+		for _, f := range p.fields {
+			if f.kind == primitiveField {
+				imports["rand"] = "math/rand"
+			}
+		}
+	}
+	var g generator
+	g.header(pkg.name, imports)
+	for _, p := range pkg.payloads {
+		tagName := tagTypeName(p)
+		data := "tag"
+		if p.versioned {
+			data = "tag.Data"
+		}
+		g.printf("func Test%sRoundTrip(t *testing.T) {\n", tagName)
+		g.printf("\ttag := New%s(%s)\n", tagName, tagIdConstName(p))
+		for _, f := range p.fields {
+			if f.kind == primitiveField {
+				g.printf("\t%s.%s = %s\n", data, f.name, f.prim.sample)
+			}
+		}
+		g.printf("\n\tbin, err := tags.ILTagToBytes(tag)\n")
+		g.printf("\trequire.Nil(t, err)\n")
+		g.printf("\tassert.Equal(t, tags.ILTagSize(tag), uint64(len(bin)))\n\n")
+		g.printf("\tdecoded := New%s(%s)\n", tagName, tagIdConstName(p))
+		g.printf("\trequire.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), decoded))\n")
+		for _, f := range p.fields {
+			if f.kind == primitiveField {
+				g.printf("\tassert.Equal(t, %s.%s, %s.%s)\n", data, f.name,
+					strings.Replace(data, "tag", "decoded", 1), f.name)
+			}
+		}
+		g.printf("\tbin2, err := tags.ILTagToBytes(decoded)\n")
+		g.printf("\trequire.Nil(t, err)\n")
+		g.printf("\tassert.Equal(t, bin, bin2)\n\n")
+		g.printf("\tfor i := 0; i < len(bin); i++ {\n")
+		g.printf("\t\tassert.Error(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin[:i]), New%s(%s)))\n",
+			tagName, tagIdConstName(p))
+		g.printf("\t\tassert.Error(t, tags.ILTagSeralize(tag, tagtest.NewLimitedWriter(i, false)))\n")
+		g.printf("\t}\n}\n\n")
+	}
+	return g.format()
+}
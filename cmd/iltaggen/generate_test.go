@@ -0,0 +1,153 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpperSnake(t *testing.T) {
+	assert.Equal(t, "RECORD", upperSnake("Record"))
+	assert.Equal(t, "BLOCK_HEADER", upperSnake("BlockHeader"))
+	assert.Equal(t, "HTTP_REQUEST", upperSnake("HTTPRequest"))
+	assert.Equal(t, "SHA256_HASH", upperSnake("Sha256Hash"))
+	assert.Equal(t, "X", upperSnake("x"))
+}
+
+func TestGroupFields(t *testing.T) {
+	a := &fieldDef{name: "A", kind: primitiveField}
+	b := &fieldDef{name: "B", kind: tagField}
+	c := &fieldDef{name: "C", kind: tagPointerField}
+	d := &fieldDef{name: "D", kind: tagField}
+	e := &fieldDef{name: "E", kind: primitiveField}
+
+	fields := []*fieldDef{a, b, c, d, e}
+	assert.Equal(t, [][]*fieldDef{{a}, {b, c, d}, {e}}, groupFields(fields, false))
+	assert.Equal(t, [][]*fieldDef{{a}, {b}, {c}, {d}, {e}}, groupFields(fields, true))
+	assert.Equal(t, [][]*fieldDef{{b, d}}, groupFields([]*fieldDef{b, d}, true))
+	assert.Nil(t, groupFields(nil, true))
+}
+
+func TestPrimitiveSizeExpr(t *testing.T) {
+	assert.Equal(t, "direct.IL_BOOL_TAG_ID_SIZE",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: boolType, id: 1}))
+	assert.Equal(t, "direct.ExplicitBoolTagSize(tags.TagID(1000))",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: boolType, id: 1000}))
+	assert.Equal(t, "direct.StdStringTagSize(p.A)",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: stringType, id: 17}))
+	assert.Equal(t, "direct.StringTagSize(tags.TagID(1000), p.A)",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: stringType, id: 1000}))
+	assert.Equal(t, "direct.RawTagSize(tags.IL_BYTES_TAG_ID, p.A)",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: bytesType, id: 16}))
+	assert.Equal(t, "direct.RawTagSize(tags.TagID(1000), p.A)",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: bytesType, id: 1000}))
+	assert.Equal(t, "direct.StdILIntTagSize(p.A)",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: ilintType, id: 10}))
+	assert.Equal(t, "direct.StdSignedILIntTagSize(p.A)",
+		primitiveSizeExpr(&fieldDef{name: "A", prim: signedType, id: 14}))
+}
+
+func TestPrimitiveFuncName(t *testing.T) {
+	assert.Equal(t, "SerializeStdInt8Tag",
+		primitiveFuncName("Serialize", &fieldDef{prim: int8Type, id: 2}))
+	assert.Equal(t, "DeserializeInt8Tag",
+		primitiveFuncName("Deserialize", &fieldDef{prim: int8Type, id: 1000}))
+	assert.Equal(t, "DeserializeStdBytesTag",
+		primitiveFuncName("Deserialize", &fieldDef{prim: bytesType, id: 16}))
+	assert.Equal(t, "SerializeRawTag",
+		primitiveFuncName("Serialize", &fieldDef{prim: bytesType, id: 1000}))
+}
+
+func TestGenerateCode(t *testing.T) {
+	pkg := &packageDef{
+		name: "x",
+		payloads: []*payloadDef{
+			{name: "A", id: 1000, fields: []*fieldDef{
+				{name: "V", kind: primitiveField, prim: stringType, id: 17}}},
+		},
+	}
+	src, err := generateCode(pkg)
+	require.Nil(t, err)
+	assert.Contains(t, string(src), "func (p *A) ValueSize() uint64")
+	assert.Contains(t, string(src), "A_TAG_ID tags.TagID = 1000")
+
+	src, err = generateTests(pkg)
+	require.Nil(t, err)
+	assert.Contains(t, string(src), "func TestATagRoundTrip(t *testing.T)")
+
+	// Invalid names result in invalid code
+	pkg.payloads[0].name = "A B"
+	_, err = generateCode(pkg)
+	assert.Error(t, err)
+}
+
+func TestGenerateCodeOmitNull(t *testing.T) {
+	pkg := &packageDef{
+		name: "x",
+		payloads: []*payloadDef{
+			{name: "A", id: 1000, imports: map[string]string{"impl": "github.com/interlockledger/go-iltags/tags/impl"},
+				fields: []*fieldDef{
+					{name: "R", kind: tagPointerField, hasId: true, id: 17, tagType: "impl.StringTag"},
+					{name: "N", kind: tagPointerField, hasId: true, id: 17, tagType: "impl.StringTag",
+						omitNull: true}}},
+		},
+	}
+	src, err := generateCode(pkg)
+	require.Nil(t, err)
+	code := string(src)
+	assert.Contains(t, code, "\t\"fmt\"\n")
+	assert.Contains(t, code, "var isNull bool")
+
+	// Required pointers
+	assert.Contains(t, code, "if p.R == nil {\n"+
+		"\t\treturn fmt.Errorf(\"the field A.R is nil: %w\", tags.ErrBadTagFormat)")
+	assert.Contains(t, code, "if err = tags.ILTagDeserializeInto(factory, r, p.R); err != nil {")
+	assert.Contains(t, code, "t.A.R = new(impl.StringTag)")
+
+	// Pointers with omitnull
+	assert.NotContains(t, code, "if p.N == nil {")
+	assert.Contains(t, code, "if isNull, err = tags.ILTagDeserializeIntoOrNull(factory, r, p.N); err != nil {")
+	assert.Contains(t, code, "} else if isNull {\n\t\tp.N = nil\n\t}")
+	assert.NotContains(t, code, "t.A.N = new(impl.StringTag)")
+
+	// Without required pointers, fmt is not needed
+	pkg.payloads[0].fields = pkg.payloads[0].fields[1:]
+	src, err = generateCode(pkg)
+	require.Nil(t, err)
+	code = string(src)
+	assert.NotContains(t, code, "\t\"fmt\"\n")
+	assert.Contains(t, code, "var isNull bool")
+}
@@ -0,0 +1,100 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+This package contains sample payloads used to test the code generated by
+iltaggen. The files example_iltags.go and example_iltags_test.go are generated
+from this file.
+*/
+package example
+
+//go:generate go run github.com/interlockledger/go-iltags/cmd/iltaggen
+
+import (
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+Sample payload with all primitive types and nested tags.
+*/
+//iltaggen:tag 1000
+type Record struct {
+	Flag     bool
+	Small    int8
+	USmall   uint8
+	Short    int16
+	UShort   uint16
+	Int      int32
+	UInt     uint32
+	Long     int64
+	ULong    uint64
+	Float    float32
+	Double   float64
+	Name     string
+	Data     []byte
+	Counter  uint64          `iltag:"10"`
+	Delta    int64           `iltag:"14"`
+	Label    string          `iltag:"1001"`
+	Blob     []byte          `iltag:"1002"`
+	Code     uint32          `iltag:"1003"`
+	Owner    impl.StringTag  `iltag:"17"`
+	Serial   impl.ILIntTag   `iltag:"10"`
+	Parent   *impl.ILIntTag  `iltag:"10,omitnull"`
+	Comment  *impl.StringTag `iltag:"17"`
+	Ignored  string          `iltag:"-"`
+	internal int
+}
+
+/*
+Sample versioned payload.
+*/
+//iltaggen:tag 1010 version=2
+type Event struct {
+	Sequence uint64 `iltag:"10"`
+	Source   string
+	Target   *impl.StringTag `iltag:"17"`
+}
+
+/*
+Sample versioned payload that uses the plain version format.
+*/
+//iltaggen:tag 1020 version=1 format=plain
+type Marker struct {
+	Value int16
+}
+
+/*
+Sample empty payload.
+*/
+//iltaggen:tag 1030
+type Empty struct {
+}
@@ -0,0 +1,372 @@
+// Code generated by iltaggen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/direct"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+const (
+	// Default tag ID of EmptyTag.
+	EMPTY_TAG_ID tags.TagID = 1030
+	// Default tag ID of EventTag.
+	EVENT_TAG_ID tags.TagID = 1010
+	// Default tag ID of MarkerTag.
+	MARKER_TAG_ID tags.TagID = 1020
+	// Default tag ID of RecordTag.
+	RECORD_TAG_ID tags.TagID = 1000
+)
+
+// Implementation of ILTagPayload.ValueSize().
+func (p *Empty) ValueSize() uint64 {
+	return 0
+}
+
+// Implementation of ILTagPayload.SerializeValue().
+func (p *Empty) SerializeValue(writer io.Writer) error {
+	return nil
+}
+
+// Implementation of ILTagPayload.DeserializeValue().
+func (p *Empty) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	if valueSize < 0 {
+		return tags.ErrBadTagFormat
+	}
+	r := &io.LimitedReader{R: reader, N: int64(valueSize)}
+	if r.N != 0 {
+		return tags.ErrBadTagFormat
+	}
+	return nil
+}
+
+/*
+EmptyTag is the tag that holds the Empty payload.
+*/
+type EmptyTag struct {
+	tags.ILTagHeaderImpl
+	Empty
+}
+
+/*
+Creates a new EmptyTag. It panics if the ID is reserved for implicit tags.
+*/
+func NewEmptyTag(id tags.TagID) *EmptyTag {
+	if id.Implicit() {
+		panic("This tag cannot have an implicit tag id.")
+	}
+	var t EmptyTag
+	t.SetId(id)
+	return &t
+}
+
+// Implementation of VersionedPayloadData.Version().
+func (p *Event) Version() uint16 {
+	return 2
+}
+
+// Implementation of VersionedPayloadData.SupportedVersion().
+func (p *Event) SupportedVersion(version uint16) bool {
+	return version == 2
+}
+
+// Implementation of VersionedPayloadData.Size().
+func (p *Event) Size() uint64 {
+	return direct.StdILIntTagSize(p.Sequence) +
+		direct.StdStringTagSize(p.Source) +
+		tags.ILTagSequenceSize(p.Target)
+}
+
+// Implementation of VersionedPayloadData.Serialize().
+func (p *Event) Serialize(writer io.Writer) error {
+	if p.Target == nil {
+		return fmt.Errorf("the field Event.Target is nil: %w", tags.ErrBadTagFormat)
+	}
+	if err := direct.SerializeStdILIntTag(p.Sequence, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdStringTag(p.Source, writer); err != nil {
+		return err
+	}
+	if err := tags.ILTagSerializeTags(writer, p.Target); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Implementation of VersionedPayloadData.Deserialize().
+func (p *Event) Deserialize(version uint16, factory tags.ILTagFactory, valueSize int, r *io.LimitedReader) error {
+	var err error
+	if p.Sequence, err = direct.DeserializeStdILIntTag(r); err != nil {
+		return err
+	}
+	if p.Source, err = direct.DeserializeStdStringTag(r); err != nil {
+		return err
+	}
+	p.Target = new(impl.StringTag)
+	p.Target.SetId(tags.TagID(17))
+	if err = tags.ILTagDeserializeInto(factory, r, p.Target); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+EventTag is the tag that holds the Event payload.
+*/
+type EventTag = ext.IL2VersionedPayloadTag[*Event]
+
+/*
+Creates a new EventTag. It panics if the ID is reserved for implicit tags.
+*/
+func NewEventTag(id tags.TagID) *EventTag {
+	data := &Event{}
+	data.Target = new(impl.StringTag)
+	data.Target.SetId(tags.TagID(17))
+	return ext.NewIL2VersionedPayloadTag(id, data)
+}
+
+// Implementation of VersionedPayloadData.Version().
+func (p *Marker) Version() uint16 {
+	return 1
+}
+
+// Implementation of VersionedPayloadData.SupportedVersion().
+func (p *Marker) SupportedVersion(version uint16) bool {
+	return version == 1
+}
+
+// Implementation of VersionedPayloadData.Size().
+func (p *Marker) Size() uint64 {
+	return direct.IL_INT16_TAG_ID_SIZE
+}
+
+// Implementation of VersionedPayloadData.Serialize().
+func (p *Marker) Serialize(writer io.Writer) error {
+	if err := direct.SerializeStdInt16Tag(p.Value, writer); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Implementation of VersionedPayloadData.Deserialize().
+func (p *Marker) Deserialize(version uint16, factory tags.ILTagFactory, valueSize int, r *io.LimitedReader) error {
+	var err error
+	if p.Value, err = direct.DeserializeStdInt16Tag(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+MarkerTag is the tag that holds the Marker payload.
+*/
+type MarkerTag = ext.VersionedPayloadTag[*Marker]
+
+/*
+Creates a new MarkerTag. It panics if the ID is reserved for implicit tags.
+*/
+func NewMarkerTag(id tags.TagID) *MarkerTag {
+	data := &Marker{}
+	return ext.NewVersionedPayloadTag(id, data)
+}
+
+// Implementation of ILTagPayload.ValueSize().
+func (p *Record) ValueSize() uint64 {
+	return direct.IL_BOOL_TAG_ID_SIZE +
+		direct.IL_INT8_TAG_ID_SIZE +
+		direct.IL_UINT8_TAG_ID_SIZE +
+		direct.IL_INT16_TAG_ID_SIZE +
+		direct.IL_UINT16_TAG_ID_SIZE +
+		direct.IL_INT32_TAG_ID_SIZE +
+		direct.IL_UINT32_TAG_ID_SIZE +
+		direct.IL_INT64_TAG_ID_SIZE +
+		direct.IL_UINT64_TAG_ID_SIZE +
+		direct.IL_BIN32_TAG_ID_SIZE +
+		direct.IL_BIN64_TAG_ID_SIZE +
+		direct.StdStringTagSize(p.Name) +
+		direct.RawTagSize(tags.IL_BYTES_TAG_ID, p.Data) +
+		direct.StdILIntTagSize(p.Counter) +
+		direct.StdSignedILIntTagSize(p.Delta) +
+		direct.StringTagSize(tags.TagID(1001), p.Label) +
+		direct.RawTagSize(tags.TagID(1002), p.Blob) +
+		direct.ExplicitUInt32TagSize(tags.TagID(1003)) +
+		tags.ILTagSequenceSize(&p.Owner, &p.Serial, p.Parent, p.Comment)
+}
+
+// Implementation of ILTagPayload.SerializeValue().
+func (p *Record) SerializeValue(writer io.Writer) error {
+	if p.Comment == nil {
+		return fmt.Errorf("the field Record.Comment is nil: %w", tags.ErrBadTagFormat)
+	}
+	if err := direct.SerializeStdBoolTag(p.Flag, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdInt8Tag(p.Small, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdUInt8Tag(p.USmall, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdInt16Tag(p.Short, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdUInt16Tag(p.UShort, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdInt32Tag(p.Int, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdUInt32Tag(p.UInt, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdInt64Tag(p.Long, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdUInt64Tag(p.ULong, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdFloat32Tag(p.Float, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdFloat64Tag(p.Double, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdStringTag(p.Name, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdBytesTag(p.Data, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdILIntTag(p.Counter, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStdSignedILIntTag(p.Delta, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeStringTag(tags.TagID(1001), p.Label, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeRawTag(tags.TagID(1002), p.Blob, writer); err != nil {
+		return err
+	}
+	if err := direct.SerializeUInt32Tag(tags.TagID(1003), p.Code, writer); err != nil {
+		return err
+	}
+	if err := tags.ILTagSerializeTags(writer, &p.Owner, &p.Serial, p.Parent, p.Comment); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Implementation of ILTagPayload.DeserializeValue().
+func (p *Record) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	if valueSize < 0 {
+		return tags.ErrBadTagFormat
+	}
+	r := &io.LimitedReader{R: reader, N: int64(valueSize)}
+	var err error
+	var isNull bool
+	if p.Flag, err = direct.DeserializeStdBoolTag(r); err != nil {
+		return err
+	}
+	if p.Small, err = direct.DeserializeStdInt8Tag(r); err != nil {
+		return err
+	}
+	if p.USmall, err = direct.DeserializeStdUInt8Tag(r); err != nil {
+		return err
+	}
+	if p.Short, err = direct.DeserializeStdInt16Tag(r); err != nil {
+		return err
+	}
+	if p.UShort, err = direct.DeserializeStdUInt16Tag(r); err != nil {
+		return err
+	}
+	if p.Int, err = direct.DeserializeStdInt32Tag(r); err != nil {
+		return err
+	}
+	if p.UInt, err = direct.DeserializeStdUInt32Tag(r); err != nil {
+		return err
+	}
+	if p.Long, err = direct.DeserializeStdInt64Tag(r); err != nil {
+		return err
+	}
+	if p.ULong, err = direct.DeserializeStdUInt64Tag(r); err != nil {
+		return err
+	}
+	if p.Float, err = direct.DeserializeStdFloat32Tag(r); err != nil {
+		return err
+	}
+	if p.Double, err = direct.DeserializeStdFloat64Tag(r); err != nil {
+		return err
+	}
+	if p.Name, err = direct.DeserializeStdStringTag(r); err != nil {
+		return err
+	}
+	if p.Data, err = direct.DeserializeStdBytesTag(r); err != nil {
+		return err
+	}
+	if p.Counter, err = direct.DeserializeStdILIntTag(r); err != nil {
+		return err
+	}
+	if p.Delta, err = direct.DeserializeStdSignedILIntTag(r); err != nil {
+		return err
+	}
+	if p.Label, err = direct.DeserializeStringTag(tags.TagID(1001), r); err != nil {
+		return err
+	}
+	if p.Blob, err = direct.DeserializeRawTag(tags.TagID(1002), r); err != nil {
+		return err
+	}
+	if p.Code, err = direct.DeserializeUInt32Tag(tags.TagID(1003), r); err != nil {
+		return err
+	}
+	if err = tags.ILTagDeserializeTagsInto(factory, r, &p.Owner, &p.Serial); err != nil {
+		return err
+	}
+	p.Parent = new(impl.ILIntTag)
+	p.Parent.SetId(tags.TagID(10))
+	if isNull, err = tags.ILTagDeserializeIntoOrNull(factory, r, p.Parent); err != nil {
+		return err
+	} else if isNull {
+		p.Parent = nil
+	}
+	p.Comment = new(impl.StringTag)
+	p.Comment.SetId(tags.TagID(17))
+	if err = tags.ILTagDeserializeInto(factory, r, p.Comment); err != nil {
+		return err
+	}
+	if r.N != 0 {
+		return tags.ErrBadTagFormat
+	}
+	return nil
+}
+
+/*
+RecordTag is the tag that holds the Record payload.
+*/
+type RecordTag struct {
+	tags.ILTagHeaderImpl
+	Record
+}
+
+/*
+Creates a new RecordTag. It panics if the ID is reserved for implicit tags.
+*/
+func NewRecordTag(id tags.TagID) *RecordTag {
+	if id.Implicit() {
+		panic("This tag cannot have an implicit tag id.")
+	}
+	var t RecordTag
+	t.SetId(id)
+	t.Record.Owner.SetId(tags.TagID(17))
+	t.Record.Serial.SetId(tags.TagID(10))
+	t.Record.Comment = new(impl.StringTag)
+	t.Record.Comment.SetId(tags.TagID(17))
+	return &t
+}
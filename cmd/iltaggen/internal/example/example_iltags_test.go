@@ -0,0 +1,132 @@
+// Code generated by iltaggen. DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tagtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmptyTagRoundTrip(t *testing.T) {
+	tag := NewEmptyTag(EMPTY_TAG_ID)
+
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, tags.ILTagSize(tag), uint64(len(bin)))
+
+	decoded := NewEmptyTag(EMPTY_TAG_ID)
+	require.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), decoded))
+	bin2, err := tags.ILTagToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, bin, bin2)
+
+	for i := 0; i < len(bin); i++ {
+		assert.Error(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin[:i]), NewEmptyTag(EMPTY_TAG_ID)))
+		assert.Error(t, tags.ILTagSeralize(tag, tagtest.NewLimitedWriter(i, false)))
+	}
+}
+
+func TestEventTagRoundTrip(t *testing.T) {
+	tag := NewEventTag(EVENT_TAG_ID)
+	tag.Data.Sequence = rand.Uint64()
+	tag.Data.Source = tagtest.GenerateRandomString()
+
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, tags.ILTagSize(tag), uint64(len(bin)))
+
+	decoded := NewEventTag(EVENT_TAG_ID)
+	require.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), decoded))
+	assert.Equal(t, tag.Data.Sequence, decoded.Data.Sequence)
+	assert.Equal(t, tag.Data.Source, decoded.Data.Source)
+	bin2, err := tags.ILTagToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, bin, bin2)
+
+	for i := 0; i < len(bin); i++ {
+		assert.Error(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin[:i]), NewEventTag(EVENT_TAG_ID)))
+		assert.Error(t, tags.ILTagSeralize(tag, tagtest.NewLimitedWriter(i, false)))
+	}
+}
+
+func TestMarkerTagRoundTrip(t *testing.T) {
+	tag := NewMarkerTag(MARKER_TAG_ID)
+	tag.Data.Value = int16(rand.Int())
+
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, tags.ILTagSize(tag), uint64(len(bin)))
+
+	decoded := NewMarkerTag(MARKER_TAG_ID)
+	require.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), decoded))
+	assert.Equal(t, tag.Data.Value, decoded.Data.Value)
+	bin2, err := tags.ILTagToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, bin, bin2)
+
+	for i := 0; i < len(bin); i++ {
+		assert.Error(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin[:i]), NewMarkerTag(MARKER_TAG_ID)))
+		assert.Error(t, tags.ILTagSeralize(tag, tagtest.NewLimitedWriter(i, false)))
+	}
+}
+
+func TestRecordTagRoundTrip(t *testing.T) {
+	tag := NewRecordTag(RECORD_TAG_ID)
+	tag.Flag = true
+	tag.Small = int8(rand.Int())
+	tag.USmall = uint8(rand.Int())
+	tag.Short = int16(rand.Int())
+	tag.UShort = uint16(rand.Int())
+	tag.Int = rand.Int31()
+	tag.UInt = rand.Uint32()
+	tag.Long = rand.Int63()
+	tag.ULong = rand.Uint64()
+	tag.Float = rand.Float32()
+	tag.Double = rand.Float64()
+	tag.Name = tagtest.GenerateRandomString()
+	tag.Data = tagtest.FillSeq(make([]byte, 1+rand.Intn(64)))
+	tag.Counter = rand.Uint64()
+	tag.Delta = rand.Int63() - rand.Int63()
+	tag.Label = tagtest.GenerateRandomString()
+	tag.Blob = tagtest.FillSeq(make([]byte, 1+rand.Intn(64)))
+	tag.Code = rand.Uint32()
+
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, tags.ILTagSize(tag), uint64(len(bin)))
+
+	decoded := NewRecordTag(RECORD_TAG_ID)
+	require.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), decoded))
+	assert.Equal(t, tag.Flag, decoded.Flag)
+	assert.Equal(t, tag.Small, decoded.Small)
+	assert.Equal(t, tag.USmall, decoded.USmall)
+	assert.Equal(t, tag.Short, decoded.Short)
+	assert.Equal(t, tag.UShort, decoded.UShort)
+	assert.Equal(t, tag.Int, decoded.Int)
+	assert.Equal(t, tag.UInt, decoded.UInt)
+	assert.Equal(t, tag.Long, decoded.Long)
+	assert.Equal(t, tag.ULong, decoded.ULong)
+	assert.Equal(t, tag.Float, decoded.Float)
+	assert.Equal(t, tag.Double, decoded.Double)
+	assert.Equal(t, tag.Name, decoded.Name)
+	assert.Equal(t, tag.Data, decoded.Data)
+	assert.Equal(t, tag.Counter, decoded.Counter)
+	assert.Equal(t, tag.Delta, decoded.Delta)
+	assert.Equal(t, tag.Label, decoded.Label)
+	assert.Equal(t, tag.Blob, decoded.Blob)
+	assert.Equal(t, tag.Code, decoded.Code)
+	bin2, err := tags.ILTagToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, bin, bin2)
+
+	for i := 0; i < len(bin); i++ {
+		assert.Error(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin[:i]), NewRecordTag(RECORD_TAG_ID)))
+		assert.Error(t, tags.ILTagSeralize(tag, tagtest.NewLimitedWriter(i, false)))
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package example
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordTagOmitNull(t *testing.T) {
+	tag := NewRecordTag(RECORD_TAG_ID)
+	require.NotNil(t, tag.Comment)
+	assert.Equal(t, tags.IL_STRING_TAG_ID, tag.Comment.Id())
+	assert.Nil(t, tag.Parent)
+
+	// Fields with omitnull accept nil
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	decoded := NewRecordTag(RECORD_TAG_ID)
+	decoded.Parent = impl.NewStdILIntTag()
+	require.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), decoded))
+	assert.Nil(t, decoded.Parent)
+	require.NotNil(t, decoded.Comment)
+
+	// Other pointers do not
+	tag.Comment = nil
+	_, err = tags.ILTagToBytes(tag)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	assert.EqualError(t, tag.SerializeValue(&bytes.Buffer{}),
+		"the field Record.Comment is nil: bad tag format")
+
+	// The ILNullTag is rejected when decoded. The empty comment is the last field.
+	payload := bin[len(bin)-int(decoded.ValueSize()):]
+	payload = append(append([]byte{}, payload[:len(payload)-2]...), 0x00)
+	var withNull bytes.Buffer
+	require.Nil(t, serialization.WriteILInt(&withNull, uint64(RECORD_TAG_ID)))
+	require.Nil(t, serialization.WriteILInt(&withNull, uint64(len(payload))))
+	withNull.Write(payload)
+	err = tags.ILTagDeserializeInto(nil, &withNull, NewRecordTag(RECORD_TAG_ID))
+	assert.ErrorIs(t, err, tags.ErrUnexpectedTagId)
+}
+
+func TestEventTagNilPointer(t *testing.T) {
+	tag := NewEventTag(EVENT_TAG_ID)
+	require.NotNil(t, tag.Data.Target)
+	_, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	tag.Data.Target = nil
+	_, err = tags.ILTagToBytes(tag)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+}
@@ -0,0 +1,152 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+Iltaggen generates the ILTagPayload implementation of Go structs. It is
+designed to be used with go generate and produces code that does not rely on
+reflection.
+
+Structs are selected by the iltaggen directive placed in their documentation:
+
+	//iltaggen:tag <id> [version=<version>] [format=il2|plain]
+
+where id is the default tag ID of the generated tag type. If a version is
+provided, the struct will implement ext.VersionedPayloadData and the tag type
+will be an alias of ext.IL2VersionedPayloadTag (format=il2, the default) or
+ext.VersionedPayloadTag (format=plain).
+
+The fields are configured by the same iltag struct tag used by the iltags
+package:
+
+	`iltag:"[<id>][,omitnull]"`
+
+Fields of type bool, int8, int16, int32, int64, uint8, uint16, uint32, uint64,
+float32, float64, string and []byte are serialized with the functions of the
+direct package. They use the standard tag of their types unless a non reserved
+tag ID is provided. The ILIntTag ID can be used by uint64 fields and the
+SignedILIntTag ID can be used by int64 fields.
+
+Fields of any other named type are handled as tags and must implement tags.ILTag
+through their pointers. Tag fields stored by value are initialized with the
+given tag ID by the generated constructor. Pointers to tags require a tag ID and
+are created with new() before being deserialized. With omitnull, nil values
+are encoded as ILNullTags and ILNullTags are decoded as nil. Otherwise the
+generated constructor creates the tag, nil values cannot be serialized and
+ILNullTags are rejected. Unexported fields and fields tagged with `iltag:"-"`
+are ignored.
+
+For each struct Foo, iltaggen generates the payload methods, the constant
+FOO_TAG_ID, the tag type FooTag and its constructor NewFooTag(). Optionally, it
+also generates round trip tests that use the tagtest helpers.
+
+Usage:
+
+	iltaggen [-dir <dir>] [-output <file>] [-tests=false]
+
+The default output file is <package>_iltags.go and the tests are written to
+<package>_iltags_test.go. When invoked by go generate, the directory defaults to
+the directory of the file that contains the go:generate directive.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Generates the files for the package in dir. It returns the names of the files
+written.
+*/
+func run(dir string, output string, tests bool) ([]string, error) {
+	var exclude []string
+	if output != "" {
+		exclude = append(exclude, output)
+	}
+	files, err := listSourceFiles(dir, exclude...)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go source files found in %s", dir)
+	}
+	pkg, err := parseFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkg.payloads) == 0 {
+		return nil, fmt.Errorf("no struct marked with %s found in %s", directivePrefix, dir)
+	}
+	if output == "" {
+		output = pkg.name + "_iltags.go"
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	src, err := generateCode(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		return nil, err
+	}
+	written := []string{output}
+	if tests {
+		src, err := generateTests(pkg)
+		if err != nil {
+			return nil, err
+		}
+		testOutput := strings.TrimSuffix(output, ".go") + "_test.go"
+		if err := os.WriteFile(testOutput, src, 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, testOutput)
+	}
+	return written, nil
+}
+
+func main() {
+	dir := flag.String("dir", "", "directory of the package (default: current directory)")
+	output := flag.String("output", "", "name of the output file (default: <package>_iltags.go)")
+	tests := flag.Bool("tests", true, "generate the round trip tests")
+	flag.Parse()
+	if *dir == "" {
+		*dir = "."
+	}
+	if _, err := run(*dir, *output, *tests); err != nil {
+		fmt.Fprintf(os.Stderr, "iltaggen: %v\n", err)
+		os.Exit(1)
+	}
+}
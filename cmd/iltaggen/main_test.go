@@ -0,0 +1,93 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := os.ReadFile(src)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(dst, data, 0644))
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, "internal/example/example.go", filepath.Join(dir, "example.go"))
+
+	written, err := run(dir, "", true)
+	require.Nil(t, err)
+	require.Len(t, written, 2)
+	assert.Equal(t, filepath.Join(dir, "example_iltags.go"), written[0])
+	assert.Equal(t, filepath.Join(dir, "example_iltags_test.go"), written[1])
+
+	// The committed files must be up to date
+	for _, f := range []string{"example_iltags.go", "example_iltags_test.go"} {
+		expected, err := os.ReadFile(filepath.Join("internal/example", f))
+		require.Nil(t, err)
+		actual, err := os.ReadFile(filepath.Join(dir, f))
+		require.Nil(t, err)
+		assert.Equal(t, string(expected), string(actual), f)
+	}
+
+	// Running again must ignore the generated files
+	written, err = run(dir, "out.go", false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "out.go")}, written)
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := run(dir, "", true)
+	assert.Error(t, err)
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644))
+	_, err = run(dir, "", true)
+	assert.Error(t, err)
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "a.go"),
+		[]byte("package a\n//iltaggen:tag 1000\ntype A struct{ B int }\n"), 0644))
+	_, err = run(dir, "", true)
+	assert.Error(t, err)
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "a.go"),
+		[]byte("package a\n//iltaggen:tag 1000\ntype A struct{}\n"), 0644))
+	_, err = run(dir, filepath.Join(dir, "missing", "a_iltags.go"), false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,532 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the comment directive that marks a struct for generation.
+const directivePrefix = "//iltaggen:tag"
+
+// Name of the struct tag used to configure the fields.
+const structTagName = "iltag"
+
+/*
+Describes a Go type that can be serialized directly by the functions of the
+direct package.
+*/
+type primitiveType struct {
+	// Name of the Go type.
+	goType string
+	// Suffix used by the functions of the direct package.
+	name string
+	// Standard tag ID of this type.
+	stdId uint64
+	// Name of the constant with the size of the standard tag. It is empty if
+	// the size depends on the value.
+	stdSizeConst string
+	// Expression used by the generated tests to create a sample value.
+	sample string
+}
+
+// Returns true if the size of the tag depends on its value.
+func (p *primitiveType) variableSize() bool {
+	return p.stdSizeConst == ""
+}
+
+var (
+	boolType    = &primitiveType{"bool", "Bool", 1, "IL_BOOL_TAG_ID_SIZE", "true"}
+	int8Type    = &primitiveType{"int8", "Int8", 2, "IL_INT8_TAG_ID_SIZE", "int8(rand.Int())"}
+	uint8Type   = &primitiveType{"uint8", "UInt8", 3, "IL_UINT8_TAG_ID_SIZE", "uint8(rand.Int())"}
+	int16Type   = &primitiveType{"int16", "Int16", 4, "IL_INT16_TAG_ID_SIZE", "int16(rand.Int())"}
+	uint16Type  = &primitiveType{"uint16", "UInt16", 5, "IL_UINT16_TAG_ID_SIZE", "uint16(rand.Int())"}
+	int32Type   = &primitiveType{"int32", "Int32", 6, "IL_INT32_TAG_ID_SIZE", "rand.Int31()"}
+	uint32Type  = &primitiveType{"uint32", "UInt32", 7, "IL_UINT32_TAG_ID_SIZE", "rand.Uint32()"}
+	int64Type   = &primitiveType{"int64", "Int64", 8, "IL_INT64_TAG_ID_SIZE", "rand.Int63()"}
+	uint64Type  = &primitiveType{"uint64", "UInt64", 9, "IL_UINT64_TAG_ID_SIZE", "rand.Uint64()"}
+	ilintType   = &primitiveType{"uint64", "ILInt", 10, "", "rand.Uint64()"}
+	float32Type = &primitiveType{"float32", "Float32", 11, "IL_BIN32_TAG_ID_SIZE", "rand.Float32()"}
+	float64Type = &primitiveType{"float64", "Float64", 12, "IL_BIN64_TAG_ID_SIZE", "rand.Float64()"}
+	signedType  = &primitiveType{"int64", "SignedILInt", 14, "", "rand.Int63() - rand.Int63()"}
+	bytesType   = &primitiveType{"[]byte", "Bytes", 16, "", "tagtest.FillSeq(make([]byte, 1+rand.Intn(64)))"}
+	stringType  = &primitiveType{"string", "String", 17, "", "tagtest.GenerateRandomString()"}
+)
+
+// Maps the Go types into their primitive types.
+var primitiveTypes = map[string]*primitiveType{
+	"bool":    boolType,
+	"int8":    int8Type,
+	"uint8":   uint8Type,
+	"byte":    uint8Type,
+	"int16":   int16Type,
+	"uint16":  uint16Type,
+	"int32":   int32Type,
+	"rune":    int32Type,
+	"uint32":  uint32Type,
+	"int64":   int64Type,
+	"uint64":  uint64Type,
+	"float32": float32Type,
+	"float64": float64Type,
+	"string":  stringType,
+}
+
+// Go types that cannot be used because their sizes depends on the platform.
+var unsupportedTypes = map[string]bool{
+	"int":        true,
+	"uint":       true,
+	"uintptr":    true,
+	"complex64":  true,
+	"complex128": true,
+}
+
+// Field names that would conflict with the methods of tags.ILTagHeaderImpl.
+var reservedFieldNames = map[string]bool{
+	"Id":       true,
+	"SetId":    true,
+	"Implicit": true,
+	"Reserved": true,
+}
+
+// Kind of the field.
+type fieldKind int
+
+const (
+	// The field is serialized by the direct package.
+	primitiveField fieldKind = iota
+	// The field is a tag stored by value.
+	tagField
+	// The field is a pointer to a tag. It may be nil.
+	tagPointerField
+)
+
+/*
+Describes a field of the payload.
+*/
+type fieldDef struct {
+	// Name of the field.
+	name string
+	// Kind of the field.
+	kind fieldKind
+	// Primitive type of the field. Used only by primitive fields.
+	prim *primitiveType
+	// If true, the tag ID was set.
+	hasId bool
+	// The tag ID.
+	id uint64
+	// Type of the tag, without the pointer. Used only by tag fields.
+	tagType string
+	// If true, nil values are encoded as ILNullTags. Used only by pointers to tags.
+	omitNull bool
+}
+
+// Returns true if the primitive field uses the standard tag ID.
+func (f *fieldDef) standard() bool {
+	return f.id == f.prim.stdId
+}
+
+/*
+Describes a struct that will have its payload methods generated.
+*/
+type payloadDef struct {
+	// Name of the struct.
+	name string
+	// Default tag ID.
+	id uint64
+	// If true, the struct implements ext.VersionedPayloadData.
+	versioned bool
+	// Version of the serialization.
+	version uint16
+	// If true, uses ext.IL2VersionedPayloadTag instead of ext.VersionedPayloadTag.
+	il2 bool
+	// List of fields.
+	fields []*fieldDef
+	// Imports required by the fields, indexed by their names.
+	imports map[string]string
+}
+
+/*
+Describes a package with structs marked for generation.
+*/
+type packageDef struct {
+	// Name of the package.
+	name string
+	// List of payloads sorted by their names.
+	payloads []*payloadDef
+}
+
+/*
+Parses the arguments of the iltaggen directive. The format of the directive is:
+
+	//iltaggen:tag <id> [version=<version>] [format=il2|plain]
+*/
+func parseDirective(text string) (*payloadDef, error) {
+	args := strings.Fields(strings.TrimPrefix(text, directivePrefix))
+	if len(args) == 0 {
+		return nil, fmt.Errorf("the tag id is missing")
+	}
+	var p payloadDef
+	id, err := strconv.ParseUint(args[0], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag id %q", args[0])
+	}
+	if id < 16 {
+		return nil, fmt.Errorf("the tag id %d is reserved for implicit tags", id)
+	}
+	p.id = id
+	p.il2 = true
+	format := false
+	for _, arg := range args[1:] {
+		switch {
+		case strings.HasPrefix(arg, "version="):
+			v, err := strconv.ParseUint(arg[len("version="):], 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version %q", arg)
+			}
+			p.versioned = true
+			p.version = uint16(v)
+		case arg == "format=il2":
+			format = true
+		case arg == "format=plain":
+			format = true
+			p.il2 = false
+		default:
+			return nil, fmt.Errorf("unknown directive argument %q", arg)
+		}
+	}
+	if format && !p.versioned {
+		return nil, fmt.Errorf("format requires a version")
+	}
+	return &p, nil
+}
+
+/*
+Returns the text of the iltaggen directive found in the given comments. It
+returns an empty string if the directive is not present.
+*/
+func findDirective(groups ...*ast.CommentGroup) string {
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		for _, c := range g.List {
+			if c.Text == directivePrefix || strings.HasPrefix(c.Text, directivePrefix+" ") {
+				return c.Text
+			}
+		}
+	}
+	return ""
+}
+
+/*
+Parses the iltag struct tag of a field. It returns the tag ID, true if it was
+set, true if omitnull was set and true if the field must be skipped.
+*/
+func parseFieldTag(field *ast.Field) (id uint64, hasId bool, omitNull bool, skip bool, err error) {
+	if field.Tag == nil {
+		return 0, false, false, false, nil
+	}
+	raw, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return 0, false, false, false, err
+	}
+	tag := reflect.StructTag(raw).Get(structTagName)
+	if tag == "-" {
+		return 0, false, false, true, nil
+	}
+	parts := strings.Split(tag, ",")
+	if s := strings.TrimSpace(parts[0]); s != "" {
+		if id, err = strconv.ParseUint(s, 0, 64); err != nil {
+			return 0, false, false, false, fmt.Errorf("invalid tag id %q", s)
+		}
+		hasId = true
+	}
+	for _, p := range parts[1:] {
+		switch strings.TrimSpace(p) {
+		case "omitnull":
+			omitNull = true
+		default:
+			return 0, false, false, false, fmt.Errorf("unsupported option %q", p)
+		}
+	}
+	return id, hasId, omitNull, false, nil
+}
+
+/*
+Returns the name of the package referenced by an import spec.
+*/
+func importName(spec *ast.ImportSpec) (name string, path string) {
+	path, _ = strconv.Unquote(spec.Path.Value)
+	if spec.Name != nil {
+		return spec.Name.Name, path
+	}
+	return path[strings.LastIndex(path, "/")+1:], path
+}
+
+/*
+Returns the source representation of a named type. It returns an empty string
+if the expression is not a named type.
+*/
+func typeName(expr ast.Expr, imports map[string]string, used map[string]string) (string, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name, nil
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		if !ok {
+			return "", nil
+		}
+		path, ok := imports[pkg.Name]
+		if !ok {
+			return "", fmt.Errorf("unknown package %q", pkg.Name)
+		}
+		used[pkg.Name] = path
+		return pkg.Name + "." + t.Sel.Name, nil
+	default:
+		return "", nil
+	}
+}
+
+/*
+Creates the definition of a field.
+*/
+func parseField(p *payloadDef, name string, field *ast.Field, imports map[string]string) (*fieldDef, error) {
+	id, hasId, omitNull, skip, err := parseFieldTag(field)
+	if err != nil || skip {
+		return nil, err
+	}
+	f := &fieldDef{name: name, hasId: hasId, id: id, omitNull: omitNull}
+	if omitNull {
+		if _, ok := field.Type.(*ast.StarExpr); !ok {
+			return nil, fmt.Errorf("the omitnull option requires a pointer to a tag")
+		}
+	}
+	expr := field.Type
+	if arr, ok := expr.(*ast.ArrayType); ok {
+		if elem, ok := arr.Elt.(*ast.Ident); ok && arr.Len == nil &&
+			(elem.Name == "byte" || elem.Name == "uint8") {
+			f.kind = primitiveField
+			f.prim = bytesType
+			return f, f.checkPrimitiveId()
+		}
+		return nil, fmt.Errorf("unsupported type")
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		if unsupportedTypes[ident.Name] {
+			return nil, fmt.Errorf("unsupported type %s", ident.Name)
+		}
+		if prim, ok := primitiveTypes[ident.Name]; ok {
+			f.kind = primitiveField
+			f.prim = prim
+			return f, f.checkPrimitiveId()
+		}
+	}
+	f.kind = tagField
+	if star, ok := expr.(*ast.StarExpr); ok {
+		f.kind = tagPointerField
+		expr = star.X
+		if !hasId {
+			return nil, fmt.Errorf("pointers to tags require a tag id")
+		}
+	}
+	if f.tagType, err = typeName(expr, imports, p.imports); err != nil {
+		return nil, err
+	}
+	if f.tagType == "" {
+		return nil, fmt.Errorf("unsupported type")
+	}
+	return f, nil
+}
+
+/*
+Checks the tag ID of a primitive field and selects the alternative encodings
+when required.
+*/
+func (f *fieldDef) checkPrimitiveId() error {
+	if !f.hasId {
+		f.id = f.prim.stdId
+		return nil
+	}
+	if f.id >= 32 || f.id == f.prim.stdId {
+		return nil
+	}
+	switch {
+	case f.prim == uint64Type && f.id == ilintType.stdId:
+		f.prim = ilintType
+		return nil
+	case f.prim == int64Type && f.id == signedType.stdId:
+		f.prim = signedType
+		return nil
+	}
+	return fmt.Errorf("the reserved tag id %d cannot be used by %s", f.id, f.prim.goType)
+}
+
+/*
+Creates the definition of the payload of a struct.
+*/
+func parseStruct(p *payloadDef, st *ast.StructType, imports map[string]string) error {
+	p.imports = make(map[string]string)
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			return fmt.Errorf("embedded fields are not supported")
+		}
+		for _, n := range field.Names {
+			if n.Name == "_" || !n.IsExported() {
+				continue
+			}
+			if !p.versioned && reservedFieldNames[n.Name] {
+				return fmt.Errorf("field %s: name conflicts with the tag header", n.Name)
+			}
+			f, err := parseField(p, n.Name, field, imports)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", n.Name, err)
+			}
+			if f != nil {
+				p.fields = append(p.fields, f)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Extracts the definitions of the payloads from a parsed source file.
+*/
+func parseFile(fset *token.FileSet, file *ast.File) ([]*payloadDef, error) {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		name, path := importName(spec)
+		imports[name] = path
+	}
+	var ret []*payloadDef
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			var directive string
+			if len(gen.Specs) == 1 {
+				directive = findDirective(gen.Doc, ts.Doc)
+			} else {
+				directive = findDirective(ts.Doc)
+			}
+			if directive == "" {
+				continue
+			}
+			pos := fset.Position(ts.Pos())
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || ts.TypeParams != nil {
+				return nil, fmt.Errorf("%s: %s is not a non generic struct", pos, ts.Name.Name)
+			}
+			p, err := parseDirective(directive)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", pos, ts.Name.Name, err)
+			}
+			p.name = ts.Name.Name
+			if err := parseStruct(p, st, imports); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", pos, ts.Name.Name, err)
+			}
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+/*
+Parses the given source files and extracts all structs marked with the
+iltaggen directive. All files must belong to the same package.
+*/
+func parseFiles(files []string) (*packageDef, error) {
+	fset := token.NewFileSet()
+	var pkg packageDef
+	for _, name := range files {
+		file, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = file.Name.Name
+		} else if pkg.name != file.Name.Name {
+			return nil, fmt.Errorf("%s: package %s, expected %s", name,
+				file.Name.Name, pkg.name)
+		}
+		payloads, err := parseFile(fset, file)
+		if err != nil {
+			return nil, err
+		}
+		pkg.payloads = append(pkg.payloads, payloads...)
+	}
+	sort.Slice(pkg.payloads, func(i, j int) bool {
+		return pkg.payloads[i].name < pkg.payloads[j].name
+	})
+	return &pkg, nil
+}
+
+/*
+Returns the list of Go source files of the package found in the given
+directory. Test files and the files listed in exclude are ignored.
+*/
+func listSourceFiles(dir string, exclude ...string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, f := range files {
+		base := filepath.Base(f)
+		if strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		skip := false
+		for _, e := range exclude {
+			if base == filepath.Base(e) {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			ret = append(ret, f)
+		}
+	}
+	return ret, nil
+}
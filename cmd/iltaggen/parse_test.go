@@ -0,0 +1,201 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestSource(t *testing.T, src string) ([]*payloadDef, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	require.Nil(t, err)
+	return parseFile(fset, file)
+}
+
+func TestParseDirective(t *testing.T) {
+	p, err := parseDirective("//iltaggen:tag 1000")
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1000), p.id)
+	assert.False(t, p.versioned)
+
+	p, err = parseDirective("//iltaggen:tag 0x20 version=3")
+	require.Nil(t, err)
+	assert.Equal(t, uint64(32), p.id)
+	assert.True(t, p.versioned)
+	assert.Equal(t, uint16(3), p.version)
+	assert.True(t, p.il2)
+
+	p, err = parseDirective("//iltaggen:tag 1000 version=1 format=plain")
+	require.Nil(t, err)
+	assert.False(t, p.il2)
+	p, err = parseDirective("//iltaggen:tag 1000 format=il2 version=1")
+	require.Nil(t, err)
+	assert.True(t, p.il2)
+
+	for _, s := range []string{
+		"//iltaggen:tag",
+		"//iltaggen:tag x",
+		"//iltaggen:tag 15",
+		"//iltaggen:tag 1000 version=65536",
+		"//iltaggen:tag 1000 format=plain",
+		"//iltaggen:tag 1000 unknown",
+	} {
+		_, err = parseDirective(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	payloads, err := parseTestSource(t, `package x
+
+import (
+	tg "github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// Not marked.
+type Other struct {
+	A int
+}
+
+// A marked struct.
+//iltaggen:tag 1000
+type Marked struct {
+	A bool
+	B byte
+	C []uint8
+	D uint64 `+"`iltag:\"10\"`"+`
+	E int64  `+"`iltag:\"14\"`"+`
+	F uint64 `+"`iltag:\"9\"`"+`
+	G string `+"`iltag:\"1001\"`"+`
+	H tg.StringTag
+	I *tg.StringTag `+"`iltag:\"17,omitnull\"`"+`
+	J Local `+"`iltag:\"1002\"`"+`
+	K string `+"`iltag:\"-\"`"+`
+	l string
+	_ struct{}
+}
+
+type (
+	//iltaggen:tag 1001 version=2
+	Versioned struct {
+		Id uint64
+	}
+)
+`)
+	require.Nil(t, err)
+	require.Len(t, payloads, 2)
+
+	p := payloads[0]
+	assert.Equal(t, "Marked", p.name)
+	assert.Equal(t, uint64(1000), p.id)
+	assert.Equal(t, map[string]string{"tg": "github.com/interlockledger/go-iltags/tags/impl"}, p.imports)
+	require.Len(t, p.fields, 10)
+	assert.Equal(t, &fieldDef{name: "A", kind: primitiveField, prim: boolType, id: 1}, p.fields[0])
+	assert.Equal(t, &fieldDef{name: "B", kind: primitiveField, prim: uint8Type, id: 3}, p.fields[1])
+	assert.Equal(t, &fieldDef{name: "C", kind: primitiveField, prim: bytesType, id: 16}, p.fields[2])
+	assert.Equal(t, &fieldDef{name: "D", kind: primitiveField, prim: ilintType, hasId: true, id: 10}, p.fields[3])
+	assert.Equal(t, &fieldDef{name: "E", kind: primitiveField, prim: signedType, hasId: true, id: 14}, p.fields[4])
+	assert.Equal(t, &fieldDef{name: "F", kind: primitiveField, prim: uint64Type, hasId: true, id: 9}, p.fields[5])
+	assert.Equal(t, &fieldDef{name: "G", kind: primitiveField, prim: stringType, hasId: true, id: 1001}, p.fields[6])
+	assert.False(t, p.fields[6].standard())
+	assert.Equal(t, &fieldDef{name: "H", kind: tagField, tagType: "tg.StringTag"}, p.fields[7])
+	assert.Equal(t, &fieldDef{name: "I", kind: tagPointerField, hasId: true, id: 17, tagType: "tg.StringTag",
+		omitNull: true}, p.fields[8])
+	assert.Equal(t, &fieldDef{name: "J", kind: tagField, hasId: true, id: 1002, tagType: "Local"}, p.fields[9])
+
+	p = payloads[1]
+	assert.Equal(t, "Versioned", p.name)
+	assert.True(t, p.versioned)
+	assert.Equal(t, uint16(2), p.version)
+	assert.Equal(t, &fieldDef{name: "Id", kind: primitiveField, prim: uint64Type, id: 9}, p.fields[0])
+}
+
+func TestParseFileErrors(t *testing.T) {
+	for _, body := range []string{
+		"A int",
+		"A uint",
+		"A []int",
+		"A [4]byte",
+		"A map[string]string",
+		"A bool `iltag:\"2\"`",
+		"A uint32 `iltag:\"10\"`",
+		"A int64 `iltag:\"10\"`",
+		"A bool `iltag:\"x\"`",
+		"A bool `iltag:\",elem=3\"`",
+		"A *Local",
+		"A bool `iltag:\",omitnull\"`",
+		"A Local `iltag:\"1002,omitnull\"`",
+		"A *Local `iltag:\"1002,omitempty\"`",
+		"A unknown.Tag",
+		"Local",
+		"Id uint64",
+		"A func()",
+	} {
+		_, err := parseTestSource(t, "package x\n\n//iltaggen:tag 1000\ntype X struct {\n"+
+			body+"\n}\n")
+		assert.Error(t, err, body)
+	}
+
+	_, err := parseTestSource(t, "package x\n\n//iltaggen:tag 1000\ntype X int\n")
+	assert.Error(t, err)
+	_, err = parseTestSource(t, "package x\n\n//iltaggen:tag 1000\ntype X[T any] struct {}\n")
+	assert.Error(t, err)
+	_, err = parseTestSource(t, "package x\n\n//iltaggen:tag\ntype X struct {}\n")
+	assert.Error(t, err)
+}
+
+func TestParseFiles(t *testing.T) {
+	files, err := listSourceFiles("internal/example", "example_iltags.go")
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+
+	pkg, err := parseFiles(files)
+	require.Nil(t, err)
+	assert.Equal(t, "example", pkg.name)
+	require.Len(t, pkg.payloads, 4)
+	assert.Equal(t, "Empty", pkg.payloads[0].name)
+	assert.Equal(t, "Event", pkg.payloads[1].name)
+	assert.Equal(t, "Marker", pkg.payloads[2].name)
+	assert.Equal(t, "Record", pkg.payloads[3].name)
+
+	_, err = parseFiles([]string{"internal/example/missing.go"})
+	assert.Error(t, err)
+	_, err = parseFiles([]string{"main.go", "internal/example/example.go"})
+	assert.Error(t, err)
+}
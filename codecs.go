@@ -0,0 +1,783 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltags
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+The decoder holds the state shared by all codecs during the decoding of a tag.
+*/
+type decoder struct {
+	// The factory used to decode the payload of raw tags.
+	factory tags.ILTagFactory
+}
+
+/*
+Loads the contents of src into dst. The ID of both tags must match.
+
+If both tags have the same type, the value of src is copied into dst. Otherwise
+the payload of src is deserialized into dst. It allows the conversion of the
+RawTags created by the factory for unknown tag IDs into the expected tag type.
+*/
+func (d *decoder) adapt(src tags.ILTag, dst tags.ILTag) error {
+	if src.Id() != dst.Id() {
+		return tags.NewErrUnexpectedTagId(dst.Id(), src.Id())
+	}
+	sv := reflect.ValueOf(src)
+	dv := reflect.ValueOf(dst)
+	if sv.Type() == dv.Type() {
+		dv.Elem().Set(sv.Elem())
+		return nil
+	}
+	var payload []byte
+	if raw, ok := src.(*tags.RawTag); ok {
+		payload = raw.Payload
+	} else {
+		w := bytes.NewBuffer(make([]byte, 0, int(src.ValueSize())))
+		if err := src.SerializeValue(w); err != nil {
+			return err
+		}
+		payload = w.Bytes()
+	}
+	size := len(payload)
+	if dst.Id() == tags.IL_ILINT_TAG_ID || dst.Id() == tags.IL_SIGNED_ILINT_TAG_ID {
+		size = -1
+	}
+	r := io.LimitedReader{R: bytes.NewReader(payload), N: int64(len(payload))}
+	if err := dst.DeserializeValue(d.factory, size, &r); err != nil {
+		return err
+	}
+	if r.N != 0 {
+		return tags.ErrBadTagFormat
+	}
+	return nil
+}
+
+// Creates a new standard null tag.
+func newNullTag() tags.ILTag {
+	return impl.NewStdNullTag()
+}
+
+// Returns true if id is the standard ID or a non reserved ID.
+func acceptsStdOrCustom(std tags.TagID, id tags.TagID) bool {
+	return id == std || !id.Reserved()
+}
+
+// Returns a pointer to the value of v. It creates a copy of v if it is not
+// addressable.
+func addressOf(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of the tags.ILTag interface.
+type ilTagCodec struct{}
+
+func (c *ilTagCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_NULL_TAG_ID, true
+}
+
+func (c *ilTagCodec) acceptsId(id tags.TagID) bool {
+	return false
+}
+
+func (c *ilTagCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := v.Interface().(tags.ILTag)
+	if tags.IsILTagNil(t) {
+		return newNullTag(), nil
+	}
+	return t, nil
+}
+
+func (c *ilTagCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	v.Set(reflect.ValueOf(tag))
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Interface of the tags that allow the change of its ID.
+type idSetter interface {
+	SetId(id tags.TagID)
+}
+
+// Codec of structs that implement tags.ILTag.
+type tagCodec struct{}
+
+func (c *tagCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_NULL_TAG_ID, true
+}
+
+func (c *tagCodec) acceptsId(id tags.TagID) bool {
+	return true
+}
+
+func (c *tagCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	var p reflect.Value
+	if el.explicitId {
+		// Always use a copy to avoid changes on the original value
+		p = reflect.New(v.Type())
+		p.Elem().Set(v)
+		s, ok := p.Interface().(idSetter)
+		if !ok {
+			return nil, fmt.Errorf("type %s does not allow the change of its ID: %w", v.Type(), ErrBadStructTag)
+		}
+		s.SetId(el.id)
+	} else {
+		p = addressOf(v)
+	}
+	return p.Interface().(tags.ILTag), nil
+}
+
+func (c *tagCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	dst := v.Addr().Interface().(tags.ILTag)
+	if s, ok := dst.(idSetter); ok {
+		if el.explicitId {
+			s.SetId(el.id)
+		} else {
+			s.SetId(tag.Id())
+		}
+	}
+	return d.adapt(tag, dst)
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of pointers.
+type pointerCodec struct {
+	elem codec
+}
+
+func (c *pointerCodec) defaultId() (tags.TagID, bool) {
+	return c.elem.defaultId()
+}
+
+func (c *pointerCodec) acceptsId(id tags.TagID) bool {
+	return c.elem.acceptsId(id)
+}
+
+func (c *pointerCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	return c.elem.encode(el, v.Elem())
+}
+
+func (c *pointerCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return c.elem.decode(d, el, tag, v.Elem())
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of bool.
+type boolCodec struct{}
+
+func (c *boolCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_BOOL_TAG_ID, true
+}
+
+func (c *boolCodec) acceptsId(id tags.TagID) bool {
+	return acceptsStdOrCustom(tags.IL_BOOL_TAG_ID, id)
+}
+
+func (c *boolCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := impl.NewBoolTag(el.id)
+	t.Payload = v.Bool()
+	return t, nil
+}
+
+func (c *boolCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := impl.NewBoolTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	v.SetBool(t.Payload)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of signed integers.
+type intCodec struct {
+	bits int
+}
+
+func (c *intCodec) defaultId() (tags.TagID, bool) {
+	switch c.bits {
+	case 8:
+		return tags.IL_INT8_TAG_ID, true
+	case 16:
+		return tags.IL_INT16_TAG_ID, true
+	case 32:
+		return tags.IL_INT32_TAG_ID, true
+	default:
+		return tags.IL_INT64_TAG_ID, true
+	}
+}
+
+func (c *intCodec) acceptsId(id tags.TagID) bool {
+	def, _ := c.defaultId()
+	return id == tags.IL_SIGNED_ILINT_TAG_ID || acceptsStdOrCustom(def, id)
+}
+
+// Creates the tag used to store the value.
+func (c *intCodec) newTag(id tags.TagID) tags.ILTag {
+	if id == tags.IL_SIGNED_ILINT_TAG_ID {
+		return impl.NewSignedILIntTag(id)
+	}
+	switch c.bits {
+	case 8:
+		return impl.NewInt8Tag(id)
+	case 16:
+		return impl.NewInt16Tag(id)
+	case 32:
+		return impl.NewInt32Tag(id)
+	default:
+		return impl.NewInt64Tag(id)
+	}
+}
+
+func (c *intCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := c.newTag(el.id)
+	x := v.Int()
+	switch t := t.(type) {
+	case *impl.SignedILIntTag:
+		t.Payload = x
+	case *impl.Int8Tag:
+		t.Payload = int8(x)
+	case *impl.Int16Tag:
+		t.Payload = int16(x)
+	case *impl.Int32Tag:
+		t.Payload = int32(x)
+	case *impl.Int64Tag:
+		t.Payload = x
+	}
+	return t, nil
+}
+
+func (c *intCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := c.newTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	var x int64
+	switch t := t.(type) {
+	case *impl.SignedILIntTag:
+		x = t.Payload
+	case *impl.Int8Tag:
+		x = int64(t.Payload)
+	case *impl.Int16Tag:
+		x = int64(t.Payload)
+	case *impl.Int32Tag:
+		x = int64(t.Payload)
+	case *impl.Int64Tag:
+		x = t.Payload
+	}
+	if v.OverflowInt(x) {
+		return fmt.Errorf("%d does not fit into %s: %w", x, v.Type(), ErrValueOutOfRange)
+	}
+	v.SetInt(x)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of unsigned integers.
+type uintCodec struct {
+	bits int
+}
+
+func (c *uintCodec) defaultId() (tags.TagID, bool) {
+	switch c.bits {
+	case 8:
+		return tags.IL_UINT8_TAG_ID, true
+	case 16:
+		return tags.IL_UINT16_TAG_ID, true
+	case 32:
+		return tags.IL_UINT32_TAG_ID, true
+	default:
+		return tags.IL_UINT64_TAG_ID, true
+	}
+}
+
+func (c *uintCodec) acceptsId(id tags.TagID) bool {
+	def, _ := c.defaultId()
+	return id == tags.IL_ILINT_TAG_ID || acceptsStdOrCustom(def, id)
+}
+
+// Creates the tag used to store the value.
+func (c *uintCodec) newTag(id tags.TagID) tags.ILTag {
+	if id == tags.IL_ILINT_TAG_ID {
+		return impl.NewILIntTag(id)
+	}
+	switch c.bits {
+	case 8:
+		return impl.NewUInt8Tag(id)
+	case 16:
+		return impl.NewUInt16Tag(id)
+	case 32:
+		return impl.NewUInt32Tag(id)
+	default:
+		return impl.NewUInt64Tag(id)
+	}
+}
+
+func (c *uintCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := c.newTag(el.id)
+	x := v.Uint()
+	switch t := t.(type) {
+	case *impl.ILIntTag:
+		t.Payload = x
+	case *impl.UInt8Tag:
+		t.Payload = uint8(x)
+	case *impl.UInt16Tag:
+		t.Payload = uint16(x)
+	case *impl.UInt32Tag:
+		t.Payload = uint32(x)
+	case *impl.UInt64Tag:
+		t.Payload = x
+	}
+	return t, nil
+}
+
+func (c *uintCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := c.newTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	var x uint64
+	switch t := t.(type) {
+	case *impl.ILIntTag:
+		x = t.Payload
+	case *impl.UInt8Tag:
+		x = uint64(t.Payload)
+	case *impl.UInt16Tag:
+		x = uint64(t.Payload)
+	case *impl.UInt32Tag:
+		x = uint64(t.Payload)
+	case *impl.UInt64Tag:
+		x = t.Payload
+	}
+	if v.OverflowUint(x) {
+		return fmt.Errorf("%d does not fit into %s: %w", x, v.Type(), ErrValueOutOfRange)
+	}
+	v.SetUint(x)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of floating point values.
+type floatCodec struct {
+	bits int
+}
+
+func (c *floatCodec) defaultId() (tags.TagID, bool) {
+	if c.bits == 32 {
+		return tags.IL_BIN32_TAG_ID, true
+	} else {
+		return tags.IL_BIN64_TAG_ID, true
+	}
+}
+
+func (c *floatCodec) acceptsId(id tags.TagID) bool {
+	def, _ := c.defaultId()
+	return acceptsStdOrCustom(def, id)
+}
+
+func (c *floatCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	if c.bits == 32 {
+		t := impl.NewFloat32Tag(el.id)
+		t.Payload = float32(v.Float())
+		return t, nil
+	} else {
+		t := impl.NewFloat64Tag(el.id)
+		t.Payload = v.Float()
+		return t, nil
+	}
+}
+
+func (c *floatCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	if c.bits == 32 {
+		t := impl.NewFloat32Tag(el.id)
+		if err := d.adapt(tag, t); err != nil {
+			return err
+		}
+		v.SetFloat(float64(t.Payload))
+	} else {
+		t := impl.NewFloat64Tag(el.id)
+		if err := d.adapt(tag, t); err != nil {
+			return err
+		}
+		v.SetFloat(t.Payload)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of strings.
+type stringCodec struct{}
+
+func (c *stringCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_STRING_TAG_ID, true
+}
+
+func (c *stringCodec) acceptsId(id tags.TagID) bool {
+	return acceptsStdOrCustom(tags.IL_STRING_TAG_ID, id)
+}
+
+func (c *stringCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := impl.NewStringTag(el.id)
+	t.Payload = v.String()
+	return t, nil
+}
+
+func (c *stringCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := impl.NewStringTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	v.SetString(t.Payload)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of byte slices and byte arrays.
+type bytesCodec struct{}
+
+func (c *bytesCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_BYTES_TAG_ID, true
+}
+
+func (c *bytesCodec) acceptsId(id tags.TagID) bool {
+	return acceptsStdOrCustom(tags.IL_BYTES_TAG_ID, id)
+}
+
+func (c *bytesCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := impl.NewBytesTag(el.id)
+	if v.Kind() == reflect.Array {
+		t.Payload = make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(t.Payload), v)
+	} else {
+		t.Payload = v.Bytes()
+	}
+	return t, nil
+}
+
+func (c *bytesCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := impl.NewBytesTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Array {
+		if v.Len() != len(t.Payload) {
+			return fmt.Errorf("%d bytes do not fit into %s: %w", len(t.Payload), v.Type(), ErrValueOutOfRange)
+		}
+		reflect.Copy(v, reflect.ValueOf(t.Payload))
+	} else {
+		b := reflect.MakeSlice(v.Type(), len(t.Payload), len(t.Payload))
+		reflect.Copy(b, reflect.ValueOf(t.Payload))
+		v.Set(b)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of big.Int.
+type bigIntCodec struct{}
+
+func (c *bigIntCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_BINT_TAG_ID, true
+}
+
+func (c *bigIntCodec) acceptsId(id tags.TagID) bool {
+	return acceptsStdOrCustom(tags.IL_BINT_TAG_ID, id)
+}
+
+func (c *bigIntCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := impl.NewBigIntTag(el.id)
+	t.SetBigInt(addressOf(v).Interface().(*big.Int))
+	return t, nil
+}
+
+func (c *bigIntCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := impl.NewBigIntTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	v.Addr().Interface().(*big.Int).Set(t.BigInt())
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of time.Time.
+type timeCodec struct{}
+
+func (c *timeCodec) defaultId() (tags.TagID, bool) {
+	return 0, false
+}
+
+func (c *timeCodec) acceptsId(id tags.TagID) bool {
+	return !id.Implicit()
+}
+
+func (c *timeCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := ext.NewTimestapTag(el.id)
+	t.SetTimestamp(v.Interface().(time.Time))
+	return t, nil
+}
+
+func (c *timeCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := ext.NewTimestapTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t.GetTimestamp()))
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of slices and arrays.
+type sliceCodec struct {
+	item *element
+}
+
+func (c *sliceCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_ILTAGARRAY_TAG_ID, true
+}
+
+func (c *sliceCodec) acceptsId(id tags.TagID) bool {
+	return acceptsStdOrCustom(tags.IL_ILTAGARRAY_TAG_ID, id)
+}
+
+func (c *sliceCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	item := el.itemElement(c.item)
+	t := impl.NewILTagArrayTag(el.id)
+	t.Payload = make([]tags.ILTag, v.Len())
+	for i := range t.Payload {
+		e, err := item.encodeValue(v.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		t.Payload[i] = e
+	}
+	return t, nil
+}
+
+func (c *sliceCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	item := el.itemElement(c.item)
+	t := impl.NewILTagArrayTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	n := len(t.Payload)
+	target := v
+	if v.Kind() == reflect.Array {
+		if v.Len() != n {
+			return fmt.Errorf("%d elements do not fit into %s: %w", n, v.Type(), ErrValueOutOfRange)
+		}
+	} else {
+		target = reflect.MakeSlice(v.Type(), n, n)
+	}
+	for i, e := range t.Payload {
+		if err := item.decodeValue(d, e, target.Index(i)); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	if v.Kind() != reflect.Array {
+		v.Set(target)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Codec of maps with string keys.
+type mapCodec struct {
+	// If true, the values are strings and can be stored as a
+	// StringDictionaryTag.
+	stringValues bool
+	item         *element
+}
+
+func (c *mapCodec) defaultId() (tags.TagID, bool) {
+	return tags.IL_DICTIONARY_TAG_ID, true
+}
+
+func (c *mapCodec) acceptsId(id tags.TagID) bool {
+	return (c.stringValues && id == tags.IL_STRING_DICTIONARY_TAG_ID) ||
+		acceptsStdOrCustom(tags.IL_DICTIONARY_TAG_ID, id)
+}
+
+// Returns the keys of the map sorted in order to produce deterministic
+// serializations.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func (c *mapCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	keys := sortedMapKeys(v)
+	if el.id == tags.IL_STRING_DICTIONARY_TAG_ID {
+		t := impl.NewStringDictionaryTag(el.id)
+		for _, k := range keys {
+			t.Map.Put(k.String(), v.MapIndex(k).String())
+		}
+		return t, nil
+	}
+	item := el.itemElement(c.item)
+	t := impl.NewDictionaryTag(el.id)
+	for _, k := range keys {
+		e, err := item.encodeValue(v.MapIndex(k))
+		if err != nil {
+			return nil, fmt.Errorf("[%q]: %w", k.String(), err)
+		}
+		t.Map.Put(k.String(), e)
+	}
+	return t, nil
+}
+
+func (c *mapCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	m := reflect.MakeMap(v.Type())
+	keyType := v.Type().Key()
+	valueType := v.Type().Elem()
+	if el.id == tags.IL_STRING_DICTIONARY_TAG_ID {
+		t := impl.NewStringDictionaryTag(el.id)
+		if err := d.adapt(tag, t); err != nil {
+			return err
+		}
+		for _, e := range t.Map.Entries() {
+			m.SetMapIndex(reflect.ValueOf(e.Key).Convert(keyType),
+				reflect.ValueOf(e.Value).Convert(valueType))
+		}
+	} else {
+		item := el.itemElement(c.item)
+		t := impl.NewDictionaryTag(el.id)
+		if err := d.adapt(tag, t); err != nil {
+			return err
+		}
+		for _, e := range t.Map.Entries() {
+			value := reflect.New(valueType).Elem()
+			if err := item.decodeValue(d, e.Value, value); err != nil {
+				return fmt.Errorf("[%q]: %w", e.Key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(e.Key).Convert(keyType), value)
+		}
+	}
+	v.Set(m)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Field of a struct.
+type structField struct {
+	name    string
+	index   int
+	element *element
+}
+
+// Codec of structs.
+type structCodec struct {
+	// If true, the struct has its own default tag ID.
+	hasId  bool
+	id     tags.TagID
+	fields []structField
+}
+
+func (c *structCodec) defaultId() (tags.TagID, bool) {
+	if c.hasId {
+		return c.id, true
+	}
+	return tags.IL_ILTAGSEQ_TAG_ID, true
+}
+
+func (c *structCodec) acceptsId(id tags.TagID) bool {
+	return acceptsStdOrCustom(tags.IL_ILTAGSEQ_TAG_ID, id)
+}
+
+func (c *structCodec) encode(el *element, v reflect.Value) (tags.ILTag, error) {
+	t := impl.NewILTagSequenceTag(el.id)
+	t.Payload = make([]tags.ILTag, len(c.fields))
+	for i, f := range c.fields {
+		e, err := f.element.encodeValue(v.Field(f.index))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		t.Payload[i] = e
+	}
+	return t, nil
+}
+
+func (c *structCodec) decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error {
+	t := impl.NewILTagSequenceTag(el.id)
+	if err := d.adapt(tag, t); err != nil {
+		return err
+	}
+	if len(t.Payload) != len(c.fields) {
+		return fmt.Errorf("expecting %d fields for %s but got %d: %w",
+			len(c.fields), v.Type(), len(t.Payload), tags.ErrBadTagFormat)
+	}
+	for i, f := range c.fields {
+		if err := f.element.decodeValue(d, t.Payload[i], v.Field(f.index)); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
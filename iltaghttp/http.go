@@ -0,0 +1,244 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltaghttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/tagjson"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+const (
+	// Content type of the binary tags.
+	ContentType = "application/iltag"
+	// Content type of the JSON representation of the tags.
+	JSONContentType = "application/json"
+)
+
+// Default maximum size of the request bodies.
+const DefaultMaxBodySize int64 = 16 * 1024 * 1024
+
+var (
+	// The content type of the request is not supported.
+	ErrUnsupportedMediaType = fmt.Errorf("unsupported media type")
+	// None of the content types accepted by the client are supported.
+	ErrNotAcceptable = fmt.Errorf("not acceptable")
+)
+
+/*
+Decodes the body of the request into a tag. The body is decoded as a binary tag
+if the request has no Content-Type or if it is application/iltag and as the
+JSON representation of a tag if it is application/json. Other content types are
+rejected with ErrUnsupportedMediaType.
+
+The body must hold exactly one tag with at most maxSize bytes. If maxSize is 0,
+DefaultMaxBodySize is used. Larger bodies are rejected with
+tags.ErrTagTooLarge without being fully read. If factory is nil, a non strict
+StandardTagFactory is used.
+*/
+func ReadRequest(r *http.Request, factory tags.ILTagFactory, maxSize int64) (tags.ILTag, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBodySize
+	}
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	contentType := ContentType
+	if s := r.Header.Get("Content-Type"); s != "" {
+		t, _, err := mime.ParseMediaType(s)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", err, ErrUnsupportedMediaType)
+		}
+		contentType = t
+	}
+	if contentType != ContentType && contentType != JSONContentType {
+		return nil, fmt.Errorf("%s: %w", contentType, ErrUnsupportedMediaType)
+	}
+	if r.ContentLength > maxSize {
+		return nil, tags.ErrTagTooLarge
+	}
+	if r.Body == nil {
+		return nil, tags.ErrBadTagFormat
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, tags.ErrTagTooLarge
+	}
+	if contentType == JSONContentType {
+		return tagjson.Unmarshal(data, factory)
+	}
+	return tags.ILTagFromBytes(factory, data)
+}
+
+/*
+Returns the HTTP status code that reports the given error returned by
+ReadRequest() or Negotiate().
+*/
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrNotAcceptable):
+		return http.StatusNotAcceptable
+	case errors.Is(err, tags.ErrTagTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+/*
+Writes the tag as the body of the response using the binary format. The
+Content-Length is computed with tags.ILTagSize(), thus the tag is serialized
+directly into the response. A nil tag is written as an ILNullTag.
+*/
+func WriteTag(w http.ResponseWriter, status int, tag tags.ILTag) error {
+	var size uint64 = 1
+	if !tags.IsILTagNil(tag) {
+		size = tags.ILTagSize(tag)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Length", strconv.FormatUint(size, 10))
+	w.WriteHeader(status)
+	return tags.ILTagSeralizeWithNull(tag, w)
+}
+
+/*
+Writes the JSON representation of the tag as the body of the response. A nil
+tag is written as an ILNullTag. Nothing is written if the tag cannot be
+represented.
+*/
+func WriteJSON(w http.ResponseWriter, status int, tag tags.ILTag) error {
+	data, err := tagjson.Marshal(tag)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", JSONContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	return err
+}
+
+/*
+Parses a single media range of the Accept header. It returns the media type
+and its quality.
+*/
+func parseMediaRange(s string) (string, float64) {
+	t, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return "", 0
+	}
+	q := 1.0
+	if v, ok := params["q"]; ok {
+		if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+			return "", 0
+		}
+	}
+	return t, q
+}
+
+/*
+Selects the content type of the response based on the Accept header of the
+request. It returns ContentType or JSONContentType. The binary format is
+preferred when both are equally accepted, including when the request has no
+Accept header. It fails with ErrNotAcceptable if the client accepts none of
+them.
+*/
+func Negotiate(r *http.Request) (string, error) {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return ContentType, nil
+	}
+	// Quality of each supported type. The most specific range wins.
+	quality := map[string]float64{ContentType: -1, JSONContentType: -1}
+	specificity := map[string]int{}
+	for _, h := range accept {
+		for _, s := range strings.Split(h, ",") {
+			t, q := parseMediaRange(s)
+			for ct := range quality {
+				level := 0
+				switch {
+				case t == ct:
+					level = 3
+				case t == "application/*":
+					level = 2
+				case t == "*/*":
+					level = 1
+				default:
+					continue
+				}
+				if level > specificity[ct] {
+					specificity[ct] = level
+					quality[ct] = q
+				}
+			}
+		}
+	}
+	best := ContentType
+	if quality[JSONContentType] > quality[ContentType] {
+		best = JSONContentType
+	}
+	if quality[best] <= 0 {
+		return "", fmt.Errorf("%s: %w", strings.Join(accept, ", "), ErrNotAcceptable)
+	}
+	return best, nil
+}
+
+/*
+Writes the tag as the body of the response in the format negotiated by
+Negotiate(). If the client accepts none of the supported formats, it replies
+with http.StatusNotAcceptable instead and returns the error.
+*/
+func WriteResponse(w http.ResponseWriter, r *http.Request, status int, tag tags.ILTag) error {
+	contentType, err := Negotiate(r)
+	if err != nil {
+		http.Error(w, err.Error(), ErrorStatus(err))
+		return err
+	}
+	if contentType == JSONContentType {
+		return WriteJSON(w, status, tag)
+	}
+	return WriteTag(w, status, tag)
+}
@@ -0,0 +1,282 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltaghttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tagjson"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a new POST request with the given body and content type.
+func newTestRequest(body []byte, contentType string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+func TestReadRequest(t *testing.T) {
+	tag := impl.MustParseTag(`dict{"a": str("b"), "c": #1000 bytes(0x01)}`)
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	js, err := tagjson.Marshal(tag)
+	require.Nil(t, err)
+
+	for _, s := range []struct {
+		body        []byte
+		contentType string
+	}{
+		{bin, ""},
+		{bin, ContentType},
+		{js, JSONContentType},
+		{js, "application/json; charset=utf-8"},
+	} {
+		r, err := ReadRequest(newTestRequest(s.body, s.contentType), nil, 0)
+		require.Nil(t, err, s.contentType)
+		assert.Equal(t, impl.FormatTag(tag), impl.FormatTag(r))
+	}
+
+	// Factory
+	_, err = ReadRequest(newTestRequest(bin, ""), impl.NewStandardTagFactory(true), 0)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+	_, err = ReadRequest(newTestRequest(js, JSONContentType), impl.NewStandardTagFactory(true), 0)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+
+	// Size limits
+	r, err := ReadRequest(newTestRequest(bin, ""), nil, int64(len(bin)))
+	assert.Nil(t, err)
+	assert.NotNil(t, r)
+	_, err = ReadRequest(newTestRequest(bin, ""), nil, int64(len(bin)-1))
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	req := newTestRequest(bin, "")
+	req.ContentLength = -1
+	_, err = ReadRequest(req, nil, int64(len(bin)-1))
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	req = newTestRequest(nil, "")
+	req.ContentLength = DefaultMaxBodySize + 1
+	_, err = ReadRequest(req, nil, 0)
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+}
+
+func TestReadRequest_Errors(t *testing.T) {
+	bin := impl.MustParseTagBytes("null")
+
+	_, err := ReadRequest(newTestRequest(bin, "text/plain"), nil, 0)
+	assert.ErrorIs(t, err, ErrUnsupportedMediaType)
+	assert.EqualError(t, err, "text/plain: unsupported media type")
+	_, err = ReadRequest(newTestRequest(bin, "/"), nil, 0)
+	assert.ErrorIs(t, err, ErrUnsupportedMediaType)
+
+	_, err = ReadRequest(newTestRequest(append(bin, 0), ""), nil, 0)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	_, err = ReadRequest(newTestRequest(nil, ""), nil, 0)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	req := newTestRequest(nil, "")
+	req.Body = nil
+	_, err = ReadRequest(req, nil, 0)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	_, err = ReadRequest(newTestRequest([]byte("{"), JSONContentType), nil, 0)
+	assert.Error(t, err)
+
+	req = newTestRequest(nil, "")
+	req.Body = io.NopCloser(errReader{})
+	_, err = ReadRequest(req, nil, 0)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+// Tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
+
+type errReader struct{}
+
+func (errReader) Read(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusUnsupportedMediaType, ErrorStatus(ErrUnsupportedMediaType))
+	assert.Equal(t, http.StatusNotAcceptable, ErrorStatus(ErrNotAcceptable))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ErrorStatus(tags.ErrTagTooLarge))
+	assert.Equal(t, http.StatusBadRequest, ErrorStatus(tags.ErrBadTagFormat))
+}
+
+func TestWriteTag(t *testing.T) {
+	tag := impl.MustParseTag(`array[str("a"), #1000 bytes(0x01)]`)
+	w := httptest.NewRecorder()
+	require.Nil(t, WriteTag(w, http.StatusCreated, tag))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "11", w.Header().Get("Content-Length"))
+	assert.Equal(t, impl.MustParseTagBytes(impl.FormatTag(tag)), w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	require.Nil(t, WriteTag(w, http.StatusOK, nil))
+	assert.Equal(t, "1", w.Header().Get("Content-Length"))
+	assert.Equal(t, []byte{0}, w.Body.Bytes())
+
+	bad := &failingTag{}
+	bad.SetId(1000)
+	assert.ErrorIs(t, WriteTag(httptest.NewRecorder(), http.StatusOK, bad), io.ErrShortWrite)
+}
+
+func TestWriteJSON(t *testing.T) {
+	tag := impl.MustParseTag(`str("a")`)
+	w := httptest.NewRecorder()
+	require.Nil(t, WriteJSON(w, http.StatusOK, tag))
+	expected, _ := tagjson.Marshal(tag)
+	assert.Equal(t, JSONContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, strconv.Itoa(len(expected)), w.Header().Get("Content-Length"))
+	assert.Equal(t, expected, w.Body.Bytes())
+
+	// Cannot be represented
+	w = httptest.NewRecorder()
+	bad := &failingTag{}
+	bad.SetId(1000)
+	assert.Error(t, WriteJSON(w, http.StatusOK, bad))
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, s := range []struct {
+		accept   []string
+		expected string
+	}{
+		{nil, ContentType},
+		{[]string{"*/*"}, ContentType},
+		{[]string{"application/*"}, ContentType},
+		{[]string{"application/json"}, JSONContentType},
+		{[]string{"application/iltag"}, ContentType},
+		{[]string{"application/json, application/iltag"}, ContentType},
+		{[]string{"application/json, application/iltag;q=0.5"}, JSONContentType},
+		{[]string{"text/html", "application/json;q=0.1"}, JSONContentType},
+		{[]string{"*/*;q=0.1, application/json"}, JSONContentType},
+		{[]string{"application/*;q=0.5, application/iltag;q=0.2"}, JSONContentType},
+		{[]string{"application/json;q=0, */*"}, ContentType},
+		{[]string{"application/iltag;q=0, */*"}, JSONContentType},
+		{[]string{"bad/, application/json"}, JSONContentType},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, a := range s.accept {
+			r.Header.Add("Accept", a)
+		}
+		ct, err := Negotiate(r)
+		assert.Nil(t, err, s.accept)
+		assert.Equal(t, s.expected, ct, s.accept)
+	}
+
+	for _, accept := range []string{"text/html", "application/json;q=0, application/iltag;q=0",
+		"*/*;q=0", "application/json;q=2", "application/json;q=x"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		_, err := Negotiate(r)
+		assert.ErrorIs(t, err, ErrNotAcceptable, accept)
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	tag := impl.MustParseTag(`str("a")`)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	require.Nil(t, WriteResponse(w, r, http.StatusOK, tag))
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, impl.MustParseTagBytes(`str("a")`), w.Body.Bytes())
+
+	r.Header.Set("Accept", JSONContentType)
+	w = httptest.NewRecorder()
+	require.Nil(t, WriteResponse(w, r, http.StatusOK, tag))
+	assert.Equal(t, JSONContentType, w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "{"))
+
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	assert.ErrorIs(t, WriteResponse(w, r, http.StatusOK, tag), ErrNotAcceptable)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+func TestServer(t *testing.T) {
+	// Echoes the received tag
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag, err := ReadRequest(r, nil, 64)
+		if err != nil {
+			http.Error(w, err.Error(), ErrorStatus(err))
+			return
+		}
+		WriteResponse(w, r, http.StatusOK, tag)
+	}))
+	defer server.Close()
+
+	body := impl.MustParseTagBytes(`#1000 bytes(0x0102)`)
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(len(body)), resp.ContentLength)
+	assert.Equal(t, body, data)
+
+	resp, err = http.Post(server.URL, ContentType, bytes.NewReader(make([]byte, 65)))
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
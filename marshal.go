@@ -0,0 +1,131 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltags
+
+import (
+	"reflect"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+Converts the value v into an ILTag. See the package documentation for details
+about the mapping between Go types and ILTags.
+
+A nil value is converted into an ILNullTag.
+*/
+func MarshalTag(v any) (tags.ILTag, error) {
+	if v == nil {
+		return newNullTag(), nil
+	}
+	rv := reflect.ValueOf(v)
+	el, err := rootElementFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	return el.encodeValue(rv)
+}
+
+/*
+Serializes the value v as an ILTag. It is equivalent to the serialization of
+the tag returned by MarshalTag().
+*/
+func Marshal(v any) ([]byte, error) {
+	t, err := MarshalTag(v)
+	if err != nil {
+		return nil, err
+	}
+	return tags.ILTagToBytes(t)
+}
+
+/*
+Loads the contents of the given tag into the value pointed by v. v must be a
+non nil pointer.
+
+The factory is used to decode the payload of tags that were not created with
+the expected type, like the RawTags created for unknown tag IDs. If nil, a
+non-strict StandardTagFactory is used.
+*/
+func UnmarshalTag(factory tags.ILTagFactory, tag tags.ILTag, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrInvalidTarget
+	}
+	el, err := rootElementFor(rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	d := decoder{factory: factory}
+	return el.decodeValue(&d, tag, rv.Elem())
+}
+
+/*
+Deserializes the tag stored in data into the value pointed by v using the given
+factory. v must be a non nil pointer. If factory is nil, a non-strict
+StandardTagFactory is used.
+
+All bytes of data must be used by the tag, otherwise it fails with
+tags.ErrBadTagFormat. Tags created by the factory with a type other than the
+expected one, like the RawTags created for unknown tag IDs, are converted into
+the expected types as required.
+
+Fields whose types implement tags.ILTag and require some initialization, like
+ext.ChainNameBlockRefTag, must be either initialized before the call or created
+by the factory.
+*/
+func UnmarshalWithFactory(factory tags.ILTagFactory, data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrInvalidTarget
+	}
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	t, err := tags.ILTagFromBytes(factory, data)
+	if err != nil {
+		return err
+	}
+	return UnmarshalTag(factory, t, v)
+}
+
+/*
+Deserializes the tag stored in data into the value pointed by v. v must be a
+non nil pointer. It is equivalent to UnmarshalWithFactory() with a nil factory.
+*/
+func Unmarshal(data []byte, v any) error {
+	return UnmarshalWithFactory(nil, data, v)
+}
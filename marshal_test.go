@@ -0,0 +1,533 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltags
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/ext"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sampleRecord struct {
+	_       struct{} `iltag:"1000"`
+	Name    string
+	Id      uint64 `iltag:"10"`
+	Balance int32
+	Ratio   float64
+	Active  bool
+	Data    []byte
+	Parent  *sampleRecord `iltag:",omitnull"`
+	Tags    []string
+	Attrs   map[string]string `iltag:"31"`
+	Values  map[string]int16
+	Counts  []uint32  `iltag:",elem=10"`
+	Created time.Time `iltag:"1001"`
+	Amount  *big.Int  `iltag:",omitnull"`
+	Ref     ext.ChainNameBlockRefTag
+	Extra   tags.ILTag `iltag:",omitnull"`
+	ignored int
+	Skipped int `iltag:"-"`
+}
+
+func newSampleRecord() *sampleRecord {
+	r := &sampleRecord{
+		Name:    "root",
+		Id:      1234567,
+		Balance: -10,
+		Ratio:   0.5,
+		Active:  true,
+		Data:    []byte{1, 2, 3},
+		Parent: &sampleRecord{
+			Name:    "parent",
+			Created: time.UnixMicro(1000),
+			Ref:     *ext.NewChainNameBlockRefTag(1002),
+		},
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]string{"z": "1", "a": "2"},
+		Values:  map[string]int16{"x": -1},
+		Counts:  []uint32{1, 1000},
+		Created: time.UnixMicro(1669903047123456),
+		Amount:  big.NewInt(-1000),
+		Ref:     *ext.NewChainNameBlockRefTag(1002),
+	}
+	r.Ref.SetChainName("chain")
+	r.Ref.SetBlockId(42)
+	s := impl.NewStdStringTag()
+	s.Payload = "extra"
+	r.Extra = s
+	return r
+}
+
+func TestMarshalTag_Structure(t *testing.T) {
+	r := newSampleRecord()
+	tag, err := MarshalTag(r)
+	require.Nil(t, err)
+
+	seq, ok := tag.(*impl.ILTagSequenceTag)
+	require.True(t, ok)
+	assert.Equal(t, tags.TagID(1000), seq.Id())
+	require.Len(t, seq.Payload, 15)
+
+	assert.Equal(t, "root", seq.Payload[0].(*impl.StringTag).Payload)
+	assert.Equal(t, tags.IL_ILINT_TAG_ID, seq.Payload[1].Id())
+	assert.Equal(t, uint64(1234567), seq.Payload[1].(*impl.ILIntTag).Payload)
+	assert.Equal(t, int32(-10), seq.Payload[2].(*impl.Int32Tag).Payload)
+	assert.Equal(t, 0.5, seq.Payload[3].(*impl.Float64Tag).Payload)
+	assert.True(t, seq.Payload[4].(*impl.BoolTag).Payload)
+	assert.Equal(t, []byte{1, 2, 3}, seq.Payload[5].(*impl.BytesTag).Payload)
+
+	parent := seq.Payload[6].(*impl.ILTagSequenceTag)
+	assert.Equal(t, tags.TagID(1000), parent.Id())
+	assert.Equal(t, tags.IL_NULL_TAG_ID, parent.Payload[6].Id())
+	assert.Equal(t, tags.IL_NULL_TAG_ID, parent.Payload[12].Id())
+	// Nil slices and maps become empty containers
+	assert.Empty(t, parent.Payload[7].(*impl.ILTagArrayTag).Payload)
+	assert.Equal(t, 0, parent.Payload[9].(*impl.DictionaryTag).Map.Size())
+
+	a := seq.Payload[7].(*impl.ILTagArrayTag)
+	assert.Equal(t, tags.IL_ILTAGARRAY_TAG_ID, a.Id())
+	assert.Equal(t, "b", a.Payload[1].(*impl.StringTag).Payload)
+
+	sd := seq.Payload[8].(*impl.StringDictionaryTag)
+	assert.Equal(t, []string{"a", "z"}, sd.Map.Keys())
+
+	d := seq.Payload[9].(*impl.DictionaryTag)
+	v, _ := d.Map.Get("x")
+	assert.Equal(t, int16(-1), v.(*impl.Int16Tag).Payload)
+
+	c := seq.Payload[10].(*impl.ILTagArrayTag)
+	assert.Equal(t, uint64(1000), c.Payload[1].(*impl.ILIntTag).Payload)
+
+	ts := seq.Payload[11].(*ext.TimestapTag)
+	assert.Equal(t, tags.TagID(1001), ts.Id())
+	assert.Equal(t, int64(1669903047123456), ts.Payload)
+
+	bi := seq.Payload[12].(*impl.BigIntTag)
+	assert.Equal(t, int64(-1000), bi.BigInt().Int64())
+
+	ref := seq.Payload[13].(*ext.ChainNameBlockRefTag)
+	assert.Equal(t, "chain", ref.ChainName())
+	// Addressable tags are used directly
+	assert.Same(t, &r.Ref, ref)
+
+	assert.Same(t, r.Extra, seq.Payload[14])
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	r := newSampleRecord()
+	bin, err := Marshal(r)
+	require.Nil(t, err)
+
+	tag, err := MarshalTag(r)
+	require.Nil(t, err)
+	exp, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, exp, bin)
+
+	// ChainNameBlockRefTag requires the proper initialization
+	var r2 sampleRecord
+	assert.ErrorIs(t, Unmarshal(bin, &r2), tags.ErrUnexpectedTagId)
+
+	f := impl.NewStandardTagFactory(false)
+	f.RegisterTag(1002, func(id tags.TagID) tags.ILTag {
+		return ext.NewChainNameBlockRefTag(id)
+	})
+	r2 = sampleRecord{}
+	require.Nil(t, UnmarshalWithFactory(f, bin, &r2))
+	assert.Equal(t, r.Name, r2.Name)
+	assert.Equal(t, r.Id, r2.Id)
+	assert.Equal(t, r.Balance, r2.Balance)
+	assert.Equal(t, r.Ratio, r2.Ratio)
+	assert.Equal(t, r.Active, r2.Active)
+	assert.Equal(t, r.Data, r2.Data)
+	require.NotNil(t, r2.Parent)
+	assert.Equal(t, "parent", r2.Parent.Name)
+	assert.Nil(t, r2.Parent.Parent)
+	assert.Nil(t, r2.Parent.Amount)
+	assert.True(t, r.Parent.Created.Equal(r2.Parent.Created))
+	assert.Equal(t, r.Tags, r2.Tags)
+	assert.Equal(t, r.Attrs, r2.Attrs)
+	assert.Equal(t, r.Values, r2.Values)
+	assert.Equal(t, r.Counts, r2.Counts)
+	assert.True(t, r.Created.Equal(r2.Created))
+	assert.Equal(t, 0, r.Amount.Cmp(r2.Amount))
+	assert.Equal(t, tags.TagID(1002), r2.Ref.Id())
+	assert.Equal(t, "chain", r2.Ref.ChainName())
+	assert.Equal(t, uint64(42), r2.Ref.BlockId())
+	assert.Equal(t, "extra", r2.Extra.(*impl.StringTag).Payload)
+
+	// Serialize again to ensure nothing was lost
+	bin2, err := Marshal(&r2)
+	require.Nil(t, err)
+	assert.Equal(t, bin, bin2)
+}
+
+func TestMarshal_Primitives(t *testing.T) {
+	bin, err := Marshal(true)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x01, 0x01}, bin)
+
+	bin, err = Marshal(int8(-1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x02, 0xFF}, bin)
+
+	bin, err = Marshal(uint16(0x1234))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x05, 0x12, 0x34}, bin)
+
+	bin, err = Marshal(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x08, 0, 0, 0, 0, 0, 0, 0, 1}, bin)
+
+	bin, err = Marshal(uint(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x09, 0, 0, 0, 0, 0, 0, 0, 1}, bin)
+
+	bin, err = Marshal(float32(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0B, 0x3f, 0x80, 0x00, 0x00}, bin)
+
+	bin, err = Marshal("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x11, 0x03, 'a', 'b', 'c'}, bin)
+
+	bin, err = Marshal([3]byte{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x10, 0x03, 1, 2, 3}, bin)
+
+	bin, err = Marshal(big.NewInt(128))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x12, 0x02, 0x00, 0x80}, bin)
+
+	bin, err = Marshal([]int8{1, -1})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x15, 0x05, 0x02, 0x02, 0x01, 0x02, 0xFF}, bin)
+
+	bin, err = Marshal(map[string]bool{"b": true, "a": false})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x1E, 0x0B, 0x02,
+		0x11, 0x01, 'a', 0x01, 0x00,
+		0x11, 0x01, 'b', 0x01, 0x01}, bin)
+
+	bin, err = Marshal(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x00}, bin)
+
+	var p *sampleRecord
+	bin, err = Marshal(p)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x00}, bin)
+}
+
+func TestUnmarshal_Primitives(t *testing.T) {
+	var b bool
+	assert.Nil(t, Unmarshal([]byte{0x01, 0x01}, &b))
+	assert.True(t, b)
+
+	var i8 int8
+	assert.Nil(t, Unmarshal([]byte{0x02, 0xFF}, &i8))
+	assert.Equal(t, int8(-1), i8)
+
+	var u16 uint16
+	assert.Nil(t, Unmarshal([]byte{0x05, 0x12, 0x34}, &u16))
+	assert.Equal(t, uint16(0x1234), u16)
+
+	var i int
+	assert.Nil(t, Unmarshal([]byte{0x08, 0, 0, 0, 0, 0, 0, 0, 1}, &i))
+	assert.Equal(t, 1, i)
+
+	var f float32
+	assert.Nil(t, Unmarshal([]byte{0x0B, 0x3f, 0x80, 0x00, 0x00}, &f))
+	assert.Equal(t, float32(1), f)
+
+	var s string
+	assert.Nil(t, Unmarshal([]byte{0x11, 0x03, 'a', 'b', 'c'}, &s))
+	assert.Equal(t, "abc", s)
+
+	var a [3]byte
+	assert.Nil(t, Unmarshal([]byte{0x10, 0x03, 1, 2, 3}, &a))
+	assert.Equal(t, [3]byte{1, 2, 3}, a)
+	assert.ErrorIs(t, Unmarshal([]byte{0x10, 0x02, 1, 2}, &a), ErrValueOutOfRange)
+
+	var bi big.Int
+	assert.Nil(t, Unmarshal([]byte{0x12, 0x02, 0x00, 0x80}, &bi))
+	assert.Equal(t, int64(128), bi.Int64())
+
+	var l []int8
+	assert.Nil(t, Unmarshal([]byte{0x15, 0x05, 0x02, 0x02, 0x01, 0x02, 0xFF}, &l))
+	assert.Equal(t, []int8{1, -1}, l)
+
+	var la [2]int8
+	assert.Nil(t, Unmarshal([]byte{0x15, 0x05, 0x02, 0x02, 0x01, 0x02, 0xFF}, &la))
+	assert.Equal(t, [2]int8{1, -1}, la)
+	var lb [3]int8
+	assert.ErrorIs(t, Unmarshal([]byte{0x15, 0x05, 0x02, 0x02, 0x01, 0x02, 0xFF}, &lb),
+		ErrValueOutOfRange)
+
+	var m map[string]bool
+	assert.Nil(t, Unmarshal([]byte{0x1E, 0x0B, 0x02,
+		0x11, 0x01, 'a', 0x01, 0x00,
+		0x11, 0x01, 'b', 0x01, 0x01}, &m))
+	assert.Equal(t, map[string]bool{"b": true, "a": false}, m)
+
+	p := newSampleRecord()
+	assert.Nil(t, Unmarshal([]byte{0x00}, &p))
+	assert.Nil(t, p)
+
+	var tag tags.ILTag
+	assert.Nil(t, Unmarshal([]byte{0x11, 0x03, 'a', 'b', 'c'}, &tag))
+	assert.Equal(t, "abc", tag.(*impl.StringTag).Payload)
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	var s string
+	assert.ErrorIs(t, Unmarshal([]byte{0x11, 0x01, 'a'}, s), ErrInvalidTarget)
+	assert.ErrorIs(t, Unmarshal([]byte{0x11, 0x01, 'a'}, (*string)(nil)), ErrInvalidTarget)
+	assert.Error(t, Unmarshal([]byte{0x11, 0x01}, &s))
+	assert.ErrorIs(t, UnmarshalWithFactory(nil, []byte{0x11, 0x01, 'a'}, nil), ErrInvalidTarget)
+	assert.ErrorIs(t, Unmarshal([]byte{0x11, 0x01, 'a', 0}, &s), tags.ErrBadTagFormat)
+	assert.ErrorIs(t, Unmarshal([]byte{0x10, 0x01, 'a'}, &s), tags.ErrUnexpectedTagId)
+	assert.ErrorIs(t, Unmarshal([]byte{0x00}, &s), ErrNilValue)
+
+	// Out of range
+	type small struct {
+		V int8 `iltag:"14"`
+	}
+	var sm small
+	bin, err := Marshal(struct {
+		V int64 `iltag:"14"`
+	}{V: 1000})
+	require.Nil(t, err)
+	assert.ErrorIs(t, Unmarshal(bin, &sm), ErrValueOutOfRange)
+
+	// Wrong number of fields
+	bin, err = Marshal(struct{ A, B int8 }{})
+	require.Nil(t, err)
+	assert.ErrorIs(t, Unmarshal(bin, &sm), tags.ErrBadTagFormat)
+
+	// Bad raw payload
+	var ts struct {
+		T time.Time `iltag:"1000"`
+	}
+	assert.Error(t, Unmarshal([]byte{0x16, 0x04, 0xF8, 0xF0, 0x01, 0xFF}, &ts))
+}
+
+func TestMarshal_CustomIds(t *testing.T) {
+	type custom struct {
+		A int16   `iltag:"100"`
+		B uint32  `iltag:"101"`
+		C string  `iltag:"102"`
+		D float32 `iltag:"103"`
+		E bool    `iltag:"104"`
+		F []byte  `iltag:"105"`
+		G []int8  `iltag:"106,elem=107"`
+		H struct {
+			X int8
+		} `iltag:"108"`
+		I map[string]int8 `iltag:"109,elem=110"`
+		J big.Int         `iltag:"111"`
+		K impl.StringTag  `iltag:"112"`
+		L int             `iltag:"14"`
+	}
+	v := custom{A: -2, B: 3, C: "x", D: 1, E: true, F: []byte{1},
+		G: []int8{5}, I: map[string]int8{"k": 7}, L: -5}
+	v.H.X = 9
+	v.J.SetInt64(-3)
+	v.K.Payload = "tag"
+
+	tag, err := MarshalTag(v)
+	require.Nil(t, err)
+	seq := tag.(*impl.ILTagSequenceTag)
+	for i, exp := range []tags.TagID{100, 101, 102, 103, 104, 105, 106, 108, 109, 111, 112, 14} {
+		assert.Equal(t, exp, seq.Payload[i].Id())
+	}
+	assert.Equal(t, tags.TagID(107), seq.Payload[6].(*impl.ILTagArrayTag).Payload[0].Id())
+	e, _ := seq.Payload[8].(*impl.DictionaryTag).Map.Get("k")
+	assert.Equal(t, tags.TagID(110), e.Id())
+	// The original value must not be changed
+	assert.Equal(t, tags.TagID(0), v.K.Id())
+
+	bin, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+
+	// Custom IDs are deserialized as RawTags and converted on demand
+	var v2 custom
+	require.Nil(t, Unmarshal(bin, &v2))
+	assert.Equal(t, v.A, v2.A)
+	assert.Equal(t, v.B, v2.B)
+	assert.Equal(t, v.C, v2.C)
+	assert.Equal(t, v.D, v2.D)
+	assert.Equal(t, v.E, v2.E)
+	assert.Equal(t, v.F, v2.F)
+	assert.Equal(t, v.G, v2.G)
+	assert.Equal(t, v.H, v2.H)
+	assert.Equal(t, v.I, v2.I)
+	assert.Equal(t, 0, v.J.Cmp(&v2.J))
+	assert.Equal(t, "tag", v2.K.Payload)
+	assert.Equal(t, tags.TagID(112), v2.K.Id())
+	assert.Equal(t, v.L, v2.L)
+}
+
+func TestUnmarshalTag(t *testing.T) {
+	var s string
+	tag := impl.NewStdStringTag()
+	tag.Payload = "abc"
+	assert.Nil(t, UnmarshalTag(nil, tag, &s))
+	assert.Equal(t, "abc", s)
+	assert.ErrorIs(t, UnmarshalTag(nil, tag, s), ErrInvalidTarget)
+
+	// Conversion from another tag type with the same serialization
+	raw := tags.NewRawTag(tags.IL_STRING_TAG_ID)
+	raw.Payload = []byte("def")
+	assert.Nil(t, UnmarshalTag(nil, raw, &s))
+	assert.Equal(t, "def", s)
+
+	var u uint64
+	l := impl.NewStdILIntTag()
+	l.Payload = 1234
+	assert.ErrorIs(t, UnmarshalTag(nil, l, &u), tags.ErrUnexpectedTagId)
+	raw = tags.NewRawTag(tags.IL_UINT64_TAG_ID)
+	raw.Payload = []byte{0, 0, 0, 0, 0, 0, 0x04, 0xD2}
+	assert.Nil(t, UnmarshalTag(nil, raw, &u))
+	assert.Equal(t, uint64(1234), u)
+
+	var lv struct {
+		V uint64 `iltag:"10"`
+	}
+	seq := impl.NewStdILTagSequenceTag()
+	seq.Payload = []tags.ILTag{tags.NewRawTag(tags.IL_ILINT_TAG_ID)}
+	assert.Error(t, UnmarshalTag(nil, seq, &lv))
+	seq.Payload[0].(*tags.RawTag).Payload = []byte{0xF8, 0x01}
+	assert.Nil(t, UnmarshalTag(nil, seq, &lv))
+	assert.Equal(t, uint64(0xF9), lv.V)
+}
+
+type recursive struct {
+	Name     string
+	Children []recursive
+}
+
+func TestMarshal_Recursive(t *testing.T) {
+	v := recursive{Name: "a", Children: []recursive{
+		{Name: "b"}, {Name: "c", Children: []recursive{{Name: "d"}}}}}
+	bin, err := Marshal(&v)
+	require.Nil(t, err)
+	var v2 recursive
+	require.Nil(t, Unmarshal(bin, &v2))
+	assert.Equal(t, "d", v2.Children[1].Children[0].Name)
+	assert.Len(t, v2.Children[0].Children, 0)
+}
+
+func TestMarshal_Errors(t *testing.T) {
+	_, err := Marshal(make(chan int))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = Marshal(map[int]int{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = Marshal(struct{ F func() }{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = Marshal(time.Now())
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A string `iltag:"18"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A uint32 `iltag:"14"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A int32 `iltag:"10"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A time.Time `iltag:"3"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A map[string]int `iltag:"31"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A string `iltag:",elem=3"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A []byte `iltag:",elem=3"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A []int8 `iltag:",elem=4"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		_ struct{} `iltag:"5"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A tags.ILTag `iltag:"1000"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = Marshal(struct {
+		A int `iltag:"bad"`
+	}{})
+	assert.ErrorIs(t, err, ErrBadStructTag)
+
+	// Nil values
+	_, err = Marshal(struct{ A *int }{})
+	assert.ErrorIs(t, err, ErrNilValue)
+	_, err = Marshal(struct{ A tags.ILTag }{})
+	assert.ErrorIs(t, err, ErrNilValue)
+	_, err = Marshal(struct{ A []*int }{A: []*int{nil}})
+	assert.Nil(t, err)
+}
+
+func TestMarshal_Deterministic(t *testing.T) {
+	m := map[string]string{}
+	for _, k := range []string{"q", "w", "e", "r", "t", "y"} {
+		m[k] = k
+	}
+	bin, err := Marshal(m)
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		bin2, err := Marshal(m)
+		require.Nil(t, err)
+		assert.True(t, bytes.Equal(bin, bin2))
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+var (
+	// The proof is not valid for the given tree size.
+	ErrBadProof = fmt.Errorf("malformed inclusion proof")
+	// The proof does not lead to the expected root.
+	ErrRootMismatch = fmt.Errorf("root mismatch")
+)
+
+/*
+Proof is the inclusion proof of a leaf. The path holds the siblings of the
+nodes from the leaf up to the root, skipping the levels where the node was
+promoted.
+
+The index and the size are not bound to the root: the same path may lead to
+the same root for other indexes of trees with other sizes. Thus the size must
+come from a trusted source, like the root itself.
+*/
+type Proof struct {
+	// Index of the leaf.
+	Index uint64
+	// Number of leaves of the tree.
+	Size uint64
+	// The hashes of the siblings.
+	Path [][]byte
+}
+
+/*
+Returns the root computed from the hash of the leaf. It fails with ErrBadProof
+if the proof is not valid for its size.
+
+Since the size is taken from the proof, a matching root proves only that the
+leaf is included at some position of a tree with that root. Use VerifyHash()
+to check the proof against a trusted size.
+*/
+func (p *Proof) RootFromHash(newHash func() hash.Hash, leaf []byte) ([]byte, error) {
+	if p.Index >= p.Size {
+		return nil, fmt.Errorf("%w: leaf %d of %d", ErrBadProof, p.Index, p.Size)
+	}
+	h := leaf
+	path := p.Path
+	for index, size := p.Index, p.Size; size > 1; index, size = index/2, size/2+size%2 {
+		if index%2 == 0 && index+1 == size {
+			// Promoted
+			continue
+		}
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: path too short", ErrBadProof)
+		}
+		if index%2 == 0 {
+			h = nodeHash(newHash, h, path[0])
+		} else {
+			h = nodeHash(newHash, path[0], h)
+		}
+		path = path[1:]
+	}
+	if len(path) != 0 {
+		return nil, fmt.Errorf("%w: path too long", ErrBadProof)
+	}
+	return h, nil
+}
+
+/*
+Verifies that the leaf with the given hash is included in the tree with the
+given root and number of leaves. It fails with ErrBadProof if the proof is for a
+tree with another size and with ErrRootMismatch if the proof does not lead to
+the root.
+*/
+func (p *Proof) VerifyHash(newHash func() hash.Hash, leaf []byte, root []byte, size uint64) error {
+	if p.Size != size {
+		return fmt.Errorf("%w: proof for %d leaves, expected %d", ErrBadProof, p.Size, size)
+	}
+	r, err := p.RootFromHash(newHash, leaf)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(r, root) {
+		return fmt.Errorf("leaf %d: %w", p.Index, ErrRootMismatch)
+	}
+	return nil
+}
+
+/*
+Verifies that the tag is included in the tree with the given root and number of
+leaves. See VerifyHash() for details.
+*/
+func (p *Proof) Verify(newHash func() hash.Hash, tag tags.ILTag, root []byte, size uint64) error {
+	leaf, err := LeafHash(newHash, tag)
+	if err != nil {
+		return err
+	}
+	return p.VerifyHash(newHash, leaf, root, size)
+}
+
+/*
+Converts the proof into an ILTagSequenceTag with the given ID. The sequence
+holds the index, the size and the path:
+
+	seq[ilint(index), ilint(size), array[bytes(hash0), bytes(hash1), ...]]
+*/
+func (p *Proof) ToTag(id tags.TagID) *impl.ILTagSequenceTag {
+	index := impl.NewStdILIntTag()
+	index.Payload = p.Index
+	size := impl.NewStdILIntTag()
+	size.Payload = p.Size
+	path := impl.NewStdILTagArrayTag()
+	path.Payload = make([]tags.ILTag, len(p.Path))
+	for i, h := range p.Path {
+		b := impl.NewStdBytesTag()
+		b.Payload = h
+		path.Payload[i] = b
+	}
+	t := impl.NewILTagSequenceTag(id)
+	t.Payload = []tags.ILTag{index, size, path}
+	return t
+}
+
+/*
+Restores the proof stored in the given tag by Proof.ToTag(). It fails with
+tags.ErrBadTagFormat if the contents of the tag are not a valid proof.
+*/
+func ProofFromTag(tag *impl.ILTagSequenceTag) (*Proof, error) {
+	if len(tag.Payload) != 3 {
+		return nil, fmt.Errorf("expected 3 elements: %w", tags.ErrBadTagFormat)
+	}
+	index, ok1 := tag.Payload[0].(*impl.ILIntTag)
+	size, ok2 := tag.Payload[1].(*impl.ILIntTag)
+	path, ok3 := tag.Payload[2].(*impl.ILTagArrayTag)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("unexpected element types: %w", tags.ErrBadTagFormat)
+	}
+	p := &Proof{Index: index.Payload, Size: size.Payload, Path: make([][]byte, len(path.Payload))}
+	for i, e := range path.Payload {
+		h, ok := e.(*tags.RawTag)
+		if !ok || h.Id() != tags.IL_BYTES_TAG_ID {
+			return nil, fmt.Errorf("bad hash %d: %w", i, tags.ErrBadTagFormat)
+		}
+		p.Path[i] = h.Payload
+	}
+	return p, nil
+}
@@ -0,0 +1,138 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofRootFromHash(t *testing.T) {
+	list := sampleTags(6)
+	tree, err := New(sha256.New, list...)
+	require.Nil(t, err)
+	p, err := tree.Proof(5)
+	require.Nil(t, err)
+	root, err := p.RootFromHash(sha256.New, tree.Leaf(5))
+	require.Nil(t, err)
+	assert.Equal(t, tree.Root(), root)
+	assert.Nil(t, p.VerifyHash(sha256.New, tree.Leaf(5), tree.Root(), 6))
+	assert.ErrorIs(t, p.VerifyHash(sha256.New, tree.Leaf(4), tree.Root(), 6), ErrRootMismatch)
+
+	// Malformed proofs
+	bad := *p
+	bad.Path = p.Path[:1]
+	_, err = bad.RootFromHash(sha256.New, tree.Leaf(5))
+	assert.ErrorIs(t, err, ErrBadProof)
+	bad.Path = append(append([][]byte{}, p.Path...), p.Path[0])
+	_, err = bad.RootFromHash(sha256.New, tree.Leaf(5))
+	assert.ErrorIs(t, err, ErrBadProof)
+	bad = *p
+	bad.Index = 6
+	assert.ErrorIs(t, bad.VerifyHash(sha256.New, tree.Leaf(5), tree.Root(), 6), ErrBadProof)
+	bad.Index = 4
+	assert.ErrorIs(t, bad.VerifyHash(sha256.New, tree.Leaf(5), tree.Root(), 6), ErrRootMismatch)
+	bad = *p
+	bad.Size = 5
+	assert.ErrorIs(t, bad.VerifyHash(sha256.New, tree.Leaf(5), tree.Root(), 6), ErrBadProof)
+
+	// The size is not bound to the root
+	tree, err = New(sha256.New, list[:3]...)
+	require.Nil(t, err)
+	p, err = tree.Proof(2)
+	require.Nil(t, err)
+	assert.Nil(t, p.VerifyHash(sha256.New, tree.Leaf(2), tree.Root(), 3))
+	bad = Proof{Index: 1, Size: 2, Path: p.Path}
+	root, err = bad.RootFromHash(sha256.New, tree.Leaf(2))
+	require.Nil(t, err)
+	assert.Equal(t, tree.Root(), root)
+	err = bad.VerifyHash(sha256.New, tree.Leaf(2), tree.Root(), 3)
+	assert.ErrorIs(t, err, ErrBadProof)
+	assert.EqualError(t, err, "malformed inclusion proof: proof for 2 leaves, expected 3")
+
+	// Huge sizes do not overflow
+	bad = Proof{Index: math.MaxUint64 - 1, Size: math.MaxUint64}
+	_, err = bad.RootFromHash(sha256.New, tree.Leaf(0))
+	assert.ErrorIs(t, err, ErrBadProof)
+
+	assert.ErrorIs(t, p.Verify(sha256.New, &failingTag{}, tree.Root(), 3), io.ErrShortWrite)
+}
+
+func TestProofTag(t *testing.T) {
+	tree, err := New(sha256.New, sampleTags(7)...)
+	require.Nil(t, err)
+	p, err := tree.Proof(3)
+	require.Nil(t, err)
+
+	tag := p.ToTag(1000)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+	b, err := tags.ILTagToBytes(p.ToTag(tags.IL_ILTAGSEQ_TAG_ID))
+	require.Nil(t, err)
+	decoded, err := tags.ILTagFromBytes(impl.NewStandardTagFactory(true), b)
+	require.Nil(t, err)
+	restored, err := ProofFromTag(decoded.(*impl.ILTagSequenceTag))
+	require.Nil(t, err)
+	assert.Equal(t, p, restored)
+	assert.Nil(t, restored.VerifyHash(sha256.New, tree.Leaf(3), tree.Root(), 7))
+
+	// Empty path
+	single, err := New(sha256.New, sampleTags(1)...)
+	require.Nil(t, err)
+	p, err = single.Proof(0)
+	require.Nil(t, err)
+	restored, err = ProofFromTag(p.ToTag(1000))
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1), restored.Size)
+	assert.Empty(t, restored.Path)
+
+	// Bad tags
+	for _, s := range []string{
+		`seq[]`,
+		`seq[ilint(1), ilint(2)]`,
+		`seq[u64(1), ilint(2), array[]]`,
+		`seq[ilint(1), u64(2), array[]]`,
+		`seq[ilint(1), ilint(2), seq[]]`,
+		`seq[ilint(1), ilint(2), array[str("")]]`,
+		`seq[ilint(1), ilint(2), array[#1000 bytes(0x01)]]`,
+	} {
+		_, err = ProofFromTag(impl.MustParseTag(s).(*impl.ILTagSequenceTag))
+		assert.ErrorIs(t, err, tags.ErrBadTagFormat, s)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package merkle
+
+import (
+	"fmt"
+	"hash"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+var (
+	// The tag is not an ILTagSequenceTag or ILTagArrayTag.
+	ErrNotList = fmt.Errorf("the tag is not a sequence or array")
+	// The leaf does not exist.
+	ErrOutOfRange = fmt.Errorf("leaf out of range")
+)
+
+// Prefix of the inner nodes.
+const nodePrefix = 0x01
+
+/*
+Returns the canonical hash of the tag, computed over its serialization. It is
+the value of the leaf of the tag.
+*/
+func LeafHash(newHash func() hash.Hash, tag tags.ILTag) ([]byte, error) {
+	h := newHash()
+	if err := tags.ILTagSeralize(tag, h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Returns the hash of the inner node with the given children.
+func nodeHash(newHash func() hash.Hash, left, right []byte) []byte {
+	h := newHash()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+/*
+Tree is an immutable Merkle tree. It keeps all nodes in memory, thus proofs are
+generated without hashing.
+*/
+type Tree struct {
+	newHash func() hash.Hash
+	// The nodes of each level. The leaves are at level 0 and the root is the
+	// only node of the last level.
+	levels [][][]byte
+}
+
+/*
+Creates a new tree with the given leaf hashes. The hashes are not copied.
+*/
+func NewFromHashes(newHash func() hash.Hash, leaves [][]byte) *Tree {
+	t := &Tree{newHash: newHash}
+	level := leaves
+	t.levels = append(t.levels, level)
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, nodeHash(newHash, level[i], level[i+1]))
+			} else {
+				next = append(next, level[i])
+			}
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// Creates a new tree over the given tags.
+func New(newHash func() hash.Hash, list ...tags.ILTag) (*Tree, error) {
+	leaves := make([][]byte, len(list))
+	for i, tag := range list {
+		h, err := LeafHash(newHash, tag)
+		if err != nil {
+			return nil, fmt.Errorf("tag %d: %w", i, err)
+		}
+		leaves[i] = h
+	}
+	return NewFromHashes(newHash, leaves), nil
+}
+
+/*
+Creates a new tree over the contents of an ILTagSequenceTag or ILTagArrayTag
+of any ID. It fails with ErrNotList for other tags.
+*/
+func FromTag(newHash func() hash.Hash, tag tags.ILTag) (*Tree, error) {
+	switch t := tag.(type) {
+	case *impl.ILTagSequenceTag:
+		return New(newHash, t.Payload...)
+	case *impl.ILTagArrayTag:
+		return New(newHash, t.Payload...)
+	default:
+		return nil, fmt.Errorf("tag %d: %w", tag.Id(), ErrNotList)
+	}
+}
+
+// Returns the number of leaves.
+func (t *Tree) Len() int {
+	return len(t.levels[0])
+}
+
+// Returns the hash of the given leaf.
+func (t *Tree) Leaf(n int) []byte {
+	return t.levels[0][n]
+}
+
+// Returns the root of the tree.
+func (t *Tree) Root() []byte {
+	if t.Len() == 0 {
+		return t.newHash().Sum(nil)
+	}
+	return t.levels[len(t.levels)-1][0]
+}
+
+/*
+Returns the inclusion proof of the given leaf. It fails with ErrOutOfRange if
+the leaf does not exist.
+*/
+func (t *Tree) Proof(n int) (*Proof, error) {
+	if n < 0 || n >= t.Len() {
+		return nil, fmt.Errorf("leaf %d: %w", n, ErrOutOfRange)
+	}
+	p := &Proof{Index: uint64(n), Size: uint64(t.Len())}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sibling := n ^ 1; sibling < len(level) {
+			p.Path = append(p.Path, level[sibling])
+		}
+		n /= 2
+	}
+	return p, nil
+}
@@ -0,0 +1,191 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates count distinct tags.
+func sampleTags(count int) []tags.ILTag {
+	list := make([]tags.ILTag, count)
+	for i := range list {
+		list[i] = impl.MustParseTag(fmt.Sprintf(`str("record %d")`, i))
+	}
+	return list
+}
+
+// Computes the hash of the inner node directly.
+func testNode(newHash func() hash.Hash, left, right []byte) []byte {
+	h := newHash()
+	h.Write(append(append([]byte{0x01}, left...), right...))
+	return h.Sum(nil)
+}
+
+// A tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
+
+func TestLeafHash(t *testing.T) {
+	tag := impl.MustParseTag(`str("record")`)
+	b, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	h, err := LeafHash(sha256.New, tag)
+	require.Nil(t, err)
+	exp := sha256.Sum256(b)
+	assert.Equal(t, exp[:], h)
+
+	_, err = LeafHash(sha256.New, &failingTag{})
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+}
+
+func TestNew(t *testing.T) {
+	for _, newHash := range []func() hash.Hash{sha256.New, sha512.New} {
+		list := sampleTags(5)
+		tree, err := New(newHash, list...)
+		require.Nil(t, err)
+		assert.Equal(t, 5, tree.Len())
+		var l [5][]byte
+		for i, tag := range list {
+			l[i], err = LeafHash(newHash, tag)
+			require.Nil(t, err)
+			assert.Equal(t, l[i], tree.Leaf(i))
+		}
+		// The last leaf is promoted twice
+		exp := testNode(newHash,
+			testNode(newHash, testNode(newHash, l[0], l[1]), testNode(newHash, l[2], l[3])),
+			l[4])
+		assert.Equal(t, exp, tree.Root())
+		assert.Len(t, tree.Root(), newHash().Size())
+	}
+
+	// Empty and single leaf trees
+	tree, err := New(sha256.New)
+	require.Nil(t, err)
+	assert.Equal(t, 0, tree.Len())
+	empty := sha256.Sum256(nil)
+	assert.Equal(t, empty[:], tree.Root())
+	tree, err = New(sha256.New, sampleTags(1)...)
+	require.Nil(t, err)
+	assert.Equal(t, tree.Leaf(0), tree.Root())
+
+	_, err = New(sha256.New, impl.NewStdNullTag(), &failingTag{})
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Contains(t, err.Error(), "tag 1: ")
+}
+
+func TestNewFromHashes(t *testing.T) {
+	leaves := [][]byte{{1}, {2}, {3}}
+	tree := NewFromHashes(sha256.New, leaves)
+	assert.Equal(t, 3, tree.Len())
+	assert.Equal(t, testNode(sha256.New, testNode(sha256.New, []byte{1}, []byte{2}), []byte{3}),
+		tree.Root())
+}
+
+func TestFromTag(t *testing.T) {
+	list := sampleTags(3)
+	tree, err := New(sha256.New, list...)
+	require.Nil(t, err)
+
+	seq := impl.NewILTagSequenceTag(1000)
+	seq.Payload = list
+	other, err := FromTag(sha256.New, seq)
+	require.Nil(t, err)
+	assert.Equal(t, tree.Root(), other.Root())
+
+	array := impl.NewStdILTagArrayTag()
+	array.Payload = list
+	other, err = FromTag(sha256.New, array)
+	require.Nil(t, err)
+	assert.Equal(t, tree.Root(), other.Root())
+
+	_, err = FromTag(sha256.New, impl.NewStdStringTag())
+	assert.ErrorIs(t, err, ErrNotList)
+}
+
+func TestTreeProof(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		list := sampleTags(size)
+		tree, err := New(sha512.New, list...)
+		require.Nil(t, err)
+		for i, tag := range list {
+			p, err := tree.Proof(i)
+			require.Nil(t, err)
+			assert.Equal(t, uint64(i), p.Index)
+			assert.Equal(t, uint64(size), p.Size)
+			assert.Nil(t, p.Verify(sha512.New, tag, tree.Root(), uint64(size)), "%d of %d", i, size)
+			// Other tags are not included with the same proof
+			other := impl.MustParseTag(`str("other")`)
+			assert.ErrorIs(t, p.Verify(sha512.New, other, tree.Root(), uint64(size)), ErrRootMismatch)
+		}
+		_, err = tree.Proof(size)
+		assert.ErrorIs(t, err, ErrOutOfRange)
+		_, err = tree.Proof(-1)
+		assert.ErrorIs(t, err, ErrOutOfRange)
+	}
+
+	tree, err := New(sha256.New)
+	require.Nil(t, err)
+	_, err = tree.Proof(0)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+
+	// The path skips the promoted levels
+	tree, err = New(sha256.New, sampleTags(5)...)
+	require.Nil(t, err)
+	p, err := tree.Proof(4)
+	require.Nil(t, err)
+	assert.Equal(t, [][]byte{tree.levels[2][0]}, p.Path)
+}
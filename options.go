@@ -0,0 +1,111 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// Name of the struct tag used by this package.
+const structTagName = "iltag"
+
+/*
+Options extracted from the iltag struct tag.
+*/
+type fieldOptions struct {
+	// If true, the field must be ignored.
+	skip bool
+	// If true, the tag ID was set.
+	hasId bool
+	// The tag ID.
+	id tags.TagID
+	// If true, nil values are encoded as null tags.
+	omitNull bool
+	// If true, the element tag ID was set.
+	hasElemId bool
+	// The tag ID of the elements.
+	elemId tags.TagID
+}
+
+// Parses a tag ID.
+func parseTagId(s string) (tags.TagID, error) {
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tag id %q: %w", s, ErrBadStructTag)
+	}
+	return tags.TagID(v), nil
+}
+
+/*
+Parses the contents of the iltag struct tag.
+*/
+func parseFieldOptions(tag string) (fieldOptions, error) {
+	var opts fieldOptions
+	if tag == "-" {
+		opts.skip = true
+		return opts, nil
+	}
+	if tag == "" {
+		return opts, nil
+	}
+	parts := strings.Split(tag, ",")
+	if s := strings.TrimSpace(parts[0]); s != "" {
+		id, err := parseTagId(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.hasId = true
+		opts.id = id
+	}
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "omitnull":
+			opts.omitNull = true
+		case strings.HasPrefix(p, "elem="):
+			id, err := parseTagId(p[len("elem="):])
+			if err != nil {
+				return opts, err
+			}
+			opts.hasElemId = true
+			opts.elemId = id
+		default:
+			return opts, fmt.Errorf("unknown option %q: %w", p, ErrBadStructTag)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltags
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFieldOptions(t *testing.T) {
+	opts, err := parseFieldOptions("")
+	assert.Nil(t, err)
+	assert.Equal(t, fieldOptions{}, opts)
+
+	opts, err = parseFieldOptions("-")
+	assert.Nil(t, err)
+	assert.Equal(t, fieldOptions{skip: true}, opts)
+
+	opts, err = parseFieldOptions("17")
+	assert.Nil(t, err)
+	assert.Equal(t, fieldOptions{hasId: true, id: tags.IL_STRING_TAG_ID}, opts)
+
+	opts, err = parseFieldOptions("0x400,omitnull")
+	assert.Nil(t, err)
+	assert.Equal(t, fieldOptions{hasId: true, id: 1024, omitNull: true}, opts)
+
+	opts, err = parseFieldOptions(",omitnull, elem=10")
+	assert.Nil(t, err)
+	assert.Equal(t, fieldOptions{omitNull: true, hasElemId: true,
+		elemId: tags.IL_ILINT_TAG_ID}, opts)
+
+	_, err = parseFieldOptions("x")
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = parseFieldOptions("-1")
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = parseFieldOptions("1,elem=")
+	assert.ErrorIs(t, err, ErrBadStructTag)
+	_, err = parseFieldOptions("1,omitempty")
+	assert.ErrorIs(t, err, ErrBadStructTag)
+}
@@ -0,0 +1,86 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+This package implements the conversion between Go values and ILTags using
+reflection. It allows the serialization of plain Go structs without the need to
+write the ValueSize(), SerializeValue() and DeserializeValue() methods by hand.
+
+The mapping between Go types and tags is the following:
+
+	bool                   -> BoolTag
+	int8, int16, int32     -> Int8Tag, Int16Tag, Int32Tag
+	int, int64             -> Int64Tag
+	uint8, uint16, uint32  -> UInt8Tag, UInt16Tag, UInt32Tag
+	uint, uint64, uintptr  -> UInt64Tag
+	float32, float64       -> Float32Tag, Float64Tag
+	string                 -> StringTag
+	[]byte, [N]byte        -> BytesTag
+	big.Int                -> BigIntTag
+	time.Time              -> ext.TimestapTag (requires an explicit tag ID)
+	slices and arrays      -> ILTagArrayTag
+	map[string]T           -> DictionaryTag
+	structs                -> ILTagSequenceTag
+	pointers               -> the tag of the value pointed by it
+	tags.ILTag             -> the tag itself
+
+The tags used to represent each field can be customized with the iltag struct
+tag. Its format is:
+
+	`iltag:"[<id>][,omitnull][,elem=<id>]"`
+
+where id is the tag ID used to encode the field, omitnull encodes nil values as
+ILNullTags and decodes ILNullTags as nil values and elem defines the tag ID used
+to encode the elements of slices, arrays and maps. Without omitnull, nil
+pointers and interfaces are rejected with ErrNilValue and nil maps and slices
+are encoded as empty containers. Since the fields of a struct are identified by
+their positions, nil fields are never omitted. Fields tagged with `iltag:"-"`
+and unexported fields are ignored.
+
+Integer types also accept the ILIntTag (unsigned) and SignedILIntTag (signed)
+IDs. Any non reserved tag ID can be used to replace the standard ID of a given
+type without changing its payload format. The StringDictionaryTag ID can be
+used by fields of type map[string]string.
+
+The default tag ID of a struct can be defined by a blank field with an iltag
+struct tag:
+
+	type Block struct {
+		_      struct{} `iltag:"1000"`
+		Name   string
+		Parent *Block   `iltag:",omitnull"`
+	}
+
+The encoding plan of each type is computed once and cached, thus the reflection
+overhead is restricted to the access to the values.
+*/
+package iltags
@@ -0,0 +1,449 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package iltags
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+var (
+	// Type of tags.ILTag.
+	ilTagType = reflect.TypeOf((*tags.ILTag)(nil)).Elem()
+	// Type of time.Time.
+	timeType = reflect.TypeOf(time.Time{})
+	// Type of big.Int.
+	bigIntType = reflect.TypeOf(big.Int{})
+)
+
+/*
+A codec converts values of a given Go type into tags and vice versa. Instances
+of codec are immutable once created thus they can be shared among goroutines.
+*/
+type codec interface {
+	/*
+		Returns the default tag ID used by this codec. It returns false if this
+		codec requires an explicit tag ID.
+	*/
+	defaultId() (tags.TagID, bool)
+
+	/*
+		Returns true if the given tag ID can be used to encode the values.
+	*/
+	acceptsId(id tags.TagID) bool
+
+	/*
+		Encodes the value v into a new tag.
+	*/
+	encode(el *element, v reflect.Value) (tags.ILTag, error)
+
+	/*
+		Decodes the tag into v. v is always addressable.
+	*/
+	decode(d *decoder, el *element, tag tags.ILTag, v reflect.Value) error
+}
+
+/*
+An element is the association of a codec with the options used to encode a
+given value, like a field of a struct or the elements of a slice.
+*/
+type element struct {
+	codec codec
+	// The tag ID.
+	id tags.TagID
+	// If true, the ID was explicitly defined by the struct tag.
+	explicitId bool
+	// If true, nil values are mapped into null tags.
+	nullable bool
+	// Overrides the element of the items of slices, arrays and maps.
+	child *element
+}
+
+/*
+Returns true if the value is a nil pointer, map, slice or interface.
+*/
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+/*
+Encodes the value v. Nil pointers and interfaces are encoded as null tags if
+the element is nullable. Nil maps and slices are encoded as null tags if the
+element is nullable or as empty containers otherwise.
+*/
+func (el *element) encodeValue(v reflect.Value) (tags.ILTag, error) {
+	if isNilValue(v) {
+		if el.nullable {
+			return newNullTag(), nil
+		}
+		if k := v.Kind(); k == reflect.Pointer || k == reflect.Interface {
+			return nil, ErrNilValue
+		}
+	}
+	return el.codec.encode(el, v)
+}
+
+/*
+Decodes the tag into v. Null tags are mapped into the zero value of v if the
+element is nullable.
+*/
+func (el *element) decodeValue(d *decoder, tag tags.ILTag, v reflect.Value) error {
+	if tag.Id() == tags.IL_NULL_TAG_ID {
+		if el.nullable {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if el.explicitId || el.id != tags.IL_NULL_TAG_ID {
+			return fmt.Errorf("%w: %v", ErrNilValue, tags.NewErrUnexpectedTagId(el.id, tag.Id()))
+		}
+	}
+	return el.codec.decode(d, el, tag, v)
+}
+
+/*
+Returns the element that must be used to encode the items of a container.
+*/
+func (el *element) itemElement(def *element) *element {
+	if el.child != nil {
+		return el.child
+	}
+	return def
+}
+
+/*
+Resolves the tag ID of the element. It uses the default ID of the codec if id is
+nil.
+*/
+func (el *element) resolveId(t reflect.Type, id *tags.TagID) error {
+	if id == nil {
+		def, ok := el.codec.defaultId()
+		if !ok {
+			return fmt.Errorf("type %s requires an explicit tag ID: %w", t, ErrBadStructTag)
+		}
+		el.id = def
+	} else {
+		if !el.codec.acceptsId(*id) {
+			return fmt.Errorf("tag ID %d cannot be used with type %s: %w", *id, t, ErrBadStructTag)
+		}
+		el.id = *id
+		el.explicitId = true
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+var (
+	// Cache of all codecs already created.
+	codecCache sync.Map
+	// Lock used to serialize the creation of new codecs.
+	codecLock sync.Mutex
+)
+
+/*
+Returns the codec for the given type. Codecs are cached, thus they are created
+only once per type.
+*/
+func codecFor(t reflect.Type) (codec, error) {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(codec), nil
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	b := planBuilder{pending: make(map[reflect.Type]codec)}
+	c, err := b.build(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.finish(); err != nil {
+		return nil, err
+	}
+	for k, v := range b.pending {
+		codecCache.LoadOrStore(k, v)
+	}
+	return c, nil
+}
+
+/*
+Returns the root element for the given type. The root element always uses the
+default tag ID of the type and accepts nil values if the type is a pointer, map,
+slice or interface.
+*/
+func rootElementFor(t reflect.Type) (*element, error) {
+	c, err := codecFor(t)
+	if err != nil {
+		return nil, err
+	}
+	el := &element{codec: c}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		el.nullable = true
+	}
+	if err := el.resolveId(t, nil); err != nil {
+		return nil, err
+	}
+	return el, nil
+}
+
+/*
+The plan builder creates the codecs of a type and all types it depends on. It
+keeps track of the codecs being created in order to handle recursive types.
+*/
+type planBuilder struct {
+	pending map[reflect.Type]codec
+	/*
+		Actions that must be executed after all codecs are created. They are
+		used to resolve the tag IDs of the elements because recursive types
+		may depend on codecs that are not complete yet.
+	*/
+	deferred []func() error
+}
+
+/*
+Creates a new element whose ID will be resolved by finish(). It uses the
+default ID of the codec if id is nil.
+*/
+func (b *planBuilder) newElement(c codec, t reflect.Type, id *tags.TagID, nullable bool) *element {
+	el := &element{codec: c, nullable: nullable}
+	b.deferred = append(b.deferred, func() error {
+		return el.resolveId(t, id)
+	})
+	return el
+}
+
+// Executes all deferred actions.
+func (b *planBuilder) finish() error {
+	for _, f := range b.deferred {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builds the codec of the given type.
+func (b *planBuilder) build(t reflect.Type) (codec, error) {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(codec), nil
+	}
+	if c, ok := b.pending[t]; ok {
+		return c, nil
+	}
+	if t == ilTagType {
+		return b.register(t, &ilTagCodec{}), nil
+	}
+	if t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(ilTagType) {
+		return b.register(t, &tagCodec{}), nil
+	}
+	switch t {
+	case timeType:
+		return b.register(t, &timeCodec{}), nil
+	case bigIntType:
+		return b.register(t, &bigIntCodec{}), nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return b.register(t, &boolCodec{}), nil
+	case reflect.Int8:
+		return b.register(t, &intCodec{8}), nil
+	case reflect.Int16:
+		return b.register(t, &intCodec{16}), nil
+	case reflect.Int32:
+		return b.register(t, &intCodec{32}), nil
+	case reflect.Int, reflect.Int64:
+		return b.register(t, &intCodec{64}), nil
+	case reflect.Uint8:
+		return b.register(t, &uintCodec{8}), nil
+	case reflect.Uint16:
+		return b.register(t, &uintCodec{16}), nil
+	case reflect.Uint32:
+		return b.register(t, &uintCodec{32}), nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return b.register(t, &uintCodec{64}), nil
+	case reflect.Float32:
+		return b.register(t, &floatCodec{32}), nil
+	case reflect.Float64:
+		return b.register(t, &floatCodec{64}), nil
+	case reflect.String:
+		return b.register(t, &stringCodec{}), nil
+	case reflect.Pointer:
+		return b.buildPointer(t)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return b.register(t, &bytesCodec{}), nil
+		}
+		return b.buildSlice(t)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return b.register(t, &bytesCodec{}), nil
+		}
+		return b.buildSlice(t)
+	case reflect.Map:
+		return b.buildMap(t)
+	case reflect.Struct:
+		return b.buildStruct(t)
+	default:
+		return nil, newErrUnsupportedType(t)
+	}
+}
+
+// Registers the codec as a pending codec.
+func (b *planBuilder) register(t reflect.Type, c codec) codec {
+	b.pending[t] = c
+	return c
+}
+
+// Builds the codec of a pointer.
+func (b *planBuilder) buildPointer(t reflect.Type) (codec, error) {
+	c := &pointerCodec{}
+	b.register(t, c)
+	elem, err := b.build(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	c.elem = elem
+	return c, nil
+}
+
+// Builds the item element of a container.
+func (b *planBuilder) buildItem(t reflect.Type) (*element, error) {
+	c, err := b.build(t)
+	if err != nil {
+		return nil, err
+	}
+	return b.newElement(c, t, nil, true), nil
+}
+
+// Builds the codec of a slice or an array.
+func (b *planBuilder) buildSlice(t reflect.Type) (codec, error) {
+	c := &sliceCodec{}
+	b.register(t, c)
+	item, err := b.buildItem(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	c.item = item
+	return c, nil
+}
+
+// Builds the codec of a map.
+func (b *planBuilder) buildMap(t reflect.Type) (codec, error) {
+	if t.Key().Kind() != reflect.String {
+		return nil, newErrUnsupportedType(t)
+	}
+	c := &mapCodec{stringValues: t.Elem().Kind() == reflect.String}
+	b.register(t, c)
+	item, err := b.buildItem(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	c.item = item
+	return c, nil
+}
+
+// Builds the codec of a struct.
+func (b *planBuilder) buildStruct(t reflect.Type) (codec, error) {
+	c := &structCodec{}
+	b.register(t, c)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		opts, err := parseFieldOptions(sf.Tag.Get(structTagName))
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %w", t, sf.Name, err)
+		}
+		if sf.Name == "_" {
+			// Blank fields may define the default tag ID of the struct
+			if opts.hasId {
+				c.hasId = true
+				c.id = opts.id
+			}
+			continue
+		}
+		if opts.skip || !sf.IsExported() {
+			continue
+		}
+		el, err := b.buildField(sf.Type, opts)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %w", t, sf.Name, err)
+		}
+		c.fields = append(c.fields, structField{name: sf.Name, index: i, element: el})
+	}
+	if c.hasId && !c.acceptsId(c.id) {
+		return nil, fmt.Errorf("tag ID %d cannot be used with type %s: %w", c.id, t, ErrBadStructTag)
+	}
+	return c, nil
+}
+
+// Builds the element of a field.
+func (b *planBuilder) buildField(t reflect.Type, opts fieldOptions) (*element, error) {
+	c, err := b.build(t)
+	if err != nil {
+		return nil, err
+	}
+	var id *tags.TagID
+	if opts.hasId {
+		id = &opts.id
+	}
+	el := b.newElement(c, t, id, opts.omitNull)
+	if opts.hasElemId {
+		itemType := t
+		for itemType.Kind() == reflect.Pointer {
+			itemType = itemType.Elem()
+		}
+		switch itemType.Kind() {
+		case reflect.Slice, reflect.Array:
+			if itemType.Elem().Kind() == reflect.Uint8 {
+				return nil, fmt.Errorf("elem option used with type %s: %w", t, ErrBadStructTag)
+			}
+		case reflect.Map:
+		default:
+			return nil, fmt.Errorf("elem option used with type %s: %w", t, ErrBadStructTag)
+		}
+		itemCodec, err := b.build(itemType.Elem())
+		if err != nil {
+			return nil, err
+		}
+		el.child = b.newElement(itemCodec, itemType.Elem(), &opts.elemId, true)
+	}
+	return el, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// The schema source is invalid.
+	ErrSyntax = fmt.Errorf("invalid schema")
+	// The tag does not match the expected type.
+	ErrTypeMismatch = fmt.Errorf("type mismatch")
+	// A null tag was found where it is not allowed.
+	ErrNullNotAllowed = fmt.Errorf("null not allowed")
+	// The version of the tag is not supported by the schema.
+	ErrUnsupportedVersion = fmt.Errorf("unsupported version")
+	// The number of fields of the struct does not match the schema.
+	ErrFieldCount = fmt.Errorf("unexpected number of fields")
+)
+
+/*
+ValidationError is the error returned by Schema.Validate(). It contains the
+path of the offending tag inside the tag tree.
+
+The path starts with $, that represents the root tag, followed by the names
+of the struct fields, the indexes of the arrays and the keys of the
+dictionaries, as in $.parent.items[2].
+*/
+type ValidationError struct {
+	// The path of the tag.
+	Path string
+	// The cause of the error.
+	Err error
+}
+
+// Implementation of error.Error().
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Returns the cause of the error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Creates a new ValidationError.
+func newValidationError(path string, err error) error {
+	var v *ValidationError
+	if errors.As(err, &v) {
+		return err
+	}
+	return &ValidationError{Path: path, Err: err}
+}
+
+// Creates a new syntax error.
+func newSyntaxError(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s: %w", line, fmt.Sprintf(format, args...), ErrSyntax)
+}
@@ -0,0 +1,70 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+This package implements a small schema language used to describe the layout of
+custom tags. A schema can be used to validate decoded tag trees and to create
+tag factories able to decode the described tags.
+
+The schema is a sequence of tag definitions. Each definition associates a name
+with a non reserved tag ID and a layout:
+
+	// Comments use the Go syntax.
+	tag Name 1000 string
+
+	tag Block 1001 struct versioned 1..2 {
+		name    Name
+		height  ilint
+		parent  Block?
+		items   array<string>
+		extra   dictionary<bytes>?  @2..
+	}
+
+A layout may be a standard type, reusing its payload format with the new ID, or
+a struct. A struct payload is the concatenation of its fields, each one encoded
+as a tag. Versioned structs start with the version of the payload encoded as a
+standard UInt16Tag, as done by ext.IL2VersionedPayload, or as a raw uint16 when
+the plain keyword is used, as done by ext.VersionedPayload. The version range of
+the struct defines the supported versions while the optional version range of a
+field, prefixed by @, defines the versions that contain it.
+
+Version ranges have the format <min>, <min>.. or <min>..<max>.
+
+The available types are the names of the standard tags (null, bool, int8,
+uint8, int16, uint16, int32, uint32, int64, uint64, ilint, float32, float64,
+float128, signedilint, bytes, string, bigint, bigdec, ilintarray, array,
+sequence, range, version, oid, dictionary and stringdictionary), the names of
+the tags defined by the schema and any, which accepts any tag. The types array
+and dictionary may also define the type of their elements, as in array<string>.
+A type followed by ? also accepts ILNullTags.
+*/
+package schema
@@ -0,0 +1,324 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Schema parser. It uses a text/scanner.Scanner to split the source into tokens.
+*/
+type parser struct {
+	s      scanner.Scanner
+	tok    rune
+	text   string
+	line   int
+	schema *Schema
+	// Named types that must be resolved after all tags are defined.
+	pending []pendingType
+	err     error
+}
+
+/*
+Named type that will be resolved after the parsing.
+*/
+type pendingType struct {
+	t    *Type
+	line int
+}
+
+// Reads the next token.
+func (p *parser) next() {
+	p.tok = p.s.Scan()
+	p.text = p.s.TokenText()
+	p.line = p.s.Position.Line
+}
+
+// Returns a syntax error at the current line.
+func (p *parser) errorf(format string, args ...any) error {
+	return newSyntaxError(p.line, format, args...)
+}
+
+// Consumes the given token or fails.
+func (p *parser) expect(text string) error {
+	if p.text != text {
+		return p.errorf("expected %q, found %q", text, p.text)
+	}
+	p.next()
+	return nil
+}
+
+// Consumes an identifier or fails.
+func (p *parser) ident() (string, error) {
+	if p.tok != scanner.Ident {
+		return "", p.errorf("expected identifier, found %q", p.text)
+	}
+	s := p.text
+	p.next()
+	return s, nil
+}
+
+// Consumes an unsigned integer or fails.
+func (p *parser) uint(bits int) (uint64, error) {
+	if p.tok != scanner.Int {
+		return 0, p.errorf("expected integer, found %q", p.text)
+	}
+	v, err := strconv.ParseUint(p.text, 0, bits)
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", p.text)
+	}
+	p.next()
+	return v, nil
+}
+
+// Parses a version range.
+func (p *parser) versionRange() (VersionRange, error) {
+	min, err := p.uint(16)
+	if err != nil {
+		return VersionRange{}, err
+	}
+	r := VersionRange{uint16(min), uint16(min)}
+	if p.text != "." {
+		return r, nil
+	}
+	p.next()
+	if err := p.expect("."); err != nil {
+		return r, err
+	}
+	if p.tok != scanner.Int {
+		r.Max = 0xFFFF
+		return r, nil
+	}
+	max, err := p.uint(16)
+	if err != nil {
+		return r, err
+	}
+	if max < min {
+		return r, p.errorf("invalid version range %d..%d", min, max)
+	}
+	r.Max = uint16(max)
+	return r, nil
+}
+
+// Parses a type.
+func (p *parser) parseType() (*Type, error) {
+	line := p.line
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	t := &Type{Name: name}
+	if std, ok := stdTypesByName[name]; ok {
+		t.std = std
+		if std.container && p.text == "<" {
+			p.next()
+			if t.Elem, err = p.parseType(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(">"); err != nil {
+				return nil, err
+			}
+		}
+	} else if name != anyTypeName {
+		// Named types are resolved later.
+		p.pending = append(p.pending, pendingType{t, line})
+	}
+	if p.text == "?" {
+		p.next()
+		t.Nullable = true
+	}
+	return t, nil
+}
+
+// Parses the fields of a struct.
+func (p *parser) parseFields(def *TagDef) error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for p.text != "}" {
+		if p.tok == scanner.EOF {
+			return p.errorf("unexpected end of schema")
+		}
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if names[name] {
+			return p.errorf("duplicated field %s", name)
+		}
+		names[name] = true
+		f := &FieldDef{Name: name, Versions: allVersions}
+		if f.Type, err = p.parseType(); err != nil {
+			return err
+		}
+		if p.text == "@" {
+			if !def.Versioned {
+				return p.errorf("field %s: %s is not versioned", name, def.Name)
+			}
+			p.next()
+			if f.Versions, err = p.versionRange(); err != nil {
+				return err
+			}
+		}
+		if p.text == ";" || p.text == "," {
+			p.next()
+		}
+		def.Fields = append(def.Fields, f)
+	}
+	p.next()
+	return nil
+}
+
+// Parses a tag definition.
+func (p *parser) parseTagDef() error {
+	if err := p.expect("tag"); err != nil {
+		return err
+	}
+	line := p.line
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, ok := stdTypesByName[name]; ok || name == anyTypeName || name == "struct" {
+		return p.errorf("%s is a reserved name", name)
+	}
+	if p.schema.byName[name] != nil {
+		return p.errorf("duplicated tag %s", name)
+	}
+	id, err := p.uint(64)
+	if err != nil {
+		return err
+	}
+	def := &TagDef{Name: name, Id: tags.TagID(id)}
+	if def.Id.Reserved() {
+		return p.errorf("tag %s: the tag id %d is reserved", name, id)
+	}
+	if other := p.schema.byId[def.Id]; other != nil {
+		return p.errorf("tag %s: the tag id %d is already used by %s", name, id, other.Name)
+	}
+	if p.text == "struct" {
+		p.next()
+		if p.text == "versioned" {
+			p.next()
+			def.Versioned = true
+			if p.text == "plain" {
+				p.next()
+				def.PlainVersion = true
+			}
+			if def.Versions, err = p.versionRange(); err != nil {
+				return err
+			}
+		} else {
+			def.Versions = allVersions
+		}
+		if err := p.parseFields(def); err != nil {
+			return err
+		}
+	} else {
+		if def.Alias, err = p.parseType(); err != nil {
+			return err
+		}
+		if def.Alias.std == nil || def.Alias.std.id == tags.IL_NULL_TAG_ID ||
+			def.Alias.Nullable {
+			return newSyntaxError(line, "tag %s: invalid layout %s", name, def.Alias)
+		}
+	}
+	p.schema.add(def)
+	return nil
+}
+
+// Resolves the named types.
+func (p *parser) resolve() error {
+	for _, pt := range p.pending {
+		def := p.schema.byName[pt.t.Name]
+		if def == nil {
+			return newSyntaxError(pt.line, "unknown type %s", pt.t.Name)
+		}
+		pt.t.Def = def
+	}
+	return nil
+}
+
+/*
+Parses a schema from the given reader.
+*/
+func Parse(reader io.Reader) (*Schema, error) {
+	p := parser{schema: newSchema()}
+	p.s.Init(reader)
+	p.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanComments |
+		scanner.SkipComments
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = newSyntaxError(s.Position.Line, "%s", msg)
+		}
+	}
+	p.next()
+	for p.tok != scanner.EOF && p.err == nil {
+		if err := p.parseTagDef(); err != nil {
+			return nil, err
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err := p.resolve(); err != nil {
+		return nil, err
+	}
+	return p.schema, nil
+}
+
+/*
+Parses a schema from a string.
+*/
+func ParseString(s string) (*Schema, error) {
+	return Parse(strings.NewReader(s))
+}
+
+/*
+Parses a schema from a string. It panics if the schema is invalid. It is meant
+to be used to initialize global variables.
+*/
+func MustParse(s string) *Schema {
+	schema, err := ParseString(s)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
@@ -0,0 +1,174 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleSchema = `
+// Sample schema
+tag Name 1000 string
+tag Names 1001 array<Name>
+
+tag Block 1002 struct versioned 1..2 {
+	name    Name
+	height  ilint;
+	parent  Block?,
+	items   array<string>
+	attrs   dictionary<bytes?>? @2..
+	any     any
+}
+
+/* Plain versioned struct */
+tag Plain 0x3F0 struct versioned plain 3 {
+	value int16 @3
+}
+
+tag Simple 1004 struct {
+	flag bool
+}
+`
+
+func TestParse(t *testing.T) {
+	s, err := ParseString(sampleSchema)
+	require.Nil(t, err)
+	require.Len(t, s.Tags(), 5)
+
+	name := s.TagByName("Name")
+	require.NotNil(t, name)
+	assert.Equal(t, tags.TagID(1000), name.Id)
+	assert.False(t, name.Struct())
+	assert.Equal(t, "string", name.Alias.String())
+	assert.Same(t, name, s.TagById(1000))
+
+	names := s.TagByName("Names")
+	assert.Equal(t, "array<Name>", names.Alias.String())
+	assert.Same(t, name, names.Alias.Elem.Def)
+
+	block := s.TagByName("Block")
+	assert.True(t, block.Struct())
+	assert.True(t, block.Versioned)
+	assert.False(t, block.PlainVersion)
+	assert.Equal(t, VersionRange{1, 2}, block.Versions)
+	require.Len(t, block.Fields, 6)
+	assert.Equal(t, "name", block.Fields[0].Name)
+	assert.Same(t, name, block.Fields[0].Type.Def)
+	assert.Equal(t, "ilint", block.Fields[1].Type.String())
+	assert.Equal(t, "Block?", block.Fields[2].Type.String())
+	assert.Same(t, block, block.Fields[2].Type.Def)
+	assert.Equal(t, "array<string>", block.Fields[3].Type.String())
+	assert.Equal(t, "dictionary<bytes?>?", block.Fields[4].Type.String())
+	assert.Equal(t, VersionRange{2, 0xFFFF}, block.Fields[4].Versions)
+	assert.Equal(t, allVersions, block.Fields[0].Versions)
+	assert.True(t, block.Fields[5].Type.Any())
+	assert.Len(t, block.FieldsOf(1), 5)
+	assert.Len(t, block.FieldsOf(2), 6)
+
+	plain := s.TagByName("Plain")
+	assert.Equal(t, tags.TagID(0x3F0), plain.Id)
+	assert.True(t, plain.PlainVersion)
+	assert.Equal(t, VersionRange{3, 3}, plain.Versions)
+	assert.Equal(t, VersionRange{3, 3}, plain.Fields[0].Versions)
+
+	simple := s.TagByName("Simple")
+	assert.False(t, simple.Versioned)
+	assert.Equal(t, allVersions, simple.Versions)
+	assert.Len(t, simple.FieldsOf(10), 1)
+
+	assert.Nil(t, s.TagByName("Unknown"))
+	assert.Nil(t, s.TagById(1))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"tag",
+		"tag 1000 string",
+		"tag A string",
+		"tag A 1000",
+		"tag A 10 string",
+		"tag A 1000 string tag B 1000 string",
+		"tag A 1000 string tag A 1001 string",
+		"tag string 1000 string",
+		"tag any 1000 string",
+		"tag A 1000 null",
+		"tag A 1000 any",
+		"tag A 1000 string?",
+		"tag A 1000 B",
+		"tag A 1000 struct { a B }",
+		"tag A 1000 struct { a string a string }",
+		"tag A 1000 struct { a string @1 }",
+		"tag A 1000 struct versioned { a string }",
+		"tag A 1000 struct versioned 2..1 { a string }",
+		"tag A 1000 struct versioned 70000 { a string }",
+		"tag A 1000 struct versioned 1. { a string }",
+		"tag A 1000 struct versioned 1 { a string @x }",
+		"tag A 1000 struct { a string",
+		"tag A 1000 struct a string }",
+		"tag A 1000 struct { 1 string }",
+		"tag A 1000 array<string",
+		"tag A 1000 array<1>",
+		"tag A 99999999999999999999999 string",
+		"tag A 1000 string 'x",
+		"tag A 1000 string $",
+	} {
+		_, err := ParseString(src)
+		assert.ErrorIs(t, err, ErrSyntax, src)
+	}
+	_, err := ParseString("\n\ntag A 1000 B")
+	assert.True(t, strings.HasPrefix(err.Error(), "line 3:"), err.Error())
+}
+
+func TestMustParse(t *testing.T) {
+	assert.NotNil(t, MustParse(sampleSchema))
+	assert.Panics(t, func() {
+		MustParse("tag")
+	})
+}
+
+func TestParseReadError(t *testing.T) {
+	_, err := Parse(&errorReader{})
+	assert.Error(t, err)
+}
+
+type errorReader struct{}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read error")
+}
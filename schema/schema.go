@@ -0,0 +1,115 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"sync"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+Schema holds the definitions of a set of custom tags. It is safe to use a
+Schema from multiple goroutines as it is never modified after being parsed.
+*/
+type Schema struct {
+	defs   []*TagDef
+	byName map[string]*TagDef
+	byId   map[tags.TagID]*TagDef
+	// Factory used to decode RawTags during the validation.
+	factoryOnce sync.Once
+	factory     *impl.StandardTagFactory
+}
+
+// Creates a new empty schema.
+func newSchema() *Schema {
+	return &Schema{
+		byName: make(map[string]*TagDef),
+		byId:   make(map[tags.TagID]*TagDef),
+	}
+}
+
+// Adds a new definition to the schema.
+func (s *Schema) add(def *TagDef) {
+	s.defs = append(s.defs, def)
+	s.byName[def.Name] = def
+	s.byId[def.Id] = def
+}
+
+// Returns the definitions of the schema in the order they were declared.
+func (s *Schema) Tags() []*TagDef {
+	return append([]*TagDef(nil), s.defs...)
+}
+
+// Returns the definition with the given name or nil if it does not exist.
+func (s *Schema) TagByName(name string) *TagDef {
+	return s.byName[name]
+}
+
+// Returns the definition with the given tag ID or nil if it does not exist.
+func (s *Schema) TagById(id tags.TagID) *TagDef {
+	return s.byId[id]
+}
+
+/*
+Registers the tags defined by this schema into the given factory. Struct
+layouts are created as StructTag instances while the other layouts use the
+implementation of their standard types.
+*/
+func (s *Schema) RegisterTags(factory *impl.StandardTagFactory) {
+	for _, def := range s.defs {
+		d := def
+		factory.RegisterTag(d.Id, func(tags.TagID) tags.ILTag {
+			return d.newTag()
+		})
+	}
+}
+
+/*
+Creates a new StandardTagFactory that is able to create all tags defined by
+this schema.
+*/
+func (s *Schema) NewFactory(strict bool) *impl.StandardTagFactory {
+	f := impl.NewStandardTagFactory(strict)
+	s.RegisterTags(f)
+	return f
+}
+
+// Returns the factory used internally by the schema.
+func (s *Schema) internalFactory() *impl.StandardTagFactory {
+	s.factoryOnce.Do(func() {
+		s.factory = s.NewFactory(false)
+	})
+	return s.factory
+}
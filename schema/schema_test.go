@@ -0,0 +1,96 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema_NewFactory(t *testing.T) {
+	s := MustParse(sampleSchema)
+
+	f := s.NewFactory(true)
+	assert.True(t, f.Strict)
+	tag, err := f.CreateTag(1000)
+	require.Nil(t, err)
+	assert.IsType(t, &impl.StringTag{}, tag)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+
+	tag, err = f.CreateTag(1001)
+	require.Nil(t, err)
+	assert.IsType(t, &impl.ILTagArrayTag{}, tag)
+
+	tag, err = f.CreateTag(1002)
+	require.Nil(t, err)
+	assert.IsType(t, &StructTag{}, tag)
+	assert.Same(t, s.TagByName("Block"), tag.(*StructTag).Def)
+
+	_, err = f.CreateTag(2000)
+	assert.Error(t, err)
+
+	f = s.NewFactory(false)
+	assert.False(t, f.Strict)
+	tag, err = f.CreateTag(2000)
+	require.Nil(t, err)
+	assert.IsType(t, &tags.RawTag{}, tag)
+}
+
+func TestSchema_RegisterTags(t *testing.T) {
+	s := MustParse(sampleSchema)
+	f := impl.NewStandardTagFactory(true)
+	f.RegisterTag(2000, func(id tags.TagID) tags.ILTag { return impl.NewStringTag(id) })
+	s.RegisterTags(f)
+
+	tag, err := f.CreateTag(2000)
+	require.Nil(t, err)
+	assert.IsType(t, &impl.StringTag{}, tag)
+	tag, err = f.CreateTag(1004)
+	require.Nil(t, err)
+	assert.IsType(t, &StructTag{}, tag)
+}
+
+func TestSchema_Tags(t *testing.T) {
+	s := MustParse(sampleSchema)
+	l := s.Tags()
+	require.Len(t, l, 5)
+	assert.Equal(t, "Name", l[0].Name)
+	assert.Equal(t, "Simple", l[4].Name)
+	// The returned list is a copy
+	l[0] = nil
+	assert.NotNil(t, s.Tags()[0])
+}
@@ -0,0 +1,211 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/direct"
+)
+
+/*
+StructTag is the generic implementation of the tags with struct layouts. The
+fields are stored in the order defined by the schema and may contain nil
+or ILNullTags.
+*/
+type StructTag struct {
+	tags.ILTagHeaderImpl
+	// The definition of the tag.
+	Def *TagDef
+	// The version of the payload. It is ignored if the tag is not versioned.
+	Version uint16
+	// The fields of the struct.
+	Fields []tags.ILTag
+}
+
+/*
+Creates a new StructTag. The version is set to the latest version supported by
+the definition and the fields are set to nil.
+*/
+func NewStructTag(def *TagDef) *StructTag {
+	t := &StructTag{Def: def}
+	t.SetId(def.Id)
+	if def.Versioned {
+		t.Version = def.Versions.Max
+	}
+	t.Fields = make([]tags.ILTag, len(def.FieldsOf(t.Version)))
+	return t
+}
+
+/*
+Returns the value of the field with the given name. It returns nil if the
+field does not exist or is not present in the current version.
+*/
+func (t *StructTag) Field(name string) tags.ILTag {
+	for i, f := range t.Def.FieldsOf(t.Version) {
+		if f.Name == name {
+			if i < len(t.Fields) {
+				return t.Fields[i]
+			}
+			break
+		}
+	}
+	return nil
+}
+
+/*
+Sets the value of the field with the given name. It returns false if the field
+does not exist or is not present in the current version.
+*/
+func (t *StructTag) SetField(name string, value tags.ILTag) bool {
+	fields := t.Def.FieldsOf(t.Version)
+	for i, f := range fields {
+		if f.Name == name {
+			if len(t.Fields) != len(fields) {
+				resized := make([]tags.ILTag, len(fields))
+				copy(resized, t.Fields)
+				t.Fields = resized
+			}
+			t.Fields[i] = value
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Implementation of tags.Container. The fields are selected by their names. Extra
+fields not present in the current version are selected by their indexes.
+*/
+func (t *StructTag) Children() []tags.ContainerChild {
+	fields := t.Def.FieldsOf(t.Version)
+	children := make([]tags.ContainerChild, len(t.Fields))
+	for i, v := range t.Fields {
+		children[i].Tag = v
+		if i < len(fields) {
+			children[i].Element = tags.KeyElement(fields[i].Name)
+		} else {
+			children[i].Element = tags.IndexElement(i)
+		}
+	}
+	return children
+}
+
+/*
+Implementation of tags.Rebuilder. The new tag has the same definition and
+version. Fields cannot be removed, but they may be replaced by nil.
+*/
+func (t *StructTag) Rebuild(children []tags.ContainerChild) (tags.ILTag, error) {
+	if len(children) != len(t.Fields) {
+		return nil, fmt.Errorf("%s requires %d fields: %w", t.Def.Name,
+			len(t.Fields), tags.ErrBadChildren)
+	}
+	n := &StructTag{Def: t.Def, Version: t.Version, Fields: make([]tags.ILTag, len(children))}
+	n.SetId(t.Id())
+	for i, c := range children {
+		n.Fields[i] = c.Tag
+	}
+	return n, nil
+}
+
+// Returns the size of the version field.
+func (t *StructTag) versionSize() uint64 {
+	switch {
+	case !t.Def.Versioned:
+		return 0
+	case t.Def.PlainVersion:
+		return 2
+	default:
+		return direct.IL_UINT16_TAG_ID_SIZE
+	}
+}
+
+// Implementation of ILTagPayload.ValueSize().
+func (t *StructTag) ValueSize() uint64 {
+	return t.versionSize() + tags.ILTagSequenceSize(t.Fields...)
+}
+
+// Implementation of ILTagPayload.SerializeValue().
+func (t *StructTag) SerializeValue(writer io.Writer) error {
+	if t.Def.Versioned {
+		var err error
+		if t.Def.PlainVersion {
+			err = serialization.WriteUInt16(writer, t.Version)
+		} else {
+			err = direct.SerializeStdUInt16Tag(t.Version, writer)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tags.ILTagSerializeTags(writer, t.Fields...)
+}
+
+// Implementation of ILTagPayload.DeserializeValue().
+func (t *StructTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	if valueSize < 0 {
+		return tags.ErrBadTagFormat
+	}
+	r := &io.LimitedReader{R: reader, N: int64(valueSize)}
+	if t.Def.Versioned {
+		var err error
+		if t.Def.PlainVersion {
+			t.Version, err = serialization.ReadUInt16(r)
+		} else {
+			t.Version, err = direct.DeserializeStdUInt16Tag(r)
+		}
+		if err != nil {
+			return fmt.Errorf("corrupted version: %w", err)
+		}
+		if !t.Def.Versions.Contains(t.Version) {
+			return fmt.Errorf("version %d of %s: %w", t.Version, t.Def.Name,
+				ErrUnsupportedVersion)
+		}
+	}
+	fields := t.Def.FieldsOf(t.Version)
+	t.Fields = make([]tags.ILTag, len(fields))
+	for i, f := range fields {
+		tag, err := tags.ILTagDeserialize(factory, r)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		t.Fields[i] = tag
+	}
+	if r.N != 0 {
+		return tags.ErrBadTagFormat
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/interlockledger/go-iltags/tagtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStructTag(t *testing.T) {
+	s := MustParse(sampleSchema)
+
+	block := NewStructTag(s.TagByName("Block"))
+	assert.Equal(t, tags.TagID(1002), block.Id())
+	assert.Equal(t, uint16(2), block.Version)
+	assert.Len(t, block.Fields, 6)
+
+	simple := NewStructTag(s.TagByName("Simple"))
+	assert.Equal(t, uint16(0), simple.Version)
+	assert.Len(t, simple.Fields, 1)
+}
+
+func TestStructTag_Field(t *testing.T) {
+	s := MustParse(sampleSchema)
+	block := NewStructTag(s.TagByName("Block"))
+
+	name := impl.NewStringTag(1000)
+	assert.True(t, block.SetField("name", name))
+	assert.Same(t, name, block.Field("name"))
+	assert.False(t, block.SetField("unknown", name))
+	assert.Nil(t, block.Field("unknown"))
+
+	// Attrs is not present in version 1
+	block.Version = 1
+	assert.Nil(t, block.Field("attrs"))
+	assert.False(t, block.SetField("attrs", impl.NewStdDictionaryTag()))
+	assert.True(t, block.SetField("any", name))
+	assert.Len(t, block.Fields, 5)
+	assert.Same(t, name, block.Field("any"))
+
+	block.Fields = nil
+	assert.Nil(t, block.Field("any"))
+}
+
+func TestStructTag_Children(t *testing.T) {
+	var _ tags.Container = (*StructTag)(nil)
+	s := MustParse(sampleSchema)
+	block := createSampleBlock(s)
+
+	children := block.Children()
+	require.Len(t, children, 6)
+	names := []string{"name", "height", "parent", "items", "attrs", "any"}
+	for i, c := range children {
+		assert.Equal(t, tags.KeyElement(names[i]), c.Element)
+		assert.True(t, block.Fields[i] == c.Tag)
+	}
+
+	// Queries select the fields by their names
+	key, err := impl.QueryAs[*impl.StringTag](block, "$.name")
+	require.Nil(t, err)
+	assert.Equal(t, "genesis", key.Payload)
+	_, err = impl.QueryOne(block, `$.attrs.a`)
+	assert.Nil(t, err)
+
+	// Extra fields are selected by their indexes
+	block.Version = 1
+	children = block.Children()
+	require.Len(t, children, 6)
+	assert.Equal(t, tags.KeyElement("any"), children[4].Element)
+	assert.Equal(t, tags.IndexElement(5), children[5].Element)
+}
+
+func TestStructTag_Rebuild(t *testing.T) {
+	var _ tags.Rebuilder = (*StructTag)(nil)
+	s := MustParse(sampleSchema)
+	block := createSampleBlock(s)
+
+	transformed, rewritten, err := impl.Transform(block, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if path.String() == "$.any" {
+			return nil, nil
+		}
+		return tag, nil
+	})
+	require.Nil(t, err)
+	require.Len(t, rewritten, 1)
+	assert.Equal(t, "$.any", rewritten[0].String())
+	n := transformed.(*StructTag)
+	assert.Same(t, block.Def, n.Def)
+	assert.Equal(t, block.Id(), n.Id())
+	assert.Equal(t, block.Version, n.Version)
+	assert.Equal(t, block.Fields[:5], n.Fields[:5])
+	assert.Nil(t, n.Fields[5])
+	assert.NotNil(t, block.Fields[5])
+
+	// Fields cannot be removed
+	_, _, err = impl.Transform(block, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if path.String() == "$.any" {
+			return nil, impl.RemoveTag
+		}
+		return tag, nil
+	})
+	assert.ErrorIs(t, err, tags.ErrBadChildren)
+	assert.EqualError(t, err, "$: Block requires 6 fields: bad container children")
+}
+
+func createSampleBlock(s *Schema) *StructTag {
+	block := NewStructTag(s.TagByName("Block"))
+	name := impl.NewStringTag(1000)
+	name.Payload = "genesis"
+	block.SetField("name", name)
+	block.SetField("height", impl.NewStdILIntTag())
+	items := impl.NewStdILTagArrayTag()
+	items.Payload = []tags.ILTag{impl.NewStdStringTag()}
+	block.SetField("items", items)
+	attrs := impl.NewStdDictionaryTag()
+	attrs.Map.Put("a", impl.NewStdBytesTag())
+	attrs.Map.Put("b", impl.NewStdNullTag())
+	block.SetField("attrs", attrs)
+	block.SetField("any", impl.NewStdBoolTag())
+	return block
+}
+
+func TestStructTagSerialization(t *testing.T) {
+	s := MustParse(sampleSchema)
+	factory := s.NewFactory(true)
+
+	for _, version := range []uint16{1, 2} {
+		block := createSampleBlock(s)
+		block.Version = version
+		if version == 1 {
+			block.Fields = append(block.Fields[:4], block.Fields[5])
+		}
+		bin, err := tags.ILTagToBytes(block)
+		require.Nil(t, err)
+		assert.Equal(t, tags.ILTagSize(block), uint64(len(bin)))
+
+		decoded, err := tags.ILTagFromBytes(factory, bin)
+		require.Nil(t, err)
+		st := decoded.(*StructTag)
+		assert.Equal(t, version, st.Version)
+		assert.Len(t, st.Fields, len(block.Fields))
+		assert.Equal(t, "genesis", st.Field("name").(*impl.StringTag).Payload)
+		assert.Equal(t, tags.IL_NULL_TAG_ID, st.Field("parent").Id())
+		assert.Nil(t, s.Validate(st))
+
+		for i := 0; i < len(bin); i++ {
+			_, err := tags.ILTagFromBytes(factory, bin[:i])
+			assert.Error(t, err)
+			assert.Error(t, tags.ILTagSeralize(block, tagtest.NewLimitedWriter(i, false)))
+		}
+	}
+}
+
+func TestStructTagPlainVersion(t *testing.T) {
+	s := MustParse(sampleSchema)
+	factory := s.NewFactory(true)
+
+	plain := NewStructTag(s.TagByName("Plain"))
+	plain.SetField("value", impl.NewStdInt16Tag())
+	bin, err := tags.ILTagToBytes(plain)
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0xf9, 0x02, 0xf8, 5, 0x00, 0x03, 4, 0, 0}, bin)
+	decoded, err := tags.ILTagFromBytes(factory, bin)
+	require.Nil(t, err)
+	assert.Equal(t, plain, decoded)
+	assert.Error(t, tags.ILTagSeralize(plain, tagtest.NewLimitedWriter(2, false)))
+
+	// Unsupported version
+	bin[5] = 4
+	_, err = tags.ILTagFromBytes(factory, bin)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	// Trailing data
+	simple := NewStructTag(s.TagByName("Simple"))
+	_, err = tags.ILTagFromBytes(factory, []byte{0xf9, 0x02, 0xf4, 3, 1, 0, 0})
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	assert.ErrorIs(t, simple.DeserializeValue(factory, -1, bytes.NewReader(nil)),
+		tags.ErrBadTagFormat)
+}
@@ -0,0 +1,245 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+Describes a standard tag type.
+*/
+type stdType struct {
+	// Name used by the schema.
+	name string
+	// Standard tag ID.
+	id tags.TagID
+	// Creates a new instance of the tag with the given ID.
+	create func(tags.TagID) tags.ILTag
+	// If true, the type accepts the definition of its element type.
+	container bool
+}
+
+// Returns the Go type of the tags of this type.
+func (t *stdType) goType() reflect.Type {
+	return reflect.TypeOf(t.create(t.id))
+}
+
+// List of the standard types.
+var stdTypes = []*stdType{
+	{"null", tags.IL_NULL_TAG_ID, impl.TagCreator(impl.NewNullTag), false},
+	{"bool", tags.IL_BOOL_TAG_ID, impl.TagCreator(impl.NewBoolTag), false},
+	{"int8", tags.IL_INT8_TAG_ID, impl.TagCreator(impl.NewInt8Tag), false},
+	{"uint8", tags.IL_UINT8_TAG_ID, impl.TagCreator(impl.NewUInt8Tag), false},
+	{"int16", tags.IL_INT16_TAG_ID, impl.TagCreator(impl.NewInt16Tag), false},
+	{"uint16", tags.IL_UINT16_TAG_ID, impl.TagCreator(impl.NewUInt16Tag), false},
+	{"int32", tags.IL_INT32_TAG_ID, impl.TagCreator(impl.NewInt32Tag), false},
+	{"uint32", tags.IL_UINT32_TAG_ID, impl.TagCreator(impl.NewUInt32Tag), false},
+	{"int64", tags.IL_INT64_TAG_ID, impl.TagCreator(impl.NewInt64Tag), false},
+	{"uint64", tags.IL_UINT64_TAG_ID, impl.TagCreator(impl.NewUInt64Tag), false},
+	{"ilint", tags.IL_ILINT_TAG_ID, impl.TagCreator(impl.NewILIntTag), false},
+	{"float32", tags.IL_BIN32_TAG_ID, impl.TagCreator(impl.NewFloat32Tag), false},
+	{"float64", tags.IL_BIN64_TAG_ID, impl.TagCreator(impl.NewFloat64Tag), false},
+	{"float128", tags.IL_BIN128_TAG_ID, impl.TagCreator(impl.NewFloat128Tag), false},
+	{"signedilint", tags.IL_SIGNED_ILINT_TAG_ID, impl.TagCreator(impl.NewSignedILIntTag), false},
+	{"bytes", tags.IL_BYTES_TAG_ID, impl.TagCreator(impl.NewBytesTag), false},
+	{"string", tags.IL_STRING_TAG_ID, impl.TagCreator(impl.NewStringTag), false},
+	{"bigint", tags.IL_BINT_TAG_ID, impl.TagCreator(impl.NewBigIntTag), false},
+	{"bigdec", tags.IL_BDEC_TAG_ID, impl.TagCreator(impl.NewBigDecTag), false},
+	{"ilintarray", tags.IL_ILINTARRAY_TAG_ID, impl.TagCreator(impl.NewILIntArrayTag), false},
+	{"array", tags.IL_ILTAGARRAY_TAG_ID, impl.TagCreator(impl.NewILTagArrayTag), true},
+	{"sequence", tags.IL_ILTAGSEQ_TAG_ID, impl.TagCreator(impl.NewILTagSequenceTag), false},
+	{"range", tags.IL_RANGE_TAG_ID, impl.TagCreator(impl.NewRangeTag), false},
+	{"version", tags.IL_VERSION_TAG_ID, impl.TagCreator(impl.NewVersionTag), false},
+	{"oid", tags.IL_OID_TAG_ID, impl.TagCreator(impl.NewOIDTag), false},
+	{"dictionary", tags.IL_DICTIONARY_TAG_ID, impl.TagCreator(impl.NewDictionaryTag), true},
+	{"stringdictionary", tags.IL_STRING_DICTIONARY_TAG_ID, impl.TagCreator(impl.NewStringDictionaryTag), false},
+}
+
+// Standard types indexed by name.
+var stdTypesByName = func() map[string]*stdType {
+	m := make(map[string]*stdType, len(stdTypes))
+	for _, t := range stdTypes {
+		m[t.name] = t
+	}
+	return m
+}()
+
+// Standard types indexed by tag ID.
+var stdTypesById = func() map[tags.TagID]*stdType {
+	m := make(map[tags.TagID]*stdType, len(stdTypes))
+	for _, t := range stdTypes {
+		m[t.id] = t
+	}
+	return m
+}()
+
+// Name of the type that accepts any tag.
+const anyTypeName = "any"
+
+/*
+Type describes the expected type of a tag.
+*/
+type Type struct {
+	// Name of the type as found in the schema.
+	Name string
+	// The definition of the tag if the type is defined by the schema.
+	Def *TagDef
+	// The type of the elements. Only array and dictionary use it.
+	Elem *Type
+	// If true, ILNullTags are also accepted.
+	Nullable bool
+	// The standard type.
+	std *stdType
+}
+
+// Returns true if this type accepts any tag.
+func (t *Type) Any() bool {
+	return t.Def == nil && t.std == nil
+}
+
+// Returns the tag ID of this type. It returns false if the type accepts any tag.
+func (t *Type) Id() (tags.TagID, bool) {
+	switch {
+	case t.Def != nil:
+		return t.Def.Id, true
+	case t.std != nil:
+		return t.std.id, true
+	default:
+		return 0, false
+	}
+}
+
+// Returns the representation of this type in the schema syntax.
+func (t *Type) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s += "<" + t.Elem.String() + ">"
+	}
+	if t.Nullable {
+		s += "?"
+	}
+	return s
+}
+
+/*
+VersionRange describes a range of versions. Max is inclusive.
+*/
+type VersionRange struct {
+	Min uint16
+	Max uint16
+}
+
+// Returns true if the version is inside the range.
+func (r VersionRange) Contains(version uint16) bool {
+	return version >= r.Min && version <= r.Max
+}
+
+// Returns the representation of this range in the schema syntax.
+func (r VersionRange) String() string {
+	switch {
+	case r.Min == r.Max:
+		return fmt.Sprint(r.Min)
+	case r.Max == 0xFFFF:
+		return fmt.Sprintf("%d..", r.Min)
+	default:
+		return fmt.Sprintf("%d..%d", r.Min, r.Max)
+	}
+}
+
+// The range that contains all versions.
+var allVersions = VersionRange{0, 0xFFFF}
+
+/*
+FieldDef describes a field of a struct.
+*/
+type FieldDef struct {
+	// Name of the field.
+	Name string
+	// Type of the field.
+	Type *Type
+	// Versions that contain this field.
+	Versions VersionRange
+}
+
+/*
+TagDef describes a tag defined by the schema.
+*/
+type TagDef struct {
+	// Name of the tag.
+	Name string
+	// Tag ID.
+	Id tags.TagID
+	// The standard type used as the layout of the tag. It is nil if the tag is
+	// a struct.
+	Alias *Type
+	// If true, the struct payload starts with its version.
+	Versioned bool
+	// If true, the version is encoded as a raw uint16 instead of a UInt16Tag.
+	PlainVersion bool
+	// Versions supported by the struct.
+	Versions VersionRange
+	// Fields of the struct.
+	Fields []*FieldDef
+}
+
+// Returns true if the definition describes a struct.
+func (d *TagDef) Struct() bool {
+	return d.Alias == nil
+}
+
+// Returns the fields present in the given version.
+func (d *TagDef) FieldsOf(version uint16) []*FieldDef {
+	if !d.Versioned {
+		return d.Fields
+	}
+	ret := make([]*FieldDef, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		if f.Versions.Contains(version) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// Creates a new instance of the tag described by this definition.
+func (d *TagDef) newTag() tags.ILTag {
+	if d.Struct() {
+		return NewStructTag(d)
+	}
+	return d.Alias.std.create(d.Id)
+}
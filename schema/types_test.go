@@ -0,0 +1,86 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdTypes(t *testing.T) {
+	assert.Len(t, stdTypesByName, len(stdTypes))
+	assert.Len(t, stdTypesById, len(stdTypes))
+	for _, std := range stdTypes {
+		tag := std.create(std.id)
+		assert.Equal(t, std.id, tag.Id(), std.name)
+		assert.Equal(t, reflect.TypeOf(tag), std.goType())
+	}
+}
+
+func TestType(t *testing.T) {
+	a := &Type{Name: "any"}
+	assert.True(t, a.Any())
+	_, ok := a.Id()
+	assert.False(t, ok)
+
+	s := &Type{Name: "string", std: stdTypesByName["string"], Nullable: true}
+	assert.False(t, s.Any())
+	id, ok := s.Id()
+	assert.True(t, ok)
+	assert.Equal(t, tags.IL_STRING_TAG_ID, id)
+	assert.Equal(t, "string?", s.String())
+
+	d := &Type{Name: "A", Def: &TagDef{Name: "A", Id: 1000}}
+	id, ok = d.Id()
+	assert.True(t, ok)
+	assert.Equal(t, tags.TagID(1000), id)
+
+	arr := &Type{Name: "array", std: stdTypesByName["array"], Elem: d}
+	assert.Equal(t, "array<A>", arr.String())
+}
+
+func TestVersionRange(t *testing.T) {
+	r := VersionRange{1, 3}
+	assert.False(t, r.Contains(0))
+	assert.True(t, r.Contains(1))
+	assert.True(t, r.Contains(3))
+	assert.False(t, r.Contains(4))
+	assert.Equal(t, "1..3", r.String())
+	assert.Equal(t, "2", VersionRange{2, 2}.String())
+	assert.Equal(t, "2..", VersionRange{2, 0xFFFF}.String())
+	assert.True(t, allVersions.Contains(0))
+	assert.True(t, allVersions.Contains(0xFFFF))
+}
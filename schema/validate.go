@@ -0,0 +1,203 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// Path of the root tag.
+const rootPath = "$"
+
+// Returns the name of the type that uses the given ID.
+func (s *Schema) describeId(id tags.TagID) string {
+	if def := s.byId[id]; def != nil {
+		return fmt.Sprintf("%s (%d)", def.Name, id)
+	}
+	if std := stdTypesById[id]; std != nil {
+		return fmt.Sprintf("%s (%d)", std.name, id)
+	}
+	return fmt.Sprintf("tag %d", id)
+}
+
+// Returns true if the tag represents a null value.
+func isNull(tag tags.ILTag) bool {
+	return tags.IsILTagNil(tag) || tag.Id() == tags.IL_NULL_TAG_ID
+}
+
+/*
+Validates the given tag tree against this schema. It returns nil if the tag is
+valid or a *ValidationError that describes the first problem found.
+
+The root tag may be any tag. Tags defined by the schema are checked against
+their definitions while the contents of the standard containers are checked
+recursively. Tags unknown to the schema are accepted as they are.
+
+Tags defined by the schema that were decoded as RawTags, usually because they
+were created by a factory that does not know them, are decoded on the fly.
+*/
+func (s *Schema) Validate(tag tags.ILTag) error {
+	if tags.IsILTagNil(tag) {
+		return newValidationError(rootPath, ErrNullNotAllowed)
+	}
+	return s.validateAny(rootPath, tag)
+}
+
+/*
+Validates the given tag against the definition with the given name.
+*/
+func (s *Schema) ValidateAs(name string, tag tags.ILTag) error {
+	def := s.byName[name]
+	if def == nil {
+		return fmt.Errorf("unknown tag %s: %w", name, ErrTypeMismatch)
+	}
+	return s.validate(rootPath, tag, &Type{Name: name, Def: def})
+}
+
+// Validates a tag against the expected type.
+func (s *Schema) validate(path string, tag tags.ILTag, t *Type) error {
+	if isNull(tag) {
+		if t.Nullable || t.Any() || (t.std != nil && t.std.id == tags.IL_NULL_TAG_ID) {
+			return nil
+		}
+		return newValidationError(path, ErrNullNotAllowed)
+	}
+	id, ok := t.Id()
+	if !ok {
+		return s.validateAny(path, tag)
+	}
+	if tag.Id() != id {
+		return newValidationError(path, fmt.Errorf("expected %s, found %s: %w",
+			s.describeId(id), s.describeId(tag.Id()), ErrTypeMismatch))
+	}
+	if t.Def != nil {
+		return s.validateDef(path, tag, t.Def)
+	}
+	return s.validateStd(path, tag, t.std, t.Elem)
+}
+
+// Validates a tag of unknown type.
+func (s *Schema) validateAny(path string, tag tags.ILTag) error {
+	if def := s.byId[tag.Id()]; def != nil {
+		return s.validateDef(path, tag, def)
+	}
+	if std := stdTypesById[tag.Id()]; std != nil {
+		return s.validateStd(path, tag, std, nil)
+	}
+	return nil
+}
+
+/*
+Converts RawTags into the tag implementation defined by the schema.
+*/
+func (s *Schema) materialize(tag tags.ILTag, def *TagDef) (tags.ILTag, error) {
+	raw, ok := tag.(*tags.RawTag)
+	if !ok || (!def.Struct() && def.Alias.std.id == tags.IL_BYTES_TAG_ID) {
+		return tag, nil
+	}
+	t := def.newTag()
+	if err := t.DeserializeValue(s.internalFactory(), len(raw.Payload),
+		bytes.NewReader(raw.Payload)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Validates a tag defined by the schema.
+func (s *Schema) validateDef(path string, tag tags.ILTag, def *TagDef) error {
+	tag, err := s.materialize(tag, def)
+	if err != nil {
+		return newValidationError(path, err)
+	}
+	if !def.Struct() {
+		return s.validateStd(path, tag, def.Alias.std, def.Alias.Elem)
+	}
+	st, ok := tag.(*StructTag)
+	if !ok {
+		return newValidationError(path, fmt.Errorf("%s is not a struct: %w",
+			reflect.TypeOf(tag), ErrTypeMismatch))
+	}
+	version := st.Version
+	if def.Versioned && !def.Versions.Contains(version) {
+		return newValidationError(path, fmt.Errorf("version %d of %s: %w",
+			version, def.Name, ErrUnsupportedVersion))
+	}
+	fields := def.FieldsOf(version)
+	if len(fields) != len(st.Fields) {
+		return newValidationError(path, fmt.Errorf("expected %d, found %d: %w",
+			len(fields), len(st.Fields), ErrFieldCount))
+	}
+	for i, f := range fields {
+		if err := s.validate(path+"."+f.Name, st.Fields[i], f.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validates a tag that uses a standard layout.
+func (s *Schema) validateStd(path string, tag tags.ILTag, std *stdType, elem *Type) error {
+	if reflect.TypeOf(tag) != std.goType() {
+		return newValidationError(path, fmt.Errorf("expected %s payload, found %s: %w",
+			std.name, reflect.TypeOf(tag), ErrTypeMismatch))
+	}
+	if elem == nil {
+		elem = &Type{Name: anyTypeName}
+	}
+	switch t := tag.(type) {
+	case *impl.ILTagArrayTag:
+		for i, e := range t.Payload {
+			if err := s.validate(fmt.Sprintf("%s[%d]", path, i), e, elem); err != nil {
+				return err
+			}
+		}
+	case *impl.ILTagSequenceTag:
+		for i, e := range t.Payload {
+			if err := s.validate(fmt.Sprintf("%s[%d]", path, i), e, elem); err != nil {
+				return err
+			}
+		}
+	case *impl.DictionaryTag:
+		for _, e := range t.Map.Entries() {
+			if err := s.validate(fmt.Sprintf("%s[%q]", path, e.Key), e.Value, elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertValidationError(t *testing.T, err error, path string, target error) {
+	var v *ValidationError
+	require.True(t, errors.As(err, &v), "%v", err)
+	assert.Equal(t, path, v.Path)
+	assert.ErrorIs(t, err, target)
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s := MustParse(sampleSchema)
+
+	block := createSampleBlock(s)
+	assert.Nil(t, s.Validate(block))
+	assert.Nil(t, s.ValidateAs("Block", block))
+
+	// Unknown and standard tags
+	assert.Nil(t, s.Validate(tags.NewRawTag(2000)))
+	assert.Nil(t, s.Validate(impl.NewStdStringTag()))
+	assertValidationError(t, s.Validate(nil), "$", ErrNullNotAllowed)
+
+	// Nested blocks
+	parent := createSampleBlock(s)
+	block.SetField("parent", parent)
+	assert.Nil(t, s.Validate(block))
+	parent.SetField("name", impl.NewStdStringTag())
+	assertValidationError(t, s.Validate(block), "$.parent.name", ErrTypeMismatch)
+	assert.Contains(t, s.Validate(block).Error(), "expected Name (1000), found string (17)")
+	parent.SetField("name", nil)
+	assertValidationError(t, s.Validate(block), "$.parent.name", ErrNullNotAllowed)
+
+	// Arrays
+	block = createSampleBlock(s)
+	items := block.Field("items").(*impl.ILTagArrayTag)
+	items.Payload = append(items.Payload, impl.NewStdILIntTag())
+	assertValidationError(t, s.Validate(block), "$.items[1]", ErrTypeMismatch)
+	block.SetField("items", impl.NewStdILTagSequenceTag())
+	assertValidationError(t, s.Validate(block), "$.items", ErrTypeMismatch)
+	block.SetField("items", impl.NewILTagSequenceTag(tags.IL_ILTAGARRAY_TAG_ID))
+	assertValidationError(t, s.Validate(block), "$.items", ErrTypeMismatch)
+
+	// Dictionaries
+	block = createSampleBlock(s)
+	attrs := block.Field("attrs").(*impl.DictionaryTag)
+	attrs.Map.Put("c", impl.NewStdStringTag())
+	assertValidationError(t, s.Validate(block), `$.attrs["c"]`, ErrTypeMismatch)
+	block.SetField("attrs", nil)
+	assert.Nil(t, s.Validate(block))
+
+	// Field count and versions
+	block = createSampleBlock(s)
+	block.Fields = block.Fields[:5]
+	assertValidationError(t, s.Validate(block), "$", ErrFieldCount)
+	block.Version = 3
+	assertValidationError(t, s.Validate(block), "$", ErrUnsupportedVersion)
+
+	// Any accepts anything but validates known tags
+	block = createSampleBlock(s)
+	block.SetField("any", nil)
+	assert.Nil(t, s.Validate(block))
+	seq := impl.NewStdILTagSequenceTag()
+	seq.Payload = []tags.ILTag{impl.NewStdNullTag(), impl.NewStringTag(1000), impl.NewStdStringTag()}
+	block.SetField("any", seq)
+	assert.Nil(t, s.Validate(block))
+	seq.Payload[1] = impl.NewBoolTag(1000)
+	assertValidationError(t, s.Validate(block), "$.any[1]", ErrTypeMismatch)
+
+	// Struct with the wrong implementation
+	assertValidationError(t, s.ValidateAs("Simple", impl.NewBoolTag(1004)), "$", ErrTypeMismatch)
+	assert.ErrorIs(t, s.ValidateAs("Unknown", block), ErrTypeMismatch)
+}
+
+func TestSchema_ValidateAlias(t *testing.T) {
+	s := MustParse(sampleSchema)
+
+	names := impl.NewILTagArrayTag(1001)
+	names.Payload = []tags.ILTag{impl.NewStringTag(1000), impl.NewStringTag(1000)}
+	assert.Nil(t, s.Validate(names))
+	names.Payload[1] = impl.NewStdStringTag()
+	assertValidationError(t, s.Validate(names), "$[1]", ErrTypeMismatch)
+
+	assert.Nil(t, MustParse("tag Blob 1000 bytes").Validate(impl.NewBytesTag(1000)))
+}
+
+func TestSchema_ValidateRawTags(t *testing.T) {
+	s := MustParse(sampleSchema)
+	block := createSampleBlock(s)
+	bin, err := tags.ILTagToBytes(block)
+	require.Nil(t, err)
+
+	// Decoded with a factory that does not know the schema
+	decoded, err := tags.ILTagFromBytes(impl.NewStandardTagFactory(false), bin)
+	require.Nil(t, err)
+	assert.IsType(t, &tags.RawTag{}, decoded)
+	assert.Nil(t, s.Validate(decoded))
+
+	// Corrupted payload
+	raw := decoded.(*tags.RawTag)
+	raw.Payload = raw.Payload[:len(raw.Payload)-1]
+	err = s.Validate(raw)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$: field any")
+
+	// Wrong payload inside a field
+	block = createSampleBlock(s)
+	block.SetField("name", tags.NewRawTag(1000))
+	assert.Nil(t, s.Validate(block))
+	name := tags.NewRawTag(1000)
+	name.Payload = []byte{0xff}
+	block.SetField("name", name)
+	err = s.Validate(block)
+	assert.Error(t, err)
+	assert.Equal(t, "$.name", err.(*ValidationError).Path)
+}
+
+func TestValidationError(t *testing.T) {
+	err := newValidationError("$.a", ErrTypeMismatch)
+	assert.Equal(t, "$.a: type mismatch", err.Error())
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.Same(t, err, newValidationError("$", err))
+}
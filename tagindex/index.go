@@ -0,0 +1,205 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagindex
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// The tag does not exist in the index.
+var ErrOutOfRange = fmt.Errorf("tag index out of range")
+
+/*
+Entry describes a single top-level tag.
+*/
+type Entry struct {
+	// Offset of the tag.
+	Offset int64
+	// ID of the tag.
+	Id tags.TagID
+	// Size of the tag, including its header.
+	Size uint64
+}
+
+/*
+Index is the list of the top-level tags found in a file. Once built, it is safe
+to use it from multiple goroutines.
+*/
+type Index struct {
+	entries []Entry
+	byId    map[tags.TagID][]int
+}
+
+// Creates a new empty index.
+func newIndex(capacity int) *Index {
+	return &Index{
+		entries: make([]Entry, 0, capacity),
+		byId:    make(map[tags.TagID][]int, 16),
+	}
+}
+
+// Adds a new entry to the index.
+func (idx *Index) add(id tags.TagID, size uint64) {
+	var offset int64
+	if n := len(idx.entries); n > 0 {
+		offset = idx.entries[n-1].Offset + int64(idx.entries[n-1].Size)
+	}
+	idx.byId[id] = append(idx.byId[id], len(idx.entries))
+	idx.entries = append(idx.entries, Entry{Offset: offset, Id: id, Size: size})
+}
+
+/*
+Computes the ID and the total size of the tag from the beginning of its
+serialization. available is the number of bytes available for the tag.
+*/
+func parseHeader(b []byte, available int64) (tags.TagID, uint64, error) {
+	id, headerSize, payloadSize, err := tags.ParseTagHeader(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	if payloadSize > uint64(available) || uint64(headerSize)+payloadSize > uint64(available) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	return id, uint64(headerSize) + payloadSize, nil
+}
+
+/*
+Builds the index of the tags stored in the first size bytes of the reader. Only
+the headers of the tags are read.
+
+It fails with io.ErrUnexpectedEOF if the last tag ends after size. The error
+reports the offset of the tag that could not be indexed.
+*/
+func Build(reader io.ReaderAt, size int64) (*Index, error) {
+	idx := newIndex(1024)
+	var buff [tags.MAX_TAG_HEADER_SIZE]byte
+	for offset := int64(0); offset < size; {
+		b := buff[:]
+		if size-offset < int64(len(b)) {
+			b = b[:size-offset]
+		}
+		n, err := reader.ReadAt(b, offset)
+		if n < len(b) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("offset %d: %w", offset, err)
+		}
+		id, tagSize, err := parseHeader(b, size-offset)
+		if err != nil {
+			return nil, fmt.Errorf("offset %d: %w", offset, err)
+		}
+		idx.add(id, tagSize)
+		offset += int64(tagSize)
+	}
+	return idx, nil
+}
+
+// Returns the number of tags in the index.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+/*
+Returns the total size of the indexed tags. It is also the offset right after
+the last tag.
+*/
+func (idx *Index) Size() int64 {
+	if n := len(idx.entries); n > 0 {
+		return idx.entries[n-1].Offset + int64(idx.entries[n-1].Size)
+	}
+	return 0
+}
+
+// Returns the entry of the n-th tag. It panics if n is out of range.
+func (idx *Index) Entry(n int) Entry {
+	return idx.entries[n]
+}
+
+/*
+Returns the positions in the index of the tags with the given ID, in order.
+The returned slice must not be modified.
+*/
+func (idx *Index) Find(id tags.TagID) []int {
+	return idx.byId[id]
+}
+
+/*
+Reads the n-th tag from the reader. The factory is used to create the tag. If
+nil, a non strict StandardTagFactory is used.
+*/
+func (idx *Index) ReadTag(reader io.ReaderAt, factory tags.ILTagFactory, n int) (tags.ILTag, error) {
+	if n < 0 || n >= len(idx.entries) {
+		return nil, fmt.Errorf("%d: %w", n, ErrOutOfRange)
+	}
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	e := idx.entries[n]
+	if e.Size > tags.MAX_TAG_SIZE+tags.MAX_TAG_HEADER_SIZE {
+		return nil, fmt.Errorf("tag %d at offset %d: %w", n, e.Offset, tags.ErrTagTooLarge)
+	}
+	b := make([]byte, e.Size)
+	if _, err := reader.ReadAt(b, e.Offset); err != nil {
+		return nil, fmt.Errorf("tag %d at offset %d: %w", n, e.Offset, err)
+	}
+	tag, err := tags.ILTagFromBytes(factory, b)
+	if err != nil {
+		return nil, fmt.Errorf("tag %d at offset %d: %w", n, e.Offset, err)
+	}
+	return tag, nil
+}
+
+/*
+Reads all tags with the given ID from the reader, in order. The factory is used
+to create the tags. If nil, a non strict StandardTagFactory is used.
+*/
+func (idx *Index) ReadTags(reader io.ReaderAt, factory tags.ILTagFactory, id tags.TagID) ([]tags.ILTag, error) {
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	positions := idx.byId[id]
+	list := make([]tags.ILTag, 0, len(positions))
+	for _, n := range positions {
+		tag, err := idx.ReadTag(reader, factory, n)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, tag)
+	}
+	return list, nil
+}
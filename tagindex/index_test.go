@@ -0,0 +1,178 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagindex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var indexSamples = []string{"null", "ilint(1000)", "silint(-1)", `str("hello")`,
+	`#1000 bytes(0x0102)`, "f128(0x000102030405060708090A0B0C0D0E0F)", `#1000 bytes()`,
+	`array[u8(1), str("")]`, "u64(1)"}
+
+// Concatenates the serialization of the tags.
+func sample(notation ...string) []byte {
+	var b []byte
+	for _, n := range notation {
+		b = append(b, impl.MustParseTagBytes(n)...)
+	}
+	return b
+}
+
+func TestBuild(t *testing.T) {
+	data := sample(indexSamples...)
+	idx, err := Build(bytes.NewReader(data), int64(len(data)))
+	require.Nil(t, err)
+	assert.Equal(t, len(indexSamples), idx.Len())
+	assert.Equal(t, int64(len(data)), idx.Size())
+
+	var offset int64
+	for i, s := range indexSamples {
+		tag := impl.MustParseTag(s)
+		e := idx.Entry(i)
+		assert.Equal(t, Entry{Offset: offset, Id: tag.Id(), Size: tags.ILTagSize(tag)}, e, s)
+		offset += int64(e.Size)
+	}
+	assert.Equal(t, []int{4, 6}, idx.Find(1000))
+	assert.Equal(t, []int{0}, idx.Find(tags.IL_NULL_TAG_ID))
+	assert.Empty(t, idx.Find(1001))
+
+	// Only the given size is indexed
+	idx, err = Build(bytes.NewReader(data), 1)
+	require.Nil(t, err)
+	assert.Equal(t, 1, idx.Len())
+
+	// Empty
+	idx, err = Build(bytes.NewReader(nil), 0)
+	require.Nil(t, err)
+	assert.Equal(t, 0, idx.Len())
+	assert.Equal(t, int64(0), idx.Size())
+}
+
+func TestBuild_Errors(t *testing.T) {
+	data := sample(indexSamples...)
+	last := int64(len(data) - len(impl.MustParseTagBytes(indexSamples[len(indexSamples)-1])))
+	for i := 1; i < len(impl.MustParseTagBytes(indexSamples[len(indexSamples)-1])); i++ {
+		_, err := Build(bytes.NewReader(data), int64(len(data)-i))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Contains(t, err.Error(), fmt.Sprintf("offset %d:", last))
+	}
+
+	samples := []struct {
+		data     []byte
+		expected error
+	}{
+		{[]byte{0x0f}, tags.ErrUnsupportedTagId},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat},
+		{[]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat},
+		{[]byte{0x10, 0xf8}, io.ErrUnexpectedEOF},
+		{[]byte{0x10, 0x02, 0x00}, io.ErrUnexpectedEOF},
+		{[]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, io.ErrUnexpectedEOF},
+		{[]byte{0x0a}, io.ErrUnexpectedEOF},
+		{[]byte{0xf8}, io.ErrUnexpectedEOF},
+		{[]byte{0x05, 0x00}, io.ErrUnexpectedEOF},
+	}
+	for _, s := range samples {
+		_, err := Build(bytes.NewReader(s.data), int64(len(s.data)))
+		assert.ErrorIs(t, err, s.expected, "%x", s.data)
+		assert.Contains(t, err.Error(), "offset 0:", "%x", s.data)
+	}
+
+	// Reader errors
+	_, err := Build(bytes.NewReader(data), int64(len(data)+1))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = Build(errReaderAt{}, 10)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+// Implementation of io.ReaderAt that always fails.
+type errReaderAt struct{}
+
+func (errReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestIndex_ReadTag(t *testing.T) {
+	data := sample(indexSamples...)
+	reader := bytes.NewReader(data)
+	idx, err := Build(reader, int64(len(data)))
+	require.Nil(t, err)
+	for i, s := range indexSamples {
+		tag, err := idx.ReadTag(reader, nil, i)
+		require.Nil(t, err)
+		assert.Equal(t, s, impl.FormatTag(tag))
+	}
+
+	for _, n := range []int{-1, len(indexSamples)} {
+		_, err = idx.ReadTag(reader, nil, n)
+		assert.ErrorIs(t, err, ErrOutOfRange)
+	}
+	_, err = idx.ReadTag(reader, impl.NewStandardTagFactory(true), 4)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+	assert.Contains(t, err.Error(), "tag 4 at offset 14:")
+	_, err = idx.ReadTag(errReaderAt{}, nil, 4)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	large := newIndex(1)
+	large.add(1000, tags.MAX_TAG_SIZE+tags.MAX_TAG_HEADER_SIZE+1)
+	_, err = large.ReadTag(reader, nil, 0)
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+}
+
+func TestIndex_ReadTags(t *testing.T) {
+	data := sample(indexSamples...)
+	reader := bytes.NewReader(data)
+	idx, err := Build(reader, int64(len(data)))
+	require.Nil(t, err)
+
+	l, err := idx.ReadTags(reader, nil, 1000)
+	require.Nil(t, err)
+	require.Len(t, l, 2)
+	assert.Equal(t, "#1000 bytes(0x0102)", impl.FormatTag(l[0]))
+	assert.Equal(t, "#1000 bytes()", impl.FormatTag(l[1]))
+
+	l, err = idx.ReadTags(reader, nil, 1001)
+	require.Nil(t, err)
+	assert.Empty(t, l)
+
+	_, err = idx.ReadTags(reader, impl.NewStandardTagFactory(true), 1000)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+}
@@ -0,0 +1,82 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagindex
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/interlockledger/go-iltags/ilint"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+Converts the index into an ILIntArrayTag with the given ID. The array holds the
+ID and the size of each tag, in order:
+
+	[id0, size0, id1, size1, ...]
+
+The offsets are not stored since the tags are contiguous.
+*/
+func (idx *Index) ToTag(id tags.TagID) *impl.ILIntArrayTag {
+	t := impl.NewILIntArrayTag(id)
+	t.Payload = make([]uint64, 0, 2*len(idx.entries))
+	for _, e := range idx.entries {
+		t.Payload = append(t.Payload, e.Id.UInt64(), e.Size)
+	}
+	return t
+}
+
+/*
+Restores the index stored in the given tag by Index.ToTag(). It fails with
+tags.ErrBadTagFormat if the contents of the tag are not a valid index.
+*/
+func FromTag(tag *impl.ILIntArrayTag) (*Index, error) {
+	if len(tag.Payload)%2 != 0 {
+		return nil, fmt.Errorf("odd number of values: %w", tags.ErrBadTagFormat)
+	}
+	idx := newIndex(len(tag.Payload) / 2)
+	var offset uint64
+	for i := 0; i < len(tag.Payload); i += 2 {
+		id := tags.TagID(tag.Payload[i])
+		size := tag.Payload[i+1]
+		if size < uint64(ilint.EncodedSize(id.UInt64())) || offset+size < offset ||
+			offset+size > math.MaxInt64 {
+			return nil, fmt.Errorf("bad size of tag %d: %w", i/2, tags.ErrBadTagFormat)
+		}
+		idx.add(id, size)
+		offset += size
+	}
+	return idx, nil
+}
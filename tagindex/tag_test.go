@@ -0,0 +1,88 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagindex
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_ToTag(t *testing.T) {
+	data := sample(`str("a")`, "null", `#1000 bytes(0x0102)`)
+	idx, err := Build(bytes.NewReader(data), int64(len(data)))
+	require.Nil(t, err)
+
+	tag := idx.ToTag(2000)
+	assert.Equal(t, tags.TagID(2000), tag.Id())
+	assert.Equal(t, []uint64{17, 3, 0, 1, 1000, 6}, tag.Payload)
+
+	// Round trip
+	b, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	decoded := impl.NewILIntArrayTag(2000)
+	require.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(b), decoded))
+	restored, err := FromTag(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, idx.entries, restored.entries)
+	assert.Equal(t, idx.byId, restored.byId)
+	read, err := restored.ReadTag(bytes.NewReader(data), nil, 2)
+	require.Nil(t, err)
+	assert.Equal(t, "#1000 bytes(0x0102)", impl.FormatTag(read))
+
+	// Empty
+	tag = newIndex(0).ToTag(2000)
+	assert.Empty(t, tag.Payload)
+	restored, err = FromTag(tag)
+	require.Nil(t, err)
+	assert.Equal(t, 0, restored.Len())
+}
+
+func TestFromTag_Errors(t *testing.T) {
+	for _, payload := range [][]uint64{
+		{17},
+		{17, 0},
+		{1000, 1},
+		{17, 3, 17, 0xFFFF_FFFF_FFFF_FFFF},
+		{17, 0x7FFF_FFFF_FFFF_FFFF, 17, 2},
+	} {
+		tag := impl.NewStdILIntArrayTag()
+		tag.Payload = payload
+		_, err := FromTag(tag)
+		assert.ErrorIs(t, err, tags.ErrBadTagFormat, payload)
+	}
+}
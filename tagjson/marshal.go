@@ -0,0 +1,311 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+JSON representation of a tag.
+*/
+type node struct {
+	TagId uint64 `json:"tagId"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+/*
+JSON representation of a dictionary entry.
+*/
+type entry struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+/*
+JSON representation of the RangeTag payload.
+*/
+type rangeValue struct {
+	Start uint64 `json:"start"`
+	Count uint16 `json:"count"`
+}
+
+/*
+JSON representation of the VersionTag payload.
+*/
+type versionValue struct {
+	Major    int32 `json:"major"`
+	Minor    int32 `json:"minor"`
+	Revision int32 `json:"revision"`
+	Build    int32 `json:"build"`
+}
+
+/*
+Prefix of the hexadecimal representations of the NaN bits and of the payloads
+of big integers that are not in their minimal form.
+*/
+const hexPrefix = "0x"
+
+// Bits of the NaNs created when "NaN" is decoded.
+var (
+	nan32Bits = math.Float32bits(float32(math.NaN()))
+	nan64Bits = math.Float64bits(math.NaN())
+)
+
+// Returns the JSON representation of a floating point value.
+func floatValue(v float64, bits int) any {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, bits))
+	}
+}
+
+/*
+Returns the JSON representation of a float32. NaNs other than the one produced
+by the decoder are represented by their bits in order to preserve them.
+*/
+func float32Value(v float32) any {
+	if bits := math.Float32bits(v); v != v && bits != nan32Bits {
+		return fmt.Sprintf("%s%08x", hexPrefix, bits)
+	}
+	return floatValue(float64(v), 32)
+}
+
+/*
+Returns the JSON representation of a float64. NaNs other than the one produced
+by the decoder are represented by their bits in order to preserve them.
+*/
+func float64Value(v float64) any {
+	if bits := math.Float64bits(v); v != v && bits != nan64Bits {
+		return fmt.Sprintf("%s%016x", hexPrefix, bits)
+	}
+	return floatValue(v, 64)
+}
+
+/*
+Returns true if the payload of the big integer is the minimal two's complement
+representation of its value, which is the one restored from its decimal form.
+*/
+func isMinimalBigInt(p *impl.BigIntPayload) bool {
+	var m impl.BigIntPayload
+	m.SetBigInt(p.BigInt())
+	if len(p.Payload) == 0 {
+		// Empty payloads are serialized as zero
+		return bytes.Equal(m.Payload, []byte{0})
+	}
+	return bytes.Equal(m.Payload, p.Payload)
+}
+
+/*
+Formats a big decimal. Positive scales are represented by the position of the
+decimal point while negative scales are represented by the exponent.
+*/
+func formatBigDec(unscaled *big.Int, scale int32) string {
+	s := unscaled.String()
+	if scale <= 0 {
+		if scale == 0 {
+			return s
+		}
+		return s + "E" + strconv.FormatInt(-int64(scale), 10)
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+	if n := int(scale) + 1 - len(s); n > 0 {
+		s = strings.Repeat("0", n) + s
+	}
+	p := len(s) - int(scale)
+	return sign + s[:p] + "." + s[p:]
+}
+
+// Returns the JSON representation of the given list of tags.
+func toNodes(list []tags.ILTag) ([]*node, error) {
+	nodes := make([]*node, len(list))
+	for i, t := range list {
+		n, err := toNode(t)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+/*
+Returns the JSON representation of the given tag.
+*/
+func toNode(tag tags.ILTag) (*node, error) {
+	if tags.IsILTagNil(tag) {
+		return &node{TagId: tags.IL_NULL_TAG_ID.UInt64(), Type: typeNull}, nil
+	}
+	n := &node{TagId: tag.Id().UInt64(), Type: typeOf(tag)}
+	switch t := tag.(type) {
+	case *impl.NullTag:
+		n.Value = nil
+	case *impl.BoolTag:
+		n.Value = t.Payload
+	case *impl.Int8Tag:
+		n.Value = t.Payload
+	case *impl.UInt8Tag:
+		n.Value = t.Payload
+	case *impl.Int16Tag:
+		n.Value = t.Payload
+	case *impl.UInt16Tag:
+		n.Value = t.Payload
+	case *impl.Int32Tag:
+		n.Value = t.Payload
+	case *impl.UInt32Tag:
+		n.Value = t.Payload
+	case *impl.Int64Tag:
+		n.Value = t.Payload
+	case *impl.UInt64Tag:
+		n.Value = t.Payload
+	case *impl.ILIntTag:
+		n.Value = t.Payload
+	case *impl.SignedILIntTag:
+		n.Value = t.Payload
+	case *impl.Float32Tag:
+		n.Value = float32Value(t.Payload)
+	case *impl.Float64Tag:
+		n.Value = float64Value(t.Payload)
+	case *impl.Float128Tag:
+		n.Value = hex.EncodeToString(t.Payload[:])
+	case *tags.RawTag:
+		n.Value = base64.StdEncoding.EncodeToString(t.Payload)
+	case *impl.StringTag:
+		n.Value = t.Payload
+	case *impl.BigIntTag:
+		if isMinimalBigInt(&t.BigIntPayload) {
+			n.Value = t.BigInt().String()
+		} else {
+			n.Value = hexPrefix + hex.EncodeToString(t.Payload)
+		}
+	case *impl.BigDecTag:
+		if isMinimalBigInt(&t.BigIntPayload) {
+			n.Value = formatBigDec(t.BigInt(), t.Scale)
+		} else {
+			n.Value = hexPrefix + hex.EncodeToString(t.Payload) + ":" +
+				strconv.FormatInt(int64(t.Scale), 10)
+		}
+	case *impl.ILIntArrayTag:
+		if t.Payload == nil {
+			n.Value = []uint64{}
+		} else {
+			n.Value = t.Payload
+		}
+	case *impl.ILTagArrayTag:
+		nodes, err := toNodes(t.Payload)
+		if err != nil {
+			return nil, err
+		}
+		n.Value = nodes
+	case *impl.ILTagSequenceTag:
+		nodes, err := toNodes(t.Payload)
+		if err != nil {
+			return nil, err
+		}
+		n.Value = nodes
+	case *impl.RangeTag:
+		n.Value = rangeValue{Start: t.Start, Count: t.Count}
+	case *impl.VersionTag:
+		n.Value = versionValue{Major: t.Major, Minor: t.Minor,
+			Revision: t.Revision, Build: t.Build}
+	case *impl.DictionaryTag:
+		entries := make([]entry, 0, t.Map.Size())
+		for _, e := range t.Map.Entries() {
+			v, err := toNode(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{Key: e.Key, Value: v})
+		}
+		n.Value = entries
+	case *impl.StringDictionaryTag:
+		entries := make([]entry, 0, t.Map.Size())
+		for _, e := range t.Map.Entries() {
+			entries = append(entries, entry{Key: e.Key, Value: e.Value})
+		}
+		n.Value = entries
+	default:
+		// Unknown implementations are represented by their payloads
+		var b bytes.Buffer
+		if err := tag.SerializeValue(&b); err != nil {
+			return nil, err
+		}
+		n.Value = base64.StdEncoding.EncodeToString(b.Bytes())
+	}
+	return n, nil
+}
+
+/*
+Returns the JSON representation of the given tag. A nil tag is represented as
+an ILNullTag.
+*/
+func Marshal(tag tags.ILTag) ([]byte, error) {
+	n, err := toNode(tag)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+/*
+Works like Marshal() but formats the output using json.MarshalIndent().
+*/
+func MarshalIndent(tag tags.ILTag, prefix, indent string) ([]byte, error) {
+	n, err := toNode(tag)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(n, prefix, indent)
+}
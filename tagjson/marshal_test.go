@@ -0,0 +1,269 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagjson
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertMarshal(t *testing.T, expected string, tag tags.ILTag) {
+	b, err := Marshal(tag)
+	require.Nil(t, err)
+	assert.JSONEq(t, expected, string(b))
+}
+
+func TestMarshalPrimitives(t *testing.T) {
+	assertMarshal(t, `{"tagId":0,"type":"null","value":null}`, nil)
+	assertMarshal(t, `{"tagId":0,"type":"null","value":null}`, (*impl.StringTag)(nil))
+	assertMarshal(t, `{"tagId":0,"type":"null","value":null}`, impl.NewStdNullTag())
+
+	b := impl.NewStdBoolTag()
+	b.Payload = true
+	assertMarshal(t, `{"tagId":1,"type":"bool","value":true}`, b)
+
+	i8 := impl.NewStdInt8Tag()
+	i8.Payload = -1
+	assertMarshal(t, `{"tagId":2,"type":"int8","value":-1}`, i8)
+	u8 := impl.NewStdUInt8Tag()
+	u8.Payload = 255
+	assertMarshal(t, `{"tagId":3,"type":"uint8","value":255}`, u8)
+	i16 := impl.NewStdInt16Tag()
+	i16.Payload = -2
+	assertMarshal(t, `{"tagId":4,"type":"int16","value":-2}`, i16)
+	u16 := impl.NewStdUInt16Tag()
+	u16.Payload = 65535
+	assertMarshal(t, `{"tagId":5,"type":"uint16","value":65535}`, u16)
+	i32 := impl.NewStdInt32Tag()
+	i32.Payload = -3
+	assertMarshal(t, `{"tagId":6,"type":"int32","value":-3}`, i32)
+	u32 := impl.NewStdUInt32Tag()
+	u32.Payload = 4294967295
+	assertMarshal(t, `{"tagId":7,"type":"uint32","value":4294967295}`, u32)
+
+	i64 := impl.NewStdInt64Tag()
+	i64.Payload = math.MinInt64
+	b1, err := Marshal(i64)
+	require.Nil(t, err)
+	assert.Equal(t, `{"tagId":8,"type":"int64","value":-9223372036854775808}`, string(b1))
+	u64 := impl.NewStdUInt64Tag()
+	u64.Payload = math.MaxUint64
+	b1, err = Marshal(u64)
+	require.Nil(t, err)
+	assert.Equal(t, `{"tagId":9,"type":"uint64","value":18446744073709551615}`, string(b1))
+	ilint := impl.NewStdILIntTag()
+	ilint.Payload = 1234
+	assertMarshal(t, `{"tagId":10,"type":"ilint","value":1234}`, ilint)
+	silint := impl.NewStdSignedILIntTag()
+	silint.Payload = -1234
+	assertMarshal(t, `{"tagId":14,"type":"signedilint","value":-1234}`, silint)
+
+	f32 := impl.NewStdFloat32Tag()
+	f32.Payload = 0.1
+	b1, err = Marshal(f32)
+	require.Nil(t, err)
+	assert.Equal(t, `{"tagId":11,"type":"float32","value":0.1}`, string(b1))
+	f64 := impl.NewStdFloat64Tag()
+	f64.Payload = 1.5e300
+	assertMarshal(t, `{"tagId":12,"type":"float64","value":1.5e300}`, f64)
+	f64.Payload = math.NaN()
+	assertMarshal(t, `{"tagId":12,"type":"float64","value":"NaN"}`, f64)
+	f64.Payload = math.Inf(1)
+	assertMarshal(t, `{"tagId":12,"type":"float64","value":"+Inf"}`, f64)
+	f32.Payload = float32(math.Inf(-1))
+	assertMarshal(t, `{"tagId":11,"type":"float32","value":"-Inf"}`, f32)
+	f32.Payload = float32(math.NaN())
+	assertMarshal(t, `{"tagId":11,"type":"float32","value":"NaN"}`, f32)
+	f32.Payload = math.Float32frombits(0x7fa00001)
+	assertMarshal(t, `{"tagId":11,"type":"float32","value":"0x7fa00001"}`, f32)
+	f64.Payload = math.Float64frombits(0x7ff8000000000000)
+	assertMarshal(t, `{"tagId":12,"type":"float64","value":"0x7ff8000000000000"}`, f64)
+
+	f128 := impl.NewStdFloat128Tag()
+	f128.Payload[0] = 0xAB
+	f128.Payload[15] = 0x01
+	assertMarshal(t, `{"tagId":13,"type":"float128","value":"ab000000000000000000000000000001"}`, f128)
+}
+
+func TestMarshalBase(t *testing.T) {
+	bytesTag := impl.NewStdBytesTag()
+	bytesTag.Payload = []byte{1, 2, 3}
+	assertMarshal(t, `{"tagId":16,"type":"bytes","value":"AQID"}`, bytesTag)
+	assertMarshal(t, `{"tagId":16,"type":"bytes","value":""}`, impl.NewStdBytesTag())
+
+	s := impl.NewStdStringTag()
+	s.Payload = "Hello"
+	assertMarshal(t, `{"tagId":17,"type":"string","value":"Hello"}`, s)
+
+	bi := impl.NewStdBigIntTag()
+	bi.SetBigInt(big.NewInt(-12345))
+	assertMarshal(t, `{"tagId":18,"type":"bigint","value":"-12345"}`, bi)
+
+	bd := impl.NewStdBigDecTag()
+	bd.SetBigInt(big.NewInt(-12345))
+	bd.Scale = 2
+	assertMarshal(t, `{"tagId":19,"type":"bigdec","value":"-123.45"}`, bd)
+
+	// Payloads that are not minimal
+	bi.Payload = []byte{0x00, 0x00, 0x01}
+	assertMarshal(t, `{"tagId":18,"type":"bigint","value":"0x000001"}`, bi)
+	bi.Payload = nil
+	assertMarshal(t, `{"tagId":18,"type":"bigint","value":"0"}`, bi)
+	bd.Payload = []byte{0xFF, 0xFF}
+	bd.Scale = -3
+	assertMarshal(t, `{"tagId":19,"type":"bigdec","value":"0xffff:-3"}`, bd)
+
+	arr := impl.NewStdILIntArrayTag()
+	assertMarshal(t, `{"tagId":20,"type":"ilintarray","value":[]}`, arr)
+	arr.Payload = []uint64{1, 2}
+	assertMarshal(t, `{"tagId":20,"type":"ilintarray","value":[1,2]}`, arr)
+	oid := impl.NewStdOIDTag()
+	oid.Payload = []uint64{1, 3, 6}
+	assertMarshal(t, `{"tagId":25,"type":"oid","value":[1,3,6]}`, oid)
+
+	r := impl.NewStdRangeTag()
+	r.Start = 10
+	r.Count = 3
+	assertMarshal(t, `{"tagId":23,"type":"range","value":{"start":10,"count":3}}`, r)
+
+	v := impl.NewStdVersionTag()
+	v.Major, v.Minor, v.Revision, v.Build = 1, 2, 3, 4
+	assertMarshal(t, `{"tagId":24,"type":"version","value":{"major":1,"minor":2,"revision":3,"build":4}}`, v)
+
+	raw := tags.NewRawTag(1000)
+	raw.Payload = []byte{0xFF}
+	assertMarshal(t, `{"tagId":1000,"type":"raw","value":"/w=="}`, raw)
+}
+
+func TestMarshalContainers(t *testing.T) {
+	s := impl.NewStdStringTag()
+	s.Payload = "a"
+
+	arr := impl.NewStdILTagArrayTag()
+	arr.Payload = []tags.ILTag{s, nil}
+	assertMarshal(t, `{"tagId":21,"type":"array","value":[
+		{"tagId":17,"type":"string","value":"a"},
+		{"tagId":0,"type":"null","value":null}]}`, arr)
+
+	seq := impl.NewStdILTagSequenceTag()
+	assertMarshal(t, `{"tagId":22,"type":"sequence","value":[]}`, seq)
+	seq.Payload = []tags.ILTag{arr}
+	b, err := Marshal(seq)
+	require.Nil(t, err)
+	assert.Contains(t, string(b), `"type":"array"`)
+
+	dict := impl.NewStdDictionaryTag()
+	dict.Map.Put("z", s)
+	dict.Map.Put("a", impl.NewStdNullTag())
+	b, err = Marshal(dict)
+	require.Nil(t, err)
+	assert.Equal(t, `{"tagId":30,"type":"dictionary","value":[`+
+		`{"key":"z","value":{"tagId":17,"type":"string","value":"a"}},`+
+		`{"key":"a","value":{"tagId":0,"type":"null","value":null}}]}`, string(b))
+
+	sdict := impl.NewStdStringDictionaryTag()
+	sdict.Map.Put("z", "1")
+	sdict.Map.Put("a", "2")
+	b, err = Marshal(sdict)
+	require.Nil(t, err)
+	assert.Equal(t, `{"tagId":31,"type":"stringdictionary","value":[`+
+		`{"key":"z","value":"1"},{"key":"a","value":"2"}]}`, string(b))
+}
+
+// Tag that is not implemented by the impl package.
+type customTag struct {
+	tags.ILTagHeaderImpl
+	impl.StringPayload
+}
+
+// Tag that cannot be serialized.
+type brokenTag struct {
+	customTag
+}
+
+func (t *brokenTag) SerializeValue(writer io.Writer) error {
+	return io.ErrUnexpectedEOF
+}
+
+func TestMarshalCustomTags(t *testing.T) {
+	c := &customTag{}
+	c.SetId(1000)
+	c.Payload = "abc"
+	assertMarshal(t, `{"tagId":1000,"type":"raw","value":"YWJj"}`, c)
+
+	broken := &brokenTag{}
+	broken.SetId(1001)
+	_, err := Marshal(broken)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = MarshalIndent(broken, "", " ")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	for _, container := range []tags.ILTag{
+		&impl.ILTagArrayTag{ILTagArrayPayload: impl.ILTagArrayPayload{Payload: []tags.ILTag{broken}}},
+		&impl.ILTagSequenceTag{ILTagSequencePayload: impl.ILTagSequencePayload{Payload: []tags.ILTag{broken}}},
+	} {
+		_, err = Marshal(container)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+	dict := impl.NewStdDictionaryTag()
+	dict.Map.Put("a", broken)
+	_, err = Marshal(dict)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestMarshalIndent(t *testing.T) {
+	b, err := MarshalIndent(impl.NewStdNullTag(), "", "  ")
+	require.Nil(t, err)
+	assert.Equal(t, "{\n  \"tagId\": 0,\n  \"type\": \"null\",\n  \"value\": null\n}", string(b))
+	assert.True(t, bytes.HasPrefix(b, []byte("{\n")))
+}
+
+func TestFormatBigDec(t *testing.T) {
+	assert.Equal(t, "0", formatBigDec(big.NewInt(0), 0))
+	assert.Equal(t, "123", formatBigDec(big.NewInt(123), 0))
+	assert.Equal(t, "12.3", formatBigDec(big.NewInt(123), 1))
+	assert.Equal(t, "0.123", formatBigDec(big.NewInt(123), 3))
+	assert.Equal(t, "0.00123", formatBigDec(big.NewInt(123), 5))
+	assert.Equal(t, "-0.00123", formatBigDec(big.NewInt(-123), 5))
+	assert.Equal(t, "-12.3", formatBigDec(big.NewInt(-123), 1))
+	assert.Equal(t, "123E4", formatBigDec(big.NewInt(123), -4))
+	assert.Equal(t, "-123E4", formatBigDec(big.NewInt(-123), -4))
+}
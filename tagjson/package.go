@@ -0,0 +1,80 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+This package implements a lossless JSON representation of ILTag trees. It is
+meant to be used to inspect and edit tags by hand.
+
+Each tag is represented by a JSON object with the following fields:
+
+	{"tagId": 17, "type": "string", "value": "Hello"}
+
+where tagId is the ID of the tag, type is the name of its payload format and
+value is the payload. The representation of the payload depends on the type:
+
+	null                     -> null
+	bool                     -> true or false
+	int8 ... uint64          -> numbers
+	ilint, signedilint       -> numbers
+	float32, float64         -> numbers or "NaN", "+Inf" and "-Inf"
+	float128                 -> hexadecimal string
+	bytes, raw               -> base64 string (standard encoding)
+	string                   -> string
+	bigint                   -> decimal string, as "-12345"
+	bigdec                   -> decimal string, as "123.45" or "12345E3"
+	ilintarray, oid          -> array of numbers
+	array, sequence          -> array of tags
+	range                    -> {"start": <number>, "count": <number>}
+	version                  -> {"major": <n>, "minor": <n>, "revision": <n>, "build": <n>}
+	dictionary               -> array of {"key": <string>, "value": <tag>}
+	stringdictionary         -> array of {"key": <string>, "value": <string>}
+
+In order to preserve the exact payloads, NaNs that differ from the one created
+when "NaN" is decoded are represented by their bits in hexadecimal, as
+"0x7ff8000000000000", while big integers and big decimals that are not in their
+minimal two's complement form are represented by the bytes of their payloads in
+hexadecimal, as "0x000001" for bigint and "0x000001:2" for bigdec, where 2 is
+the scale.
+
+The order of the dictionary entries is preserved. Tags that are not implemented
+by the impl package, including the RawTags created for unknown tag IDs, use the
+raw type. Their payloads are restored by the factory used by Unmarshal(), thus
+they round-trip unchanged.
+
+Tags with custom IDs that use one of the standard payload formats are
+recreated with the given format even if the factory does not know them.
+
+This format is defined by this package. It follows the same principles of the
+JSON representation used by other ILTags implementations but no compatibility
+with them is guaranteed.
+*/
+package tagjson
@@ -0,0 +1,210 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagjson
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+var (
+	// The JSON does not represent a valid tag.
+	ErrInvalidJSON = fmt.Errorf("invalid tag JSON")
+	// The type of the JSON node does not match the tag created by the factory.
+	ErrTypeMismatch = fmt.Errorf("type mismatch")
+)
+
+/*
+PathError is the error returned by Unmarshal(). It contains the path of the
+offending node inside the JSON tree.
+
+The path starts with $, that represents the root tag, followed by the indexes
+of the arrays and the keys of the dictionaries, as in $[2]["key"].
+*/
+type PathError struct {
+	// The path of the node.
+	Path string
+	// The cause of the error.
+	Err error
+}
+
+// Implementation of error.Error().
+func (e *PathError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Returns the cause of the error.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// Names of the payload types.
+const (
+	typeNull             = "null"
+	typeBool             = "bool"
+	typeInt8             = "int8"
+	typeUInt8            = "uint8"
+	typeInt16            = "int16"
+	typeUInt16           = "uint16"
+	typeInt32            = "int32"
+	typeUInt32           = "uint32"
+	typeInt64            = "int64"
+	typeUInt64           = "uint64"
+	typeILInt            = "ilint"
+	typeFloat32          = "float32"
+	typeFloat64          = "float64"
+	typeFloat128         = "float128"
+	typeSignedILInt      = "signedilint"
+	typeBytes            = "bytes"
+	typeString           = "string"
+	typeBigInt           = "bigint"
+	typeBigDec           = "bigdec"
+	typeILIntArray       = "ilintarray"
+	typeArray            = "array"
+	typeSequence         = "sequence"
+	typeRange            = "range"
+	typeVersion          = "version"
+	typeOID              = "oid"
+	typeDictionary       = "dictionary"
+	typeStringDictionary = "stringdictionary"
+	typeRaw              = "raw"
+)
+
+// Constructors of the tags of each type.
+var typeCreators = map[string]func(tags.TagID) tags.ILTag{
+	typeNull:             impl.TagCreator(impl.NewNullTag),
+	typeBool:             impl.TagCreator(impl.NewBoolTag),
+	typeInt8:             impl.TagCreator(impl.NewInt8Tag),
+	typeUInt8:            impl.TagCreator(impl.NewUInt8Tag),
+	typeInt16:            impl.TagCreator(impl.NewInt16Tag),
+	typeUInt16:           impl.TagCreator(impl.NewUInt16Tag),
+	typeInt32:            impl.TagCreator(impl.NewInt32Tag),
+	typeUInt32:           impl.TagCreator(impl.NewUInt32Tag),
+	typeInt64:            impl.TagCreator(impl.NewInt64Tag),
+	typeUInt64:           impl.TagCreator(impl.NewUInt64Tag),
+	typeILInt:            impl.TagCreator(impl.NewILIntTag),
+	typeFloat32:          impl.TagCreator(impl.NewFloat32Tag),
+	typeFloat64:          impl.TagCreator(impl.NewFloat64Tag),
+	typeFloat128:         impl.TagCreator(impl.NewFloat128Tag),
+	typeSignedILInt:      impl.TagCreator(impl.NewSignedILIntTag),
+	typeBytes:            impl.TagCreator(impl.NewBytesTag),
+	typeString:           impl.TagCreator(impl.NewStringTag),
+	typeBigInt:           impl.TagCreator(impl.NewBigIntTag),
+	typeBigDec:           impl.TagCreator(impl.NewBigDecTag),
+	typeILIntArray:       impl.TagCreator(impl.NewILIntArrayTag),
+	typeArray:            impl.TagCreator(impl.NewILTagArrayTag),
+	typeSequence:         impl.TagCreator(impl.NewILTagSequenceTag),
+	typeRange:            impl.TagCreator(impl.NewRangeTag),
+	typeVersion:          impl.TagCreator(impl.NewVersionTag),
+	typeOID:              impl.TagCreator(impl.NewOIDTag),
+	typeDictionary:       impl.TagCreator(impl.NewDictionaryTag),
+	typeStringDictionary: impl.TagCreator(impl.NewStringDictionaryTag),
+	typeRaw:              impl.TagCreator(tags.NewRawTag),
+}
+
+/*
+Returns the name of the type of the given tag. Tags that share the same
+implementation are distinguished by their standard IDs.
+*/
+func typeOf(tag tags.ILTag) string {
+	switch tag.(type) {
+	case *impl.NullTag:
+		return typeNull
+	case *impl.BoolTag:
+		return typeBool
+	case *impl.Int8Tag:
+		return typeInt8
+	case *impl.UInt8Tag:
+		return typeUInt8
+	case *impl.Int16Tag:
+		return typeInt16
+	case *impl.UInt16Tag:
+		return typeUInt16
+	case *impl.Int32Tag:
+		return typeInt32
+	case *impl.UInt32Tag:
+		return typeUInt32
+	case *impl.Int64Tag:
+		return typeInt64
+	case *impl.UInt64Tag:
+		return typeUInt64
+	case *impl.ILIntTag:
+		return typeILInt
+	case *impl.Float32Tag:
+		return typeFloat32
+	case *impl.Float64Tag:
+		return typeFloat64
+	case *impl.Float128Tag:
+		return typeFloat128
+	case *impl.SignedILIntTag:
+		return typeSignedILInt
+	case *tags.RawTag:
+		if tag.Id() == tags.IL_BYTES_TAG_ID {
+			return typeBytes
+		}
+		return typeRaw
+	case *impl.StringTag:
+		return typeString
+	case *impl.BigIntTag:
+		return typeBigInt
+	case *impl.BigDecTag:
+		return typeBigDec
+	case *impl.ILIntArrayTag:
+		if tag.Id() == tags.IL_OID_TAG_ID {
+			return typeOID
+		}
+		return typeILIntArray
+	case *impl.ILTagArrayTag:
+		return typeArray
+	case *impl.ILTagSequenceTag:
+		return typeSequence
+	case *impl.RangeTag:
+		return typeRange
+	case *impl.VersionTag:
+		return typeVersion
+	case *impl.DictionaryTag:
+		return typeDictionary
+	case *impl.StringDictionaryTag:
+		return typeStringDictionary
+	default:
+		return typeRaw
+	}
+}
+
+// Returns true if the tag has the same Go type of the tags created by create.
+func hasType(tag tags.ILTag, create func(tags.TagID) tags.ILTag) bool {
+	return reflect.TypeOf(tag) == reflect.TypeOf(create(tag.Id()))
+}
@@ -0,0 +1,513 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+/*
+JSON representation of a tag with the value not decoded yet.
+*/
+type rawNode struct {
+	TagId *uint64         `json:"tagId"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+/*
+JSON representation of a dictionary entry with the value not decoded yet.
+*/
+type rawEntry struct {
+	Key   *string         `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+/*
+Decodes a JSON value. Numbers are kept as json.Number and unknown fields are
+rejected.
+*/
+func decodeJSON(data []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if d.More() {
+		return fmt.Errorf("unexpected data after the value")
+	}
+	return nil
+}
+
+/*
+Decodes a JSON number. Unlike the decoding of json.Number, strings are not
+accepted.
+*/
+func decodeNumber(data []byte) (string, error) {
+	if d := bytes.TrimSpace(data); len(d) > 0 && d[0] == '"' {
+		return "", fmt.Errorf("number expected")
+	}
+	var n json.Number
+	if err := decodeJSON(data, &n); err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+// Decodes a signed integer.
+func decodeInt(data []byte, bits int) (int64, error) {
+	n, err := decodeNumber(data)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(n, 10, bits)
+}
+
+// Decodes an unsigned integer.
+func decodeUint(data []byte, bits int) (uint64, error) {
+	n, err := decodeNumber(data)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(n, 10, bits)
+}
+
+/*
+Decodes a floating point value with the given number of bits. The value is
+returned as its IEEE 754 bits in order to preserve the NaNs represented in
+hexadecimal.
+*/
+func decodeFloat(data []byte, bits int) (uint64, error) {
+	var v float64
+	var s string
+	if err := decodeJSON(data, &s); err == nil {
+		switch {
+		case s == "NaN":
+			v = math.NaN()
+		case s == "+Inf":
+			v = math.Inf(1)
+		case s == "-Inf":
+			v = math.Inf(-1)
+		case strings.HasPrefix(s, hexPrefix) && len(s) == len(hexPrefix)+bits/4:
+			return strconv.ParseUint(s[len(hexPrefix):], 16, bits)
+		default:
+			return 0, fmt.Errorf("invalid floating point value %q", s)
+		}
+	} else {
+		n, err := decodeNumber(data)
+		if err != nil {
+			return 0, err
+		}
+		if v, err = strconv.ParseFloat(n, bits); err != nil {
+			return 0, err
+		}
+	}
+	if bits == 32 {
+		return uint64(math.Float32bits(float32(v))), nil
+	}
+	return math.Float64bits(v), nil
+}
+
+// Decodes a string.
+func decodeString(data []byte) (string, error) {
+	var s string
+	err := decodeJSON(data, &s)
+	return s, err
+}
+
+// Decodes a base64 string.
+func decodeBase64(data []byte) ([]byte, error) {
+	s, err := decodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+/*
+Decodes the payload of a big integer. It may be represented by its decimal
+value or by its bytes in hexadecimal.
+*/
+func decodeBigInt(s string) ([]byte, error) {
+	var p impl.BigIntPayload
+	if strings.HasPrefix(s, hexPrefix) {
+		b, err := hex.DecodeString(s[len(hexPrefix):])
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		return b, nil
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	p.SetBigInt(v)
+	return p.Payload, nil
+}
+
+/*
+Decodes the payload and the scale of a big decimal. It may be represented in
+the format produced by formatBigDec() or by the bytes of its unscaled value in
+hexadecimal followed by ':' and the scale.
+*/
+func decodeBigDec(s string) ([]byte, int32, error) {
+	if strings.HasPrefix(s, hexPrefix) {
+		i := strings.IndexByte(s, ':')
+		if i < 0 {
+			return nil, 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		scale, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		b, err := decodeBigInt(s[:i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		return b, int32(scale), nil
+	}
+	v, scale, err := parseBigDec(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	var p impl.BigIntPayload
+	p.SetBigInt(v)
+	return p.Payload, scale, nil
+}
+
+/*
+Parses a big decimal in the format produced by formatBigDec().
+*/
+func parseBigDec(s string) (*big.Int, int32, error) {
+	digits := s
+	var scale int64
+	if i := strings.IndexAny(digits, "eE"); i >= 0 {
+		exp, err := strconv.ParseInt(digits[i+1:], 10, 32)
+		if err != nil || exp < 0 {
+			return nil, 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		scale = -exp
+		digits = digits[:i]
+	}
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		if scale != 0 || i == len(digits)-1 {
+			return nil, 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		scale = int64(len(digits) - i - 1)
+		digits = digits[:i] + digits[i+1:]
+	}
+	if scale > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	return v, int32(scale), nil
+}
+
+// Decodes a list of tags.
+func (d *decoder) decodeTags(path string, data []byte) ([]tags.ILTag, error) {
+	var list []json.RawMessage
+	if err := decodeJSON(data, &list); err != nil {
+		return nil, err
+	}
+	ret := make([]tags.ILTag, len(list))
+	for i, item := range list {
+		t, err := d.decode(fmt.Sprintf("%s[%d]", path, i), item)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = t
+	}
+	return ret, nil
+}
+
+/*
+Decoder of the JSON representation.
+*/
+type decoder struct {
+	factory tags.ILTagFactory
+}
+
+/*
+Creates the tag described by the node.
+*/
+func (d *decoder) createTag(id tags.TagID, typeName string) (tags.ILTag, error) {
+	if typeName == typeRaw {
+		return d.factory.CreateTag(id)
+	}
+	create := typeCreators[typeName]
+	if create == nil {
+		return nil, fmt.Errorf("unknown type %q: %w", typeName, ErrInvalidJSON)
+	}
+	tag, err := d.factory.CreateTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if hasType(tag, create) {
+		return tag, nil
+	}
+	if _, ok := tag.(*tags.RawTag); ok && !id.Reserved() {
+		// Unknown custom tags are recreated with the given format
+		return create(id), nil
+	}
+	return nil, fmt.Errorf("tag %d cannot be represented as %s: %w", id,
+		typeName, ErrTypeMismatch)
+}
+
+/*
+Decodes the JSON representation of a tag. The path is used to identify the
+node in error messages.
+*/
+func (d *decoder) decode(path string, data []byte) (tags.ILTag, error) {
+	var n rawNode
+	if err := decodeJSON(data, &n); err != nil {
+		return nil, &PathError{path, fmt.Errorf("%v: %w", err, ErrInvalidJSON)}
+	}
+	if n.TagId == nil {
+		return nil, &PathError{path, fmt.Errorf("tagId is missing: %w", ErrInvalidJSON)}
+	}
+	if len(n.Value) == 0 {
+		n.Value = json.RawMessage("null")
+	}
+	tag, err := d.createTag(tags.TagID(*n.TagId), n.Type)
+	if err != nil {
+		return nil, &PathError{path, err}
+	}
+	if n.Type == typeRaw {
+		err = d.decodeRaw(tag, n.Value)
+	} else {
+		err = d.decodeValue(path, tag, n.Value)
+	}
+	if err != nil {
+		var pe *PathError
+		if errors.As(err, &pe) {
+			return nil, err
+		}
+		return nil, &PathError{path, err}
+	}
+	return tag, nil
+}
+
+// Decodes the payload of a raw tag.
+func (d *decoder) decodeRaw(tag tags.ILTag, data []byte) error {
+	payload, err := decodeBase64(data)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidJSON)
+	}
+	if raw, ok := tag.(*tags.RawTag); ok {
+		raw.Payload = payload
+		return nil
+	}
+	return tag.DeserializeValue(d.factory, len(payload), bytes.NewReader(payload))
+}
+
+// Decodes the value of a tag.
+func (d *decoder) decodeValue(path string, tag tags.ILTag, data []byte) error {
+	var err error
+	switch t := tag.(type) {
+	case *impl.NullTag:
+		if string(bytes.TrimSpace(data)) != "null" {
+			err = fmt.Errorf("null expected")
+		}
+	case *impl.BoolTag:
+		err = decodeJSON(data, &t.Payload)
+	case *impl.Int8Tag:
+		var v int64
+		v, err = decodeInt(data, 8)
+		t.Payload = int8(v)
+	case *impl.UInt8Tag:
+		var v uint64
+		v, err = decodeUint(data, 8)
+		t.Payload = uint8(v)
+	case *impl.Int16Tag:
+		var v int64
+		v, err = decodeInt(data, 16)
+		t.Payload = int16(v)
+	case *impl.UInt16Tag:
+		var v uint64
+		v, err = decodeUint(data, 16)
+		t.Payload = uint16(v)
+	case *impl.Int32Tag:
+		var v int64
+		v, err = decodeInt(data, 32)
+		t.Payload = int32(v)
+	case *impl.UInt32Tag:
+		var v uint64
+		v, err = decodeUint(data, 32)
+		t.Payload = uint32(v)
+	case *impl.Int64Tag:
+		t.Payload, err = decodeInt(data, 64)
+	case *impl.UInt64Tag:
+		t.Payload, err = decodeUint(data, 64)
+	case *impl.ILIntTag:
+		t.Payload, err = decodeUint(data, 64)
+	case *impl.SignedILIntTag:
+		t.Payload, err = decodeInt(data, 64)
+	case *impl.Float32Tag:
+		var v uint64
+		v, err = decodeFloat(data, 32)
+		t.Payload = math.Float32frombits(uint32(v))
+	case *impl.Float64Tag:
+		var v uint64
+		v, err = decodeFloat(data, 64)
+		t.Payload = math.Float64frombits(v)
+	case *impl.Float128Tag:
+		var s string
+		var b []byte
+		if s, err = decodeString(data); err == nil {
+			if b, err = hex.DecodeString(s); err == nil && len(b) != len(t.Payload) {
+				err = fmt.Errorf("float128 requires %d bytes", len(t.Payload))
+			}
+			copy(t.Payload[:], b)
+		}
+	case *tags.RawTag:
+		t.Payload, err = decodeBase64(data)
+	case *impl.StringTag:
+		t.Payload, err = decodeString(data)
+	case *impl.BigIntTag:
+		var s string
+		if s, err = decodeString(data); err == nil {
+			t.Payload, err = decodeBigInt(s)
+		}
+	case *impl.BigDecTag:
+		var s string
+		if s, err = decodeString(data); err == nil {
+			t.Payload, t.Scale, err = decodeBigDec(s)
+		}
+	case *impl.ILIntArrayTag:
+		var list []json.Number
+		if err = decodeJSON(data, &list); err == nil {
+			t.Payload = make([]uint64, len(list))
+			for i, n := range list {
+				if t.Payload[i], err = strconv.ParseUint(n.String(), 10, 64); err != nil {
+					break
+				}
+			}
+		}
+	case *impl.ILTagArrayTag:
+		t.Payload, err = d.decodeTags(path, data)
+	case *impl.ILTagSequenceTag:
+		t.Payload, err = d.decodeTags(path, data)
+	case *impl.RangeTag:
+		var v rangeValue
+		if err = decodeJSON(data, &v); err == nil {
+			t.Start, t.Count = v.Start, v.Count
+		}
+	case *impl.VersionTag:
+		var v versionValue
+		if err = decodeJSON(data, &v); err == nil {
+			t.Major, t.Minor, t.Revision, t.Build = v.Major, v.Minor, v.Revision, v.Build
+		}
+	case *impl.DictionaryTag:
+		err = d.decodeDictionary(path, t, data)
+	case *impl.StringDictionaryTag:
+		err = decodeStringDictionary(t, data)
+	}
+	var pe *PathError
+	if err != nil && !errors.As(err, &pe) {
+		err = fmt.Errorf("%v: %w", err, ErrInvalidJSON)
+	}
+	return err
+}
+
+// Decodes the entries of a dictionary.
+func (d *decoder) decodeDictionary(path string, t *impl.DictionaryTag, data []byte) error {
+	var entries []rawEntry
+	if err := decodeJSON(data, &entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Key == nil {
+			return fmt.Errorf("key is missing")
+		}
+		if _, found := t.Map.Get(*e.Key); found {
+			return fmt.Errorf("duplicated key %q", *e.Key)
+		}
+		v, err := d.decode(fmt.Sprintf("%s[%q]", path, *e.Key), e.Value)
+		if err != nil {
+			return err
+		}
+		t.Map.Put(*e.Key, v)
+	}
+	return nil
+}
+
+// Decodes the entries of a string dictionary.
+func decodeStringDictionary(t *impl.StringDictionaryTag, data []byte) error {
+	var entries []struct {
+		Key   *string `json:"key"`
+		Value *string `json:"value"`
+	}
+	if err := decodeJSON(data, &entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Key == nil || e.Value == nil {
+			return fmt.Errorf("key or value is missing")
+		}
+		if _, found := t.Map.Get(*e.Key); found {
+			return fmt.Errorf("duplicated key %q", *e.Key)
+		}
+		t.Map.Put(*e.Key, *e.Value)
+	}
+	return nil
+}
+
+/*
+Creates a tag from its JSON representation. The factory is used to create the
+tags and defaults to a non strict StandardTagFactory if nil.
+
+The errors related to the contents of the JSON are returned as *PathError.
+*/
+func Unmarshal(data []byte, factory tags.ILTagFactory) (tags.ILTag, error) {
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	d := decoder{factory: factory}
+	return d.decode("$", data)
+}
@@ -0,0 +1,364 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagjson
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a tree that contains all standard tags.
+func createSampleTree() tags.ILTag {
+	var list []tags.ILTag
+	list = append(list, impl.NewStdNullTag())
+	b := impl.NewStdBoolTag()
+	b.Payload = true
+	list = append(list, b)
+	i8 := impl.NewStdInt8Tag()
+	i8.Payload = math.MinInt8
+	list = append(list, i8)
+	u8 := impl.NewStdUInt8Tag()
+	u8.Payload = math.MaxUint8
+	list = append(list, u8)
+	i16 := impl.NewStdInt16Tag()
+	i16.Payload = math.MinInt16
+	list = append(list, i16)
+	u16 := impl.NewStdUInt16Tag()
+	u16.Payload = math.MaxUint16
+	list = append(list, u16)
+	i32 := impl.NewStdInt32Tag()
+	i32.Payload = math.MinInt32
+	list = append(list, i32)
+	u32 := impl.NewStdUInt32Tag()
+	u32.Payload = math.MaxUint32
+	list = append(list, u32)
+	i64 := impl.NewStdInt64Tag()
+	i64.Payload = math.MinInt64
+	list = append(list, i64)
+	u64 := impl.NewStdUInt64Tag()
+	u64.Payload = math.MaxUint64
+	list = append(list, u64)
+	ilint := impl.NewStdILIntTag()
+	ilint.Payload = math.MaxUint64
+	list = append(list, ilint)
+	f32 := impl.NewStdFloat32Tag()
+	f32.Payload = math.MaxFloat32
+	list = append(list, f32)
+	f64 := impl.NewStdFloat64Tag()
+	f64.Payload = math.SmallestNonzeroFloat64
+	list = append(list, f64)
+	f64inf := impl.NewStdFloat64Tag()
+	f64inf.Payload = math.Inf(-1)
+	list = append(list, f64inf)
+	f128 := impl.NewStdFloat128Tag()
+	for i := range f128.Payload {
+		f128.Payload[i] = byte(i)
+	}
+	list = append(list, f128)
+	silint := impl.NewStdSignedILIntTag()
+	silint.Payload = math.MinInt64
+	list = append(list, silint)
+	bytesTag := impl.NewStdBytesTag()
+	bytesTag.Payload = []byte{0, 1, 2, 0xFF}
+	list = append(list, bytesTag)
+	s := impl.NewStdStringTag()
+	s.Payload = "Olá \"mundo\" <&>"
+	list = append(list, s)
+	bi := impl.NewStdBigIntTag()
+	v, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	bi.SetBigInt(v)
+	list = append(list, bi)
+	bd := impl.NewStdBigDecTag()
+	bd.SetBigInt(v)
+	bd.Scale = 40
+	list = append(list, bd)
+	bd2 := impl.NewStdBigDecTag()
+	bd2.SetBigInt(big.NewInt(7))
+	bd2.Scale = -3
+	list = append(list, bd2)
+	arr := impl.NewStdILIntArrayTag()
+	arr.Payload = []uint64{0, math.MaxUint64}
+	list = append(list, arr)
+	oid := impl.NewStdOIDTag()
+	oid.Payload = []uint64{1, 3, 6, 1}
+	list = append(list, oid)
+	r := impl.NewStdRangeTag()
+	r.Start = math.MaxUint64
+	r.Count = math.MaxUint16
+	list = append(list, r)
+	ver := impl.NewStdVersionTag()
+	ver.Major, ver.Minor, ver.Revision, ver.Build = 1, -2, 3, math.MaxInt32
+	list = append(list, ver)
+	dict := impl.NewStdDictionaryTag()
+	dict.Map.Put("z", s)
+	dict.Map.Put("a", impl.NewStdILTagArrayTag())
+	list = append(list, dict)
+	sdict := impl.NewStdStringDictionaryTag()
+	sdict.Map.Put("z", "1")
+	sdict.Map.Put("a", "2")
+	list = append(list, sdict)
+	raw := tags.NewRawTag(1000)
+	raw.Payload = []byte{1, 2, 3}
+	list = append(list, raw)
+	custom := impl.NewStringTag(1001)
+	custom.Payload = "custom"
+	list = append(list, custom)
+
+	seq := impl.NewStdILTagSequenceTag()
+	seq.Payload = list
+	root := impl.NewStdILTagArrayTag()
+	root.Payload = []tags.ILTag{seq}
+	return root
+}
+
+func TestRoundTrip(t *testing.T) {
+	tree := createSampleTree()
+	expected, err := tags.ILTagToBytes(tree)
+	require.Nil(t, err)
+
+	js, err := Marshal(tree)
+	require.Nil(t, err)
+	decoded, err := Unmarshal(js, nil)
+	require.Nil(t, err)
+	actual, err := tags.ILTagToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	// Indented output
+	js, err = MarshalIndent(tree, "", "\t")
+	require.Nil(t, err)
+	decoded, err = Unmarshal(js, impl.NewStandardTagFactory(false))
+	require.Nil(t, err)
+	actual, err = tags.ILTagToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	// The custom tag was recreated as a StringTag
+	seq := decoded.(*impl.ILTagArrayTag).Payload[0].(*impl.ILTagSequenceTag)
+	custom := seq.Payload[len(seq.Payload)-1]
+	assert.IsType(t, &impl.StringTag{}, custom)
+	assert.Equal(t, tags.TagID(1001), custom.Id())
+	assert.IsType(t, &tags.RawTag{}, seq.Payload[len(seq.Payload)-2])
+
+	// NaN
+	f := impl.NewStdFloat32Tag()
+	f.Payload = float32(math.NaN())
+	js, err = Marshal(f)
+	require.Nil(t, err)
+	decoded, err = Unmarshal(js, nil)
+	require.Nil(t, err)
+	assert.True(t, math.IsNaN(float64(decoded.(*impl.Float32Tag).Payload)))
+}
+
+func TestRoundTripLossless(t *testing.T) {
+	f32 := impl.NewStdFloat32Tag()
+	f32.Payload = math.Float32frombits(0x7fa00001)
+	f32q := impl.NewStdFloat32Tag()
+	f32q.Payload = math.Float32frombits(0xffc00000)
+	f64 := impl.NewStdFloat64Tag()
+	f64.Payload = math.Float64frombits(0x7ff8000000000000)
+	f64s := impl.NewStdFloat64Tag()
+	f64s.Payload = math.Float64frombits(0xfff0000000000001)
+	bi := impl.NewStdBigIntTag()
+	bi.Payload = []byte{0x00, 0x00, 0x01}
+	bin := impl.NewStdBigIntTag()
+	bin.Payload = []byte{0xFF, 0xFF, 0x80}
+	bd := impl.NewStdBigDecTag()
+	bd.Payload = []byte{0x00, 0x7F}
+	bd.Scale = 2
+
+	for _, tag := range []tags.ILTag{f32, f32q, f64, f64s, bi, bin, bd} {
+		expected, err := tags.ILTagToBytes(tag)
+		require.Nil(t, err)
+		js, err := Marshal(tag)
+		require.Nil(t, err)
+		decoded, err := Unmarshal(js, nil)
+		require.Nil(t, err, string(js))
+		actual, err := tags.ILTagToBytes(decoded)
+		require.Nil(t, err)
+		assert.Equal(t, expected, actual, string(js))
+	}
+}
+
+func TestUnmarshalRawTags(t *testing.T) {
+	factory := impl.NewStandardTagFactory(true)
+	factory.RegisterTag(1000, func(id tags.TagID) tags.ILTag {
+		return impl.NewStringTag(id)
+	})
+
+	// Raw tags are deserialized by the factory
+	tag, err := Unmarshal([]byte(`{"tagId":1000,"type":"raw","value":"YWJj"}`), factory)
+	require.Nil(t, err)
+	assert.Equal(t, "abc", tag.(*impl.StringTag).Payload)
+
+	// Custom tags with standard formats
+	tag, err = Unmarshal([]byte(`{"tagId":1000,"type":"string","value":"abc"}`), factory)
+	require.Nil(t, err)
+	assert.Equal(t, "abc", tag.(*impl.StringTag).Payload)
+	_, err = Unmarshal([]byte(`{"tagId":1000,"type":"bool","value":true}`), factory)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	// Strict factories do not accept unknown tags
+	_, err = Unmarshal([]byte(`{"tagId":1001,"type":"raw","value":"YWJj"}`), factory)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+	_, err = Unmarshal([]byte(`{"tagId":1001,"type":"string","value":"abc"}`), factory)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+
+	// Invalid payloads
+	_, err = Unmarshal([]byte(`{"tagId":1000,"type":"raw","value":"/w=="}`), factory)
+	assert.Error(t, err)
+	_, err = Unmarshal([]byte(`{"tagId":1000,"type":"raw","value":"*"}`), factory)
+	assert.ErrorIs(t, err, ErrInvalidJSON)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, js := range []string{
+		``,
+		`[]`,
+		`{"type":"null"}`,
+		`{"tagId":-1,"type":"null"}`,
+		`{"tagId":0,"type":"null","value":null,"extra":1}`,
+		`{"tagId":0,"type":"null","value":null} {}`,
+		`{"tagId":0,"type":"unknown","value":null}`,
+		`{"tagId":0,"type":"null","value":1}`,
+		`{"tagId":1,"type":"bool","value":1}`,
+		`{"tagId":2,"type":"int8","value":128}`,
+		`{"tagId":2,"type":"int8","value":"1"}`,
+		`{"tagId":3,"type":"uint8","value":-1}`,
+		`{"tagId":3,"type":"uint8","value":"1"}`,
+		`{"tagId":4,"type":"int16","value":1.5}`,
+		`{"tagId":5,"type":"uint16","value":65536}`,
+		`{"tagId":6,"type":"int32","value":2147483648}`,
+		`{"tagId":7,"type":"uint32","value":4294967296}`,
+		`{"tagId":8,"type":"int64","value":9223372036854775808}`,
+		`{"tagId":9,"type":"uint64","value":18446744073709551616}`,
+		`{"tagId":10,"type":"ilint","value":-1}`,
+		`{"tagId":11,"type":"float32","value":1e39}`,
+		`{"tagId":11,"type":"float32","value":"inf"}`,
+		`{"tagId":12,"type":"float64","value":true}`,
+		`{"tagId":12,"type":"float64","value":"0x7ff8"}`,
+		`{"tagId":12,"type":"float64","value":"0x7ff800000000000z"}`,
+		`{"tagId":11,"type":"float32","value":"0x7ff8000000000000"}`,
+		`{"tagId":13,"type":"float128","value":"00"}`,
+		`{"tagId":13,"type":"float128","value":"zz"}`,
+		`{"tagId":13,"type":"float128","value":1}`,
+		`{"tagId":14,"type":"signedilint","value":1e3}`,
+		`{"tagId":16,"type":"bytes","value":"*"}`,
+		`{"tagId":16,"type":"bytes","value":1}`,
+		`{"tagId":17,"type":"string","value":1}`,
+		`{"tagId":17,"type":"bool","value":true}`,
+		`{"tagId":18,"type":"bigint","value":"1.5"}`,
+		`{"tagId":18,"type":"bigint","value":1}`,
+		`{"tagId":18,"type":"bigint","value":"0x"}`,
+		`{"tagId":18,"type":"bigint","value":"0x0"}`,
+		`{"tagId":19,"type":"bigdec","value":"1.5.1"}`,
+		`{"tagId":19,"type":"bigdec","value":"1.5E3"}`,
+		`{"tagId":19,"type":"bigdec","value":"15E-3"}`,
+		`{"tagId":19,"type":"bigdec","value":"1."}`,
+		`{"tagId":19,"type":"bigdec","value":"x"}`,
+		`{"tagId":19,"type":"bigdec","value":1}`,
+		`{"tagId":19,"type":"bigdec","value":"0x01"}`,
+		`{"tagId":19,"type":"bigdec","value":"0x:1"}`,
+		`{"tagId":19,"type":"bigdec","value":"0x01:x"}`,
+		`{"tagId":19,"type":"bigdec","value":"0x01:2147483648"}`,
+		`{"tagId":20,"type":"ilintarray","value":[-1]}`,
+		`{"tagId":20,"type":"ilintarray","value":{}}`,
+		`{"tagId":21,"type":"array","value":[{}]}`,
+		`{"tagId":21,"type":"array","value":{}}`,
+		`{"tagId":22,"type":"sequence","value":[1]}`,
+		`{"tagId":23,"type":"range","value":{"start":1,"count":65536}}`,
+		`{"tagId":23,"type":"range","value":{"first":1}}`,
+		`{"tagId":24,"type":"version","value":{"major":2147483648}}`,
+		`{"tagId":25,"type":"oid","value":[1.5]}`,
+		`{"tagId":30,"type":"dictionary","value":[{"value":{"tagId":0,"type":"null"}}]}`,
+		`{"tagId":30,"type":"dictionary","value":[{"key":"a","value":{"tagId":0,"type":"null"}},{"key":"a","value":{"tagId":0,"type":"null"}}]}`,
+		`{"tagId":30,"type":"dictionary","value":[{"key":"a","value":{}}]}`,
+		`{"tagId":30,"type":"dictionary","value":{}}`,
+		`{"tagId":31,"type":"stringdictionary","value":[{"key":"a"}]}`,
+		`{"tagId":31,"type":"stringdictionary","value":[{"key":"a","value":"1"},{"key":"a","value":"2"}]}`,
+		`{"tagId":31,"type":"stringdictionary","value":{}}`,
+		`{"tagId":15,"type":"raw","value":""}`,
+	} {
+		_, err := Unmarshal([]byte(js), nil)
+		assert.Error(t, err, js)
+		var pe *PathError
+		assert.True(t, errors.As(err, &pe), js)
+	}
+
+	// Missing values are null
+	tag, err := Unmarshal([]byte(`{"tagId":0,"type":"null"}`), nil)
+	require.Nil(t, err)
+	assert.IsType(t, &impl.NullTag{}, tag)
+}
+
+func TestUnmarshalErrorPaths(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"tagId":21,"type":"array","value":[
+		{"tagId":0,"type":"null"},
+		{"tagId":30,"type":"dictionary","value":[
+			{"key":"k","value":{"tagId":17,"type":"string","value":1}}]}]}`), nil)
+	var pe *PathError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, `$[1]["k"]`, pe.Path)
+	assert.ErrorIs(t, err, ErrInvalidJSON)
+	assert.Contains(t, err.Error(), `$[1]["k"]: `)
+
+	_, err = Unmarshal([]byte(`{"tagId":17,"type":"bool","value":true}`), nil)
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, "$", pe.Path)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+}
+
+func TestParseBigDec(t *testing.T) {
+	for _, s := range []string{"0", "123", "-12.3", "0.00123", "-0.00123", "123E4", "-123E4"} {
+		v, scale, err := parseBigDec(s)
+		require.Nil(t, err, s)
+		assert.Equal(t, s, formatBigDec(v, scale))
+	}
+	v, scale, err := parseBigDec("1.50")
+	require.Nil(t, err)
+	assert.Equal(t, int64(150), v.Int64())
+	assert.Equal(t, int32(2), scale)
+
+	for _, s := range []string{"", "1.", "1.2.3", "1.2E3", "1E", "1E-1", "1E99999999999", "a",
+		"0.1e2"} {
+		_, _, err := parseBigDec(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package taglog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Iterator reads the records of a Log in order. It follows the log, thus the
+records appended after its creation are also returned.
+
+An Iterator must not be used by multiple goroutines at the same time.
+*/
+type Iterator struct {
+	log    *Log
+	offset int64
+	limit  int64
+	reader *bufio.Reader
+}
+
+/*
+Returns an iterator that starts at the record with the given offset. The offset
+0 is the beginning of the log. Other offsets must be the beginning of a record,
+as returned by Iterator.Offset() and Log.Offset(), or the size of the log.
+
+The offset of the iterator can be stored to resume the iteration later.
+*/
+func (l *Log) Iterate(offset int64) (*Iterator, error) {
+	if offset == 0 {
+		offset = int64(headerSize)
+	} else if _, err := l.Seq(offset); err != nil {
+		return nil, err
+	}
+	return &Iterator{log: l, offset: offset}, nil
+}
+
+/*
+Returns the next tag of the log. It returns io.EOF when there are no more
+records. Since the log may grow, Next() may be called again after io.EOF to
+get the records appended later.
+
+On errors, the iterator does not move, thus the next call tries to read the
+same record again.
+*/
+func (it *Iterator) Next() (tags.ILTag, error) {
+	if it.reader == nil || it.offset >= it.limit {
+		file, size, err := it.log.state()
+		if err != nil {
+			return nil, err
+		}
+		if it.offset >= size {
+			return nil, io.EOF
+		}
+		it.limit = size
+		it.reader = bufio.NewReader(io.NewSectionReader(file, it.offset, size-it.offset))
+	}
+	tag, next, err := it.log.readNext(it.reader, it.offset)
+	if err != nil {
+		it.reader = nil
+		return nil, fmt.Errorf("offset %d: %w", it.offset, err)
+	}
+	it.offset = next
+	return tag, nil
+}
+
+/*
+Returns the offset of the next record to be read by the iterator.
+*/
+func (it *Iterator) Offset() int64 {
+	return it.offset
+}
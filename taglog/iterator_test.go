@@ -0,0 +1,118 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package taglog
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Reads the remaining tags of the iterator in the text notation.
+func iterateAll(t *testing.T, it *Iterator) []string {
+	found := []string{}
+	for {
+		tag, err := it.Next()
+		if err == io.EOF {
+			return found
+		}
+		require.Nil(t, err)
+		found = append(found, impl.FormatTag(tag))
+	}
+}
+
+func TestIterator(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		l, _ := newTestLog(t, &Options{Checksum: checksum})
+		defer l.Close()
+
+		it, err := l.Iterate(0)
+		require.Nil(t, err)
+		assert.Equal(t, int64(headerSize), it.Offset())
+		assert.Equal(t, logSamples, iterateAll(t, it))
+		assert.Equal(t, l.Size(), it.Offset())
+
+		// Follows the log
+		_, err = l.Append(impl.MustParseTag(`str("new")`))
+		require.Nil(t, err)
+		assert.Equal(t, []string{`str("new")`}, iterateAll(t, it))
+		assert.Equal(t, l.Size(), it.Offset())
+
+		// Resumes from each record
+		for i := range logSamples {
+			offset, err := l.Offset(uint64(i))
+			require.Nil(t, err)
+			it, err := l.Iterate(offset)
+			require.Nil(t, err)
+			assert.Equal(t, append(logSamples[i:], `str("new")`), iterateAll(t, it))
+		}
+		it, err = l.Iterate(l.Size())
+		require.Nil(t, err)
+		assert.Empty(t, iterateAll(t, it))
+	}
+}
+
+func TestIterator_Errors(t *testing.T) {
+	l, path := newTestLog(t, &Options{Checksum: true})
+	defer l.Close()
+
+	_, err := l.Iterate(int64(headerSize + 1))
+	assert.ErrorIs(t, err, ErrBadOffset)
+
+	offset, err := l.Offset(1)
+	require.Nil(t, err)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.Nil(t, err)
+	f.WriteAt([]byte{'H'}, offset+2)
+	f.Close()
+
+	it, err := l.Iterate(0)
+	require.Nil(t, err)
+	tag, err := it.Next()
+	require.Nil(t, err)
+	assert.Equal(t, "null", impl.FormatTag(tag))
+	for i := 0; i < 2; i++ {
+		_, err = it.Next()
+		assert.ErrorIs(t, err, ErrChecksum)
+		assert.EqualError(t, err, "offset 13: checksum mismatch")
+		assert.Equal(t, offset, it.Offset())
+	}
+
+	require.Nil(t, l.Close())
+	_, err = it.Next()
+	assert.Equal(t, ErrClosed, err)
+}
@@ -0,0 +1,532 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package taglog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// Suffix of the name of the index file.
+const IndexSuffix = ".idx"
+
+var (
+	// The log is closed.
+	ErrClosed = fmt.Errorf("log closed")
+	// The sequence number does not exist in the log.
+	ErrOutOfRange = fmt.Errorf("sequence number out of range")
+	// The offset does not point to the beginning of a record.
+	ErrBadOffset = fmt.Errorf("offset is not a record boundary")
+)
+
+/*
+SyncPolicy defines when the appended records are flushed to the storage with
+fsync.
+*/
+type SyncPolicy int
+
+const (
+	// The records are flushed only by Sync() and Close().
+	SyncNever SyncPolicy = iota
+	// The records are flushed by every call to Append().
+	SyncAlways
+	/*
+		The records are flushed by Append() when at least Options.SyncInterval
+		has passed since the last flush. There is no background flush, thus the
+		last records remain pending until the next Append(), Sync() or Close().
+	*/
+	SyncInterval
+)
+
+/*
+Options of a Log.
+*/
+type Options struct {
+	// Adds a CRC32 trailer to each record. Used only when the log is created.
+	Checksum bool
+	// When the appended records are flushed.
+	Sync SyncPolicy
+	// Minimum interval between flushes for SyncInterval.
+	SyncInterval time.Duration
+	// Maximum size of the payload of a tag. 0 means tags.MAX_TAG_SIZE.
+	MaxTagSize uint64
+	/*
+		Factory used to create the tags read from the log. If nil, a non strict
+		StandardTagFactory is used.
+	*/
+	Factory tags.ILTagFactory
+}
+
+/*
+Log is an append-only log of tags stored in a file. Each record is identified
+by its sequence number, starting at 0, and by its offset in the file.
+
+It is safe to use a Log from multiple goroutines.
+*/
+type Log struct {
+	mutex     sync.Mutex
+	file      *os.File
+	index     *os.File
+	opts      Options
+	checksum  bool
+	size      int64
+	count     uint64
+	lastSync  time.Time
+	truncated int64
+}
+
+/*
+Opens the log stored in the given file, creating it if it does not exist. The
+index file is created or rebuilt as required. Existing logs are recovered as
+described in the package documentation.
+*/
+func Open(path string, opts *Options) (*Log, error) {
+	l := &Log{}
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.Factory == nil {
+		l.opts.Factory = impl.NewStandardTagFactory(false)
+	}
+	if l.opts.MaxTagSize == 0 {
+		l.opts.MaxTagSize = tags.MAX_TAG_SIZE
+	}
+	var err error
+	if l.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return nil, err
+	}
+	if l.index, err = os.OpenFile(path+IndexSuffix, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	if err = l.recover(); err != nil {
+		l.file.Close()
+		l.index.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l.lastSync = time.Now()
+	return l, nil
+}
+
+// Initializes the header of a new log or reads the header of an existing one.
+func (l *Log) initHeader(fileSize int64) error {
+	expected := encodeHeader(l.opts.Checksum)
+	if fileSize < int64(headerSize) {
+		// New log or crash during its creation
+		h := make([]byte, fileSize)
+		if _, err := l.file.ReadAt(h, 0); err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(expected, h) {
+			return ErrBadHeader
+		}
+		if _, err := l.file.WriteAt(expected, 0); err != nil {
+			return err
+		}
+		if err := l.file.Sync(); err != nil {
+			return err
+		}
+		l.checksum = l.opts.Checksum
+		return nil
+	}
+	h := make([]byte, headerSize)
+	if _, err := l.file.ReadAt(h, 0); err != nil {
+		return err
+	}
+	var err error
+	l.checksum, err = decodeHeader(h)
+	return err
+}
+
+// Returns the entry of the index with the given sequence number.
+func (l *Log) indexEntry(seq uint64) (int64, error) {
+	var b [indexEntrySize]byte
+	if _, err := l.index.ReadAt(b[:], int64(seq)*indexEntrySize); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b[:])), nil
+}
+
+/*
+Returns true if the record at the given offset is complete and, if the log has
+checksums, valid.
+*/
+func (l *Log) validRecord(offset int64, fileSize int64) (bool, error) {
+	reader := bufio.NewReader(io.NewSectionReader(l.file, offset, fileSize-offset))
+	_, err := readRecord(reader, l.checksum, l.opts.MaxTagSize)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+/*
+Finds the last indexed record that may be valid. It returns the number of
+entries of the index that precede it and its offset. If there is no such
+record, it returns the offset of the first record.
+
+The index may be flushed before the records it points to, thus, if the log has
+checksums, the indexed records are verified from the last to the first until a
+valid one is found.
+*/
+func (l *Log) lastIndexed(fileSize int64) (uint64, int64, error) {
+	info, err := l.index.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	n := uint64(info.Size() / indexEntrySize)
+	for ; n > 0; n-- {
+		offset, err := l.indexEntry(n - 1)
+		if err != nil {
+			return 0, 0, err
+		}
+		if offset < int64(headerSize) || offset >= fileSize {
+			continue
+		}
+		if !l.checksum {
+			return n - 1, offset, nil
+		}
+		valid, err := l.validRecord(offset, fileSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		if valid {
+			return n - 1, offset, nil
+		}
+	}
+	return 0, int64(headerSize), nil
+}
+
+/*
+Verifies and indexes the records after the last indexed record and truncates
+the log at the first invalid record.
+*/
+func (l *Log) recover() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := info.Size()
+	if err := l.initHeader(fileSize); err != nil {
+		return err
+	}
+	if fileSize < int64(headerSize) {
+		fileSize = int64(headerSize)
+	}
+	count, offset, err := l.lastIndexed(fileSize)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(l.file, offset, fileSize-offset))
+	var entries []byte
+	for {
+		record, err := readRecord(reader, l.checksum, l.opts.MaxTagSize)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pathErr *os.PathError
+			if errors.As(err, &pathErr) {
+				return err
+			}
+			// Torn or corrupted tail
+			break
+		}
+		var e [indexEntrySize]byte
+		binary.BigEndian.PutUint64(e[:], uint64(offset))
+		entries = append(entries, e[:]...)
+		offset += l.recordSize(record)
+	}
+	if _, err := l.index.WriteAt(entries, int64(count)*indexEntrySize); err != nil {
+		return err
+	}
+	l.count = count + uint64(len(entries)/indexEntrySize)
+	if err := l.index.Truncate(int64(l.count) * indexEntrySize); err != nil {
+		return err
+	}
+	if offset < fileSize {
+		if err := l.file.Truncate(offset); err != nil {
+			return err
+		}
+		if err := l.file.Sync(); err != nil {
+			return err
+		}
+	}
+	l.size = offset
+	l.truncated = fileSize - offset
+	return nil
+}
+
+// Returns the size of the record in the file.
+func (l *Log) recordSize(tag []byte) int64 {
+	if l.checksum {
+		return int64(len(tag) + checksumSize)
+	}
+	return int64(len(tag))
+}
+
+/*
+Returns the number of bytes removed from the end of the log by the recovery
+performed by Open().
+*/
+func (l *Log) Truncated() int64 {
+	return l.truncated
+}
+
+// Returns true if the records of this log have checksums.
+func (l *Log) Checksum() bool {
+	return l.checksum
+}
+
+// Returns the number of records in the log.
+func (l *Log) Len() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.count
+}
+
+/*
+Returns the size of the log file. It is also the offset of the next record to
+be appended.
+*/
+func (l *Log) Size() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.size
+}
+
+/*
+Appends the tag to the log and returns its sequence number. The record is
+flushed according to the sync policy. A nil tag is appended as an ILNullTag.
+
+If the write fails, the log is restored to its previous size.
+*/
+func (l *Log) Append(tag tags.ILTag) (uint64, error) {
+	if !tags.IsILTagNil(tag) && tag.ValueSize() > l.opts.MaxTagSize {
+		return 0, tags.ErrTagTooLarge
+	}
+	var b bytes.Buffer
+	if err := tags.ILTagSeralizeWithNull(tag, &b); err != nil {
+		return 0, err
+	}
+	record := b.Bytes()
+	if l.checksum {
+		record = appendChecksum(record)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return 0, ErrClosed
+	}
+	var e [indexEntrySize]byte
+	binary.BigEndian.PutUint64(e[:], uint64(l.size))
+	if _, err := l.file.WriteAt(record, l.size); err != nil {
+		l.file.Truncate(l.size)
+		return 0, err
+	}
+	if _, err := l.index.WriteAt(e[:], int64(l.count)*indexEntrySize); err != nil {
+		l.file.Truncate(l.size)
+		return 0, err
+	}
+	seq := l.count
+	l.size += int64(len(record))
+	l.count++
+	if l.opts.Sync == SyncAlways ||
+		(l.opts.Sync == SyncInterval && time.Since(l.lastSync) >= l.opts.SyncInterval) {
+		if err := l.sync(); err != nil {
+			return seq, err
+		}
+	}
+	return seq, nil
+}
+
+// Flushes the log file.
+func (l *Log) sync() error {
+	l.lastSync = time.Now()
+	return l.file.Sync()
+}
+
+// Flushes the log and the index to the storage.
+func (l *Log) Sync() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrClosed
+	}
+	if err := l.sync(); err != nil {
+		return err
+	}
+	return l.index.Sync()
+}
+
+/*
+Returns the offset of the record with the given sequence number. It fails with
+ErrOutOfRange if the record does not exist.
+*/
+func (l *Log) Offset(seq uint64) (int64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return 0, ErrClosed
+	}
+	if seq >= l.count {
+		return 0, fmt.Errorf("%d: %w", seq, ErrOutOfRange)
+	}
+	return l.indexEntry(seq)
+}
+
+/*
+Returns the sequence number of the record at the given offset. It fails with
+ErrBadOffset if the offset is not the beginning of a record.
+*/
+func (l *Log) Seq(offset int64) (uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return 0, ErrClosed
+	}
+	var err error
+	seq := sort.Search(int(l.count), func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var e int64
+		e, err = l.indexEntry(uint64(i))
+		return e >= offset
+	})
+	if err != nil {
+		return 0, err
+	}
+	if uint64(seq) == l.count && offset == l.size {
+		return l.count, nil
+	}
+	if uint64(seq) < l.count {
+		if e, err := l.indexEntry(uint64(seq)); err != nil {
+			return 0, err
+		} else if e == offset {
+			return uint64(seq), nil
+		}
+	}
+	return 0, fmt.Errorf("%d: %w", offset, ErrBadOffset)
+}
+
+/*
+Reads the record at the given offset of the file. The record must end before
+limit. It returns the tag and the offset of the next record.
+*/
+func (l *Log) readAt(file *os.File, offset int64, limit int64) (tags.ILTag, int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, limit-offset))
+	return l.readNext(reader, offset)
+}
+
+/*
+Reads the record at the current position of the reader, whose offset is given.
+It returns the tag and the offset of the next record.
+*/
+func (l *Log) readNext(reader *bufio.Reader, offset int64) (tags.ILTag, int64, error) {
+	record, err := readRecord(reader, l.checksum, l.opts.MaxTagSize)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	tag, err := tags.ILTagFromBytes(l.opts.Factory, record)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tag, offset + l.recordSize(record), nil
+}
+
+// Returns the log file and its size.
+func (l *Log) state() (*os.File, int64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil, 0, ErrClosed
+	}
+	return l.file, l.size, nil
+}
+
+// Reads the record with the given sequence number.
+func (l *Log) Read(seq uint64) (tags.ILTag, error) {
+	offset, err := l.Offset(seq)
+	if err != nil {
+		return nil, err
+	}
+	file, size, err := l.state()
+	if err != nil {
+		return nil, err
+	}
+	tag, _, err := l.readAt(file, offset, size)
+	if err != nil {
+		return nil, fmt.Errorf("record %d at offset %d: %w", seq, offset, err)
+	}
+	return tag, nil
+}
+
+/*
+Closes the log. The log and the index are flushed before being closed.
+*/
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrClosed
+	}
+	err := l.file.Sync()
+	if e := l.index.Sync(); err == nil {
+		err = e
+	}
+	if e := l.file.Close(); err == nil {
+		err = e
+	}
+	if e := l.index.Close(); err == nil {
+		err = e
+	}
+	l.file = nil
+	l.index = nil
+	return err
+}
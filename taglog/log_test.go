@@ -0,0 +1,364 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package taglog
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logSamples = []string{"null", `str("hello")`, "ilint(1000)", `#1000 bytes(0x0102)`,
+	`array[u8(1), str("")]`}
+
+// Creates a new log with the samples.
+func newTestLog(t *testing.T, opts *Options) (*Log, string) {
+	path := filepath.Join(t.TempDir(), "log")
+	l, err := Open(path, opts)
+	require.Nil(t, err)
+	for i, s := range logSamples {
+		seq, err := l.Append(impl.MustParseTag(s))
+		require.Nil(t, err)
+		assert.Equal(t, uint64(i), seq)
+	}
+	return l, path
+}
+
+// Reads all tags of the log in the text notation.
+func readAll(t *testing.T, l *Log) []string {
+	var found []string
+	for i := uint64(0); i < l.Len(); i++ {
+		tag, err := l.Read(i)
+		require.Nil(t, err)
+		found = append(found, impl.FormatTag(tag))
+	}
+	return found
+}
+
+func TestOpen(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		l, path := newTestLog(t, &Options{Checksum: checksum})
+		assert.Equal(t, checksum, l.Checksum())
+		assert.Equal(t, uint64(len(logSamples)), l.Len())
+		assert.Equal(t, logSamples, readAll(t, l))
+		size := l.Size()
+		require.Nil(t, l.Close())
+
+		data, err := os.ReadFile(path)
+		require.Nil(t, err)
+		assert.Equal(t, encodeHeader(checksum), data[:headerSize])
+		assert.Equal(t, size, int64(len(data)))
+		index, err := os.ReadFile(path + IndexSuffix)
+		require.Nil(t, err)
+		assert.Len(t, index, len(logSamples)*indexEntrySize)
+		assert.Equal(t, uint64(headerSize), binary.BigEndian.Uint64(index))
+
+		// The header has precedence over the options
+		l, err = Open(path, &Options{Checksum: !checksum})
+		require.Nil(t, err)
+		assert.Equal(t, checksum, l.Checksum())
+		assert.Equal(t, int64(0), l.Truncated())
+		assert.Equal(t, size, l.Size())
+		assert.Equal(t, logSamples, readAll(t, l))
+		seq, err := l.Append(nil)
+		require.Nil(t, err)
+		assert.Equal(t, uint64(len(logSamples)), seq)
+		require.Nil(t, l.Close())
+	}
+
+	// Default options
+	l, err := Open(filepath.Join(t.TempDir(), "log"), nil)
+	require.Nil(t, err)
+	assert.False(t, l.Checksum())
+	assert.Equal(t, uint64(0), l.Len())
+	assert.Equal(t, int64(headerSize), l.Size())
+	require.Nil(t, l.Close())
+}
+
+func TestOpen_Errors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log")
+	require.Nil(t, os.WriteFile(path, []byte("ILTLOX\x01\x00"), 0644))
+	_, err := Open(path, nil)
+	assert.ErrorIs(t, err, ErrBadHeader)
+	require.Nil(t, os.WriteFile(path, []byte("X"), 0644))
+	_, err = Open(path, nil)
+	assert.ErrorIs(t, err, ErrBadHeader)
+
+	// Partial header
+	require.Nil(t, os.WriteFile(path, []byte("ILT"), 0644))
+	l, err := Open(path, &Options{Checksum: true})
+	require.Nil(t, err)
+	assert.True(t, l.Checksum())
+	require.Nil(t, l.Close())
+
+	_, err = Open(filepath.Join(dir, "x", "log"), nil)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "y"+IndexSuffix), 0755))
+	_, err = Open(filepath.Join(dir, "y"), nil)
+	assert.Error(t, err)
+}
+
+func TestOpen_Recovery(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		l, path := newTestLog(t, &Options{Checksum: checksum})
+		last, err := l.Offset(l.Len() - 1)
+		require.Nil(t, err)
+		size := l.Size()
+		require.Nil(t, l.Close())
+
+		// Torn tail
+		for cut := size - 1; cut > last; cut-- {
+			require.Nil(t, os.Truncate(path, cut))
+			l, err = Open(path, nil)
+			require.Nil(t, err)
+			assert.Equal(t, cut-last, l.Truncated())
+			assert.Equal(t, last, l.Size())
+			assert.Equal(t, logSamples[:len(logSamples)-1], readAll(t, l))
+			_, err = l.Append(impl.MustParseTag(logSamples[len(logSamples)-1]))
+			require.Nil(t, err)
+			assert.Equal(t, size, l.Size())
+			require.Nil(t, l.Close())
+		}
+
+		// Missing index
+		require.Nil(t, os.Remove(path+IndexSuffix))
+		l, err = Open(path, nil)
+		require.Nil(t, err)
+		assert.Equal(t, int64(0), l.Truncated())
+		assert.Equal(t, logSamples, readAll(t, l))
+		require.Nil(t, l.Close())
+
+		// Index ahead of the log and partial entries
+		f, err := os.OpenFile(path+IndexSuffix, os.O_APPEND|os.O_WRONLY, 0)
+		require.Nil(t, err)
+		f.Write([]byte{0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 0})
+		f.Close()
+		l, err = Open(path, nil)
+		require.Nil(t, err)
+		assert.Equal(t, logSamples, readAll(t, l))
+		require.Nil(t, l.Close())
+		info, err := os.Stat(path + IndexSuffix)
+		require.Nil(t, err)
+		assert.Equal(t, int64(len(logSamples)*indexEntrySize), info.Size())
+	}
+
+	// Corrupted tail
+	l, path := newTestLog(t, &Options{Checksum: true})
+	last, err := l.Offset(l.Len() - 1)
+	require.Nil(t, err)
+	require.Nil(t, l.Close())
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.Nil(t, err)
+	f.WriteAt([]byte{0xff}, last+3)
+	f.Close()
+	l, err = Open(path, nil)
+	require.Nil(t, err)
+	assert.Equal(t, logSamples[:len(logSamples)-1], readAll(t, l))
+	assert.Equal(t, last, l.Size())
+	require.Nil(t, l.Close())
+
+	// Zeroed tail covered by the index
+	l, path = newTestLog(t, &Options{Checksum: true})
+	second, err := l.Offset(2)
+	require.Nil(t, err)
+	size := l.Size()
+	require.Nil(t, l.Close())
+	f, err = os.OpenFile(path, os.O_WRONLY, 0)
+	require.Nil(t, err)
+	f.WriteAt(make([]byte, size-second), second)
+	f.Close()
+	l, err = Open(path, nil)
+	require.Nil(t, err)
+	assert.Equal(t, logSamples[:2], readAll(t, l))
+	assert.Equal(t, second, l.Size())
+	assert.Equal(t, size-second, l.Truncated())
+	seq, err := l.Append(impl.MustParseTag(logSamples[2]))
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), seq)
+	require.Nil(t, l.Close())
+
+	// Nothing valid
+	f, err = os.OpenFile(path, os.O_WRONLY, 0)
+	require.Nil(t, err)
+	f.WriteAt(make([]byte, second-int64(headerSize)+1), int64(headerSize))
+	f.Close()
+	l, err = Open(path, nil)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), l.Len())
+	assert.Equal(t, int64(headerSize), l.Size())
+	require.Nil(t, l.Close())
+
+	// Garbage after the last record
+	l, path = newTestLog(t, &Options{Checksum: true})
+	last = l.Size()
+	require.Nil(t, l.Close())
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.Nil(t, err)
+	f.Write([]byte{0x0f, 0x00})
+	f.Close()
+	l, err = Open(path, nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), l.Truncated())
+	assert.Equal(t, last, l.Size())
+	require.Nil(t, l.Close())
+}
+
+func TestLog_Append(t *testing.T) {
+	l, _ := newTestLog(t, nil)
+	defer l.Close()
+	l.opts.MaxTagSize = 4
+	_, err := l.Append(impl.MustParseTag(`str("hello")`))
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	assert.Equal(t, uint64(len(logSamples)), l.Len())
+
+	bad := &failingTag{}
+	bad.SetId(1000)
+	_, err = l.Append(bad)
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, uint64(len(logSamples)), l.Len())
+}
+
+// Tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
+
+func TestLog_SyncPolicies(t *testing.T) {
+	for _, opts := range []*Options{
+		{Sync: SyncNever},
+		{Sync: SyncAlways},
+		{Sync: SyncInterval, SyncInterval: time.Hour},
+		{Sync: SyncInterval},
+	} {
+		l, _ := newTestLog(t, opts)
+		last := l.lastSync
+		_, err := l.Append(nil)
+		require.Nil(t, err)
+		if opts.Sync == SyncAlways || (opts.Sync == SyncInterval && opts.SyncInterval == 0) {
+			assert.True(t, l.lastSync.After(last))
+		} else {
+			assert.Equal(t, last, l.lastSync)
+		}
+		require.Nil(t, l.Sync())
+		assert.True(t, l.lastSync.After(last))
+		require.Nil(t, l.Close())
+	}
+}
+
+func TestLog_OffsetSeq(t *testing.T) {
+	l, _ := newTestLog(t, nil)
+	defer l.Close()
+	offset := int64(headerSize)
+	for i, s := range logSamples {
+		o, err := l.Offset(uint64(i))
+		require.Nil(t, err)
+		assert.Equal(t, offset, o)
+		seq, err := l.Seq(offset)
+		require.Nil(t, err)
+		assert.Equal(t, uint64(i), seq)
+		size := int64(len(impl.MustParseTagBytes(s)))
+		if size > 1 {
+			_, err = l.Seq(offset + 1)
+			assert.ErrorIs(t, err, ErrBadOffset)
+		}
+		offset += size
+	}
+	_, err := l.Offset(uint64(len(logSamples)))
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.EqualError(t, err, "5: sequence number out of range")
+
+	seq, err := l.Seq(l.Size())
+	require.Nil(t, err)
+	assert.Equal(t, uint64(len(logSamples)), seq)
+	for _, o := range []int64{0, l.Size() + 1} {
+		_, err = l.Seq(o)
+		assert.ErrorIs(t, err, ErrBadOffset)
+	}
+}
+
+func TestLog_ReadErrors(t *testing.T) {
+	l, path := newTestLog(t, &Options{Checksum: true, Factory: impl.NewStandardTagFactory(true)})
+	defer l.Close()
+
+	_, err := l.Read(3)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+	_, err = l.Read(10)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+
+	offset, err := l.Offset(1)
+	require.Nil(t, err)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.Nil(t, err)
+	f.WriteAt([]byte{'H'}, offset+2)
+	f.Close()
+	_, err = l.Read(1)
+	assert.ErrorIs(t, err, ErrChecksum)
+	assert.Contains(t, err.Error(), "record 1 at offset 13:")
+}
+
+func TestLog_Close(t *testing.T) {
+	l, _ := newTestLog(t, nil)
+	require.Nil(t, l.Close())
+	assert.Equal(t, ErrClosed, l.Close())
+	assert.Equal(t, ErrClosed, l.Sync())
+	_, err := l.Append(nil)
+	assert.Equal(t, ErrClosed, err)
+	_, err = l.Offset(0)
+	assert.Equal(t, ErrClosed, err)
+	_, err = l.Seq(0)
+	assert.Equal(t, ErrClosed, err)
+	_, err = l.Read(0)
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, uint64(len(logSamples)), l.Len())
+}
@@ -0,0 +1,60 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+This package implements an append-only log of tags stored in a file. It is
+meant to be used as a simple journal that survives crashes.
+
+The log file starts with a small header followed by the records. Each record
+is a serialized tag, optionally followed by the CRC32 (IEEE) of the tag in big
+endian order:
+
+	"ILTLOG" <version:1> <flags:1> <record>*
+	record := <tag> [<crc32:4>]
+
+The checksums are enabled when the log is created and apply to all its
+records. They are strongly recommended since they are the only way to detect
+corrupted records. Without them, a tail filled with zeros by the file system
+after a crash is indistinguishable from a sequence of ILNullTags.
+
+The offsets of the records are kept in a sidecar index file with the suffix
+".idx". It is a plain array of 64-bit big endian offsets, thus the offset of the
+record with sequence number n is found at the position n * 8 of the index.
+
+When the log is opened, the records after the last indexed record are verified
+and indexed. If the log has checksums, the indexed records are verified as well,
+from the last to the first, until a valid one is found, since the index may
+point to records that were never flushed. The first incomplete or corrupted
+record found marks the end of the log and it is truncated there along with
+everything that follows it. The index is rebuilt accordingly.
+*/
+package taglog
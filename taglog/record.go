@@ -0,0 +1,144 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package taglog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/interlockledger/go-iltags/ilint"
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// Magic number of the log files.
+const logMagic = "ILTLOG"
+
+// Version of the log format.
+const logVersion = 1
+
+// Size of the header of the log file.
+const headerSize = len(logMagic) + 2
+
+// The records have CRC32 trailers.
+const flagChecksum = 0x01
+
+// Size of the CRC32 trailer.
+const checksumSize = 4
+
+// Size of each entry of the index.
+const indexEntrySize = 8
+
+var (
+	// The file is not a tag log.
+	ErrBadHeader = fmt.Errorf("not a tag log")
+	// The checksum of the record does not match its contents.
+	ErrChecksum = fmt.Errorf("checksum mismatch")
+)
+
+// Creates the header of a log file.
+func encodeHeader(checksum bool) []byte {
+	h := make([]byte, 0, headerSize)
+	h = append(h, logMagic...)
+	h = append(h, logVersion)
+	if checksum {
+		return append(h, flagChecksum)
+	}
+	return append(h, 0)
+}
+
+// Decodes the header of a log file. It returns true if the log has checksums.
+func decodeHeader(h []byte) (bool, error) {
+	if len(h) != headerSize || string(h[:len(logMagic)]) != logMagic ||
+		h[len(logMagic)] != logVersion || h[len(logMagic)+1]&^flagChecksum != 0 {
+		return false, ErrBadHeader
+	}
+	return h[len(logMagic)+1]&flagChecksum != 0, nil
+}
+
+// Appends the CRC32 trailer of the tag to the record.
+func appendChecksum(record []byte) []byte {
+	var crc [checksumSize]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(record))
+	return append(record, crc[:]...)
+}
+
+// Converts io.EOF into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+/*
+Reads the next record from the reader. It returns the serialized tag without
+the checksum. The size of the record in the file is the length of the tag plus
+checksumSize if checksum is true.
+
+It returns io.EOF if the reader ends before the record, io.ErrUnexpectedEOF if
+it ends in the middle of the record and ErrChecksum if the checksum does not
+match. Only the header of the tag and the values of the ILInt tags are
+verified.
+*/
+func readRecord(reader *bufio.Reader, checksum bool, maxSize uint64) ([]byte, error) {
+	id, headerSize, payloadSize, err := tags.PeekTagHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !id.Implicit() && payloadSize > maxSize {
+		return nil, tags.ErrTagTooLarge
+	}
+	b := make([]byte, uint64(headerSize)+payloadSize)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if id == tags.IL_ILINT_TAG_ID || id == tags.IL_SIGNED_ILINT_TAG_ID {
+		// The header only tells the size of the ILInt
+		if _, _, err := ilint.Decode(b[headerSize:]); err != nil {
+			return nil, tags.ErrBadTagFormat
+		}
+	}
+	if checksum {
+		var crc [checksumSize]byte
+		if _, err := io.ReadFull(reader, crc[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if binary.BigEndian.Uint32(crc[:]) != crc32.ChecksumIEEE(b) {
+			return nil, ErrChecksum
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,123 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package taglog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeader(t *testing.T) {
+	h := encodeHeader(false)
+	assert.Equal(t, []byte("ILTLOG\x01\x00"), h)
+	checksum, err := decodeHeader(h)
+	require.Nil(t, err)
+	assert.False(t, checksum)
+
+	h = encodeHeader(true)
+	assert.Equal(t, []byte("ILTLOG\x01\x01"), h)
+	checksum, err = decodeHeader(h)
+	require.Nil(t, err)
+	assert.True(t, checksum)
+
+	for _, bad := range []string{"", "ILTLOG\x01", "ILTLOX\x01\x00", "ILTLOG\x02\x00",
+		"ILTLOG\x01\x02", "ILTLOG\x01\x00\x00"} {
+		_, err = decodeHeader([]byte(bad))
+		assert.ErrorIs(t, err, ErrBadHeader, "%q", bad)
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	samples := []string{"null", "bool(true)", "ilint(1000)", "silint(-1000)",
+		"f128(0x000102030405060708090A0B0C0D0E0F)", `str("hello")`, `#1000 bytes(0x0102)`,
+		`array[u8(1), str("")]`}
+	for _, checksum := range []bool{false, true} {
+		var data []byte
+		for _, s := range samples {
+			record := impl.MustParseTagBytes(s)
+			if checksum {
+				record = appendChecksum(record)
+			}
+			data = append(data, record...)
+		}
+		reader := bufio.NewReader(bytes.NewReader(data))
+		for _, s := range samples {
+			record, err := readRecord(reader, checksum, tags.MAX_TAG_SIZE)
+			require.Nil(t, err, s)
+			assert.Equal(t, impl.MustParseTagBytes(s), record)
+		}
+		_, err := readRecord(reader, checksum, tags.MAX_TAG_SIZE)
+		assert.Equal(t, io.EOF, err)
+
+		// Truncated records
+		for i := 1; i < len(data); i++ {
+			reader := bufio.NewReader(bytes.NewReader(data[:i]))
+			var err error
+			for err == nil {
+				_, err = readRecord(reader, checksum, tags.MAX_TAG_SIZE)
+			}
+			if err != io.EOF {
+				assert.Equal(t, io.ErrUnexpectedEOF, err, "%d", i)
+			}
+		}
+	}
+}
+
+func TestReadRecord_Errors(t *testing.T) {
+	record := appendChecksum(impl.MustParseTagBytes(`str("hello")`))
+	record[3]++
+	_, err := readRecord(bufio.NewReader(bytes.NewReader(record)), true, tags.MAX_TAG_SIZE)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	samples := []struct {
+		data     []byte
+		expected error
+	}{
+		{[]byte{0x0f}, tags.ErrUnsupportedTagId},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat},
+		{[]byte{0x10, 0x06, 1, 2, 3, 4, 5, 6}, tags.ErrTagTooLarge},
+		{[]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat},
+		{[]byte{0x10, 0xff}, io.ErrUnexpectedEOF},
+	}
+	for _, s := range samples {
+		_, err := readRecord(bufio.NewReader(bytes.NewReader(s.data)), false, 5)
+		assert.ErrorIs(t, err, s.expected, "%x", s.data)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagnet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tagstream"
+)
+
+// Default maximum size of the payload of the tags exchanged by a TagConn.
+const DefaultMaxTagSize uint64 = 16 * 1024 * 1024
+
+// Default maximum time Close() waits for the Send() in progress.
+const DefaultCloseTimeout = 5 * time.Second
+
+// Size of the read and write buffers.
+const bufferSize = 4096
+
+// The connection was closed.
+var ErrClosed = fmt.Errorf("connection closed")
+
+/*
+TagConn sends and receives tags over a net.Conn.
+
+Received tags are decoded incrementally, thus a Receive() that fails due to a
+read timeout may be retried without losing the data of the incomplete tag. Any
+other error leaves the connection in an undefined state and it must be closed.
+
+It is safe to use a TagConn with one goroutine calling Receive() and another
+calling Send() at the same time. Concurrent calls to the same method are
+serialized.
+
+The fields must be set before the connection is used.
+*/
+type TagConn struct {
+	// Maximum size of the payload of the tags. 0 means DefaultMaxTagSize.
+	MaxTagSize uint64
+	// Maximum time to wait for each tag in Receive(). 0 means no limit.
+	ReadTimeout time.Duration
+	// Maximum time to wait for each call to Send(). 0 means no limit.
+	WriteTimeout time.Duration
+	// Maximum time Close() waits for the Send() in progress. 0 means
+	// DefaultCloseTimeout.
+	CloseTimeout time.Duration
+	conn         net.Conn
+	closed       int32
+	readMutex    sync.Mutex
+	decoder      *tagstream.Decoder
+	readBuff     []byte
+	pending      []tags.ILTag
+	readErr      error
+	writeMutex   sync.Mutex
+	writer       *bufio.Writer
+}
+
+/*
+Creates a new TagConn that uses the given factory to create the received tags.
+The TagConn takes the ownership of the connection.
+*/
+func NewTagConn(conn net.Conn, factory tags.ILTagFactory) *TagConn {
+	return &TagConn{
+		conn:     conn,
+		decoder:  tagstream.NewDecoder(factory),
+		readBuff: make([]byte, bufferSize),
+		writer:   bufio.NewWriterSize(conn, bufferSize),
+	}
+}
+
+// Returns the underlying connection.
+func (c *TagConn) Conn() net.Conn {
+	return c.conn
+}
+
+// Returns true if Close() was called.
+func (c *TagConn) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) != 0
+}
+
+// Returns the maximum size of the payload of the tags.
+func (c *TagConn) maxTagSize() uint64 {
+	if c.MaxTagSize == 0 {
+		return DefaultMaxTagSize
+	}
+	return c.MaxTagSize
+}
+
+// Returns the maximum time Close() waits for the Send() in progress.
+func (c *TagConn) closeTimeout() time.Duration {
+	if c.CloseTimeout <= 0 {
+		return DefaultCloseTimeout
+	}
+	return c.CloseTimeout
+}
+
+// Replaces the errors caused by Close() with ErrClosed.
+func (c *TagConn) mapError(err error) error {
+	if err != nil && c.isClosed() {
+		return ErrClosed
+	}
+	return err
+}
+
+/*
+Sends the given tags to the peer. All tags are written before the buffer is
+flushed, thus sending multiple tags at once reduces the number of writes on the
+connection. Nil tags are sent as ILNullTags.
+
+It fails with tags.ErrTagTooLarge before anything is sent if the payload of any
+tag is larger than the limit.
+*/
+func (c *TagConn) Send(list ...tags.ILTag) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.isClosed() {
+		return ErrClosed
+	}
+	maxSize := c.maxTagSize()
+	for _, t := range list {
+		if !tags.IsILTagNil(t) && t.ValueSize() > maxSize {
+			return tags.ErrTagTooLarge
+		}
+	}
+	if c.WriteTimeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return c.mapError(err)
+		}
+	}
+	for _, t := range list {
+		if err := tags.ILTagSeralizeWithNull(t, c.writer); err != nil {
+			return c.mapError(err)
+		}
+	}
+	return c.mapError(c.writer.Flush())
+}
+
+/*
+Receives the next tag from the peer. It blocks until a complete tag arrives.
+
+It returns io.EOF if the peer closes the connection between tags and
+io.ErrUnexpectedEOF if it closes the connection in the middle of a tag. Tags
+with payloads larger than the limit are rejected with tags.ErrTagTooLarge as
+soon as their headers arrive.
+*/
+func (c *TagConn) Receive() (tags.ILTag, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	if len(c.pending) == 0 && c.readErr == nil {
+		c.readErr = c.fill()
+	}
+	if len(c.pending) == 0 {
+		err := c.readErr
+		if isTimeout(err) {
+			// Timeouts are not permanent
+			c.readErr = nil
+		}
+		return nil, err
+	}
+	tag := c.pending[0]
+	c.pending[0] = nil
+	c.pending = c.pending[1:]
+	return tag, nil
+}
+
+// Returns true if the error is a timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+/*
+Reads the connection until at least one tag is complete. The tags found are
+added to the pending list. The error is returned only after the pending tags
+are consumed.
+*/
+func (c *TagConn) fill() error {
+	c.decoder.MaxTagSize = c.maxTagSize()
+	if c.ReadTimeout > 0 {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
+			return c.mapError(err)
+		}
+	}
+	for len(c.pending) == 0 {
+		n, err := c.conn.Read(c.readBuff)
+		if n > 0 {
+			l, ferr := c.decoder.Feed(c.readBuff[:n])
+			c.pending = append(c.pending, l...)
+			if ferr != nil {
+				return ferr
+			}
+		}
+		if err != nil {
+			if err == io.EOF && c.decoder.Buffered() > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return c.mapError(err)
+		}
+	}
+	return nil
+}
+
+/*
+Closes the connection gracefully. Close() waits for the Send() in progress, if
+any, flushes the buffered data and shuts down the writing side of connections
+that support it, like *net.TCPConn, before closing it. Thus the peer receives
+every tag sent before the end of the stream. A Receive() in progress is
+interrupted and returns ErrClosed.
+
+If the Send() in progress does not finish within CloseTimeout, the connection is
+closed immediately. In this case, the Send() returns ErrClosed and the peer may
+receive a truncated tag.
+
+All calls to Send() and Receive() made after Close() return ErrClosed.
+*/
+func (c *TagConn) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return ErrClosed
+	}
+	// Closing the connection unblocks a Send() that holds the write mutex
+	forced := make(chan error, 1)
+	timer := time.AfterFunc(c.closeTimeout(), func() {
+		forced <- c.conn.Close()
+	})
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if !timer.Stop() {
+		return <-forced
+	}
+	err := c.writer.Flush()
+	if cw, ok := c.conn.(interface{ CloseWrite() error }); ok && err == nil {
+		err = cw.CloseWrite()
+	}
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
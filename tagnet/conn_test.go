@@ -0,0 +1,360 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagnet
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a pair of connected TagConns.
+func newTestPair(t *testing.T) (*TagConn, *TagConn) {
+	a, b := net.Pipe()
+	ca := NewTagConn(a, impl.NewStandardTagFactory(false))
+	cb := NewTagConn(b, impl.NewStandardTagFactory(false))
+	t.Cleanup(func() {
+		ca.Close()
+		cb.Close()
+	})
+	return ca, cb
+}
+
+// Writes the raw bytes into the connection in the background.
+func writeRaw(conn net.Conn, chunks ...[]byte) chan error {
+	done := make(chan error, 1)
+	go func() {
+		for _, c := range chunks {
+			if _, err := conn.Write(c); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	return done
+}
+
+func TestNewTagConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := NewTagConn(a, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	assert.Same(t, a, c.Conn())
+	assert.Equal(t, DefaultMaxTagSize, c.maxTagSize())
+	c.MaxTagSize = 10
+	assert.Equal(t, uint64(10), c.maxTagSize())
+	assert.Equal(t, DefaultCloseTimeout, c.closeTimeout())
+	c.CloseTimeout = time.Second
+	assert.Equal(t, time.Second, c.closeTimeout())
+}
+
+func TestTagConn_SendReceive(t *testing.T) {
+	a, b := newTestPair(t)
+	samples := []string{"null", "ilint(1000)", `str("hello")`,
+		`array[u8(1), seq[null]]`, `#1000 bytes(0x0102)`, `dict{"a": str("b")}`}
+
+	done := make(chan error, 1)
+	go func() {
+		for _, s := range samples {
+			if err := a.Send(impl.MustParseTag(s)); err != nil {
+				done <- err
+				return
+			}
+		}
+		// Multiple tags at once
+		var l []tags.ILTag
+		for _, s := range samples {
+			l = append(l, impl.MustParseTag(s))
+		}
+		done <- a.Send(l...)
+	}()
+	for i := 0; i < 2; i++ {
+		for _, s := range samples {
+			tag, err := b.Receive()
+			require.Nil(t, err)
+			assert.Equal(t, s, impl.FormatTag(tag))
+		}
+	}
+	require.Nil(t, <-done)
+
+	// Nil tags
+	go func() { done <- a.Send(nil) }()
+	tag, err := b.Receive()
+	require.Nil(t, err)
+	assert.Equal(t, tags.IL_NULL_TAG_ID, tag.Id())
+	require.Nil(t, <-done)
+}
+
+func TestTagConn_Concurrent(t *testing.T) {
+	a, b := newTestPair(t)
+	const count = 100
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, c := range []*TagConn{a, b} {
+		go func(c *TagConn) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				tag := impl.NewStdUInt64Tag()
+				tag.Payload = uint64(i)
+				assert.Nil(t, c.Send(tag))
+			}
+		}(c)
+	}
+	for _, c := range []*TagConn{a, b} {
+		for i := 0; i < count; i++ {
+			tag, err := c.Receive()
+			require.Nil(t, err)
+			assert.Equal(t, uint64(i), tag.(*impl.UInt64Tag).Payload)
+		}
+	}
+	wg.Wait()
+}
+
+func TestTagConn_ReceiveChunks(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	c := NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+
+	data := impl.MustParseTagBytes(`str("hello")`)
+	var chunks [][]byte
+	for _, x := range data {
+		chunks = append(chunks, []byte{x})
+	}
+	chunks = append(chunks, append(impl.MustParseTagBytes("null"),
+		impl.MustParseTagBytes("u8(1)")...))
+	done := writeRaw(a, chunks...)
+	for _, s := range []string{`str("hello")`, "null", "u8(1)"} {
+		tag, err := c.Receive()
+		require.Nil(t, err)
+		assert.Equal(t, s, impl.FormatTag(tag))
+	}
+	require.Nil(t, <-done)
+}
+
+func TestTagConn_ReceiveEOF(t *testing.T) {
+	a, b := net.Pipe()
+	c := NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	done := writeRaw(a, impl.MustParseTagBytes("null"))
+	go func() {
+		<-done
+		a.Close()
+	}()
+	tag, err := c.Receive()
+	require.Nil(t, err)
+	assert.Equal(t, tags.IL_NULL_TAG_ID, tag.Id())
+	_, err = c.Receive()
+	assert.Equal(t, io.EOF, err)
+	_, err = c.Receive()
+	assert.Equal(t, io.EOF, err)
+
+	// In the middle of a tag
+	a, b = net.Pipe()
+	c = NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	data := impl.MustParseTagBytes(`str("hello")`)
+	done = writeRaw(a, data[:3])
+	go func() {
+		<-done
+		a.Close()
+	}()
+	_, err = c.Receive()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestTagConn_ReceiveErrors(t *testing.T) {
+	// Bad tag after a good one
+	a, b := net.Pipe()
+	defer a.Close()
+	c := NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	done := writeRaw(a, []byte{0x00, 0x0f})
+	tag, err := c.Receive()
+	require.Nil(t, err)
+	assert.Equal(t, tags.IL_NULL_TAG_ID, tag.Id())
+	_, err = c.Receive()
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+	assert.Contains(t, err.Error(), "offset 1:")
+	_, err2 := c.Receive()
+	assert.Same(t, err, err2)
+	require.Nil(t, <-done)
+
+	// Too large
+	a, b = net.Pipe()
+	defer a.Close()
+	c = NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	c.MaxTagSize = 2
+	done = writeRaw(a, impl.MustParseTagBytes(`str("abc")`)[:2])
+	_, err = c.Receive()
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	require.Nil(t, <-done)
+
+	// Default limit
+	a, b = net.Pipe()
+	defer a.Close()
+	c = NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	done = writeRaw(a, []byte{0x10, 0xfb, 0x01, 0x00, 0x00, 0x00})
+	_, err = c.Receive()
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	require.Nil(t, <-done)
+}
+
+func TestTagConn_ReadTimeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	c := NewTagConn(b, impl.NewStandardTagFactory(false))
+	defer c.Close()
+	c.ReadTimeout = 20 * time.Millisecond
+
+	// The partial tag survives the timeout
+	data := impl.MustParseTagBytes(`str("hello")`)
+	done := writeRaw(a, data[:3])
+	_, err := c.Receive()
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.Nil(t, <-done)
+	done = writeRaw(a, data[3:])
+	tag, err := c.Receive()
+	require.Nil(t, err)
+	assert.Equal(t, `str("hello")`, impl.FormatTag(tag))
+	require.Nil(t, <-done)
+}
+
+func TestTagConn_SendErrors(t *testing.T) {
+	a, b := newTestPair(t)
+
+	// Too large
+	a.MaxTagSize = 2
+	assert.ErrorIs(t, a.Send(impl.MustParseTag("null"), impl.MustParseTag(`str("abc")`)),
+		tags.ErrTagTooLarge)
+	a.MaxTagSize = 0
+
+	// Timeout
+	a.WriteTimeout = 20 * time.Millisecond
+	assert.ErrorIs(t, a.Send(impl.MustParseTag("null")), os.ErrDeadlineExceeded)
+
+	// Peer closed
+	b.Close()
+	a.WriteTimeout = 0
+	a.writer.Reset(a.conn)
+	assert.ErrorIs(t, a.Send(impl.MustParseTag("null")), io.ErrClosedPipe)
+}
+
+func TestTagConn_Close(t *testing.T) {
+	_, b := newTestPair(t)
+
+	// Interrupts the pending Receive()
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.Receive()
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.Nil(t, b.Close())
+	assert.Equal(t, ErrClosed, <-done)
+	_, err := b.Receive()
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, ErrClosed, b.Send(impl.MustParseTag("null")))
+	assert.Equal(t, ErrClosed, b.Close())
+
+	// Waits for the pending Send()
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+	c := NewTagConn(p1, impl.NewStandardTagFactory(false))
+	tag := impl.MustParseTag(`str("hello")`)
+	go func() { done <- c.Send(tag) }()
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan error, 1)
+	go func() { closed <- c.Close() }()
+	received, err := io.ReadAll(p2)
+	assert.Nil(t, err)
+	expected, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, expected, received)
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-closed)
+
+	// Interrupts a Send() blocked for longer than the close timeout
+	p1, p2 = net.Pipe()
+	defer p2.Close()
+	c = NewTagConn(p1, impl.NewStandardTagFactory(false))
+	c.CloseTimeout = 10 * time.Millisecond
+	go func() { done <- c.Send(tag) }()
+	time.Sleep(10 * time.Millisecond)
+	go func() { closed <- c.Close() }()
+	select {
+	case err = <-closed:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Close() blocked by Send()")
+	}
+	assert.Equal(t, ErrClosed, <-done)
+	_, err = p2.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+// Implementation of net.Conn that records the calls to CloseWrite() and Close().
+type halfCloseConn struct {
+	net.Conn
+	calls []string
+}
+
+func (c *halfCloseConn) CloseWrite() error {
+	c.calls = append(c.calls, "CloseWrite")
+	return nil
+}
+
+func (c *halfCloseConn) Close() error {
+	c.calls = append(c.calls, "Close")
+	return c.Conn.Close()
+}
+
+func TestTagConn_CloseWrite(t *testing.T) {
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+	conn := &halfCloseConn{Conn: p1}
+	c := NewTagConn(conn, impl.NewStandardTagFactory(false))
+	require.Nil(t, c.Close())
+	assert.Equal(t, []string{"CloseWrite", "Close"}, conn.calls)
+}
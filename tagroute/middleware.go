@@ -0,0 +1,97 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagroute
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// A handler panicked.
+var ErrPanic = fmt.Errorf("handler panicked")
+
+/*
+Returns a middleware that recovers from the panics of the handlers. The panic is
+converted into an error that wraps ErrPanic.
+*/
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, tag tags.ILTag) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("tag %d: %v: %w", tag.Id(), p, ErrPanic)
+				}
+			}()
+			return next(ctx, tag)
+		}
+	}
+}
+
+/*
+Returns a middleware that logs each dispatched tag with its ID, the time spent
+by the handler and the error returned by it, if any. logf has the signature of
+log.Printf().
+*/
+func Logging(logf func(format string, args ...any)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, tag tags.ILTag) error {
+			start := time.Now()
+			err := next(ctx, tag)
+			if err != nil {
+				logf("tag %d: failed after %v: %v", tag.Id(), time.Since(start), err)
+			} else {
+				logf("tag %d: handled in %v", tag.Id(), time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+/*
+Returns a middleware that reports the ID of each dispatched tag, the time spent
+by the handler and the error returned by it to observe. It is meant to feed
+metrics systems.
+*/
+func Metrics(observe func(id tags.TagID, elapsed time.Duration, err error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, tag tags.ILTag) error {
+			start := time.Now()
+			err := next(ctx, tag)
+			observe(tag.Id(), time.Since(start), err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagroute
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	r := NewRouter(nil)
+	r.Use(Recover())
+	r.HandleID(1000, func(ctx context.Context, tag tags.ILTag) error {
+		panic("boom")
+	})
+	r.HandleID(1001, func(ctx context.Context, tag tags.ILTag) error {
+		return io.ErrShortWrite
+	})
+	err := r.Dispatch(context.Background(), impl.MustParseTag(`#1000 bytes(0x01)`))
+	assert.ErrorIs(t, err, ErrPanic)
+	assert.EqualError(t, err, "tag 1000: boom: handler panicked")
+	assert.Equal(t, io.ErrShortWrite, r.Dispatch(context.Background(),
+		impl.MustParseTag(`#1001 bytes(0x01)`)))
+}
+
+func TestLogging(t *testing.T) {
+	var logged []string
+	r := NewRouter(nil)
+	r.Use(Logging(func(format string, args ...any) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}))
+	r.HandleID(1000, func(ctx context.Context, tag tags.ILTag) error { return nil })
+
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`#1000 bytes(0x01)`)))
+	assert.ErrorIs(t, r.Dispatch(context.Background(), impl.MustParseTag(`null`)), ErrNoRoute)
+	require.Len(t, logged, 2)
+	assert.Regexp(t, `^tag 1000: handled in \S+$`, logged[0])
+	assert.Regexp(t, `^tag 0: failed after \S+: tag 0: no handler for the tag$`, logged[1])
+}
+
+func TestMetrics(t *testing.T) {
+	type observation struct {
+		id  tags.TagID
+		err error
+	}
+	var observed []observation
+	r := NewRouter(nil)
+	r.Use(Metrics(func(id tags.TagID, elapsed time.Duration, err error) {
+		assert.GreaterOrEqual(t, elapsed, 5*time.Millisecond)
+		observed = append(observed, observation{id, err})
+	}))
+	r.Fallback(func(ctx context.Context, tag tags.ILTag) error {
+		time.Sleep(5 * time.Millisecond)
+		if tag.Id() == 1001 {
+			return io.ErrShortWrite
+		}
+		return nil
+	})
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`#1000 bytes(0x01)`)))
+	assert.Equal(t, io.ErrShortWrite, r.Dispatch(context.Background(),
+		impl.MustParseTag(`#1001 bytes(0x01)`)))
+	assert.Equal(t, []observation{{1000, nil}, {1001, io.ErrShortWrite}}, observed)
+}
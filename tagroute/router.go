@@ -0,0 +1,239 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagroute
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// There is no handler for the tag.
+var ErrNoRoute = fmt.Errorf("no handler for the tag")
+
+/*
+HandlerFunc handles a single tag.
+*/
+type HandlerFunc func(ctx context.Context, tag tags.ILTag) error
+
+/*
+Middleware wraps a handler with additional behavior. It is called once for each
+dispatched tag.
+*/
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// A registered handler.
+type route struct {
+	// Creates the tag. If nil, the tag is created by the factory of the router.
+	create  func() tags.ILTag
+	handler HandlerFunc
+}
+
+/*
+Router dispatches tags to the handlers registered for their IDs.
+
+The Router must be configured before being used. Once configured, it is safe
+to use it from multiple goroutines.
+*/
+type Router struct {
+	factory    tags.ILTagFactory
+	routes     map[tags.TagID]route
+	fallback   HandlerFunc
+	middleware []Middleware
+}
+
+/*
+Creates a new Router. The factory is used to create the tags that have no typed
+handlers. If nil, a non strict StandardTagFactory is used.
+*/
+func NewRouter(factory tags.ILTagFactory) *Router {
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	return &Router{factory: factory, routes: make(map[tags.TagID]route, 16)}
+}
+
+/*
+Implementation of tags.ILTagFactory. It creates the tags registered by Handle()
+and delegates the creation of all other tags to the factory of the router.
+
+Since the same factory is used to decode the contents of the containers,
+nested tags with the IDs registered by Handle() are created with the registered
+types as well.
+*/
+func (r *Router) CreateTag(id tags.TagID) (tags.ILTag, error) {
+	if rt, ok := r.routes[id]; ok && rt.create != nil {
+		return rt.create(), nil
+	}
+	return r.factory.CreateTag(id)
+}
+
+/*
+Registers the handler of the tags with the given ID. The tags are created by
+the factory of the router. It replaces any previous handler of the ID.
+*/
+func (r *Router) HandleID(id tags.TagID, handler HandlerFunc) {
+	if handler == nil {
+		panic("The handler cannot be nil.")
+	}
+	r.routes[id] = route{handler: handler}
+}
+
+/*
+Registers a handler that receives the tags as instances of T. The function
+create must return new instances of T and the ID of the returned tags defines
+the ID handled. It replaces any previous handler of the ID.
+
+Tags of other types dispatched to this handler, like RawTags created by other
+factories, are converted into T with tags.ILTagDeserializeInto().
+*/
+func Handle[T tags.ILTag](r *Router, create func() T, handler func(ctx context.Context, tag T) error) {
+	if handler == nil {
+		panic("The handler cannot be nil.")
+	}
+	newTag := func() tags.ILTag { return create() }
+	r.routes[create().Id()] = route{
+		create: newTag,
+		handler: func(ctx context.Context, tag tags.ILTag) error {
+			t, ok := tag.(T)
+			if !ok {
+				converted, err := r.convert(tag, newTag())
+				if err != nil {
+					return err
+				}
+				t = converted.(T)
+			}
+			return handler(ctx, t)
+		},
+	}
+}
+
+// Converts the tag into the target tag by deserializing it again.
+func (r *Router) convert(tag tags.ILTag, target tags.ILTag) (tags.ILTag, error) {
+	b, err := tags.ILTagToBytes(tag)
+	if err != nil {
+		return nil, err
+	}
+	if err := tags.ILTagDeserializeInto(r, bytes.NewReader(b), target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+/*
+Sets the handler of the tags without registered handlers. By default, those
+tags are rejected with ErrNoRoute.
+*/
+func (r *Router) Fallback(handler HandlerFunc) {
+	r.fallback = handler
+}
+
+/*
+Adds middleware to the router. They are applied in the order they were added,
+thus the first one is the outermost. The middleware wraps the fallback as well.
+*/
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Rejects the tags without handlers.
+func noRoute(ctx context.Context, tag tags.ILTag) error {
+	return fmt.Errorf("tag %d: %w", tag.Id(), ErrNoRoute)
+}
+
+/*
+Dispatches the tag to its handler, wrapped by the middleware, and returns the
+error returned by it. The tag must not be nil.
+*/
+func (r *Router) Dispatch(ctx context.Context, tag tags.ILTag) error {
+	var h HandlerFunc
+	if rt, ok := r.routes[tag.Id()]; ok {
+		h = rt.handler
+	} else if r.fallback != nil {
+		h = r.fallback
+	} else {
+		h = noRoute
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h(ctx, tag)
+}
+
+/*
+Reads the tags from the reader and dispatches each of them as soon as it is
+decoded. The tags are created by the router itself.
+
+It returns nil when the reader reaches the end of the stream between two tags.
+It stops at the first error, returned either by the decoding or by a handler,
+and when the context is done.
+*/
+func (r *Router) Serve(ctx context.Context, reader io.Reader) error {
+	cr := countingReader{reader: bufio.NewReader(reader)}
+	for index := 0; ; index++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		offset := cr.offset
+		tag, err := tags.ILTagDeserialize(r, &cr)
+		if err != nil {
+			if err == io.EOF {
+				if cr.offset == offset {
+					return nil
+				}
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("tag %d at offset %d: %w", index, offset, err)
+		}
+		if err := r.Dispatch(ctx, tag); err != nil {
+			return err
+		}
+	}
+}
+
+// Implementation of io.Reader that counts the bytes read.
+type countingReader struct {
+	reader io.Reader
+	offset int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,271 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagroute
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStringTag() *impl.StringTag {
+	return impl.NewStringTag(1000)
+}
+
+func newTestUInt64Tag() *impl.UInt64Tag {
+	return impl.NewUInt64Tag(1001)
+}
+
+// Records the dispatched tags.
+type testRecorder struct {
+	handled []string
+}
+
+func (r *testRecorder) record(prefix string) HandlerFunc {
+	return func(ctx context.Context, tag tags.ILTag) error {
+		r.handled = append(r.handled, prefix+impl.FormatTag(tag))
+		return nil
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	r := NewRouter(nil)
+	assert.IsType(t, &impl.StandardTagFactory{}, r.factory)
+	assert.NotNil(t, r.routes)
+
+	f := impl.NewStandardTagFactory(true)
+	r = NewRouter(f)
+	assert.Same(t, f, r.factory)
+}
+
+func TestRouter_CreateTag(t *testing.T) {
+	r := NewRouter(impl.NewStandardTagFactory(true))
+	Handle(r, newTestStringTag, func(ctx context.Context, tag *impl.StringTag) error { return nil })
+	r.HandleID(1001, func(ctx context.Context, tag tags.ILTag) error { return nil })
+
+	tag, err := r.CreateTag(1000)
+	require.Nil(t, err)
+	assert.IsType(t, &impl.StringTag{}, tag)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+
+	tag, err = r.CreateTag(tags.IL_STRING_TAG_ID)
+	require.Nil(t, err)
+	assert.IsType(t, &impl.StringTag{}, tag)
+
+	// Untyped routes use the factory
+	_, err = r.CreateTag(1001)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+}
+
+func TestRouter_Dispatch(t *testing.T) {
+	var rec testRecorder
+	r := NewRouter(nil)
+	r.HandleID(1001, rec.record("id:"))
+	Handle(r, newTestStringTag, func(ctx context.Context, tag *impl.StringTag) error {
+		rec.handled = append(rec.handled, "typed:"+tag.Payload)
+		return nil
+	})
+
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`#1001 bytes(0x01)`)))
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`#1000 str("a")`)))
+	// Converted by the router
+	raw := tags.NewRawTag(1000)
+	raw.Payload = []byte("b")
+	require.Nil(t, r.Dispatch(context.Background(), raw))
+	assert.Equal(t, []string{"id:#1001 bytes(0x01)", "typed:a", "typed:b"}, rec.handled)
+
+	// No route
+	err := r.Dispatch(context.Background(), impl.MustParseTag(`null`))
+	assert.ErrorIs(t, err, ErrNoRoute)
+	assert.EqualError(t, err, "tag 0: no handler for the tag")
+
+	// Fallback
+	r.Fallback(rec.record("fallback:"))
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`null`)))
+	assert.Equal(t, "fallback:null", rec.handled[len(rec.handled)-1])
+
+	// Handler errors
+	r.HandleID(1001, func(ctx context.Context, tag tags.ILTag) error { return io.ErrShortWrite })
+	assert.Equal(t, io.ErrShortWrite, r.Dispatch(context.Background(),
+		impl.MustParseTag(`#1001 bytes(0x01)`)))
+}
+
+func TestRouter_DispatchConversionErrors(t *testing.T) {
+	r := NewRouter(nil)
+	Handle(r, newTestUInt64Tag, func(ctx context.Context, tag *impl.UInt64Tag) error {
+		assert.Fail(t, "Must not be called")
+		return nil
+	})
+
+	// Bad payload
+	raw := tags.NewRawTag(1001)
+	raw.Payload = []byte{1, 2, 3}
+	assert.Error(t, r.Dispatch(context.Background(), raw))
+
+	// Cannot be serialized
+	bad := &failingTag{}
+	bad.SetId(1001)
+	assert.ErrorIs(t, r.Dispatch(context.Background(), bad), io.ErrShortWrite)
+}
+
+// Tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
+
+func TestRouter_Handle(t *testing.T) {
+	r := NewRouter(nil)
+	assert.Panics(t, func() { r.HandleID(1000, nil) })
+	assert.Panics(t, func() {
+		Handle[*impl.StringTag](r, newTestStringTag, nil)
+	})
+
+	// Replaces the previous handlers
+	var rec testRecorder
+	Handle(r, newTestStringTag, func(ctx context.Context, tag *impl.StringTag) error {
+		return io.ErrShortWrite
+	})
+	r.HandleID(1000, rec.record(""))
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`#1000 str("a")`)))
+	tag, err := r.CreateTag(1000)
+	require.Nil(t, err)
+	assert.IsType(t, &tags.RawTag{}, tag)
+}
+
+func TestRouter_Use(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, tag tags.ILTag) error {
+				calls = append(calls, name+">")
+				err := next(ctx, tag)
+				calls = append(calls, "<"+name)
+				return err
+			}
+		}
+	}
+	r := NewRouter(nil)
+	r.HandleID(1000, func(ctx context.Context, tag tags.ILTag) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	r.Use(trace("a"), trace("b"))
+	r.Use(trace("c"))
+	require.Nil(t, r.Dispatch(context.Background(), impl.MustParseTag(`#1000 str("a")`)))
+	assert.Equal(t, []string{"a>", "b>", "c>", "handler", "<c", "<b", "<a"}, calls)
+
+	// Also wraps the fallback
+	calls = nil
+	assert.ErrorIs(t, r.Dispatch(context.Background(), impl.MustParseTag(`null`)), ErrNoRoute)
+	assert.Equal(t, []string{"a>", "b>", "c>", "<c", "<b", "<a"}, calls)
+}
+
+// Concatenates the serialization of the tags.
+func sample(notation ...string) []byte {
+	var b []byte
+	for _, n := range notation {
+		b = append(b, impl.MustParseTagBytes(n)...)
+	}
+	return b
+}
+
+func TestRouter_Serve(t *testing.T) {
+	var rec testRecorder
+	r := NewRouter(nil)
+	Handle(r, newTestStringTag, func(ctx context.Context, tag *impl.StringTag) error {
+		rec.handled = append(rec.handled, "typed:"+tag.Payload)
+		return nil
+	})
+	r.HandleID(tags.IL_ILTAGARRAY_TAG_ID, func(ctx context.Context, tag tags.ILTag) error {
+		// Nested tags are created by the router as well
+		for _, e := range tag.(*impl.ILTagArrayTag).Payload {
+			rec.handled = append(rec.handled, fmt.Sprintf("nested:%T", e))
+		}
+		return nil
+	})
+	r.Fallback(rec.record("fallback:"))
+
+	data := sample(`#1000 str("a")`, `array[#1000 str("b")]`, "u8(1)", `#1000 str("c")`)
+	require.Nil(t, r.Serve(context.Background(), bytes.NewReader(data)))
+	assert.Equal(t, []string{"typed:a", "nested:*impl.StringTag", "fallback:u8(1)", "typed:c"},
+		rec.handled)
+
+	// Empty
+	require.Nil(t, r.Serve(context.Background(), bytes.NewReader(nil)))
+
+	// Truncated
+	for i := 1; i < 4; i++ {
+		err := r.Serve(context.Background(), bytes.NewReader(data[:len(data)-i]))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Contains(t, err.Error(), "tag 3 at offset 15:")
+	}
+	err := r.Serve(context.Background(), bytes.NewReader(data[:len(data)-6]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), "tag 2 at offset 13:")
+
+	// Handler errors
+	r.Fallback(func(ctx context.Context, tag tags.ILTag) error { return io.ErrShortWrite })
+	rec.handled = nil
+	assert.Equal(t, io.ErrShortWrite, r.Serve(context.Background(), bytes.NewReader(data)))
+	assert.Equal(t, []string{"typed:a", "nested:*impl.StringTag"}, rec.handled)
+
+	// Canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Fallback(func(ctx context.Context, tag tags.ILTag) error {
+		cancel()
+		return nil
+	})
+	rec.handled = nil
+	assert.True(t, errors.Is(r.Serve(ctx, bytes.NewReader(data)), context.Canceled))
+	assert.Equal(t, []string{"typed:a", "nested:*impl.StringTag"}, rec.handled)
+}
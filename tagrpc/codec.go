@@ -0,0 +1,287 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagrpc
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+
+	iltags "github.com/interlockledger/go-iltags"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+)
+
+// Default maximum size of the payload of the header and body tags.
+const DefaultMaxMessageSize uint64 = 16 * 1024 * 1024
+
+// Header of the requests.
+type requestHeader struct {
+	ServiceMethod string
+	Seq           uint64
+}
+
+// Header of the responses.
+type responseHeader struct {
+	ServiceMethod string
+	Seq           uint64
+	Error         string
+}
+
+/*
+Implements the transport shared by the client and the server codecs. Reads and
+writes are independent from each other and writes are serialized.
+*/
+type tagCodec struct {
+	// Maximum size of the payload of the received tags. 0 means DefaultMaxMessageSize.
+	MaxMessageSize uint64
+	conn           io.ReadWriteCloser
+	factory        tags.ILTagFactory
+	reader         *bufio.Reader
+	readErr        error
+	writeMutex     sync.Mutex
+	writer         *bufio.Writer
+}
+
+// Initializes the codec.
+func (c *tagCodec) init(conn io.ReadWriteCloser, factory tags.ILTagFactory) {
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	c.conn = conn
+	c.factory = factory
+	c.reader = bufio.NewReader(conn)
+	c.writer = bufio.NewWriter(conn)
+}
+
+// Returns the maximum size of the payload of the received tags.
+func (c *tagCodec) maxMessageSize() uint64 {
+	if c.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+	return c.MaxMessageSize
+}
+
+/*
+Reads the next tag. Tags larger than the limit are rejected with
+tags.ErrTagTooLarge as soon as their headers are read. Since the stream cannot
+be resumed after that, all subsequent reads fail with the same error.
+*/
+func (c *tagCodec) readTag() (tags.ILTag, error) {
+	if c.readErr != nil {
+		return nil, c.readErr
+	}
+	// Broken headers are reported by the deserialization
+	id, _, size, err := tags.PeekTagHeader(c.reader)
+	if err == io.EOF {
+		return nil, err
+	}
+	if err == nil && !id.Implicit() && size > c.maxMessageSize() {
+		c.readErr = tags.ErrTagTooLarge
+		return nil, c.readErr
+	}
+	return tags.ILTagDeserialize(c.factory, c.reader)
+}
+
+/*
+Reads the header tag into the given header. It returns io.EOF if the connection
+was closed before the header.
+*/
+func (c *tagCodec) readHeader(header any) error {
+	tag, err := c.readTag()
+	if err != nil {
+		return err
+	}
+	return iltags.UnmarshalTag(c.factory, tag, header)
+}
+
+// Reads the body tag into body. If body is nil, the tag is discarded.
+func (c *tagCodec) readBody(body any) error {
+	tag, err := c.readTag()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if body == nil {
+		return nil
+	}
+	return iltags.UnmarshalTag(c.factory, tag, body)
+}
+
+// Writes the header and the body tags and flushes the connection.
+func (c *tagCodec) write(header tags.ILTag, body tags.ILTag) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if err := tags.ILTagSerializeTags(c.writer, header, body); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+// Closes the connection.
+func (c *tagCodec) Close() error {
+	return c.conn.Close()
+}
+
+/*
+Implementation of rpc.ClientCodec that encodes the messages as ILTags.
+*/
+type ClientCodec struct {
+	tagCodec
+}
+
+/*
+Creates a new ClientCodec that exchanges messages over conn. The factory is used
+to create the tags of the responses. If nil, a non-strict StandardTagFactory is
+used. Responses with tags larger than DefaultMaxMessageSize are rejected unless
+MaxMessageSize is set.
+*/
+func NewClientCodec(conn io.ReadWriteCloser, factory tags.ILTagFactory) *ClientCodec {
+	c := &ClientCodec{}
+	c.init(conn, factory)
+	return c
+}
+
+// Implementation of rpc.ClientCodec.
+func (c *ClientCodec) WriteRequest(r *rpc.Request, body any) error {
+	header, err := iltags.MarshalTag(&requestHeader{ServiceMethod: r.ServiceMethod, Seq: r.Seq})
+	if err != nil {
+		return err
+	}
+	tag, err := iltags.MarshalTag(body)
+	if err != nil {
+		return err
+	}
+	return c.write(header, tag)
+}
+
+// Implementation of rpc.ClientCodec.
+func (c *ClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	var h responseHeader
+	if err := c.readHeader(&h); err != nil {
+		return err
+	}
+	r.ServiceMethod = h.ServiceMethod
+	r.Seq = h.Seq
+	r.Error = h.Error
+	return nil
+}
+
+// Implementation of rpc.ClientCodec.
+func (c *ClientCodec) ReadResponseBody(body any) error {
+	return c.readBody(body)
+}
+
+/*
+Implementation of rpc.ServerCodec that encodes the messages as ILTags.
+*/
+type ServerCodec struct {
+	tagCodec
+}
+
+/*
+Creates a new ServerCodec that exchanges messages over conn. The factory is used
+to create the tags of the requests. If nil, a non-strict StandardTagFactory is
+used. Requests with tags larger than DefaultMaxMessageSize are rejected unless
+MaxMessageSize is set.
+*/
+func NewServerCodec(conn io.ReadWriteCloser, factory tags.ILTagFactory) *ServerCodec {
+	c := &ServerCodec{}
+	c.init(conn, factory)
+	return c
+}
+
+// Implementation of rpc.ServerCodec.
+func (c *ServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	var h requestHeader
+	if err := c.readHeader(&h); err != nil {
+		return err
+	}
+	r.ServiceMethod = h.ServiceMethod
+	r.Seq = h.Seq
+	return nil
+}
+
+// Implementation of rpc.ServerCodec.
+func (c *ServerCodec) ReadRequestBody(body any) error {
+	return c.readBody(body)
+}
+
+/*
+Implementation of rpc.ServerCodec. If the body cannot be encoded, it is
+replaced by an ILNullTag and the error is sent to the client instead.
+*/
+func (c *ServerCodec) WriteResponse(r *rpc.Response, body any) error {
+	h := responseHeader{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: r.Error}
+	tag, err := iltags.MarshalTag(body)
+	if err != nil {
+		if h.Error == "" {
+			h.Error = "unable to encode the response: " + err.Error()
+		}
+		tag = impl.NewStdNullTag()
+	}
+	header, err := iltags.MarshalTag(&h)
+	if err != nil {
+		return err
+	}
+	return c.write(header, tag)
+}
+
+/*
+Returns a new rpc.Client that uses a ClientCodec over conn with the default
+factory.
+*/
+func NewClient(conn io.ReadWriteCloser) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(conn, nil))
+}
+
+// Connects to the RPC server at the given address.
+func Dial(network, address string) (*rpc.Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+/*
+Runs the rpc.DefaultServer on a single connection using a ServerCodec with the
+default factory. It blocks until the client hangs up.
+*/
+func ServeConn(conn io.ReadWriteCloser) {
+	rpc.ServeCodec(NewServerCodec(conn, nil))
+}
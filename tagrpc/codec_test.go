@@ -0,0 +1,294 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagrpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Quotient struct {
+	Quo, Rem int
+}
+
+type Arith struct{}
+
+func (a *Arith) Divide(args *Args, reply *Quotient) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	reply.Quo = args.A / args.B
+	reply.Rem = args.A % args.B
+	return nil
+}
+
+func (a *Arith) Upper(args *impl.StringTag, reply *impl.StringTag) error {
+	reply.SetId(tags.IL_STRING_TAG_ID)
+	reply.Payload = fmt.Sprintf("%s:%d", args.Payload, args.Id())
+	return nil
+}
+
+func (a *Arith) Bad(args int, reply *chan int) error {
+	return nil
+}
+
+// Creates a client connected to a server with the Arith service.
+func newTestClient(t *testing.T) *rpc.Client {
+	server := rpc.NewServer()
+	require.Nil(t, server.Register(&Arith{}))
+	a, b := net.Pipe()
+	go server.ServeCodec(NewServerCodec(a, nil))
+	client := rpc.NewClientWithCodec(NewClientCodec(b, nil))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCodec(t *testing.T) {
+	client := newTestClient(t)
+
+	var q Quotient
+	require.Nil(t, client.Call("Arith.Divide", &Args{A: 17, B: 5}, &q))
+	assert.Equal(t, Quotient{Quo: 3, Rem: 2}, q)
+
+	// Tags as arguments
+	arg := impl.NewStringTag(1000)
+	arg.Payload = "abc"
+	reply := impl.NewStdStringTag()
+	require.Nil(t, client.Call("Arith.Upper", arg, reply))
+	assert.Equal(t, "abc:1000", reply.Payload)
+
+	// Concurrent calls
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var q Quotient
+			assert.Nil(t, client.Call("Arith.Divide", &Args{A: i, B: 3}, &q))
+			assert.Equal(t, Quotient{Quo: i / 3, Rem: i % 3}, q)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCodec_Errors(t *testing.T) {
+	client := newTestClient(t)
+
+	var q Quotient
+	err := client.Call("Arith.Divide", &Args{A: 1}, &q)
+	assert.Equal(t, rpc.ServerError("divide by zero"), err)
+
+	err = client.Call("Arith.Unknown", &Args{}, &q)
+	assert.Equal(t, rpc.ServerError("rpc: can't find method Arith.Unknown"), err)
+
+	// The reply cannot be encoded
+	var c chan int
+	err = client.Call("Arith.Bad", 1, &c)
+	assert.ErrorContains(t, err, "unable to encode the response: ")
+
+	// The request cannot be encoded
+	err = client.Call("Arith.Divide", make(chan int), &q)
+	assert.Error(t, err)
+
+	// Bad request body
+	err = client.Call("Arith.Divide", "x", &q)
+	assert.Error(t, err)
+
+	// The connection is still usable
+	require.Nil(t, client.Call("Arith.Divide", &Args{A: 4, B: 2}, &q))
+	assert.Equal(t, Quotient{Quo: 2}, q)
+}
+
+func TestCodec_WireFormat(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	c := NewClientCodec(b, nil)
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.WriteRequest(&rpc.Request{ServiceMethod: "A.B", Seq: 7},
+			&Args{A: 1, B: 2})
+	}()
+	expected := impl.MustParseTagBytes(`seq[str("A.B"), u64(7)]`)
+	expected = append(expected, impl.MustParseTagBytes(`seq[i64(1), i64(2)]`)...)
+	data := make([]byte, len(expected))
+	_, err := io.ReadFull(a, data)
+	require.Nil(t, err)
+	assert.Equal(t, expected, data)
+	require.Nil(t, <-done)
+
+	go func() {
+		_, err := a.Write(impl.MustParseTagBytes(`seq[str("A.B"), u64(7), str("oops")]`))
+		if err == nil {
+			_, err = a.Write(impl.MustParseTagBytes(`null`))
+		}
+		done <- err
+	}()
+	var r rpc.Response
+	require.Nil(t, c.ReadResponseHeader(&r))
+	assert.Equal(t, rpc.Response{ServiceMethod: "A.B", Seq: 7, Error: "oops"}, r)
+	require.Nil(t, c.ReadResponseBody(nil))
+	require.Nil(t, <-done)
+}
+
+func TestCodec_ReadErrors(t *testing.T) {
+	a, b := net.Pipe()
+	s := NewServerCodec(b, nil)
+	defer s.Close()
+	go func() {
+		a.Write(impl.MustParseTagBytes(`seq[str("A.B"), u64(7)]`))
+		a.Write(impl.MustParseTagBytes(`str("x")`)[:2])
+		a.Close()
+	}()
+	var r rpc.Request
+	require.Nil(t, s.ReadRequestHeader(&r))
+	assert.Equal(t, rpc.Request{ServiceMethod: "A.B", Seq: 7}, r)
+	assert.Equal(t, io.ErrUnexpectedEOF, s.ReadRequestBody(nil))
+
+	// Clean EOF before the header
+	a, b = net.Pipe()
+	s = NewServerCodec(b, nil)
+	defer s.Close()
+	a.Close()
+	assert.Equal(t, io.EOF, s.ReadRequestHeader(&r))
+
+	// EOF before the body
+	a, b = net.Pipe()
+	c := NewClientCodec(b, nil)
+	defer c.Close()
+	go func() {
+		a.Write(impl.MustParseTagBytes(`seq[str("A.B"), u64(7), str("")]`))
+		a.Close()
+	}()
+	var resp rpc.Response
+	require.Nil(t, c.ReadResponseHeader(&resp))
+	assert.Equal(t, io.ErrUnexpectedEOF, c.ReadResponseBody(nil))
+
+	// Bad header
+	a, b = net.Pipe()
+	c = NewClientCodec(b, impl.NewStandardTagFactory(true))
+	defer c.Close()
+	go func() {
+		a.Write(impl.MustParseTagBytes(`str("A.B")`))
+		a.Close()
+	}()
+	assert.Error(t, c.ReadResponseHeader(&resp))
+	_, err := tags.ILTagDeserialize(c.factory, c.reader)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCodec_MaxMessageSize(t *testing.T) {
+	// Only the header of a huge tag is sent
+	a, b := net.Pipe()
+	defer a.Close()
+	s := NewServerCodec(b, nil)
+	defer s.Close()
+	var header bytes.Buffer
+	require.Nil(t, serialization.WriteILInt(&header, uint64(tags.IL_BYTES_TAG_ID)))
+	require.Nil(t, serialization.WriteILInt(&header, DefaultMaxMessageSize+1))
+	go a.Write(header.Bytes())
+	var r rpc.Request
+	assert.Equal(t, tags.ErrTagTooLarge, s.ReadRequestHeader(&r))
+	assert.Equal(t, tags.ErrTagTooLarge, s.ReadRequestHeader(&r))
+
+	// Custom limit
+	a, b = net.Pipe()
+	defer a.Close()
+	c := NewClientCodec(b, nil)
+	defer c.Close()
+	c.MaxMessageSize = 4
+	go a.Write(impl.MustParseTagBytes(`str("hello")`))
+	var resp rpc.Response
+	assert.Equal(t, tags.ErrTagTooLarge, c.ReadResponseBody(nil))
+	assert.Equal(t, tags.ErrTagTooLarge, c.ReadResponseHeader(&resp))
+
+	// Implicit tags and tags within the limit
+	a, b = net.Pipe()
+	defer a.Close()
+	c = NewClientCodec(b, nil)
+	defer c.Close()
+	c.MaxMessageSize = 5
+	go func() {
+		a.Write(impl.MustParseTagBytes(`u64(1)`))
+		a.Write(impl.MustParseTagBytes(`str("hello")`))
+	}()
+	var u uint64
+	require.Nil(t, c.ReadResponseBody(&u))
+	assert.Equal(t, uint64(1), u)
+	var str string
+	require.Nil(t, c.ReadResponseBody(&str))
+	assert.Equal(t, "hello", str)
+}
+
+func TestDial(t *testing.T) {
+	require.Nil(t, rpc.Register(&Arith{}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go ServeConn(conn)
+		}
+	}()
+
+	client, err := Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	defer client.Close()
+	var q Quotient
+	require.Nil(t, client.Call("Arith.Divide", &Args{A: 7, B: 2}, &q))
+	assert.Equal(t, Quotient{Quo: 3, Rem: 1}, q)
+
+	_, err = Dial("tcp", "127.0.0.1:0")
+	assert.Error(t, err)
+}
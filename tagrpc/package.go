@@ -0,0 +1,58 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+/*
+This package implements a net/rpc codec that uses ILTags as its wire format.
+It allows existing net/rpc services to exchange tags instead of gob values
+without changes to their code.
+
+Each request and each response is encoded as a pair of tags. The first one is
+the header, encoded by the reflection marshaller of the iltags package as the
+ILTagSequenceTag:
+
+	request:  seq[str(ServiceMethod), u64(Seq)]
+	response: seq[str(ServiceMethod), u64(Seq), str(Error)]
+
+The second one is the body. It is encoded by iltags.MarshalTag() and decoded
+by iltags.UnmarshalTag(), thus the arguments and replies may be plain Go values
+supported by the marshaller or types that implement tags.ILTag. Since net/rpc
+creates the replies as zero values, the methods that use tags as replies must
+set their IDs before returning.
+
+Bodies that cannot be encoded by the server are replaced by an ILNullTag and
+the error is reported to the client in the Error field of the response.
+
+The codecs reject received tags with payloads larger than their MaxMessageSize
+as soon as the tag headers are read, thus a peer cannot force the allocation of
+large buffers.
+*/
+package tagrpc
@@ -0,0 +1,310 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/ilint"
+)
+
+// Number of bytes shown in each line of the dump.
+const dumpBytesPerLine = 8
+
+/*
+Annotated hex dump generator. It works over the complete serialization in
+memory in order to report the exact offset of each field.
+*/
+type dumper struct {
+	writer  io.Writer
+	factory ILTagFactory
+	data    []byte
+	offset  int
+	// Offset where the decoding failed.
+	failedAt int
+	// First error returned by the writer.
+	err error
+}
+
+/*
+Writes a line of the dump. If b is longer than dumpBytesPerLine, the remaining
+bytes are written in the following lines without the description.
+*/
+func (d *dumper) line(offset int, b []byte, depth int, format string, args ...any) {
+	desc := strings.Repeat("  ", depth) + fmt.Sprintf(format, args...)
+	for first := true; first || len(b) > 0; first = false {
+		n := len(b)
+		if n > dumpBytesPerLine {
+			n = dumpBytesPerLine
+		}
+		hex := fmt.Sprintf("% x", b[:n])
+		if d.err == nil {
+			l := fmt.Sprintf("%08x  %-*s  %s", offset, dumpBytesPerLine*3-1,
+				hex, desc)
+			_, d.err = io.WriteString(d.writer, strings.TrimRight(l, " ")+"\n")
+		}
+		desc = ""
+		offset += n
+		b = b[n:]
+	}
+}
+
+// Returns an error that reports the offset where the decoding stopped.
+func (d *dumper) fail(offset int, err error) error {
+	d.failedAt = offset
+	return fmt.Errorf("offset %d: %w", offset, err)
+}
+
+// Returns an ErrBadTagFormat that reports the offset where the decoding stopped.
+func (d *dumper) errorf(offset int, format string, args ...any) error {
+	return d.fail(offset, fmt.Errorf("%s: %w", fmt.Sprintf(format, args...),
+		ErrBadTagFormat))
+}
+
+// Reads an ILInt that must end before end.
+func (d *dumper) ilint(end int, field string) (uint64, []byte, error) {
+	v, n, err := ilint.Decode(d.data[d.offset:end])
+	if err != nil {
+		return 0, nil, d.errorf(d.offset, "truncated or corrupted %s", field)
+	}
+	b := d.data[d.offset : d.offset+n]
+	d.offset += n
+	return v, b, nil
+}
+
+// Returns the description of the tag ID.
+func dumpTagName(id TagID) string {
+	if name := id.StdName(); name != "" {
+		return fmt.Sprintf("tag %d (%s)", id, name)
+	}
+	return fmt.Sprintf("tag %d", id)
+}
+
+/*
+Describes the value of the payload. It uses the factory to decode it, thus the
+value is only shown if the tag created by the factory implements fmt.Stringer.
+*/
+func (d *dumper) describeValue(id TagID, payload []byte, valueSize int) string {
+	if d.factory == nil {
+		return "payload"
+	}
+	tag, err := d.factory.CreateTag(id)
+	if err != nil {
+		return "payload"
+	}
+	if _, ok := tag.(*RawTag); ok {
+		return "payload"
+	}
+	r := bytes.NewReader(payload)
+	if err := tag.DeserializeValue(d.factory, valueSize, r); err != nil {
+		return fmt.Sprintf("payload (invalid: %v)", err)
+	}
+	if r.Len() != 0 {
+		return fmt.Sprintf("payload (invalid: %v)", ErrBadTagFormat)
+	}
+	if s, ok := tag.(fmt.Stringer); ok {
+		return "value: " + s.String()
+	}
+	return "payload"
+}
+
+// Dumps a sequence of count tags or all tags until end if count is negative.
+func (d *dumper) tags(depth int, end int, count int64) error {
+	for i := int64(0); (count < 0 && d.offset < end) || i < count; i++ {
+		if d.offset >= end {
+			return d.errorf(d.offset, "missing %d tags", count-i)
+		}
+		if err := d.tag(depth, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads and dumps the number of entries of a container.
+func (d *dumper) count(depth int, end int, minSize int) (int64, error) {
+	start := d.offset
+	count, b, err := d.ilint(end, "count")
+	if err != nil {
+		return 0, err
+	}
+	if count > uint64((end-d.offset)/minSize) {
+		return 0, d.errorf(start, "invalid count %d", count)
+	}
+	d.line(start, b, depth, "count: %d", count)
+	return int64(count), nil
+}
+
+// Dumps the payload of a container tag.
+func (d *dumper) container(id TagID, depth int, end int) error {
+	var err error
+	var count int64
+	switch id {
+	case IL_ILTAGARRAY_TAG_ID:
+		if count, err = d.count(depth, end, 1); err == nil {
+			err = d.tags(depth, end, count)
+		}
+	case IL_ILTAGSEQ_TAG_ID:
+		err = d.tags(depth, end, -1)
+	case IL_DICTIONARY_TAG_ID, IL_STRING_DICTIONARY_TAG_ID:
+		// The smallest key is an empty string (2 bytes). The smallest value
+		// is a null (1 byte) or, in string dictionaries, an empty string.
+		minSize := 3
+		if id == IL_STRING_DICTIONARY_TAG_ID {
+			minSize = 4
+		}
+		if count, err = d.count(depth, end, minSize); err == nil {
+			err = d.tags(depth, end, 2*count)
+		}
+	}
+	if err == nil && d.offset != end {
+		err = d.errorf(d.offset, "unexpected data at the end of %s",
+			dumpTagName(id))
+	}
+	return err
+}
+
+// Dumps a single tag that must end before end.
+func (d *dumper) tag(depth int, end int) error {
+	start := d.offset
+	v, b, err := d.ilint(end, "tag id")
+	if err != nil {
+		return err
+	}
+	id := TagID(v)
+	d.line(start, b, depth, "%s", dumpTagName(id))
+	var size uint64
+	valueSize := 0
+	switch {
+	case id == IL_ILINT_TAG_ID || id == IL_SIGNED_ILINT_TAG_ID:
+		_, n, err := ilint.Decode(d.data[d.offset:end])
+		if err != nil {
+			return d.errorf(d.offset, "truncated or corrupted payload of %s",
+				dumpTagName(id))
+		}
+		size = uint64(n)
+		valueSize = -1
+	case id.Implicit():
+		n := implicitPayloadSize(id)
+		if n < 0 {
+			return d.fail(start, NewErrUnsupportedTagId(id))
+		}
+		size = uint64(n)
+		valueSize = n
+	default:
+		sizeStart := d.offset
+		if size, b, err = d.ilint(end, "tag size"); err != nil {
+			return err
+		}
+		d.line(sizeStart, b, depth+1, "size: %d", size)
+		valueSize = int(size)
+	}
+	container := false
+	switch id {
+	case IL_ILTAGARRAY_TAG_ID, IL_ILTAGSEQ_TAG_ID, IL_DICTIONARY_TAG_ID,
+		IL_STRING_DICTIONARY_TAG_ID:
+		container = true
+	}
+	truncated := size > uint64(end-d.offset)
+	if truncated && !container {
+		return d.errorf(d.offset, "truncated payload of %s: %d bytes expected",
+			dumpTagName(id), size)
+	}
+	if container {
+		// Truncated containers are dumped until the point where they were cut
+		if !truncated {
+			end = d.offset + int(size)
+		}
+		if err := d.container(id, depth+1, end); err != nil || !truncated {
+			return err
+		}
+		return d.errorf(d.offset, "truncated payload of %s: %d bytes expected",
+			dumpTagName(id), size)
+	}
+	payloadEnd := d.offset + int(size)
+	if id != IL_NULL_TAG_ID {
+		payload := d.data[d.offset:payloadEnd]
+		d.line(d.offset, payload, depth+1, "%s",
+			d.describeValue(id, payload, valueSize))
+	}
+	d.offset = payloadEnd
+	return nil
+}
+
+/*
+Writes an annotated hex dump of all tags serialized in the reader. Each line of
+the dump contains the offset of the field, its bytes and a description of the
+field, as in:
+
+	00000000  15                       tag 21 (ILTagArray)
+	00000001  07                         size: 7
+	00000002  02                         count: 2
+	00000003  11                         tag 17 (String)
+	00000004  02                           size: 2
+	00000005  68 69                        value: str("hi")
+	00000007  03                         tag 3 (UInt8)
+	00000008  01                           value: u8(1)
+
+The contents of the standard containers (ILTagArrayTag, ILTagSequenceTag,
+DictionaryTag and StringDictionaryTag) are shown as nested tags. The factory is
+used to decode the payload of the other tags in order to show their values.
+Only tags that implement fmt.Stringer have their values shown. The factory may
+be nil, in which case no values are shown.
+
+If the data is truncated or corrupted, the dump stops at the offset where the
+decoding failed, writes the description of the problem followed by the bytes
+that were not decoded and returns an error that reports the offset.
+*/
+func Dump(reader io.Reader, factory ILTagFactory, writer io.Writer) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	d := dumper{writer: writer, factory: factory, data: data}
+	for d.offset < len(data) && err == nil {
+		err = d.tag(0, len(data))
+	}
+	if err != nil {
+		d.line(d.failedAt, nil, 0, "error: %v", err)
+		if d.failedAt < len(data) {
+			d.line(d.failedAt, data[d.failedAt:], 0, "not decoded")
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return err
+}
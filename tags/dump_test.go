@@ -0,0 +1,269 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/interlockledger/go-iltags/tagtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tag that shows its payload as a quoted string.
+type dumpTestTag struct {
+	RawTag
+}
+
+func (t *dumpTestTag) String() string {
+	return fmt.Sprintf("%q", t.Payload)
+}
+
+// Tag that rejects any payload.
+type dumpTestBadTag struct {
+	RawTag
+}
+
+func (t *dumpTestBadTag) DeserializeValue(factory ILTagFactory, valueSize int, reader io.Reader) error {
+	return ErrBadTagFormat
+}
+
+// Factory used by the dump tests.
+type dumpTestFactory struct{}
+
+func (f dumpTestFactory) CreateTag(tagId TagID) (ILTag, error) {
+	switch tagId {
+	case IL_STRING_TAG_ID, IL_UINT8_TAG_ID:
+		t := &dumpTestTag{}
+		t.SetId(tagId)
+		return t, nil
+	case IL_BYTES_TAG_ID:
+		t := &dumpTestBadTag{}
+		t.SetId(tagId)
+		return t, nil
+	case IL_BOOL_TAG_ID:
+		// Implements nothing else than ILTag
+		t := &struct {
+			ILTagHeaderImpl
+			RawPayload
+		}{}
+		t.SetId(tagId)
+		return t, nil
+	case IL_NULL_TAG_ID:
+		return &RawTag{}, nil
+	default:
+		return nil, NewErrUnsupportedTagId(tagId)
+	}
+}
+
+func dumpLines(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestDump(t *testing.T) {
+	data := []byte{
+		0x15, 0x0a, 0x03, // array with 3 entries
+		0x11, 0x02, 'h', 'i', // string
+		0x03, 0x01, // uint8
+		0x16, 0x01, 0x00, // sequence with a null
+		0xf9, 0x02, 0xf8, 0x00, // empty tag 1008
+		0x0a, 0xf8, 0x01, // ilint
+		0x01, 0x01, // bool
+	}
+	var b bytes.Buffer
+	require.Nil(t, Dump(bytes.NewReader(data), dumpTestFactory{}, &b))
+	assert.Equal(t, dumpLines(
+		`00000000  15                       tag 21 (ILTagArray)`,
+		`00000001  0a                         size: 10`,
+		`00000002  03                         count: 3`,
+		`00000003  11                         tag 17 (String)`,
+		`00000004  02                           size: 2`,
+		`00000005  68 69                        value: "hi"`,
+		`00000007  03                         tag 3 (UInt8)`,
+		`00000008  01                           value: "\x01"`,
+		`00000009  16                         tag 22 (ILTagSequence)`,
+		`0000000a  01                           size: 1`,
+		`0000000b  00                           tag 0 (Null)`,
+		`0000000c  f9 02 f8                 tag 1008`,
+		`0000000f  00                         size: 0`,
+		`00000010                             payload`,
+		`00000010  0a                       tag 10 (ILInt)`,
+		`00000011  f8 01                      payload`,
+		`00000013  01                       tag 1 (Bool)`,
+		`00000014  01                         payload`,
+	), b.String())
+
+	// Without factory
+	b.Reset()
+	require.Nil(t, Dump(bytes.NewReader([]byte{0x11, 0x02, 'h', 'i'}), nil, &b))
+	assert.Equal(t, dumpLines(
+		`00000000  11                       tag 17 (String)`,
+		`00000001  02                         size: 2`,
+		`00000002  68 69                      payload`,
+	), b.String())
+
+	// Empty
+	b.Reset()
+	require.Nil(t, Dump(bytes.NewReader(nil), nil, &b))
+	assert.Equal(t, "", b.String())
+}
+
+func TestDump_LongPayload(t *testing.T) {
+	data := append([]byte{0x10, 0x0a}, tagtest.FillSeq(make([]byte, 10))...)
+	data = append(data, 0x10, 0x01, 0xff)
+	var b bytes.Buffer
+	require.Nil(t, Dump(bytes.NewReader(data), dumpTestFactory{}, &b))
+	assert.Equal(t, dumpLines(
+		`00000000  10                       tag 16 (Bytes)`,
+		`00000001  0a                         size: 10`,
+		`00000002  00 01 02 03 04 05 06 07    payload (invalid: bad tag format)`,
+		`0000000a  08 09`,
+		`0000000c  10                       tag 16 (Bytes)`,
+		`0000000d  01                         size: 1`,
+		`0000000e  ff                         payload (invalid: bad tag format)`,
+	), b.String())
+}
+
+func TestDump_Dictionaries(t *testing.T) {
+	data := []byte{
+		0x1e, 0x06, 0x01, 0x11, 0x01, 'a', 0x03, 0x05, // dictionary
+		0x1f, 0x07, 0x01, 0x11, 0x01, 'a', 0x11, 0x01, 'b', // string dictionary
+	}
+	var b bytes.Buffer
+	require.Nil(t, Dump(bytes.NewReader(data), nil, &b))
+	assert.Equal(t, dumpLines(
+		`00000000  1e                       tag 30 (Dictionary)`,
+		`00000001  06                         size: 6`,
+		`00000002  01                         count: 1`,
+		`00000003  11                         tag 17 (String)`,
+		`00000004  01                           size: 1`,
+		`00000005  61                           payload`,
+		`00000006  03                         tag 3 (UInt8)`,
+		`00000007  05                           payload`,
+		`00000008  1f                       tag 31 (StringDictionary)`,
+		`00000009  07                         size: 7`,
+		`0000000a  01                         count: 1`,
+		`0000000b  11                         tag 17 (String)`,
+		`0000000c  01                           size: 1`,
+		`0000000d  61                           payload`,
+		`0000000e  11                         tag 17 (String)`,
+		`0000000f  01                           size: 1`,
+		`00000010  62                           payload`,
+	), b.String())
+
+	// Entries with an empty key and a null value take only 3 bytes
+	data = []byte{0x1e, 0x08, 0x02, 0x11, 0x00, 0x00, 0x11, 0x01, 'a', 0x00}
+	b.Reset()
+	require.Nil(t, Dump(bytes.NewReader(data), nil, &b))
+	assert.Equal(t, dumpLines(
+		`00000000  1e                       tag 30 (Dictionary)`,
+		`00000001  08                         size: 8`,
+		`00000002  02                         count: 2`,
+		`00000003  11                         tag 17 (String)`,
+		`00000004  00                           size: 0`,
+		`00000005                               payload`,
+		`00000005  00                         tag 0 (Null)`,
+		`00000006  11                         tag 17 (String)`,
+		`00000007  01                           size: 1`,
+		`00000008  61                           payload`,
+		`00000009  00                         tag 0 (Null)`,
+	), b.String())
+}
+
+func TestDump_Corrupted(t *testing.T) {
+	samples := []struct {
+		data     []byte
+		offset   int
+		expected error
+	}{
+		{[]byte{0xf9, 0x02}, 0, ErrBadTagFormat},                   // Truncated ID
+		{[]byte{0x0f}, 0, ErrUnsupportedTagId},                     // Reserved implicit ID
+		{[]byte{0x0a}, 1, ErrBadTagFormat},                         // Truncated ILInt
+		{[]byte{0x06, 0x01}, 1, ErrBadTagFormat},                   // Truncated implicit
+		{[]byte{0x11, 0xf9}, 1, ErrBadTagFormat},                   // Truncated size
+		{[]byte{0x11, 0x03, 'a'}, 2, ErrBadTagFormat},              // Truncated payload
+		{[]byte{0x15, 0x01, 0x02}, 2, ErrBadTagFormat},             // Bad count
+		{[]byte{0x15, 0x01, 0xf9}, 2, ErrBadTagFormat},             // Truncated count
+		{[]byte{0x15, 0x03, 0x01, 0x00, 0x00}, 4, ErrBadTagFormat}, // Extra data
+		{[]byte{0x15, 0x02, 0x01, 0x0f}, 3, ErrUnsupportedTagId},   // Bad element
+		{[]byte{0x16, 0x02, 0x03}, 3, ErrBadTagFormat},
+		{[]byte{0x16, 0x05, 0x00, 0x00}, 4, ErrBadTagFormat},                      // Truncated container
+		{[]byte{0x15, 0x05, 0x01, 0x00}, 4, ErrBadTagFormat},                      // Truncated container                            // Truncated element
+		{[]byte{0x1e, 0x06, 0x01, 0x11, 0x03, 'a', 'b', 'c'}, 8, ErrBadTagFormat}, // Missing value
+		{[]byte{0x1f, 0x03, 0x00, 0x00, 0x00}, 3, ErrBadTagFormat},                // Extra data
+		{[]byte{0x1e, 0x03, 0x02, 0x11, 0x00}, 2, ErrBadTagFormat},                // Bad count
+		{[]byte{0x1f, 0x04, 0x01, 0x11, 0x00, 0x00}, 2, ErrBadTagFormat},          // Bad count
+		{[]byte{0x00, 0x0f, 0x00}, 1, ErrUnsupportedTagId},                        // Second tag
+	}
+	for _, s := range samples {
+		var b bytes.Buffer
+		err := Dump(bytes.NewReader(s.data), nil, &b)
+		assert.ErrorIs(t, err, s.expected, "%x", s.data)
+		assert.Contains(t, err.Error(), fmt.Sprintf("offset %d:", s.offset))
+		assert.Contains(t, b.String(), fmt.Sprintf("%08x  %23s  error: %v\n", s.offset, "", err))
+		if s.offset < len(s.data) {
+			assert.Contains(t, b.String(), fmt.Sprintf("%08x  % x", s.offset, s.data[s.offset:]))
+			assert.True(t, strings.HasSuffix(b.String(), "not decoded\n"))
+		}
+	}
+
+	var b bytes.Buffer
+	assert.NotNil(t, Dump(bytes.NewReader([]byte{0x11, 0x03, 'a'}), nil, &b))
+	assert.Equal(t, dumpLines(
+		`00000000  11                       tag 17 (String)`,
+		`00000001  03                         size: 3`,
+		`00000002                           error: offset 2: truncated payload of tag 17 (String): 3 bytes expected: bad tag format`,
+		`00000002  61                       not decoded`,
+	), b.String())
+}
+
+func TestDump_Errors(t *testing.T) {
+	data := []byte{0x11, 0x02, 'h', 'i'}
+
+	// Reader
+	r := io.MultiReader(bytes.NewReader(data), iotest.ErrReader(io.ErrUnexpectedEOF))
+	assert.ErrorIs(t, Dump(r, nil, &bytes.Buffer{}), io.ErrUnexpectedEOF)
+
+	// Writer
+	for i := 0; i < 3; i++ {
+		w := tagtest.NewLimitedWriter(i*10, false)
+		assert.Error(t, Dump(bytes.NewReader(data), nil, w))
+	}
+	w := tagtest.NewLimitedWriter(10, false)
+	assert.Error(t, Dump(bytes.NewReader([]byte{0x0f}), nil, w))
+}
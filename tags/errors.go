@@ -43,6 +43,8 @@ var (
 	ErrBadTagFormat = fmt.Errorf("bad tag format")
 	// Unexpected tag id.
 	ErrUnexpectedTagId = fmt.Errorf("unexpected tag ID")
+	// The container cannot hold the given children.
+	ErrBadChildren = fmt.Errorf("bad container children")
 )
 
 // Create a new UnsupportedTagIdError with the specified tag id.
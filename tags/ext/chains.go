@@ -33,6 +33,7 @@
 package ext
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/interlockledger/go-iltags/tags"
@@ -91,6 +92,17 @@ func (p *ChainNameBlockRefPayload) SetBlockId(id uint64) {
 	p.BlockIdTag.Payload = id
 }
 
+/*
+Implements tags.Container. The chain name tag and the block id tag are the
+elements 0 and 1 respectively.
+*/
+func (p *ChainNameBlockRefPayload) Children() []tags.ContainerChild {
+	return []tags.ContainerChild{
+		{Element: tags.IndexElement(0), Tag: &p.ChainNameTag},
+		{Element: tags.IndexElement(1), Tag: &p.BlockIdTag},
+	}
+}
+
 //------------------------------------------------------------------------------
 
 /*
@@ -132,3 +144,23 @@ func NewChainNameBlockRefTag(id tags.TagID, innerTagIds ...tags.TagID) *ChainNam
 	t.BlockIdTag.SetId(blockIdTagId)
 	return t
 }
+
+/*
+Implementation of tags.Rebuilder. The children must be a StringTag followed by
+an ILIntTag. Their IDs are kept.
+*/
+func (t *ChainNameBlockRefTag) Rebuild(children []tags.ContainerChild) (tags.ILTag, error) {
+	if len(children) == 2 {
+		name, ok1 := children[0].Tag.(*impl.StringTag)
+		blockId, ok2 := children[1].Tag.(*impl.ILIntTag)
+		if ok1 && ok2 && name != nil && blockId != nil {
+			n := &ChainNameBlockRefTag{}
+			n.SetId(t.Id())
+			n.ChainNameTag = *name
+			n.BlockIdTag = *blockId
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("a StringTag and an ILIntTag are required: %w",
+		tags.ErrBadChildren)
+}
@@ -37,7 +37,9 @@ import (
 	"testing"
 
 	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestChainNameBlockRefPayload_ValueSize(t *testing.T) {
@@ -166,6 +168,61 @@ func TestChainNameBlockRefPayload_DeserializeValue(t *testing.T) {
 	assert.Equal(t, uint64(0x0123456), p.BlockId())
 }
 
+func TestChainNameBlockRefPayload_Children(t *testing.T) {
+	var _ tags.Container = (*ChainNameBlockRefTag)(nil)
+
+	tag := NewChainNameBlockRefTag(1234)
+	tag.SetChainName("chain")
+	tag.SetBlockId(10)
+	children := tag.Children()
+	assert.Equal(t, []tags.ContainerChild{
+		{Element: tags.IndexElement(0), Tag: &tag.ChainNameTag},
+		{Element: tags.IndexElement(1), Tag: &tag.BlockIdTag},
+	}, children)
+	assert.Same(t, &tag.ChainNameTag, children[0].Tag)
+	assert.Same(t, &tag.BlockIdTag, children[1].Tag)
+
+	var paths []string
+	assert.Nil(t, tags.WalkFunc(tag, func(path tags.Path, tag tags.ILTag) error {
+		paths = append(paths, path.String())
+		return nil
+	}))
+	assert.Equal(t, []string{"$", "$[0]", "$[1]"}, paths)
+}
+
+func TestChainNameBlockRefTag_Rebuild(t *testing.T) {
+	var _ tags.Rebuilder = (*ChainNameBlockRefTag)(nil)
+
+	tag := NewChainNameBlockRefTag(1234, 5678)
+	tag.SetChainName("chain")
+	tag.SetBlockId(10)
+	transformed, _, err := impl.Transform(tag, func(path tags.Path, child tags.ILTag) (tags.ILTag, error) {
+		if s, ok := child.(*impl.StringTag); ok {
+			n := impl.NewStringTag(s.Id())
+			n.Payload = "other"
+			return n, nil
+		}
+		return child, nil
+	})
+	require.Nil(t, err)
+	n := transformed.(*ChainNameBlockRefTag)
+	assert.Equal(t, tags.TagID(1234), n.Id())
+	assert.Equal(t, tags.TagID(5678), n.ChainNameTag.Id())
+	assert.Equal(t, "other", n.ChainName())
+	assert.Equal(t, uint64(10), n.BlockId())
+	assert.Equal(t, "chain", tag.ChainName())
+
+	// Bad children
+	for _, children := range [][]tags.ContainerChild{
+		tag.Children()[:1],
+		{tag.Children()[1], tag.Children()[0]},
+		{tag.Children()[0], {Element: tags.IndexElement(1), Tag: (*impl.ILIntTag)(nil)}},
+	} {
+		_, err = tag.Rebuild(children)
+		assert.ErrorIs(t, err, tags.ErrBadChildren)
+	}
+}
+
 //------------------------------------------------------------------------------
 
 func TestNewChainNameBlockRefTag(t *testing.T) {
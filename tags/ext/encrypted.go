@@ -0,0 +1,280 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ext
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/ilint"
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/interlockledger/go-iltags/utils"
+)
+
+var (
+	// The encryption algorithm is not supported or does not match the key.
+	ErrUnsupportedEncryption = fmt.Errorf("unsupported encryption algorithm")
+	// The ciphertext could not be decrypted or authenticated.
+	ErrDecryptionFailed = fmt.Errorf("decryption failed")
+)
+
+/*
+EncryptionAlgorithm identifies the authenticated encryption algorithm used by
+the EncryptedPayload.
+*/
+type EncryptionAlgorithm uint64
+
+const (
+	// AES-128 in GCM mode with 96-bit nonces.
+	EncAES128GCM EncryptionAlgorithm = 1
+	// AES-192 in GCM mode with 96-bit nonces.
+	EncAES192GCM EncryptionAlgorithm = 2
+	// AES-256 in GCM mode with 96-bit nonces.
+	EncAES256GCM EncryptionAlgorithm = 3
+)
+
+/*
+Returns the size of the key in bytes. It returns 0 if the algorithm is unknown.
+*/
+func (a EncryptionAlgorithm) KeySize() int {
+	switch a {
+	case EncAES128GCM:
+		return 16
+	case EncAES192GCM:
+		return 24
+	case EncAES256GCM:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// Creates the AEAD of the algorithm with the given key.
+func (a EncryptionAlgorithm) newAEAD(key []byte) (cipher.AEAD, error) {
+	size := a.KeySize()
+	if size == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEncryption, uint64(a))
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("%w: algorithm %d requires %d byte keys", ErrUnsupportedEncryption,
+			uint64(a), size)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+/*
+KeyProvider provides the keys used by EncryptedPayload.
+*/
+type KeyProvider interface {
+	/*
+		Returns the key with the given identifier. The returned key is not
+		modified or retained.
+	*/
+	Key(keyId []byte) ([]byte, error)
+}
+
+/*
+KeyProviderFunc is a function that implements KeyProvider.
+*/
+type KeyProviderFunc func(keyId []byte) ([]byte, error)
+
+// Implementation of KeyProvider.
+func (f KeyProviderFunc) Key(keyId []byte) ([]byte, error) {
+	return f(keyId)
+}
+
+//------------------------------------------------------------------------------
+
+/*
+EncryptedPayload is the payload of an encrypted envelope. It holds the
+authenticated encryption of the serialization of an inner tag. The algorithm
+and the key identifier are authenticated as additional data, thus they cannot
+be changed without breaking the decryption.
+
+It is serialized as the ILInt algorithm followed by standard bytes tags with the
+key identifier, the nonce and the ciphertext.
+*/
+type EncryptedPayload struct {
+	// The encryption algorithm.
+	Algorithm EncryptionAlgorithm
+	// The identifier of the key, as understood by the KeyProvider.
+	KeyId []byte
+	// The nonce.
+	Nonce []byte
+	// The ciphertext, including the authentication tag.
+	Ciphertext []byte
+}
+
+// Implementation of ILTagPayload.ValueSize().
+func (p *EncryptedPayload) ValueSize() uint64 {
+	return uint64(ilint.EncodedSize(uint64(p.Algorithm))) +
+		tags.ILTagSequenceSize(newBytesTag(p.KeyId), newBytesTag(p.Nonce),
+			newBytesTag(p.Ciphertext))
+}
+
+// Implementation of ILTagPayload.SerializeValue()
+func (p *EncryptedPayload) SerializeValue(writer io.Writer) error {
+	if err := serialization.WriteILInt(writer, uint64(p.Algorithm)); err != nil {
+		return err
+	}
+	return tags.ILTagSerializeTags(writer, newBytesTag(p.KeyId), newBytesTag(p.Nonce),
+		newBytesTag(p.Ciphertext))
+}
+
+// Implementation of ILTagPayload.DeserializeValue()
+func (p *EncryptedPayload) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	r := &io.LimitedReader{R: reader, N: int64(valueSize)}
+	alg, err := serialization.ReadILInt(r)
+	if err != nil {
+		return err
+	}
+	var fields [3]*tags.RawTag
+	for i := range fields {
+		fields[i] = tags.NewRawTag(tags.IL_BYTES_TAG_ID)
+		if err := tags.ILTagDeserializeInto(factory, r, fields[i]); err != nil {
+			return err
+		}
+	}
+	if r.N != 0 {
+		return tags.ErrBadTagFormat
+	}
+	p.Algorithm = EncryptionAlgorithm(alg)
+	p.KeyId = fields[0].Payload
+	p.Nonce = fields[1].Payload
+	p.Ciphertext = fields[2].Payload
+	return nil
+}
+
+// Returns the additional data authenticated with the ciphertext.
+func (p *EncryptedPayload) additionalData() []byte {
+	var b bytes.Buffer
+	serialization.WriteILInt(&b, uint64(p.Algorithm))
+	tags.ILTagSeralize(newBytesTag(p.KeyId), &b)
+	return b.Bytes()
+}
+
+/*
+Encrypts the serialization of the inner tag with the key provided by keys and
+replaces the contents of the payload. The nonce is read from rand, usually
+crypto/rand.Reader. The serialization of the inner tag is shredded after the
+encryption.
+*/
+func (p *EncryptedPayload) Seal(rand io.Reader, inner tags.ILTag, alg EncryptionAlgorithm,
+	keyId []byte, keys KeyProvider) error {
+	key, err := keys.Key(keyId)
+	if err != nil {
+		return err
+	}
+	aead, err := alg.newAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand, nonce); err != nil {
+		return err
+	}
+	plaintext, err := tags.ILTagToBytes(inner)
+	if err != nil {
+		return err
+	}
+	defer utils.ShredBytes(plaintext)
+	sealed := EncryptedPayload{Algorithm: alg, KeyId: keyId, Nonce: nonce}
+	sealed.Ciphertext = aead.Seal(nil, nonce, plaintext, sealed.additionalData())
+	*p = sealed
+	return nil
+}
+
+/*
+Decrypts the inner tag with the key provided by keys and deserializes it with
+the given factory. If factory is nil, a non strict StandardTagFactory is used.
+It fails with ErrDecryptionFailed if the ciphertext cannot be authenticated. The
+decrypted plaintext is shredded after the deserialization.
+*/
+func (p *EncryptedPayload) Open(factory tags.ILTagFactory, keys KeyProvider) (tags.ILTag, error) {
+	key, err := keys.Key(p.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := p.Algorithm.newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: bad nonce size", ErrDecryptionFailed)
+	}
+	plaintext, err := aead.Open(nil, p.Nonce, p.Ciphertext, p.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	defer utils.ShredBytes(plaintext)
+	if factory == nil {
+		factory = impl.NewStandardTagFactory(false)
+	}
+	return tags.ILTagFromBytes(factory, plaintext)
+}
+
+//------------------------------------------------------------------------------
+
+/*
+EncryptedTag is a generic encrypted envelope that stores an EncryptedPayload.
+
+Since it is not a standard tag it does not have a Standard tag ID associated
+with it.
+*/
+type EncryptedTag struct {
+	tags.ILTagHeaderImpl
+	EncryptedPayload
+}
+
+/*
+Create a new EncryptedTag.
+
+This function panics if the provided id is reserved for implicit tags.
+*/
+func NewEncryptedTag(id tags.TagID) *EncryptedTag {
+	if id.Implicit() {
+		panic("This tag cannot have an implicit tag id.")
+	}
+	var t EncryptedTag
+	t.SetId(id)
+	return &t
+}
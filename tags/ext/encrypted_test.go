@@ -0,0 +1,251 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ext
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A KeyProvider with keys of all sizes.
+var testKeys = KeyProviderFunc(func(keyId []byte) ([]byte, error) {
+	switch string(keyId) {
+	case "k128":
+		return bytes.Repeat([]byte{1}, 16), nil
+	case "k192":
+		return bytes.Repeat([]byte{2}, 24), nil
+	case "k256":
+		return bytes.Repeat([]byte{3}, 32), nil
+	case "k256b":
+		return bytes.Repeat([]byte{4}, 32), nil
+	default:
+		return nil, io.ErrUnexpectedEOF
+	}
+})
+
+// Serializes and deserializes the envelope.
+func roundTripEncryptedTag(t *testing.T, tag *EncryptedTag) *EncryptedTag {
+	b, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(len(b)), tags.ILTagSize(tag))
+	restored := NewEncryptedTag(tag.Id())
+	require.Nil(t, tags.ILTagDeserializeInto(impl.NewStandardTagFactory(true),
+		bytes.NewReader(b), restored))
+	return restored
+}
+
+func TestEncryptionAlgorithm_KeySize(t *testing.T) {
+	assert.Equal(t, 16, EncAES128GCM.KeySize())
+	assert.Equal(t, 24, EncAES192GCM.KeySize())
+	assert.Equal(t, 32, EncAES256GCM.KeySize())
+	assert.Equal(t, 0, EncryptionAlgorithm(0).KeySize())
+	assert.Equal(t, 0, EncryptionAlgorithm(4).KeySize())
+}
+
+func TestKeyProviderFunc(t *testing.T) {
+	k, err := testKeys.Key([]byte("k128"))
+	require.Nil(t, err)
+	assert.Len(t, k, 16)
+	_, err = testKeys.Key(nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestEncryptedPayload_SealOpen(t *testing.T) {
+	factory := impl.NewStandardTagFactory(true)
+	inner := impl.MustParseTag(`seq[str("secret"), bytes(0x010203)]`)
+	for _, c := range []struct {
+		alg   EncryptionAlgorithm
+		keyId string
+	}{{EncAES128GCM, "k128"}, {EncAES192GCM, "k192"}, {EncAES256GCM, "k256"}} {
+		tag := NewEncryptedTag(1000)
+		require.Nil(t, tag.Seal(rand.Reader, inner, c.alg, []byte(c.keyId), testKeys))
+		assert.Equal(t, c.alg, tag.Algorithm)
+		assert.Equal(t, []byte(c.keyId), tag.KeyId)
+		assert.Len(t, tag.Nonce, 12)
+		assert.NotContains(t, string(tag.Ciphertext), "secret")
+
+		restored := roundTripEncryptedTag(t, tag)
+		assert.Equal(t, tag.EncryptedPayload, restored.EncryptedPayload)
+		opened, err := restored.Open(factory, testKeys)
+		require.Nil(t, err)
+		assert.Equal(t, inner, opened)
+	}
+
+	// Without a factory
+	tag := NewEncryptedTag(1000)
+	require.Nil(t, tag.Seal(rand.Reader, inner, EncAES128GCM, []byte("k128"), testKeys))
+	opened, err := tag.Open(nil, testKeys)
+	require.Nil(t, err)
+	assert.Equal(t, inner, opened)
+
+	// Each seal uses a new nonce
+	a := NewEncryptedTag(1000)
+	require.Nil(t, a.Seal(rand.Reader, inner, EncAES256GCM, []byte("k256"), testKeys))
+	b := NewEncryptedTag(1000)
+	require.Nil(t, b.Seal(rand.Reader, inner, EncAES256GCM, []byte("k256"), testKeys))
+	assert.NotEqual(t, a.Nonce, b.Nonce)
+	assert.NotEqual(t, a.Ciphertext, b.Ciphertext)
+}
+
+func TestEncryptedPayload_SealErrors(t *testing.T) {
+	inner := impl.MustParseTag(`str("secret")`)
+	tag := NewEncryptedTag(1000)
+	assert.ErrorIs(t, tag.Seal(rand.Reader, inner, EncAES256GCM, []byte("none"), testKeys),
+		io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, tag.Seal(rand.Reader, inner, EncAES256GCM, []byte("k128"), testKeys),
+		ErrUnsupportedEncryption)
+	assert.ErrorIs(t, tag.Seal(rand.Reader, inner, 100, []byte("k128"), testKeys),
+		ErrUnsupportedEncryption)
+	assert.ErrorIs(t, tag.Seal(bytes.NewReader([]byte{1, 2}), inner, EncAES128GCM,
+		[]byte("k128"), testKeys), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, tag.Seal(rand.Reader, &failingTag{}, EncAES128GCM,
+		[]byte("k128"), testKeys), io.ErrShortWrite)
+	assert.Equal(t, EncryptedPayload{}, tag.EncryptedPayload)
+}
+
+func TestEncryptedPayload_OpenErrors(t *testing.T) {
+	factory := impl.NewStandardTagFactory(true)
+	tag := NewEncryptedTag(1000)
+	require.Nil(t, tag.Seal(rand.Reader, impl.MustParseTag(`str("secret")`), EncAES256GCM,
+		[]byte("k256"), testKeys))
+
+	// Wrong key
+	bad := *tag
+	bad.KeyId = []byte("k256b")
+	_, err := bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+	bad.KeyId = []byte("none")
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// The algorithm is authenticated
+	bad = *tag
+	bad.Algorithm = EncAES128GCM
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrUnsupportedEncryption)
+	bad.Algorithm = 100
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrUnsupportedEncryption)
+
+	// Changed nonce and ciphertext
+	bad = *tag
+	bad.Nonce = append([]byte{}, tag.Nonce...)
+	bad.Nonce[0] ^= 1
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+	bad.Nonce = tag.Nonce[:11]
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+	bad = *tag
+	bad.Ciphertext = append([]byte{}, tag.Ciphertext...)
+	bad.Ciphertext[0] ^= 1
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+	bad.Ciphertext = nil
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	// The inner tag is not supported by the factory
+	raw := tags.NewRawTag(1001)
+	raw.Payload = []byte{1}
+	require.Nil(t, bad.Seal(rand.Reader, raw, EncAES128GCM, []byte("k128"), testKeys))
+	_, err = bad.Open(factory, testKeys)
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+}
+
+func TestEncryptedPayload_ValueSize(t *testing.T) {
+	var p EncryptedPayload
+	assert.Equal(t, uint64(1+2+2+2), p.ValueSize())
+
+	p = EncryptedPayload{Algorithm: 1000, KeyId: []byte("k"), Nonce: make([]byte, 12),
+		Ciphertext: make([]byte, 300)}
+	assert.Equal(t, uint64(3+3+14+(1+2+300)), p.ValueSize())
+}
+
+func TestEncryptedPayload_SerializeValue(t *testing.T) {
+	p := EncryptedPayload{Algorithm: EncAES128GCM, KeyId: []byte("k"), Nonce: []byte{1, 2},
+		Ciphertext: []byte{3}}
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, p.SerializeValue(w))
+	bin := []byte{
+		0x01,
+		0x10, 0x01, 'k',
+		0x10, 0x02, 0x01, 0x02,
+		0x10, 0x01, 0x03}
+	assert.Equal(t, bin, w.Bytes())
+	assert.Equal(t, uint64(len(bin)), p.ValueSize())
+
+	for limit := 0; limit < len(bin); limit++ {
+		assert.ErrorIs(t, p.SerializeValue(&DummyWriter{limit}), io.ErrShortWrite)
+	}
+}
+
+func TestEncryptedPayload_DeserializeValue(t *testing.T) {
+	bin := []byte{
+		0x01,
+		0x10, 0x01, 'k',
+		0x10, 0x02, 0x01, 0x02,
+		0x10, 0x01, 0x03}
+
+	var p EncryptedPayload
+	require.Nil(t, p.DeserializeValue(nil, len(bin), bytes.NewReader(bin)))
+	assert.Equal(t, EncryptedPayload{Algorithm: EncAES128GCM, KeyId: []byte("k"),
+		Nonce: []byte{1, 2}, Ciphertext: []byte{3}}, p)
+
+	for size := 0; size < len(bin); size++ {
+		p = EncryptedPayload{}
+		assert.Error(t, p.DeserializeValue(nil, size, bytes.NewReader(bin)), size)
+		assert.Equal(t, EncryptedPayload{}, p)
+	}
+
+	ext := append(append([]byte{}, bin...), 0x00)
+	assert.ErrorIs(t, p.DeserializeValue(nil, len(ext), bytes.NewReader(ext)), tags.ErrBadTagFormat)
+
+	bad := []byte{0x01, 0x11, 0x00, 0x10, 0x00, 0x10, 0x00}
+	assert.ErrorIs(t, p.DeserializeValue(nil, len(bad), bytes.NewReader(bad)), tags.ErrUnexpectedTagId)
+}
+
+func TestNewEncryptedTag(t *testing.T) {
+	tag := NewEncryptedTag(1000)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+	assert.Equal(t, EncryptedPayload{}, tag.EncryptedPayload)
+
+	assert.Panics(t, func() { NewEncryptedTag(tags.IMPLICIT_ID_MAX) })
+}
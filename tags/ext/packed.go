@@ -0,0 +1,530 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ext
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/interlockledger/go-iltags/ilint"
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Size of the buffer used to serialize and deserialize packed arrays. Values are
+converted in blocks of at most this size in order to avoid the allocation of a
+second copy of the whole array.
+*/
+const packedBufferSize = 4096
+
+/*
+PackedNumber is the constraint that lists all numeric types that can be stored
+by PackedArrayPayload.
+*/
+type PackedNumber interface {
+	int8 | uint8 | int16 | uint16 | int32 | uint32 | int64 | uint64 | float32 | float64
+}
+
+/*
+Returns the size of each element of a packed array of T in bytes.
+*/
+func PackedElementSize[T PackedNumber]() int {
+	var v T
+	switch any(v).(type) {
+	case int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+/*
+Encodes the values of v into b using the big endian byte order. b must have at
+least len(v) * PackedElementSize[T]() bytes.
+*/
+func encodePacked[T PackedNumber](v []T, b []byte) {
+	switch a := any(v).(type) {
+	case []int8:
+		for i, x := range a {
+			b[i] = byte(x)
+		}
+	case []uint8:
+		copy(b, a)
+	case []int16:
+		for i, x := range a {
+			binary.BigEndian.PutUint16(b[i*2:], uint16(x))
+		}
+	case []uint16:
+		for i, x := range a {
+			binary.BigEndian.PutUint16(b[i*2:], x)
+		}
+	case []int32:
+		for i, x := range a {
+			binary.BigEndian.PutUint32(b[i*4:], uint32(x))
+		}
+	case []uint32:
+		for i, x := range a {
+			binary.BigEndian.PutUint32(b[i*4:], x)
+		}
+	case []float32:
+		for i, x := range a {
+			binary.BigEndian.PutUint32(b[i*4:], math.Float32bits(x))
+		}
+	case []int64:
+		for i, x := range a {
+			binary.BigEndian.PutUint64(b[i*8:], uint64(x))
+		}
+	case []uint64:
+		for i, x := range a {
+			binary.BigEndian.PutUint64(b[i*8:], x)
+		}
+	case []float64:
+		for i, x := range a {
+			binary.BigEndian.PutUint64(b[i*8:], math.Float64bits(x))
+		}
+	}
+}
+
+/*
+Decodes the big endian values stored in b into v. b must have at least
+len(v) * PackedElementSize[T]() bytes.
+*/
+func decodePacked[T PackedNumber](b []byte, v []T) {
+	switch a := any(v).(type) {
+	case []int8:
+		for i := range a {
+			a[i] = int8(b[i])
+		}
+	case []uint8:
+		copy(a, b)
+	case []int16:
+		for i := range a {
+			a[i] = int16(binary.BigEndian.Uint16(b[i*2:]))
+		}
+	case []uint16:
+		for i := range a {
+			a[i] = binary.BigEndian.Uint16(b[i*2:])
+		}
+	case []int32:
+		for i := range a {
+			a[i] = int32(binary.BigEndian.Uint32(b[i*4:]))
+		}
+	case []uint32:
+		for i := range a {
+			a[i] = binary.BigEndian.Uint32(b[i*4:])
+		}
+	case []float32:
+		for i := range a {
+			a[i] = math.Float32frombits(binary.BigEndian.Uint32(b[i*4:]))
+		}
+	case []int64:
+		for i := range a {
+			a[i] = int64(binary.BigEndian.Uint64(b[i*8:]))
+		}
+	case []uint64:
+		for i := range a {
+			a[i] = binary.BigEndian.Uint64(b[i*8:])
+		}
+	case []float64:
+		for i := range a {
+			a[i] = math.Float64frombits(binary.BigEndian.Uint64(b[i*8:]))
+		}
+	}
+}
+
+/*
+Writes the packed representation of v into the writer.
+*/
+func writePacked[T PackedNumber](writer io.Writer, v []T) error {
+	elementSize := PackedElementSize[T]()
+	size := len(v) * elementSize
+	if size > packedBufferSize {
+		size = packedBufferSize
+	}
+	buff := make([]byte, size)
+	step := len(buff) / elementSize
+	for start := 0; start < len(v); start += step {
+		end := start + step
+		if end > len(v) {
+			end = len(v)
+		}
+		b := buff[:(end-start)*elementSize]
+		encodePacked(v[start:end], b)
+		if err := serialization.WriteBytes(writer, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Reads a packed array with valueSize bytes from the reader. It fails if
+valueSize is not a multiple of the size of the elements.
+*/
+func readPacked[T PackedNumber](reader io.Reader, valueSize int) ([]T, error) {
+	elementSize := PackedElementSize[T]()
+	if valueSize < 0 || valueSize%elementSize != 0 {
+		return nil, tags.ErrBadTagFormat
+	}
+	v := make([]T, valueSize/elementSize)
+	size := valueSize
+	if size > packedBufferSize {
+		size = packedBufferSize
+	}
+	buff := make([]byte, size)
+	step := len(buff) / elementSize
+	for start := 0; start < len(v); start += step {
+		end := start + step
+		if end > len(v) {
+			end = len(v)
+		}
+		b := buff[:(end-start)*elementSize]
+		if _, err := io.ReadFull(reader, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		decodePacked(b, v[start:end])
+	}
+	return v, nil
+}
+
+/*
+Reads the header of an explicit tag and verifies if it matches the expected tag
+ID and the maximum tag size supported by this library. Returns the size of the
+payload.
+*/
+func readExplicitHeader(expectedId tags.TagID, reader io.Reader) (uint64, error) {
+	id, err := serialization.ReadILInt(reader)
+	if err != nil {
+		return 0, err
+	}
+	if id != expectedId.UInt64() {
+		return 0, tags.NewErrUnexpectedTagId(expectedId, tags.TagID(id))
+	}
+	size, err := serialization.ReadILInt(reader)
+	if err != nil {
+		return 0, err
+	}
+	if size > tags.MAX_TAG_SIZE {
+		return 0, tags.ErrTagTooLarge
+	}
+	return size, nil
+}
+
+//------------------------------------------------------------------------------
+
+/*
+PackedArrayPayload is the payload of an array of numeric values. The values are
+stored sequentially, without any separator or count, using the big endian byte
+order just like the functions serialization.WriteXXX(). Thus, the number of
+elements is always the size of the payload divided by the size of the element.
+
+It is a far more compact and efficient alternative to ILTagArrayTag when all
+elements have the same numeric type.
+*/
+type PackedArrayPayload[T PackedNumber] struct {
+	Payload []T
+}
+
+// Implementation of ILTagPayload.ValueSize().
+func (p *PackedArrayPayload[T]) ValueSize() uint64 {
+	return uint64(len(p.Payload) * PackedElementSize[T]())
+}
+
+// Implementation of ILTagPayload.SerializeValue()
+func (p *PackedArrayPayload[T]) SerializeValue(writer io.Writer) error {
+	return writePacked(writer, p.Payload)
+}
+
+// Implementation of ILTagPayload.DeserializeValue()
+func (p *PackedArrayPayload[T]) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	if v, err := readPacked[T](reader, valueSize); err != nil {
+		return err
+	} else {
+		p.Payload = v
+		return nil
+	}
+}
+
+/*
+PackedArrayTag is a generic tag that stores an array of numeric values as a
+PackedArrayPayload.
+
+Since it is not a standard tag it does not have a Standard tag ID associated
+with it.
+*/
+type PackedArrayTag[T PackedNumber] struct {
+	tags.ILTagHeaderImpl
+	PackedArrayPayload[T]
+}
+
+/*
+Creates a new PackedArrayTag.
+
+This function panics if the provided id is reserved for implicit tags.
+*/
+func NewPackedArrayTag[T PackedNumber](id tags.TagID) *PackedArrayTag[T] {
+	if id.Implicit() {
+		panic("This tag cannot have an implicit tag id.")
+	}
+	var t PackedArrayTag[T]
+	t.SetId(id)
+	return &t
+}
+
+// Packed array of int8 values.
+type PackedInt8ArrayTag = PackedArrayTag[int8]
+
+// Packed array of uint8 values.
+type PackedUInt8ArrayTag = PackedArrayTag[uint8]
+
+// Packed array of int16 values.
+type PackedInt16ArrayTag = PackedArrayTag[int16]
+
+// Packed array of uint16 values.
+type PackedUInt16ArrayTag = PackedArrayTag[uint16]
+
+// Packed array of int32 values.
+type PackedInt32ArrayTag = PackedArrayTag[int32]
+
+// Packed array of uint32 values.
+type PackedUInt32ArrayTag = PackedArrayTag[uint32]
+
+// Packed array of int64 values.
+type PackedInt64ArrayTag = PackedArrayTag[int64]
+
+// Packed array of uint64 values.
+type PackedUInt64ArrayTag = PackedArrayTag[uint64]
+
+// Packed array of float32 values.
+type PackedFloat32ArrayTag = PackedArrayTag[float32]
+
+// Packed array of float64 values.
+type PackedFloat64ArrayTag = PackedArrayTag[float64]
+
+/*
+Returns the size of a PackedArrayTag with the given tagId and values.
+*/
+func PackedArrayTagSize[T PackedNumber](tagId tags.TagID, v []T) uint64 {
+	return tags.GetExplicitTagSize(tagId, uint64(len(v)*PackedElementSize[T]()))
+}
+
+/*
+Serializes a PackedArrayTag directly into a writer. The provided tagId must
+belong to an explicit tag.
+*/
+func SerializePackedArrayTag[T PackedNumber](tagId tags.TagID, v []T, writer io.Writer) error {
+	if err := serialization.WriteILInt(writer, tagId.UInt64()); err != nil {
+		return err
+	}
+	if err := serialization.WriteILInt(writer, uint64(len(v)*PackedElementSize[T]())); err != nil {
+		return err
+	}
+	return writePacked(writer, v)
+}
+
+/*
+Deserializes a PackedArrayTag directly from a reader. The provided tagId must
+match the expected tagId.
+*/
+func DeserializePackedArrayTag[T PackedNumber](tagId tags.TagID, reader io.Reader) ([]T, error) {
+	size, err := readExplicitHeader(tagId, reader)
+	if err != nil {
+		return nil, err
+	}
+	return readPacked[T](reader, int(size))
+}
+
+//------------------------------------------------------------------------------
+
+/*
+Returns the number of bytes required to store n bits.
+*/
+func bitsetSize(n int) int {
+	return (n + 7) / 8
+}
+
+/*
+Writes the packed representation of the boolean array into the writer.
+*/
+func writePackedBools(writer io.Writer, v []bool) error {
+	if err := serialization.WriteILInt(writer, uint64(len(v))); err != nil {
+		return err
+	}
+	if len(v) == 0 {
+		return nil
+	}
+	b := make([]byte, bitsetSize(len(v)))
+	for i, x := range v {
+		if x {
+			b[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return serialization.WriteBytes(writer, b)
+}
+
+/*
+Reads a packed boolean array with valueSize bytes from the reader.
+*/
+func readPackedBools(reader io.Reader, valueSize int) ([]bool, error) {
+	if valueSize < 1 {
+		return nil, tags.ErrBadTagFormat
+	}
+	r := io.LimitedReader{R: reader, N: int64(valueSize)}
+	n, err := serialization.ReadILInt(&r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.N)*8 || bitsetSize(int(n)) != int(r.N) {
+		return nil, tags.ErrBadTagFormat
+	}
+	v := make([]bool, int(n))
+	if n == 0 {
+		return v, nil
+	}
+	b := make([]byte, int(r.N))
+	if _, err := io.ReadFull(&r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	for i := range v {
+		v[i] = b[i/8]&(0x80>>(i%8)) != 0
+	}
+	// The unused bits of the last byte must be 0
+	if unused := len(b)*8 - len(v); unused > 0 && b[len(b)-1]&(0xFF>>(8-unused)) != 0 {
+		return nil, tags.ErrBadTagFormat
+	}
+	return v, nil
+}
+
+/*
+PackedBoolArrayPayload is the payload of an array of boolean values stored as a
+bitset. The payload is composed by the number of elements encoded as an ILInt
+followed by the bits packed into bytes. The first element is stored in the most
+significant bit of the first byte. The unused bits of the last byte are always
+set to 0.
+*/
+type PackedBoolArrayPayload struct {
+	Payload []bool
+}
+
+// Implementation of ILTagPayload.ValueSize().
+func (p *PackedBoolArrayPayload) ValueSize() uint64 {
+	return uint64(ilint.EncodedSize(uint64(len(p.Payload))) + bitsetSize(len(p.Payload)))
+}
+
+// Implementation of ILTagPayload.SerializeValue()
+func (p *PackedBoolArrayPayload) SerializeValue(writer io.Writer) error {
+	return writePackedBools(writer, p.Payload)
+}
+
+// Implementation of ILTagPayload.DeserializeValue()
+func (p *PackedBoolArrayPayload) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	if v, err := readPackedBools(reader, valueSize); err != nil {
+		return err
+	} else {
+		p.Payload = v
+		return nil
+	}
+}
+
+/*
+PackedBoolArrayTag is a generic tag that stores an array of boolean values as a
+PackedBoolArrayPayload.
+
+Since it is not a standard tag it does not have a Standard tag ID associated
+with it.
+*/
+type PackedBoolArrayTag struct {
+	tags.ILTagHeaderImpl
+	PackedBoolArrayPayload
+}
+
+/*
+Creates a new PackedBoolArrayTag.
+
+This function panics if the provided id is reserved for implicit tags.
+*/
+func NewPackedBoolArrayTag(id tags.TagID) *PackedBoolArrayTag {
+	if id.Implicit() {
+		panic("This tag cannot have an implicit tag id.")
+	}
+	var t PackedBoolArrayTag
+	t.SetId(id)
+	return &t
+}
+
+/*
+Returns the size of a PackedBoolArrayTag with the given tagId and values.
+*/
+func PackedBoolArrayTagSize(tagId tags.TagID, v []bool) uint64 {
+	return tags.GetExplicitTagSize(tagId,
+		uint64(ilint.EncodedSize(uint64(len(v)))+bitsetSize(len(v))))
+}
+
+/*
+Serializes a PackedBoolArrayTag directly into a writer. The provided tagId must
+belong to an explicit tag.
+*/
+func SerializePackedBoolArrayTag(tagId tags.TagID, v []bool, writer io.Writer) error {
+	if err := serialization.WriteILInt(writer, tagId.UInt64()); err != nil {
+		return err
+	}
+	size := ilint.EncodedSize(uint64(len(v))) + bitsetSize(len(v))
+	if err := serialization.WriteILInt(writer, uint64(size)); err != nil {
+		return err
+	}
+	return writePackedBools(writer, v)
+}
+
+/*
+Deserializes a PackedBoolArrayTag directly from a reader. The provided tagId
+must match the expected tagId.
+*/
+func DeserializePackedBoolArrayTag(tagId tags.TagID, reader io.Reader) ([]bool, error) {
+	size, err := readExplicitHeader(tagId, reader)
+	if err != nil {
+		return nil, err
+	}
+	return readPackedBools(reader, int(size))
+}
@@ -0,0 +1,276 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ext
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tagtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackedElementSize(t *testing.T) {
+	assert.Equal(t, 1, PackedElementSize[int8]())
+	assert.Equal(t, 1, PackedElementSize[uint8]())
+	assert.Equal(t, 2, PackedElementSize[int16]())
+	assert.Equal(t, 2, PackedElementSize[uint16]())
+	assert.Equal(t, 4, PackedElementSize[int32]())
+	assert.Equal(t, 4, PackedElementSize[uint32]())
+	assert.Equal(t, 4, PackedElementSize[float32]())
+	assert.Equal(t, 8, PackedElementSize[int64]())
+	assert.Equal(t, 8, PackedElementSize[uint64]())
+	assert.Equal(t, 8, PackedElementSize[float64]())
+}
+
+func testPackedRoundTrip[T PackedNumber](t *testing.T, v []T, exp []byte) {
+	p := PackedArrayPayload[T]{Payload: v}
+	assert.Equal(t, uint64(len(exp)), p.ValueSize())
+	w := bytes.NewBuffer(nil)
+	assert.Nil(t, p.SerializeValue(w))
+	assert.Equal(t, exp, w.Bytes())
+
+	var p2 PackedArrayPayload[T]
+	assert.Nil(t, p2.DeserializeValue(nil, len(exp), bytes.NewReader(exp)))
+	assert.Equal(t, v, p2.Payload)
+}
+
+func TestPackedArrayPayload(t *testing.T) {
+	testPackedRoundTrip(t, []int8{-1, 0, 1}, []byte{0xFF, 0x00, 0x01})
+	testPackedRoundTrip(t, []uint8{1, 2, 3}, []byte{0x01, 0x02, 0x03})
+	testPackedRoundTrip(t, []int16{-2, 0x1234}, []byte{0xFF, 0xFE, 0x12, 0x34})
+	testPackedRoundTrip(t, []uint16{0xFEDC, 0x1234}, []byte{0xFE, 0xDC, 0x12, 0x34})
+	testPackedRoundTrip(t, []int32{-2, 0x12345678},
+		[]byte{0xFF, 0xFF, 0xFF, 0xFE, 0x12, 0x34, 0x56, 0x78})
+	testPackedRoundTrip(t, []uint32{0xFEDCBA98},
+		[]byte{0xFE, 0xDC, 0xBA, 0x98})
+	testPackedRoundTrip(t, []int64{-2},
+		[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE})
+	testPackedRoundTrip(t, []uint64{0x0123456789ABCDEF},
+		[]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF})
+	testPackedRoundTrip(t, []float32{1.0},
+		[]byte{0x3f, 0x80, 0x00, 0x00})
+	testPackedRoundTrip(t, []float64{1.0, math.Inf(-1)},
+		[]byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0xff, 0xf0, 0, 0, 0, 0, 0, 0})
+	testPackedRoundTrip(t, []float64{}, nil)
+}
+
+func TestPackedArrayPayload_Large(t *testing.T) {
+	// Larger than the internal buffer and not aligned to it
+	v := make([]float64, packedBufferSize/8*3+5)
+	for i := range v {
+		v[i] = rand.NormFloat64()
+	}
+	p := PackedArrayPayload[float64]{Payload: v}
+	w := bytes.NewBuffer(nil)
+	assert.Nil(t, p.SerializeValue(w))
+	assert.Equal(t, int(p.ValueSize()), w.Len())
+	for i, x := range v {
+		assert.Equal(t, math.Float64bits(x), uint64(w.Bytes()[i*8])<<56|
+			uint64(w.Bytes()[i*8+1])<<48|uint64(w.Bytes()[i*8+2])<<40|
+			uint64(w.Bytes()[i*8+3])<<32|uint64(w.Bytes()[i*8+4])<<24|
+			uint64(w.Bytes()[i*8+5])<<16|uint64(w.Bytes()[i*8+6])<<8|
+			uint64(w.Bytes()[i*8+7]))
+	}
+
+	var p2 PackedArrayPayload[float64]
+	assert.Nil(t, p2.DeserializeValue(nil, w.Len(), bytes.NewReader(w.Bytes())))
+	assert.Equal(t, v, p2.Payload)
+}
+
+func TestPackedArrayPayload_SerializeValueFail(t *testing.T) {
+	v := make([]uint32, packedBufferSize)
+	p := PackedArrayPayload[uint32]{Payload: v}
+	for _, n := range []int{0, 10, packedBufferSize + 3} {
+		w := tagtest.NewLimitedWriter(n, false)
+		assert.ErrorIs(t, p.SerializeValue(w), io.ErrShortWrite)
+	}
+}
+
+func TestPackedArrayPayload_DeserializeValueFail(t *testing.T) {
+	bin := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	var p PackedArrayPayload[int32]
+	assert.ErrorIs(t, p.DeserializeValue(nil, -1, bytes.NewReader(bin)), tags.ErrBadTagFormat)
+	assert.ErrorIs(t, p.DeserializeValue(nil, 6, bytes.NewReader(bin)), tags.ErrBadTagFormat)
+	assert.ErrorIs(t, p.DeserializeValue(nil, 8, bytes.NewReader(bin)), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, p.DeserializeValue(nil, 4, bytes.NewReader(nil)), io.ErrUnexpectedEOF)
+	assert.Nil(t, p.Payload)
+}
+
+func TestNewPackedArrayTag(t *testing.T) {
+	var _ tags.ILTag = (*PackedFloat64ArrayTag)(nil)
+
+	tag := NewPackedArrayTag[float64](1234)
+	assert.Equal(t, tags.TagID(1234), tag.Id())
+	assert.Nil(t, tag.Payload)
+	assert.Panics(t, func() {
+		NewPackedArrayTag[int8](tags.IMPLICIT_ID_MAX)
+	})
+
+	tag.Payload = []float64{1.0, 2.0}
+	bin, err := tags.ILTagToBytes(tag)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xf9, 0x03, 0xda, 16,
+		0x3f, 0xf0, 0, 0, 0, 0, 0, 0,
+		0x40, 0x00, 0, 0, 0, 0, 0, 0}, bin)
+
+	tag2 := NewPackedArrayTag[float64](1234)
+	assert.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(bin), tag2))
+	assert.Equal(t, tag.Payload, tag2.Payload)
+}
+
+func TestPackedArrayTagDirect(t *testing.T) {
+	v := []int16{1, -1, 0x7FFF}
+	exp := []byte{0xf9, 0x03, 0xda, 6, 0x00, 0x01, 0xFF, 0xFF, 0x7F, 0xFF}
+
+	assert.Equal(t, uint64(len(exp)), PackedArrayTagSize(1234, v))
+	w := bytes.NewBuffer(nil)
+	assert.Nil(t, SerializePackedArrayTag(1234, v, w))
+	assert.Equal(t, exp, w.Bytes())
+
+	r, err := DeserializePackedArrayTag[int16](1234, bytes.NewReader(exp))
+	assert.Nil(t, err)
+	assert.Equal(t, v, r)
+
+	_, err = DeserializePackedArrayTag[int16](1235, bytes.NewReader(exp))
+	assert.ErrorIs(t, err, tags.ErrUnexpectedTagId)
+	_, err = DeserializePackedArrayTag[int32](1234, bytes.NewReader(exp))
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	for i := 0; i < len(exp); i++ {
+		_, err = DeserializePackedArrayTag[int16](1234, bytes.NewReader(exp[:i]))
+		assert.Error(t, err)
+	}
+	_, err = DeserializePackedArrayTag[int16](1234,
+		bytes.NewReader([]byte{0xf9, 0x03, 0xda, 0xfb, 0x20, 0x00, 0x00, 0x00}))
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+
+	for i := 0; i < len(exp); i++ {
+		w := tagtest.NewLimitedWriter(i, false)
+		assert.ErrorIs(t, SerializePackedArrayTag(1234, v, w), io.ErrShortWrite)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestPackedBoolArrayPayload(t *testing.T) {
+	samples := []struct {
+		v   []bool
+		bin []byte
+	}{
+		{[]bool{}, []byte{0x00}},
+		{[]bool{true}, []byte{0x01, 0x80}},
+		{[]bool{true, false, true, false, false, false, false, true},
+			[]byte{0x08, 0xA1}},
+		{[]bool{false, false, false, false, false, false, false, false, true, true},
+			[]byte{0x0A, 0x00, 0xC0}},
+	}
+	for _, s := range samples {
+		p := PackedBoolArrayPayload{Payload: s.v}
+		assert.Equal(t, uint64(len(s.bin)), p.ValueSize())
+		w := bytes.NewBuffer(nil)
+		assert.Nil(t, p.SerializeValue(w))
+		assert.Equal(t, s.bin, w.Bytes())
+
+		var p2 PackedBoolArrayPayload
+		assert.Nil(t, p2.DeserializeValue(nil, len(s.bin), bytes.NewReader(s.bin)))
+		assert.Equal(t, s.v, p2.Payload)
+
+		for i := 0; i < len(s.bin); i++ {
+			w := tagtest.NewLimitedWriter(i, false)
+			assert.ErrorIs(t, p.SerializeValue(w), io.ErrShortWrite)
+		}
+	}
+}
+
+func TestPackedBoolArrayPayload_DeserializeValueFail(t *testing.T) {
+	var p PackedBoolArrayPayload
+	// Empty
+	assert.ErrorIs(t, p.DeserializeValue(nil, 0, bytes.NewReader(nil)), tags.ErrBadTagFormat)
+	// Too many bits
+	bin := []byte{0x09, 0xFF}
+	assert.ErrorIs(t, p.DeserializeValue(nil, len(bin), bytes.NewReader(bin)), tags.ErrBadTagFormat)
+	// Too many bytes
+	bin = []byte{0x01, 0x80, 0x00}
+	assert.ErrorIs(t, p.DeserializeValue(nil, len(bin), bytes.NewReader(bin)), tags.ErrBadTagFormat)
+	// Unused bits set
+	bin = []byte{0x01, 0x81}
+	assert.ErrorIs(t, p.DeserializeValue(nil, len(bin), bytes.NewReader(bin)), tags.ErrBadTagFormat)
+	// Truncated
+	bin = []byte{0x09, 0x81, 0x80}
+	assert.ErrorIs(t, p.DeserializeValue(nil, len(bin), bytes.NewReader(bin[:2])), io.ErrUnexpectedEOF)
+	assert.Error(t, p.DeserializeValue(nil, len(bin), bytes.NewReader(nil)))
+	assert.Nil(t, p.Payload)
+}
+
+func TestNewPackedBoolArrayTag(t *testing.T) {
+	var _ tags.ILTag = (*PackedBoolArrayTag)(nil)
+
+	tag := NewPackedBoolArrayTag(1234)
+	assert.Equal(t, tags.TagID(1234), tag.Id())
+	assert.Nil(t, tag.Payload)
+	assert.Panics(t, func() {
+		NewPackedBoolArrayTag(tags.IMPLICIT_ID_MAX)
+	})
+}
+
+func TestPackedBoolArrayTagDirect(t *testing.T) {
+	v := []bool{true, true, false}
+	exp := []byte{0xf9, 0x03, 0xda, 2, 0x03, 0xC0}
+
+	assert.Equal(t, uint64(len(exp)), PackedBoolArrayTagSize(1234, v))
+	w := bytes.NewBuffer(nil)
+	assert.Nil(t, SerializePackedBoolArrayTag(1234, v, w))
+	assert.Equal(t, exp, w.Bytes())
+
+	tag := NewPackedBoolArrayTag(1234)
+	assert.Nil(t, tags.ILTagDeserializeInto(nil, bytes.NewReader(exp), tag))
+	assert.Equal(t, v, tag.Payload)
+
+	r, err := DeserializePackedBoolArrayTag(1234, bytes.NewReader(exp))
+	assert.Nil(t, err)
+	assert.Equal(t, v, r)
+
+	_, err = DeserializePackedBoolArrayTag(1235, bytes.NewReader(exp))
+	assert.ErrorIs(t, err, tags.ErrUnexpectedTagId)
+	for i := 0; i < len(exp); i++ {
+		_, err = DeserializePackedBoolArrayTag(1234, bytes.NewReader(exp[:i]))
+		assert.Error(t, err)
+		w := tagtest.NewLimitedWriter(i, false)
+		assert.ErrorIs(t, SerializePackedBoolArrayTag(1234, v, w), io.ErrShortWrite)
+	}
+}
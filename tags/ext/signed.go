@@ -0,0 +1,460 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ext
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/ilint"
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+var (
+	// The signature algorithm is not supported or does not match the key.
+	ErrUnsupportedSignature = fmt.Errorf("unsupported signature algorithm")
+	// The signature does not match the signed data.
+	ErrInvalidSignature = fmt.Errorf("invalid signature")
+	// The envelope has no signatures.
+	ErrNoSignatures = fmt.Errorf("no signatures")
+	// The envelope has no inner tag.
+	ErrNoInnerTag = fmt.Errorf("no inner tag")
+	// No SignerKeyResolver was provided.
+	ErrNoKeyResolver = fmt.Errorf("no signer key resolver")
+	// The signer key is not trusted by the SignerKeyResolver.
+	ErrUntrustedKey = fmt.Errorf("untrusted signer key")
+)
+
+/*
+SignatureAlgorithm identifies the signature scheme and the hash function used
+to compute the digest that is actually signed.
+*/
+type SignatureAlgorithm uint64
+
+const (
+	// Ed25519 over the SHA-512 digest.
+	SigEd25519 SignatureAlgorithm = 1
+	// ECDSA over the SHA-256 digest, ASN.1 encoded.
+	SigECDSASHA256 SignatureAlgorithm = 2
+	// ECDSA over the SHA-384 digest, ASN.1 encoded.
+	SigECDSASHA384 SignatureAlgorithm = 3
+	// ECDSA over the SHA-512 digest, ASN.1 encoded.
+	SigECDSASHA512 SignatureAlgorithm = 4
+)
+
+/*
+Returns the hash function used to compute the digest. It returns 0 if the
+algorithm is unknown.
+*/
+func (a SignatureAlgorithm) Hash() crypto.Hash {
+	switch a {
+	case SigEd25519, SigECDSASHA512:
+		return crypto.SHA512
+	case SigECDSASHA256:
+		return crypto.SHA256
+	case SigECDSASHA384:
+		return crypto.SHA384
+	default:
+		return 0
+	}
+}
+
+// Returns true if the algorithm can be used with the given public key.
+func (a SignatureAlgorithm) supports(pub crypto.PublicKey) bool {
+	switch pub.(type) {
+	case ed25519.PublicKey:
+		return a == SigEd25519
+	case *ecdsa.PublicKey:
+		return a == SigECDSASHA256 || a == SigECDSASHA384 || a == SigECDSASHA512
+	default:
+		return false
+	}
+}
+
+/*
+Computes the digest of the canonical serialization of the tag that is signed
+by the given algorithm.
+*/
+func SignatureDigest(alg SignatureAlgorithm, tag tags.ILTag) ([]byte, error) {
+	h := alg.Hash()
+	if h == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSignature, uint64(alg))
+	}
+	hash := h.New()
+	if err := tags.ILTagSeralize(tag, hash); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+/*
+SignerKeyResolver returns the public key identified by a signer key tag.
+*/
+type SignerKeyResolver func(key tags.ILTag) (crypto.PublicKey, error)
+
+/*
+Creates a signer key tag that holds the public key encoded as a PKIX DER
+structure inside a standard bytes tag.
+*/
+func NewPKIXKeyTag(pub crypto.PublicKey) (*tags.RawTag, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	t := tags.NewRawTag(tags.IL_BYTES_TAG_ID)
+	t.Payload = b
+	return t, nil
+}
+
+/*
+Implementation of SignerKeyResolver for the key tags created by
+NewPKIXKeyTag().
+
+It only decodes the key embedded in the key tag, thus a signature verified with
+it proves that the data was signed by the holder of that key but not who the
+signer is. Anyone can replace the inner tag and sign it again with their own
+key. Use NewTrustedKeyResolver() or check the keys against a trusted source in
+order to authenticate the signers.
+*/
+func PKIXKeyResolver(key tags.ILTag) (crypto.PublicKey, error) {
+	raw, ok := key.(*tags.RawTag)
+	if !ok || raw.Id() != tags.IL_BYTES_TAG_ID {
+		return nil, fmt.Errorf("the key tag %d is not a bytes tag: %w", key.Id(),
+			tags.ErrBadTagFormat)
+	}
+	return x509.ParsePKIXPublicKey(raw.Payload)
+}
+
+/*
+Creates a SignerKeyResolver that accepts only the key tags created by
+NewPKIXKeyTag() for the given trusted keys. Other keys fail with
+ErrUntrustedKey.
+*/
+func NewTrustedKeyResolver(trusted ...crypto.PublicKey) SignerKeyResolver {
+	return func(key tags.ILTag) (crypto.PublicKey, error) {
+		pub, err := PKIXKeyResolver(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range trusted {
+			if k, ok := t.(interface{ Equal(crypto.PublicKey) bool }); ok && k.Equal(pub) {
+				return pub, nil
+			}
+		}
+		return nil, ErrUntrustedKey
+	}
+}
+
+//------------------------------------------------------------------------------
+
+/*
+Signature is a signature stored by the SignedPayload.
+*/
+type Signature struct {
+	// The signature algorithm.
+	Algorithm SignatureAlgorithm
+	// The tag that identifies the key of the signer.
+	Key tags.ILTag
+	// The signature.
+	Signature []byte
+}
+
+// Returns a standard bytes tag that holds the given bytes.
+func newBytesTag(b []byte) *tags.RawTag {
+	t := tags.NewRawTag(tags.IL_BYTES_TAG_ID)
+	t.Payload = b
+	return t
+}
+
+/*
+Verifies the signature against the digest of the signed tag computed by
+SignatureDigest().
+*/
+func (s *Signature) verifyDigest(digest []byte, resolver SignerKeyResolver) error {
+	if tags.IsILTagNil(s.Key) {
+		return fmt.Errorf("no signer key: %w", ErrInvalidSignature)
+	}
+	pub, err := resolver(s.Key)
+	if err != nil {
+		return err
+	}
+	if !s.Algorithm.supports(pub) {
+		return fmt.Errorf("%w: %d for %T", ErrUnsupportedSignature, uint64(s.Algorithm), pub)
+	}
+	var ok bool
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, digest, s.Signature)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, digest, s.Signature)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+/*
+SignedPayload is the payload of a signed envelope. It holds an inner tag and the
+signatures of the canonical serialization of the inner tag. Each signature is
+computed over the digest of the serialization, using the hash function of its
+algorithm.
+
+In the detached mode, the inner tag is omitted and the signatures are computed
+over an external tag, which must be provided to verify them. Since both modes
+sign the same digest, a detached signature of a tag is also valid for an
+envelope that holds the same tag.
+
+The payload is serialized as the inner tag (or a NullTag in the detached mode),
+followed by the ILInt number of signatures and the signatures. Each signature is
+serialized as the ILInt algorithm, the signer key tag and a standard bytes tag
+with the signature.
+*/
+type SignedPayload struct {
+	// The signed tag. It is nil in the detached mode.
+	Inner tags.ILTag
+	// The signatures.
+	Signatures []Signature
+}
+
+// Implementation of ILTagPayload.ValueSize().
+func (p *SignedPayload) ValueSize() uint64 {
+	size := tags.ILTagSequenceSize(p.Inner) + uint64(ilint.EncodedSize(uint64(len(p.Signatures))))
+	for i := range p.Signatures {
+		s := &p.Signatures[i]
+		size += uint64(ilint.EncodedSize(uint64(s.Algorithm))) +
+			tags.ILTagSequenceSize(s.Key, newBytesTag(s.Signature))
+	}
+	return size
+}
+
+// Implementation of ILTagPayload.SerializeValue()
+func (p *SignedPayload) SerializeValue(writer io.Writer) error {
+	if err := tags.ILTagSeralizeWithNull(p.Inner, writer); err != nil {
+		return err
+	}
+	if err := serialization.WriteILInt(writer, uint64(len(p.Signatures))); err != nil {
+		return err
+	}
+	for i := range p.Signatures {
+		s := &p.Signatures[i]
+		if err := serialization.WriteILInt(writer, uint64(s.Algorithm)); err != nil {
+			return err
+		}
+		if err := tags.ILTagSerializeTags(writer, s.Key, newBytesTag(s.Signature)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Implementation of ILTagPayload.DeserializeValue()
+func (p *SignedPayload) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	r := &io.LimitedReader{R: reader, N: int64(valueSize)}
+	inner, err := tags.ILTagDeserialize(factory, r)
+	if err != nil {
+		return err
+	}
+	if inner.Id() == tags.IL_NULL_TAG_ID {
+		inner = nil
+	}
+	count, err := serialization.ReadILInt(r)
+	if err != nil {
+		return err
+	}
+	// Each signature has at least 3 bytes
+	if count > uint64(r.N/3) {
+		return tags.ErrBadTagFormat
+	}
+	signatures := make([]Signature, int(count))
+	for i := range signatures {
+		s := &signatures[i]
+		alg, err := serialization.ReadILInt(r)
+		if err != nil {
+			return err
+		}
+		s.Algorithm = SignatureAlgorithm(alg)
+		if s.Key, err = tags.ILTagDeserialize(factory, r); err != nil {
+			return err
+		}
+		sig := tags.NewRawTag(tags.IL_BYTES_TAG_ID)
+		if err := tags.ILTagDeserializeInto(factory, r, sig); err != nil {
+			return err
+		}
+		s.Signature = sig.Payload
+	}
+	if r.N != 0 {
+		return tags.ErrBadTagFormat
+	}
+	p.Inner = inner
+	p.Signatures = signatures
+	return nil
+}
+
+/*
+Signs the digest of the given tag and adds the signature. If key is nil, the
+signer key tag is created by NewPKIXKeyTag() with the public key of the signer.
+*/
+func (p *SignedPayload) signTag(tag tags.ILTag, rand io.Reader, signer crypto.Signer,
+	alg SignatureAlgorithm, key tags.ILTag) error {
+	pub := signer.Public()
+	if !alg.supports(pub) {
+		return fmt.Errorf("%w: %d for %T", ErrUnsupportedSignature, uint64(alg), pub)
+	}
+	digest, err := SignatureDigest(alg, tag)
+	if err != nil {
+		return err
+	}
+	if tags.IsILTagNil(key) {
+		if key, err = NewPKIXKeyTag(pub); err != nil {
+			return err
+		}
+	}
+	// Ed25519 signs the digest as the message
+	var opts crypto.SignerOpts = alg.Hash()
+	if alg == SigEd25519 {
+		opts = crypto.Hash(0)
+	}
+	sig, err := signer.Sign(rand, digest, opts)
+	if err != nil {
+		return err
+	}
+	p.Signatures = append(p.Signatures, Signature{Algorithm: alg, Key: key, Signature: sig})
+	return nil
+}
+
+/*
+Signs the inner tag with the given signer and adds the signature to the
+payload. The signer must hold an ed25519 or ECDSA key compatible with the
+algorithm. If key is nil, the signer key tag is created by NewPKIXKeyTag().
+
+It fails with ErrNoInnerTag if the inner tag is not set.
+*/
+func (p *SignedPayload) Sign(rand io.Reader, signer crypto.Signer, alg SignatureAlgorithm,
+	key tags.ILTag) error {
+	if tags.IsILTagNil(p.Inner) {
+		return ErrNoInnerTag
+	}
+	return p.signTag(p.Inner, rand, signer, alg, key)
+}
+
+/*
+Signs the external tag in the detached mode and adds the signature to the
+payload. The inner tag is not changed, thus it should be nil. See Sign() for
+the other parameters.
+*/
+func (p *SignedPayload) SignDetached(external tags.ILTag, rand io.Reader, signer crypto.Signer,
+	alg SignatureAlgorithm, key tags.ILTag) error {
+	return p.signTag(external, rand, signer, alg, key)
+}
+
+// Verifies all signatures against the given tag.
+func (p *SignedPayload) verifyTag(tag tags.ILTag, resolver SignerKeyResolver) error {
+	if resolver == nil {
+		return ErrNoKeyResolver
+	}
+	if len(p.Signatures) == 0 {
+		return ErrNoSignatures
+	}
+	for i := range p.Signatures {
+		s := &p.Signatures[i]
+		digest, err := SignatureDigest(s.Algorithm, tag)
+		if err == nil {
+			err = s.verifyDigest(digest, resolver)
+		}
+		if err != nil {
+			return fmt.Errorf("signature %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+/*
+Verifies all signatures against the inner tag. The resolver returns the public
+keys of the signer key tags and is responsible for deciding which signers are
+trusted. See PKIXKeyResolver() for details.
+
+It fails with ErrNoKeyResolver if the resolver is nil, with ErrNoSignatures if
+there are no signatures and with ErrNoInnerTag if the inner tag is not set.
+Errors of individual signatures report their indexes.
+*/
+func (p *SignedPayload) Verify(resolver SignerKeyResolver) error {
+	if tags.IsILTagNil(p.Inner) {
+		return ErrNoInnerTag
+	}
+	return p.verifyTag(p.Inner, resolver)
+}
+
+/*
+Verifies all signatures against the external tag, ignoring the inner tag. See
+Verify() for details.
+*/
+func (p *SignedPayload) VerifyDetached(external tags.ILTag, resolver SignerKeyResolver) error {
+	return p.verifyTag(external, resolver)
+}
+
+//------------------------------------------------------------------------------
+
+/*
+SignedTag is a generic signed envelope that stores a SignedPayload.
+
+Since it is not a standard tag it does not have a Standard tag ID associated
+with it.
+*/
+type SignedTag struct {
+	tags.ILTagHeaderImpl
+	SignedPayload
+}
+
+/*
+Create a new SignedTag.
+
+This function panics if the provided id is reserved for implicit tags.
+*/
+func NewSignedTag(id tags.TagID) *SignedTag {
+	if id.Implicit() {
+		panic("This tag cannot have an implicit tag id.")
+	}
+	var t SignedTag
+	t.SetId(id)
+	return &t
+}
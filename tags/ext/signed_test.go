@@ -0,0 +1,400 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ext
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a fixed ed25519 key.
+func testEd25519Key() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+}
+
+// Returns a new ECDSA key.
+func testECDSAKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	k, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.Nil(t, err)
+	return k
+}
+
+// A crypto.Signer that always fails.
+type failingSigner struct {
+	crypto.Signer
+}
+
+func (s failingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+// Serializes and deserializes the envelope.
+func roundTripSignedTag(t *testing.T, tag *SignedTag) *SignedTag {
+	b, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(len(b)), tags.ILTagSize(tag))
+	restored := NewSignedTag(tag.Id())
+	require.Nil(t, tags.ILTagDeserializeInto(impl.NewStandardTagFactory(true),
+		bytes.NewReader(b), restored))
+	return restored
+}
+
+func TestSignatureAlgorithm_Hash(t *testing.T) {
+	assert.Equal(t, crypto.SHA512, SigEd25519.Hash())
+	assert.Equal(t, crypto.SHA256, SigECDSASHA256.Hash())
+	assert.Equal(t, crypto.SHA384, SigECDSASHA384.Hash())
+	assert.Equal(t, crypto.SHA512, SigECDSASHA512.Hash())
+	assert.Equal(t, crypto.Hash(0), SignatureAlgorithm(0).Hash())
+	assert.Equal(t, crypto.Hash(0), SignatureAlgorithm(5).Hash())
+}
+
+func TestSignatureDigest(t *testing.T) {
+	tag := impl.MustParseTag(`str("data")`)
+	b, err := tags.ILTagToBytes(tag)
+	require.Nil(t, err)
+
+	d, err := SignatureDigest(SigECDSASHA256, tag)
+	require.Nil(t, err)
+	exp256 := sha256.Sum256(b)
+	assert.Equal(t, exp256[:], d)
+	d, err = SignatureDigest(SigEd25519, tag)
+	require.Nil(t, err)
+	exp512 := sha512.Sum512(b)
+	assert.Equal(t, exp512[:], d)
+
+	_, err = SignatureDigest(SignatureAlgorithm(100), tag)
+	assert.ErrorIs(t, err, ErrUnsupportedSignature)
+	_, err = SignatureDigest(SigEd25519, &failingTag{})
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+}
+
+// A tag that cannot be serialized.
+type failingTag struct {
+	tags.ILTagHeaderImpl
+}
+
+func (t *failingTag) ValueSize() uint64 {
+	return 1
+}
+
+func (t *failingTag) SerializeValue(writer io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func (t *failingTag) DeserializeValue(factory tags.ILTagFactory, valueSize int, reader io.Reader) error {
+	return io.ErrShortWrite
+}
+
+func TestPKIXKeyTag(t *testing.T) {
+	key := testEd25519Key()
+	tag, err := NewPKIXKeyTag(key.Public())
+	require.Nil(t, err)
+	assert.Equal(t, tags.IL_BYTES_TAG_ID, tag.Id())
+	pub, err := PKIXKeyResolver(tag)
+	require.Nil(t, err)
+	assert.Equal(t, key.Public(), pub)
+
+	ec := testECDSAKey(t, elliptic.P256())
+	tag, err = NewPKIXKeyTag(ec.Public())
+	require.Nil(t, err)
+	pub, err = PKIXKeyResolver(tag)
+	require.Nil(t, err)
+	assert.True(t, ec.PublicKey.Equal(pub))
+
+	_, err = NewPKIXKeyTag(struct{}{})
+	assert.Error(t, err)
+	_, err = PKIXKeyResolver(impl.NewStdStringTag())
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	other := tags.NewRawTag(1000)
+	_, err = PKIXKeyResolver(other)
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	_, err = PKIXKeyResolver(impl.NewStdBytesTag())
+	assert.Error(t, err)
+}
+
+func TestSignedPayload_SignVerify(t *testing.T) {
+	ed := testEd25519Key()
+	p256 := testECDSAKey(t, elliptic.P256())
+	p384 := testECDSAKey(t, elliptic.P384())
+
+	tag := NewSignedTag(1000)
+	tag.Inner = impl.MustParseTag(`seq[str("record"), u64(1)]`)
+	assert.ErrorIs(t, tag.Verify(PKIXKeyResolver), ErrNoSignatures)
+	require.Nil(t, tag.Sign(rand.Reader, ed, SigEd25519, nil))
+	require.Nil(t, tag.Sign(rand.Reader, p256, SigECDSASHA256, nil))
+	require.Nil(t, tag.Sign(rand.Reader, p384, SigECDSASHA384, nil))
+	require.Nil(t, tag.Sign(rand.Reader, p256, SigECDSASHA512, nil))
+	require.Len(t, tag.Signatures, 4)
+	assert.Nil(t, tag.Verify(PKIXKeyResolver))
+
+	// Ed25519 signs the digest as the message
+	digest, err := SignatureDigest(SigEd25519, tag.Inner)
+	require.Nil(t, err)
+	assert.True(t, ed25519.Verify(ed.Public().(ed25519.PublicKey), digest, tag.Signatures[0].Signature))
+
+	// Serialization
+	restored := roundTripSignedTag(t, tag)
+	assert.Equal(t, tag.Signatures, restored.Signatures)
+	assert.Nil(t, restored.Verify(PKIXKeyResolver))
+
+	// Changed inner tag
+	restored.Inner.(*impl.ILTagSequenceTag).Payload[1].(*impl.UInt64Tag).Payload = 2
+	err = restored.Verify(PKIXKeyResolver)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.Contains(t, err.Error(), "signature 0: ")
+
+	// Changed signature
+	restored = roundTripSignedTag(t, tag)
+	restored.Signatures[2].Signature[5] ^= 0xFF
+	err = restored.Verify(PKIXKeyResolver)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.Contains(t, err.Error(), "signature 2: ")
+
+	// Bad algorithms
+	restored = roundTripSignedTag(t, tag)
+	restored.Signatures[1].Algorithm = SigEd25519
+	assert.ErrorIs(t, restored.Verify(PKIXKeyResolver), ErrUnsupportedSignature)
+	restored.Signatures[1].Algorithm = 100
+	assert.ErrorIs(t, restored.Verify(PKIXKeyResolver), ErrUnsupportedSignature)
+	restored.Signatures[1].Algorithm = SigECDSASHA256
+	restored.Signatures[1].Key = nil
+	assert.ErrorIs(t, restored.Verify(PKIXKeyResolver), ErrInvalidSignature)
+	restored.Signatures[1].Key = impl.NewStdStringTag()
+	assert.ErrorIs(t, restored.Verify(PKIXKeyResolver), tags.ErrBadTagFormat)
+}
+
+func TestSignedPayload_TrustedKeys(t *testing.T) {
+	ed := testEd25519Key()
+	p256 := testECDSAKey(t, elliptic.P256())
+	attacker := testECDSAKey(t, elliptic.P256())
+	resolver := NewTrustedKeyResolver(ed.Public(), p256.Public())
+
+	tag := NewSignedTag(1000)
+	tag.Inner = impl.MustParseTag(`str("record")`)
+	require.Nil(t, tag.Sign(rand.Reader, ed, SigEd25519, nil))
+	require.Nil(t, tag.Sign(rand.Reader, p256, SigECDSASHA256, nil))
+	assert.Nil(t, tag.Verify(resolver))
+	assert.ErrorIs(t, tag.Verify(nil), ErrNoKeyResolver)
+	assert.ErrorIs(t, tag.VerifyDetached(tag.Inner, nil), ErrNoKeyResolver)
+
+	// The inner tag replaced and signed again with another key
+	forged := NewSignedTag(1000)
+	forged.Inner = impl.MustParseTag(`str("forged")`)
+	require.Nil(t, forged.Sign(rand.Reader, attacker, SigECDSASHA256, nil))
+	assert.Nil(t, forged.Verify(PKIXKeyResolver))
+	assert.ErrorIs(t, forged.Verify(resolver), ErrUntrustedKey)
+
+	key := tag.Signatures[0].Key
+	tag.Signatures[0].Key = impl.NewStdStringTag()
+	assert.ErrorIs(t, tag.Verify(resolver), tags.ErrBadTagFormat)
+	tag.Signatures[0].Key = key
+	assert.ErrorIs(t, tag.Verify(NewTrustedKeyResolver()), ErrUntrustedKey)
+	assert.ErrorIs(t, tag.Verify(NewTrustedKeyResolver(struct{}{})), ErrUntrustedKey)
+}
+
+func TestSignedPayload_SignErrors(t *testing.T) {
+	ed := testEd25519Key()
+	p256 := testECDSAKey(t, elliptic.P256())
+
+	tag := NewSignedTag(1000)
+	assert.ErrorIs(t, tag.Sign(rand.Reader, ed, SigEd25519, nil), ErrNoInnerTag)
+	assert.ErrorIs(t, tag.Verify(PKIXKeyResolver), ErrNoInnerTag)
+
+	tag.Inner = impl.MustParseTag(`str("record")`)
+	assert.ErrorIs(t, tag.Sign(rand.Reader, ed, SigECDSASHA256, nil), ErrUnsupportedSignature)
+	assert.ErrorIs(t, tag.Sign(rand.Reader, p256, SigEd25519, nil), ErrUnsupportedSignature)
+	assert.ErrorIs(t, tag.Sign(rand.Reader, p256, 100, nil), ErrUnsupportedSignature)
+	assert.ErrorIs(t, tag.Sign(rand.Reader, failingSigner{ed}, SigEd25519, nil), io.ErrUnexpectedEOF)
+	tag.Inner = &failingTag{}
+	assert.ErrorIs(t, tag.Sign(rand.Reader, ed, SigEd25519, nil), io.ErrShortWrite)
+	assert.Empty(t, tag.Signatures)
+}
+
+func TestSignedPayload_CustomKeys(t *testing.T) {
+	ed := testEd25519Key()
+	name := impl.NewStringTag(1001)
+	name.Payload = "signer-1"
+	resolver := func(key tags.ILTag) (crypto.PublicKey, error) {
+		if s, ok := key.(*impl.StringTag); ok && s.Payload == "signer-1" {
+			return ed.Public(), nil
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	tag := NewSignedTag(1000)
+	tag.Inner = impl.MustParseTag(`str("record")`)
+	require.Nil(t, tag.Sign(rand.Reader, ed, SigEd25519, name))
+	assert.Same(t, name, tag.Signatures[0].Key)
+	assert.Nil(t, tag.Verify(resolver))
+	assert.ErrorIs(t, tag.Verify(PKIXKeyResolver), tags.ErrBadTagFormat)
+	name.Payload = "signer-2"
+	assert.ErrorIs(t, tag.Verify(resolver), io.ErrUnexpectedEOF)
+}
+
+func TestSignedPayload_Detached(t *testing.T) {
+	ed := testEd25519Key()
+	p256 := testECDSAKey(t, elliptic.P256())
+	external := impl.MustParseTag(`array[str("a"), str("b")]`)
+
+	tag := NewSignedTag(1000)
+	require.Nil(t, tag.SignDetached(external, rand.Reader, ed, SigEd25519, nil))
+	require.Nil(t, tag.SignDetached(external, rand.Reader, p256, SigECDSASHA256, nil))
+	assert.Nil(t, tag.VerifyDetached(external, PKIXKeyResolver))
+	assert.ErrorIs(t, tag.Verify(PKIXKeyResolver), ErrNoInnerTag)
+	assert.ErrorIs(t, tag.VerifyDetached(impl.MustParseTag(`str("a")`), PKIXKeyResolver), ErrInvalidSignature)
+
+	// The inner tag is serialized as null
+	restored := roundTripSignedTag(t, tag)
+	assert.Nil(t, restored.Inner)
+	assert.Nil(t, restored.VerifyDetached(external, PKIXKeyResolver))
+
+	// Detached signatures are valid for embedded tags
+	restored.Inner = external
+	assert.Nil(t, restored.Verify(PKIXKeyResolver))
+	restored = roundTripSignedTag(t, restored)
+	assert.Nil(t, restored.Verify(PKIXKeyResolver))
+
+	assert.ErrorIs(t, NewSignedTag(1000).VerifyDetached(external, PKIXKeyResolver), ErrNoSignatures)
+}
+
+func TestSignedPayload_ValueSize(t *testing.T) {
+	var p SignedPayload
+	assert.Equal(t, uint64(2), p.ValueSize())
+
+	p.Inner = impl.MustParseTag(`str("abc")`)
+	assert.Equal(t, uint64(5+1), p.ValueSize())
+
+	p.Signatures = []Signature{
+		{Algorithm: SigEd25519, Key: impl.MustParseTag(`str("k")`), Signature: []byte{1, 2}},
+		{Algorithm: 1000},
+	}
+	assert.Equal(t, uint64(5+1+(1+3+4)+(3+1+2)), p.ValueSize())
+}
+
+func TestSignedPayload_SerializeValue(t *testing.T) {
+	var p SignedPayload
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, p.SerializeValue(w))
+	assert.Equal(t, []byte{0x00, 0x00}, w.Bytes())
+
+	p.Inner = impl.MustParseTag(`u8(1)`)
+	p.Signatures = []Signature{
+		{Algorithm: SigEd25519, Key: impl.MustParseTag(`str("k")`), Signature: []byte{1, 2}},
+		{Algorithm: SigECDSASHA256},
+	}
+	w = bytes.NewBuffer(nil)
+	require.Nil(t, p.SerializeValue(w))
+	bin := []byte{
+		0x03, 0x01,
+		0x02,
+		0x01, 0x11, 0x01, 'k', 0x10, 0x02, 0x01, 0x02,
+		0x02, 0x00, 0x10, 0x00}
+	assert.Equal(t, bin, w.Bytes())
+	assert.Equal(t, uint64(len(bin)), p.ValueSize())
+
+	for limit := 0; limit < len(bin); limit++ {
+		assert.ErrorIs(t, p.SerializeValue(&DummyWriter{limit}), io.ErrShortWrite)
+	}
+}
+
+func TestSignedPayload_DeserializeValue(t *testing.T) {
+	factory := impl.NewStandardTagFactory(true)
+	bin := []byte{
+		0x03, 0x01,
+		0x02,
+		0x01, 0x11, 0x01, 'k', 0x10, 0x02, 0x01, 0x02,
+		0x02, 0x00, 0x10, 0x00}
+
+	var p SignedPayload
+	require.Nil(t, p.DeserializeValue(factory, len(bin), bytes.NewReader(bin)))
+	assert.Equal(t, impl.MustParseTag(`u8(1)`), p.Inner)
+	require.Len(t, p.Signatures, 2)
+	assert.Equal(t, SigEd25519, p.Signatures[0].Algorithm)
+	assert.Equal(t, impl.MustParseTag(`str("k")`), p.Signatures[0].Key)
+	assert.Equal(t, []byte{1, 2}, p.Signatures[0].Signature)
+	assert.Equal(t, SigECDSASHA256, p.Signatures[1].Algorithm)
+	assert.Equal(t, impl.NewStdNullTag(), p.Signatures[1].Key)
+	assert.Empty(t, p.Signatures[1].Signature)
+
+	// Detached
+	p = SignedPayload{}
+	require.Nil(t, p.DeserializeValue(factory, 2, bytes.NewReader([]byte{0x00, 0x00})))
+	assert.Nil(t, p.Inner)
+	assert.Empty(t, p.Signatures)
+
+	// Truncated
+	for size := 0; size < len(bin); size++ {
+		p = SignedPayload{}
+		assert.Error(t, p.DeserializeValue(factory, size, bytes.NewReader(bin)), size)
+		assert.Nil(t, p.Inner)
+	}
+
+	// Trailing bytes
+	p = SignedPayload{}
+	ext := append(append([]byte{}, bin...), 0x00)
+	assert.ErrorIs(t, p.DeserializeValue(factory, len(ext), bytes.NewReader(ext)), tags.ErrBadTagFormat)
+
+	// Too many signatures
+	assert.ErrorIs(t, p.DeserializeValue(factory, 5, bytes.NewReader([]byte{0x00, 0x02, 0x01, 0x00, 0x10})),
+		tags.ErrBadTagFormat)
+
+	// The signature must be a bytes tag
+	bad := []byte{0x00, 0x01, 0x01, 0x00, 0x11, 0x00}
+	assert.ErrorIs(t, p.DeserializeValue(factory, len(bad), bytes.NewReader(bad)), tags.ErrUnexpectedTagId)
+
+	// Unknown tags
+	bad = []byte{0x00, 0x01, 0x01, 0xf8, 0x07, 0x00, 0x10, 0x00}
+	assert.Error(t, p.DeserializeValue(factory, len(bad), bytes.NewReader(bad)))
+}
+
+func TestNewSignedTag(t *testing.T) {
+	tag := NewSignedTag(1000)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+	assert.Nil(t, tag.Inner)
+	assert.Empty(t, tag.Signatures)
+
+	assert.Panics(t, func() { NewSignedTag(tags.IMPLICIT_ID_MAX) })
+}
@@ -134,7 +134,55 @@ func (i TagID) Reserved() bool {
 	return i <= RESERVED_ID_MAX
 }
 
+/*
+Returns the size of the payload of the implicit tags with fixed sizes. It
+returns -1 for explicit tags and for implicit tags with variable sizes, like the
+ILInt tags.
+*/
+func (i TagID) ImplicitPayloadSize() int {
+	return implicitPayloadSize(i)
+}
+
 // Returns the Id as an uint64.
 func (i TagID) UInt64() uint64 {
 	return uint64(i)
 }
+
+// Names of the standard tags indexed by their IDs.
+var stdTagNames = map[TagID]string{
+	IL_NULL_TAG_ID:              "Null",
+	IL_BOOL_TAG_ID:              "Bool",
+	IL_INT8_TAG_ID:              "Int8",
+	IL_UINT8_TAG_ID:             "UInt8",
+	IL_INT16_TAG_ID:             "Int16",
+	IL_UINT16_TAG_ID:            "UInt16",
+	IL_INT32_TAG_ID:             "Int32",
+	IL_UINT32_TAG_ID:            "UInt32",
+	IL_INT64_TAG_ID:             "Int64",
+	IL_UINT64_TAG_ID:            "UInt64",
+	IL_ILINT_TAG_ID:             "ILInt",
+	IL_BIN32_TAG_ID:             "Binary32",
+	IL_BIN64_TAG_ID:             "Binary64",
+	IL_BIN128_TAG_ID:            "Binary128",
+	IL_SIGNED_ILINT_TAG_ID:      "SignedILInt",
+	IL_BYTES_TAG_ID:             "Bytes",
+	IL_STRING_TAG_ID:            "String",
+	IL_BINT_TAG_ID:              "BigInteger",
+	IL_BDEC_TAG_ID:              "BigDecimal",
+	IL_ILINTARRAY_TAG_ID:        "ILIntArray",
+	IL_ILTAGARRAY_TAG_ID:        "ILTagArray",
+	IL_ILTAGSEQ_TAG_ID:          "ILTagSequence",
+	IL_RANGE_TAG_ID:             "Range",
+	IL_VERSION_TAG_ID:           "Version",
+	IL_OID_TAG_ID:               "OID",
+	IL_DICTIONARY_TAG_ID:        "Dictionary",
+	IL_STRING_DICTIONARY_TAG_ID: "StringDictionary",
+}
+
+/*
+Returns the name of the standard tag associated with this ID. It returns an
+empty string if the ID is not assigned to a standard tag.
+*/
+func (i TagID) StdName() string {
+	return stdTagNames[i]
+}
@@ -90,3 +90,27 @@ func TestTagID(t *testing.T) {
 	assert.False(t, id.Reserved())
 	assert.Equal(t, uint64(32), id.UInt64())
 }
+
+func TestTagID_StdName(t *testing.T) {
+	assert.Equal(t, "Null", IL_NULL_TAG_ID.StdName())
+	assert.Equal(t, "SignedILInt", IL_SIGNED_ILINT_TAG_ID.StdName())
+	assert.Equal(t, "String", IL_STRING_TAG_ID.StdName())
+	assert.Equal(t, "StringDictionary", IL_STRING_DICTIONARY_TAG_ID.StdName())
+	assert.Equal(t, "", TagID(15).StdName())
+	assert.Equal(t, "", TagID(26).StdName())
+	assert.Equal(t, "", TagID(1000).StdName())
+	for id := TagID(0); id < 32; id++ {
+		if implicitPayloadSize(id) >= 0 || id == IL_ILINT_TAG_ID || id == IL_SIGNED_ILINT_TAG_ID {
+			assert.NotEmpty(t, id.StdName())
+		}
+	}
+}
+
+func TestTagID_ImplicitPayloadSize(t *testing.T) {
+	for id := TagID(0); id < 32; id++ {
+		assert.Equal(t, implicitPayloadSize(id), id.ImplicitPayloadSize())
+	}
+	assert.Equal(t, 16, IL_BIN128_TAG_ID.ImplicitPayloadSize())
+	assert.Equal(t, -1, IL_ILINT_TAG_ID.ImplicitPayloadSize())
+	assert.Equal(t, -1, TagID(1000).ImplicitPayloadSize())
+}
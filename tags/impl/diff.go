@@ -0,0 +1,195 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"fmt"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Type of a Change.
+*/
+type ChangeOp int
+
+const (
+	// The tag at the path was replaced.
+	ChangeReplace ChangeOp = iota
+	// A new tag was inserted at the path.
+	ChangeInsert
+	// The tag at the path was removed.
+	ChangeDelete
+)
+
+// Names of the operations.
+var changeOpNames = []string{"replace", "insert", "delete"}
+
+// Implementation of fmt.Stringer.
+func (op ChangeOp) String() string {
+	if op < 0 || int(op) >= len(changeOpNames) {
+		return fmt.Sprintf("ChangeOp(%d)", int(op))
+	}
+	return changeOpNames[op]
+}
+
+/*
+Change describes a single difference between two tag trees.
+*/
+type Change struct {
+	// The operation.
+	Op ChangeOp
+	// Location of the change.
+	Path tags.Path
+	// The tag found in the original tree. It is nil for insertions.
+	Old tags.ILTag
+	// The tag found in the new tree. It is nil for deletions.
+	New tags.ILTag
+}
+
+// Implementation of fmt.Stringer. Tags are represented in the text notation.
+func (c Change) String() string {
+	switch c.Op {
+	case ChangeInsert:
+		return fmt.Sprintf("%s %s: %s", c.Op, c.Path, FormatTag(c.New))
+	case ChangeDelete:
+		return fmt.Sprintf("%s %s: %s", c.Op, c.Path, FormatTag(c.Old))
+	default:
+		return fmt.Sprintf("%s %s: %s -> %s", c.Op, c.Path, FormatTag(c.Old),
+			FormatTag(c.New))
+	}
+}
+
+/*
+Returns true if the keys of a that are also in b appear in the same order in
+both dictionaries and all keys of b that are not in a are after them. It means
+that b can be obtained from a by replacing, removing and appending entries.
+*/
+func compatibleKeys(a, b *DictionaryTag) bool {
+	i := 0
+	keys := b.Map.Keys()
+	for _, k := range a.Map.Keys() {
+		if _, ok := b.Map.Get(k); ok {
+			if keys[i] != k {
+				return false
+			}
+			i++
+		}
+	}
+	for _, k := range keys[i:] {
+		if _, ok := a.Map.Get(k); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Computes the differences between two lists of tags.
+func diffList(path tags.Path, a, b []tags.ILTag, changes []Change) []Change {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		changes = diff(path.Index(i), a[i], b[i], changes)
+	}
+	// Removes from the end in order to keep the indexes valid
+	for i := len(a) - 1; i >= n; i-- {
+		changes = append(changes, Change{Op: ChangeDelete, Path: path.Index(i), Old: a[i]})
+	}
+	for i := n; i < len(b); i++ {
+		changes = append(changes, Change{Op: ChangeInsert, Path: path.Index(i), New: b[i]})
+	}
+	return changes
+}
+
+// Computes the differences between two compatible dictionaries.
+func diffDictionary(path tags.Path, a, b *DictionaryTag, changes []Change) []Change {
+	for _, e := range a.Map.Entries() {
+		if v, ok := b.Map.Get(e.Key); ok {
+			changes = diff(path.Key(e.Key), e.Value, v, changes)
+		} else {
+			changes = append(changes, Change{Op: ChangeDelete, Path: path.Key(e.Key), Old: e.Value})
+		}
+	}
+	for _, e := range b.Map.Entries() {
+		if _, ok := a.Map.Get(e.Key); !ok {
+			changes = append(changes, Change{Op: ChangeInsert, Path: path.Key(e.Key), New: e.Value})
+		}
+	}
+	return changes
+}
+
+// Computes the differences between two tags.
+func diff(path tags.Path, a, b tags.ILTag, changes []Change) []Change {
+	if EqualTags(a, b) {
+		return changes
+	}
+	if !tags.IsILTagNil(a) && !tags.IsILTagNil(b) && a.Id() == b.Id() {
+		switch ta := a.(type) {
+		case *ILTagArrayTag:
+			if tb, ok := b.(*ILTagArrayTag); ok {
+				return diffList(path, ta.Payload, tb.Payload, changes)
+			}
+		case *ILTagSequenceTag:
+			if tb, ok := b.(*ILTagSequenceTag); ok {
+				return diffList(path, ta.Payload, tb.Payload, changes)
+			}
+		case *DictionaryTag:
+			if tb, ok := b.(*DictionaryTag); ok && compatibleKeys(ta, tb) {
+				return diffDictionary(path, ta, tb, changes)
+			}
+		}
+	}
+	return append(changes, Change{Op: ChangeReplace, Path: path, Old: a, New: b})
+}
+
+/*
+Returns the list of changes required to transform the tag tree a into the tag
+tree b. Applying the changes to a with Apply() produces a tree with the same
+serialization of b.
+
+The comparison descends into DictionaryTags, ILTagArrayTags and
+ILTagSequenceTags that have the same ID in both trees. Elements of arrays and
+sequences are compared by their positions, thus removed elements are reported
+from the end of the list and new elements are appended to it. Dictionaries are
+compared by their keys as long as the common keys appear in the same order in
+both trees; otherwise the whole dictionary is replaced to preserve the order of
+the entries. All other tags are compared by their serializations.
+
+It returns an empty list if both trees are equal. The returned changes share
+the tags of both trees.
+*/
+func Diff(a, b tags.ILTag) []Change {
+	return diff(nil, a, b, []Change{})
+}
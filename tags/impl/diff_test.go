@@ -0,0 +1,148 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeOp_String(t *testing.T) {
+	assert.Equal(t, "replace", ChangeReplace.String())
+	assert.Equal(t, "insert", ChangeInsert.String())
+	assert.Equal(t, "delete", ChangeDelete.String())
+	assert.Equal(t, "ChangeOp(3)", ChangeOp(3).String())
+	assert.Equal(t, "ChangeOp(-1)", ChangeOp(-1).String())
+}
+
+func TestChange_String(t *testing.T) {
+	path := tags.Path{}.Key("a").Index(1)
+	assert.Equal(t, "replace $.a[1]: u8(1) -> null", Change{Op: ChangeReplace,
+		Path: path, Old: MustParseTag("u8(1)"), New: NewStdNullTag()}.String())
+	assert.Equal(t, `insert $.a[1]: str("x")`, Change{Op: ChangeInsert,
+		Path: path, New: MustParseTag(`str("x")`)}.String())
+	assert.Equal(t, "delete $: u8(1)", Change{Op: ChangeDelete,
+		Old: MustParseTag("u8(1)")}.String())
+}
+
+// Returns the string representation of the changes.
+func changeStrings(changes []Change) []string {
+	l := make([]string, 0, len(changes))
+	for _, c := range changes {
+		l = append(l, c.String())
+	}
+	return l
+}
+
+func TestDiff(t *testing.T) {
+	samples := []struct {
+		a, b     string
+		expected []string
+	}{
+		{"u8(1)", "u8(1)", []string{}},
+		{"u8(1)", "u8(2)", []string{"replace $: u8(1) -> u8(2)"}},
+		{"u8(1)", "i8(1)", []string{"replace $: u8(1) -> i8(1)"}},
+		{`#1000 bytes(0x01)`, `#1000 u8(1)`, []string{}},
+		{"array[u8(1)]", "seq[u8(1)]", []string{"replace $: array[u8(1)] -> seq[u8(1)]"}},
+		{"array[u8(1)]", "#1000 array[u8(1)]", []string{"replace $: array[u8(1)] -> #1000 array[u8(1)]"}},
+		{
+			"array[u8(1), u8(2), u8(3)]",
+			"array[u8(1), u8(5)]",
+			[]string{"replace $[1]: u8(2) -> u8(5)", "delete $[2]: u8(3)"},
+		},
+		{
+			"seq[u8(1)]",
+			"seq[u8(2), u8(3), u8(4)]",
+			[]string{"replace $[0]: u8(1) -> u8(2)", "insert $[1]: u8(3)", "insert $[2]: u8(4)"},
+		},
+		{
+			"seq[u8(1), u8(2), u8(3)]",
+			"seq[]",
+			[]string{"delete $[2]: u8(3)", "delete $[1]: u8(2)", "delete $[0]: u8(1)"},
+		},
+		{
+			`dict{"payload": dict{"signatures": array[dict{"key": bytes(0x01)}]}, "id": u8(1)}`,
+			`dict{"payload": dict{"signatures": array[dict{"key": bytes(0x02)}]}, "id": u8(1)}`,
+			[]string{`replace $.payload.signatures[0].key: bytes(0x01) -> bytes(0x02)`},
+		},
+		{
+			`dict{"a": u8(1), "b": u8(2), "c": u8(3)}`,
+			`dict{"a": u8(1), "c": u8(4), "d e": null}`,
+			[]string{`delete $.b: u8(2)`, `replace $.c: u8(3) -> u8(4)`, `insert $["d e"]: null`},
+		},
+		{
+			// The order of the keys changed
+			`dict{"a": u8(1), "b": u8(2)}`,
+			`dict{"b": u8(2), "a": u8(1)}`,
+			[]string{`replace $: dict{"a": u8(1), "b": u8(2)} -> dict{"b": u8(2), "a": u8(1)}`},
+		},
+		{
+			// A new key was inserted before an existing one
+			`dict{"a": u8(1)}`,
+			`dict{"b": u8(2), "a": u8(1)}`,
+			[]string{`replace $: dict{"a": u8(1)} -> dict{"b": u8(2), "a": u8(1)}`},
+		},
+		{
+			`array[seq[null, dict{"x": array[]}]]`,
+			`array[seq[null, dict{"x": array[u8(1)]}]]`,
+			[]string{`insert $[0][1].x[0]: u8(1)`},
+		},
+		{
+			`strdict{"a": "b"}`,
+			`strdict{"a": "c"}`,
+			[]string{`replace $: strdict{"a": "b"} -> strdict{"a": "c"}`},
+		},
+	}
+	for _, s := range samples {
+		a := MustParseTag(s.a)
+		b := MustParseTag(s.b)
+		changes := Diff(a, b)
+		assert.Equal(t, s.expected, changeStrings(changes), "%s -> %s", s.a, s.b)
+
+		// The patch must produce b
+		patched, err := Apply(a, changes)
+		require.Nil(t, err, "%s -> %s", s.a, s.b)
+		assert.True(t, EqualTags(b, patched), "%s -> %s", s.a, s.b)
+		assert.Equal(t, s.a, FormatTag(a))
+	}
+
+	// Nil tags
+	assert.Equal(t, []Change{}, Diff(nil, NewStdNullTag()))
+	assert.Equal(t, []string{"replace $: null -> u8(1)"},
+		changeStrings(Diff(nil, MustParseTag("u8(1)"))))
+	assert.Equal(t, []string{"replace $: u8(1) -> null"},
+		changeStrings(Diff(MustParseTag("u8(1)"), nil)))
+}
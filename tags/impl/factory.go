@@ -39,6 +39,13 @@ import (
 // This is the type of the common interface for all ILTag creators.
 type TagCreatorFunc func(tags.TagID) tags.ILTag
 
+// Wraps a typed tag constructor, such as NewStringTag, into a TagCreatorFunc.
+func TagCreator[T tags.ILTag](f func(tags.TagID) T) TagCreatorFunc {
+	return func(id tags.TagID) tags.ILTag {
+		return f(id)
+	}
+}
+
 // Standard tag factory. At general, instances of this struct can be considred
 // thread safe if if is acessed ILTag
 type StandardTagFactory struct {
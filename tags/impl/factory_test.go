@@ -61,6 +61,13 @@ func TestNewStandardTagFactory(t *testing.T) {
 	assert.Nil(t, f.tagCreators)
 }
 
+func TestTagCreator(t *testing.T) {
+	c := TagCreator(NewStringTag)
+	tag := c(1234)
+	assert.IsType(t, &StringTag{}, tag)
+	assert.Equal(t, TagID(1234), tag.Id())
+}
+
 func TestStandardTagFactoryRegisterTag(t *testing.T) {
 	for _, strict := range []bool{false, true} {
 		var cf TagCreatorFunc
@@ -0,0 +1,929 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// Error returned when the text does not follow the tag text notation.
+var ErrBadNotation = fmt.Errorf("invalid tag notation")
+
+// Names used by the text notation.
+const (
+	notationNull        = "null"
+	notationBool        = "bool"
+	notationInt8        = "i8"
+	notationUInt8       = "u8"
+	notationInt16       = "i16"
+	notationUInt16      = "u16"
+	notationInt32       = "i32"
+	notationUInt32      = "u32"
+	notationInt64       = "i64"
+	notationUInt64      = "u64"
+	notationILInt       = "ilint"
+	notationFloat32     = "f32"
+	notationFloat64     = "f64"
+	notationFloat128    = "f128"
+	notationSignedILInt = "silint"
+	notationBytes       = "bytes"
+	notationString      = "str"
+	notationBigInt      = "bigint"
+	notationBigDec      = "bigdec"
+	notationILIntArray  = "ilints"
+	notationArray       = "array"
+	notationSequence    = "seq"
+	notationRange       = "range"
+	notationVersion     = "version"
+	notationOID         = "oid"
+	notationDictionary  = "dict"
+	notationStrDict     = "strdict"
+)
+
+// Standard tag ID associated with each name.
+var notationIds = map[string]tags.TagID{
+	notationNull:        tags.IL_NULL_TAG_ID,
+	notationBool:        tags.IL_BOOL_TAG_ID,
+	notationInt8:        tags.IL_INT8_TAG_ID,
+	notationUInt8:       tags.IL_UINT8_TAG_ID,
+	notationInt16:       tags.IL_INT16_TAG_ID,
+	notationUInt16:      tags.IL_UINT16_TAG_ID,
+	notationInt32:       tags.IL_INT32_TAG_ID,
+	notationUInt32:      tags.IL_UINT32_TAG_ID,
+	notationInt64:       tags.IL_INT64_TAG_ID,
+	notationUInt64:      tags.IL_UINT64_TAG_ID,
+	notationILInt:       tags.IL_ILINT_TAG_ID,
+	notationFloat32:     tags.IL_BIN32_TAG_ID,
+	notationFloat64:     tags.IL_BIN64_TAG_ID,
+	notationFloat128:    tags.IL_BIN128_TAG_ID,
+	notationSignedILInt: tags.IL_SIGNED_ILINT_TAG_ID,
+	notationBytes:       tags.IL_BYTES_TAG_ID,
+	notationString:      tags.IL_STRING_TAG_ID,
+	notationBigInt:      tags.IL_BINT_TAG_ID,
+	notationBigDec:      tags.IL_BDEC_TAG_ID,
+	notationILIntArray:  tags.IL_ILINTARRAY_TAG_ID,
+	notationArray:       tags.IL_ILTAGARRAY_TAG_ID,
+	notationSequence:    tags.IL_ILTAGSEQ_TAG_ID,
+	notationRange:       tags.IL_RANGE_TAG_ID,
+	notationVersion:     tags.IL_VERSION_TAG_ID,
+	notationOID:         tags.IL_OID_TAG_ID,
+	notationDictionary:  tags.IL_DICTIONARY_TAG_ID,
+	notationStrDict:     tags.IL_STRING_DICTIONARY_TAG_ID,
+}
+
+//------------------------------------------------------------------------------
+
+/*
+Text notation printer.
+*/
+type notationPrinter struct {
+	sb strings.Builder
+}
+
+// Writes the header of the tag. The ID is written only if it is not stdId.
+func (p *notationPrinter) header(tag tags.ILTag, name string) {
+	if tag.Id() != notationIds[name] {
+		fmt.Fprintf(&p.sb, "#%d ", tag.Id())
+	}
+	p.sb.WriteString(name)
+}
+
+// Writes a tag with a single argument.
+func (p *notationPrinter) single(tag tags.ILTag, name string, arg string) {
+	p.header(tag, name)
+	p.sb.WriteByte('(')
+	p.sb.WriteString(arg)
+	p.sb.WriteByte(')')
+}
+
+// Formats a floating point value.
+func formatNotationFloat(v float64, bits int) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, bits)
+	}
+}
+
+// Formats a byte array as an hexadecimal literal.
+func formatNotationBytes(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return "0x" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+/*
+Formats a big decimal. Positive scales are represented by the position of the
+decimal point while negative scales are represented by the exponent.
+*/
+func formatNotationBigDec(unscaled *big.Int, scale int32) string {
+	s := unscaled.String()
+	if scale <= 0 {
+		if scale == 0 {
+			return s
+		}
+		return s + "E" + strconv.FormatInt(-int64(scale), 10)
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+	if n := int(scale) + 1 - len(s); n > 0 {
+		s = strings.Repeat("0", n) + s
+	}
+	i := len(s) - int(scale)
+	return sign + s[:i] + "." + s[i:]
+}
+
+// Writes a list of uint64.
+func (p *notationPrinter) uints(tag tags.ILTag, name string, l []uint64) {
+	p.header(tag, name)
+	p.sb.WriteByte('[')
+	for i, v := range l {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.sb.WriteString(strconv.FormatUint(v, 10))
+	}
+	p.sb.WriteByte(']')
+}
+
+// Writes a list of tags.
+func (p *notationPrinter) list(tag tags.ILTag, name string, l []tags.ILTag) {
+	p.header(tag, name)
+	p.sb.WriteByte('[')
+	for i, v := range l {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.tag(v)
+	}
+	p.sb.WriteByte(']')
+}
+
+// Writes a tag.
+func (p *notationPrinter) tag(tag tags.ILTag) {
+	if tags.IsILTagNil(tag) {
+		p.sb.WriteString(notationNull)
+		return
+	}
+	switch t := tag.(type) {
+	case *NullTag:
+		p.header(t, notationNull)
+	case *BoolTag:
+		p.single(t, notationBool, strconv.FormatBool(t.Payload))
+	case *Int8Tag:
+		p.single(t, notationInt8, strconv.FormatInt(int64(t.Payload), 10))
+	case *UInt8Tag:
+		p.single(t, notationUInt8, strconv.FormatUint(uint64(t.Payload), 10))
+	case *Int16Tag:
+		p.single(t, notationInt16, strconv.FormatInt(int64(t.Payload), 10))
+	case *UInt16Tag:
+		p.single(t, notationUInt16, strconv.FormatUint(uint64(t.Payload), 10))
+	case *Int32Tag:
+		p.single(t, notationInt32, strconv.FormatInt(int64(t.Payload), 10))
+	case *UInt32Tag:
+		p.single(t, notationUInt32, strconv.FormatUint(uint64(t.Payload), 10))
+	case *Int64Tag:
+		p.single(t, notationInt64, strconv.FormatInt(t.Payload, 10))
+	case *UInt64Tag:
+		p.single(t, notationUInt64, strconv.FormatUint(t.Payload, 10))
+	case *ILIntTag:
+		p.single(t, notationILInt, strconv.FormatUint(t.Payload, 10))
+	case *SignedILIntTag:
+		p.single(t, notationSignedILInt, strconv.FormatInt(t.Payload, 10))
+	case *Float32Tag:
+		p.single(t, notationFloat32, formatNotationFloat(float64(t.Payload), 32))
+	case *Float64Tag:
+		p.single(t, notationFloat64, formatNotationFloat(t.Payload, 64))
+	case *Float128Tag:
+		p.single(t, notationFloat128, formatNotationBytes(t.Payload[:]))
+	case *tags.RawTag:
+		p.single(t, notationBytes, formatNotationBytes(t.Payload))
+	case *StringTag:
+		p.single(t, notationString, strconv.Quote(t.Payload))
+	case *BigIntTag:
+		p.single(t, notationBigInt, t.BigInt().String())
+	case *BigDecTag:
+		p.single(t, notationBigDec, formatNotationBigDec(t.BigInt(), t.Scale))
+	case *ILIntArrayTag:
+		if t.Id() == tags.IL_OID_TAG_ID {
+			p.uints(t, notationOID, t.Payload)
+		} else {
+			p.uints(t, notationILIntArray, t.Payload)
+		}
+	case *ILTagArrayTag:
+		p.list(t, notationArray, t.Payload)
+	case *ILTagSequenceTag:
+		p.list(t, notationSequence, t.Payload)
+	case *RangeTag:
+		p.single(t, notationRange, fmt.Sprintf("%d, %d", t.Start, t.Count))
+	case *VersionTag:
+		p.single(t, notationVersion, fmt.Sprintf("%d, %d, %d, %d", t.Major,
+			t.Minor, t.Revision, t.Build))
+	case *DictionaryTag:
+		p.header(t, notationDictionary)
+		p.sb.WriteByte('{')
+		for i, e := range t.Map.Entries() {
+			if i > 0 {
+				p.sb.WriteString(", ")
+			}
+			p.sb.WriteString(strconv.Quote(e.Key))
+			p.sb.WriteString(": ")
+			p.tag(e.Value)
+		}
+		p.sb.WriteByte('}')
+	case *StringDictionaryTag:
+		p.header(t, notationStrDict)
+		p.sb.WriteByte('{')
+		for i, e := range t.Map.Entries() {
+			if i > 0 {
+				p.sb.WriteString(", ")
+			}
+			fmt.Fprintf(&p.sb, "%q: %q", e.Key, e.Value)
+		}
+		p.sb.WriteByte('}')
+	default:
+		var b bytes.Buffer
+		if err := tag.SerializeValue(&b); err != nil {
+			fmt.Fprintf(&p.sb, "#%d error(%q)", tag.Id(), err.Error())
+		} else {
+			fmt.Fprintf(&p.sb, "#%d %s(%s)", tag.Id(), notationBytes,
+				formatNotationBytes(b.Bytes()))
+		}
+	}
+}
+
+/*
+Returns the text notation of the given tag. The text notation is a compact
+human readable representation of tags. Each tag is represented by the name of
+its type followed by its payload:
+
+	null                      NullTag
+	bool(true)                BoolTag
+	i8(-1) u8(1)              Int8Tag and UInt8Tag
+	i16(-1) u16(1)            Int16Tag and UInt16Tag
+	i32(-1) u32(1)            Int32Tag and UInt32Tag
+	i64(-1) u64(1)            Int64Tag and UInt64Tag
+	ilint(1) silint(-1)       ILIntTag and SignedILIntTag
+	f32(1.5) f64(-Inf)        Float32Tag and Float64Tag, including NaN, +Inf and -Inf
+	f128(0x00...00)           Float128Tag with 16 bytes in hexadecimal
+	bytes(0xCAFE) bytes()     BytesTag
+	str("text")               StringTag, using the Go string syntax
+	bigint(-123)              BigIntTag
+	bigdec(1.23) bigdec(1E3)  BigDecTag, scale set by the decimal point or exponent
+	ilints[1, 2] oid[1, 3]    ILIntArrayTag and OIDTag
+	array[...] seq[...]       ILTagArrayTag and ILTagSequenceTag
+	range(10, 2)              RangeTag with start and count
+	version(1, 2, 3, 4)       VersionTag with major, minor, revision and build
+	dict{"a": u8(1)}          DictionaryTag
+	strdict{"a": "b"}         StringDictionaryTag
+
+Tags with non standard IDs are prefixed by # followed by their IDs, as in
+`#1000 str("x")`. Tags that are not implemented by this package, including
+the RawTags created for unknown tag IDs, are represented by their payloads, as
+in `#1000 bytes(0xDEAD)`. Since OIDTag and ILIntArrayTag share the same payload,
+only the standard OID tag ID is represented as oid.
+
+Go style comments are allowed by the parser.
+
+Tags that cannot be serialized are represented as `#<id> error("<message>")`,
+which cannot be parsed back.
+*/
+func FormatTag(tag tags.ILTag) string {
+	var p notationPrinter
+	p.tag(tag)
+	return p.sb.String()
+}
+
+//------------------------------------------------------------------------------
+
+/*
+Text notation parser. It uses a text/scanner.Scanner to split the source into
+tokens.
+*/
+type notationParser struct {
+	s    scanner.Scanner
+	tok  rune
+	text string
+	err  error
+}
+
+// Reads the next token.
+func (p *notationParser) next() {
+	p.tok = p.s.Scan()
+	p.text = p.s.TokenText()
+}
+
+// Returns an error at the current position.
+func (p *notationParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s: %s: %w", p.s.Position, fmt.Sprintf(format, args...),
+		ErrBadNotation)
+}
+
+// Consumes the given token or fails.
+func (p *notationParser) expect(text string) error {
+	if p.text != text {
+		return p.errorf("expected %q, found %q", text, p.text)
+	}
+	p.next()
+	return nil
+}
+
+// Consumes a number, including its sign, and returns its text.
+func (p *notationParser) number() (string, error) {
+	sign := ""
+	if p.text == "-" || p.text == "+" {
+		sign = p.text
+		p.next()
+	}
+	switch {
+	case p.tok == scanner.Int || p.tok == scanner.Float:
+	case p.tok == scanner.Ident && (p.text == "Inf" || (p.text == "NaN" && sign == "")):
+	default:
+		return "", p.errorf("expected number, found %q", p.text)
+	}
+	s := sign + p.text
+	p.next()
+	return s, nil
+}
+
+// Parses a signed integer.
+func (p *notationParser) int(bits int) (int64, error) {
+	s, err := p.number()
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", s)
+	}
+	return v, nil
+}
+
+// Parses an unsigned integer.
+func (p *notationParser) uint(bits int) (uint64, error) {
+	s, err := p.number()
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", s)
+	}
+	return v, nil
+}
+
+// Parses a floating point value.
+func (p *notationParser) float(bits int) (float64, error) {
+	s, err := p.number()
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, p.errorf("invalid floating point %q", s)
+	}
+	return v, nil
+}
+
+// Parses a string literal.
+func (p *notationParser) string() (string, error) {
+	if p.tok != scanner.String && p.tok != scanner.RawString {
+		return "", p.errorf("expected string, found %q", p.text)
+	}
+	s, err := strconv.Unquote(p.text)
+	if err != nil {
+		return "", p.errorf("invalid string %s", p.text)
+	}
+	p.next()
+	return s, nil
+}
+
+// Parses an hexadecimal byte array. It may be empty.
+func (p *notationParser) bytes() ([]byte, error) {
+	if p.text == ")" {
+		return []byte{}, nil
+	}
+	if p.tok != scanner.Int || !(strings.HasPrefix(p.text, "0x") ||
+		strings.HasPrefix(p.text, "0X")) {
+		return nil, p.errorf("expected hexadecimal bytes, found %q", p.text)
+	}
+	b, err := hex.DecodeString(p.text[2:])
+	if err != nil {
+		return nil, p.errorf("invalid hexadecimal bytes %q", p.text)
+	}
+	p.next()
+	return b, nil
+}
+
+// Parses a big decimal.
+func (p *notationParser) bigDec() (*big.Int, int32, error) {
+	s, err := p.number()
+	if err != nil {
+		return nil, 0, err
+	}
+	digits := s
+	var scale int64
+	if i := strings.IndexAny(digits, "eE"); i >= 0 {
+		exp, err := strconv.ParseInt(digits[i+1:], 10, 32)
+		if err != nil || exp < 0 {
+			return nil, 0, p.errorf("invalid decimal %q", s)
+		}
+		scale = -exp
+		digits = digits[:i]
+	}
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		if scale != 0 {
+			return nil, 0, p.errorf("invalid decimal %q", s)
+		}
+		scale = int64(len(digits) - i - 1)
+		digits = digits[:i] + digits[i+1:]
+	}
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok || scale > math.MaxInt32 {
+		return nil, 0, p.errorf("invalid decimal %q", s)
+	}
+	return v, int32(scale), nil
+}
+
+// Parses a comma separated list until the closing token.
+func (p *notationParser) items(end string, item func() error) error {
+	for p.text != end {
+		if err := item(); err != nil {
+			return err
+		}
+		if p.text != end {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+	}
+	p.next()
+	return nil
+}
+
+// Parses a list of tags.
+func (p *notationParser) tags() ([]tags.ILTag, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	l := []tags.ILTag{}
+	err := p.items("]", func() error {
+		t, err := p.tag()
+		if err == nil {
+			l = append(l, t)
+		}
+		return err
+	})
+	return l, err
+}
+
+// Parses a list of unsigned integers.
+func (p *notationParser) uints() ([]uint64, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	l := []uint64{}
+	err := p.items("]", func() error {
+		v, err := p.uint(64)
+		if err == nil {
+			l = append(l, v)
+		}
+		return err
+	})
+	return l, err
+}
+
+// Parses the entries of a dictionary.
+func (p *notationParser) entries(value func(key string) error) error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	keys := make(map[string]bool)
+	return p.items("}", func() error {
+		key, err := p.string()
+		if err != nil {
+			return err
+		}
+		if keys[key] {
+			return p.errorf("duplicated key %q", key)
+		}
+		keys[key] = true
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		return value(key)
+	})
+}
+
+// Parses the arguments of the tag that has a payload between parenthesis.
+func (p *notationParser) args(tag tags.ILTag) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	var err error
+	switch t := tag.(type) {
+	case *BoolTag:
+		switch p.text {
+		case "true", "false":
+			t.Payload = p.text == "true"
+			p.next()
+		default:
+			err = p.errorf("expected boolean, found %q", p.text)
+		}
+	case *Int8Tag:
+		var v int64
+		v, err = p.int(8)
+		t.Payload = int8(v)
+	case *UInt8Tag:
+		var v uint64
+		v, err = p.uint(8)
+		t.Payload = uint8(v)
+	case *Int16Tag:
+		var v int64
+		v, err = p.int(16)
+		t.Payload = int16(v)
+	case *UInt16Tag:
+		var v uint64
+		v, err = p.uint(16)
+		t.Payload = uint16(v)
+	case *Int32Tag:
+		var v int64
+		v, err = p.int(32)
+		t.Payload = int32(v)
+	case *UInt32Tag:
+		var v uint64
+		v, err = p.uint(32)
+		t.Payload = uint32(v)
+	case *Int64Tag:
+		t.Payload, err = p.int(64)
+	case *UInt64Tag:
+		t.Payload, err = p.uint(64)
+	case *ILIntTag:
+		t.Payload, err = p.uint(64)
+	case *SignedILIntTag:
+		t.Payload, err = p.int(64)
+	case *Float32Tag:
+		var v float64
+		v, err = p.float(32)
+		t.Payload = float32(v)
+	case *Float64Tag:
+		t.Payload, err = p.float(64)
+	case *Float128Tag:
+		var b []byte
+		if b, err = p.bytes(); err == nil {
+			if len(b) != len(t.Payload) {
+				err = p.errorf("f128 requires %d bytes", len(t.Payload))
+			}
+			copy(t.Payload[:], b)
+		}
+	case *tags.RawTag:
+		t.Payload, err = p.bytes()
+	case *StringTag:
+		t.Payload, err = p.string()
+	case *BigIntTag:
+		var s string
+		if s, err = p.number(); err == nil {
+			v, ok := new(big.Int).SetString(s, 10)
+			if !ok {
+				err = p.errorf("invalid integer %q", s)
+			} else {
+				t.SetBigInt(v)
+			}
+		}
+	case *BigDecTag:
+		var v *big.Int
+		if v, t.Scale, err = p.bigDec(); err == nil {
+			t.SetBigInt(v)
+		}
+	case *RangeTag:
+		if t.Start, err = p.uint(64); err == nil {
+			if err = p.expect(","); err == nil {
+				var v uint64
+				v, err = p.uint(16)
+				t.Count = uint16(v)
+			}
+		}
+	case *VersionTag:
+		fields := []*int32{&t.Major, &t.Minor, &t.Revision, &t.Build}
+		for i, f := range fields {
+			if i > 0 {
+				if err = p.expect(","); err != nil {
+					break
+				}
+			}
+			var v int64
+			if v, err = p.int(32); err != nil {
+				break
+			}
+			*f = int32(v)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return p.expect(")")
+}
+
+// Parses a tag.
+func (p *notationParser) tag() (tags.ILTag, error) {
+	var id tags.TagID
+	hasId := false
+	if p.text == "#" {
+		p.next()
+		v, err := p.uint(64)
+		if err != nil {
+			return nil, err
+		}
+		id = tags.TagID(v)
+		hasId = true
+	}
+	if p.tok != scanner.Ident {
+		return nil, p.errorf("expected tag type, found %q", p.text)
+	}
+	name := p.text
+	stdId, ok := notationIds[name]
+	if !ok {
+		return nil, p.errorf("unknown tag type %q", name)
+	}
+	if !hasId {
+		id = stdId
+	} else if id != stdId && id.Reserved() {
+		return nil, p.errorf("the reserved tag id %d cannot be used by %s", id, name)
+	}
+	p.next()
+	var tag tags.ILTag
+	var err error
+	switch name {
+	case notationNull:
+		tag = NewNullTag(id)
+	case notationILIntArray, notationOID:
+		t := NewILIntArrayTag(id)
+		t.Payload, err = p.uints()
+		tag = t
+	case notationArray:
+		t := NewILTagArrayTag(id)
+		t.Payload, err = p.tags()
+		tag = t
+	case notationSequence:
+		t := NewILTagSequenceTag(id)
+		t.Payload, err = p.tags()
+		tag = t
+	case notationDictionary:
+		t := NewDictionaryTag(id)
+		err = p.entries(func(key string) error {
+			v, err := p.tag()
+			if err == nil {
+				t.Map.Put(key, v)
+			}
+			return err
+		})
+		tag = t
+	case notationStrDict:
+		t := NewStringDictionaryTag(id)
+		err = p.entries(func(key string) error {
+			v, err := p.string()
+			if err == nil {
+				t.Map.Put(key, v)
+			}
+			return err
+		})
+		tag = t
+	default:
+		tag, _ = NewStandardTag(stdId)
+		tag.(interface{ SetId(tags.TagID) }).SetId(id)
+		err = p.args(tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+/*
+Parses a tag represented in the text notation. See FormatTag() for details
+about the notation.
+*/
+func ParseTag(s string) (tags.ILTag, error) {
+	var p notationParser
+	p.s.Init(strings.NewReader(s))
+	p.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats |
+		scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments |
+		scanner.SkipComments
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = fmt.Errorf("%s: %s: %w", s.Position, msg, ErrBadNotation)
+		}
+	}
+	p.next()
+	tag, err := p.tag()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != scanner.EOF {
+		return nil, p.errorf("unexpected %q after the tag", p.text)
+	}
+	return tag, nil
+}
+
+/*
+Works like ParseTag() but panics if the text is invalid. It is meant to be used
+to create test fixtures and global variables, as in:
+
+	var sample = impl.MustParseTag(`dict{"a": u32(5), "b": array[str("x"), null]}`)
+*/
+func MustParseTag(s string) tags.ILTag {
+	tag, err := ParseTag(s)
+	if err != nil {
+		panic(err)
+	}
+	return tag
+}
+
+/*
+Parses a tag represented in the text notation and returns its serialization.
+It panics if the text is invalid. It is meant to be used to create test
+fixtures.
+*/
+func MustParseTagBytes(s string) []byte {
+	b, err := tags.ILTagToBytes(MustParseTag(s))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+//------------------------------------------------------------------------------
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *NullTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *BoolTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Int8Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *UInt8Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Int16Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *UInt16Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Int32Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *UInt32Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Int64Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *UInt64Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *ILIntTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *SignedILIntTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Float32Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Float64Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *Float128Tag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *StringTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *BigIntTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *BigDecTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *ILIntArrayTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *ILTagArrayTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *ILTagSequenceTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *RangeTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *VersionTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *StringDictionaryTag) String() string {
+	return FormatTag(t)
+}
+
+// Implementation of fmt.Stringer. It returns the text notation of the tag.
+func (t *DictionaryTag) String() string {
+	return FormatTag(t)
+}
@@ -0,0 +1,341 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"io"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatTag(t *testing.T) {
+	assert.Equal(t, "null", FormatTag(nil))
+	assert.Equal(t, "null", FormatTag(NewStdNullTag()))
+	assert.Equal(t, "#1000 null", FormatTag(NewNullTag(1000)))
+
+	b := NewStdBoolTag()
+	b.Payload = true
+	assert.Equal(t, "bool(true)", FormatTag(b))
+
+	i8 := NewStdInt8Tag()
+	i8.Payload = -128
+	assert.Equal(t, "i8(-128)", FormatTag(i8))
+	u8 := NewStdUInt8Tag()
+	u8.Payload = 255
+	assert.Equal(t, "u8(255)", FormatTag(u8))
+	i16 := NewStdInt16Tag()
+	i16.Payload = -1
+	assert.Equal(t, "i16(-1)", FormatTag(i16))
+	u16 := NewStdUInt16Tag()
+	u16.Payload = 65535
+	assert.Equal(t, "u16(65535)", FormatTag(u16))
+	i32 := NewStdInt32Tag()
+	i32.Payload = -2
+	assert.Equal(t, "i32(-2)", FormatTag(i32))
+	u32 := NewUInt32Tag(1000)
+	u32.Payload = 5
+	assert.Equal(t, "#1000 u32(5)", FormatTag(u32))
+	i64 := NewStdInt64Tag()
+	i64.Payload = math.MinInt64
+	assert.Equal(t, "i64(-9223372036854775808)", FormatTag(i64))
+	u64 := NewStdUInt64Tag()
+	u64.Payload = math.MaxUint64
+	assert.Equal(t, "u64(18446744073709551615)", FormatTag(u64))
+	ilint := NewStdILIntTag()
+	ilint.Payload = 1234
+	assert.Equal(t, "ilint(1234)", FormatTag(ilint))
+	silint := NewStdSignedILIntTag()
+	silint.Payload = -1234
+	assert.Equal(t, "silint(-1234)", FormatTag(silint))
+
+	f32 := NewStdFloat32Tag()
+	f32.Payload = 1.5
+	assert.Equal(t, "f32(1.5)", FormatTag(f32))
+	f64 := NewStdFloat64Tag()
+	f64.Payload = math.Inf(-1)
+	assert.Equal(t, "f64(-Inf)", FormatTag(f64))
+	f64.Payload = math.Inf(1)
+	assert.Equal(t, "f64(+Inf)", FormatTag(f64))
+	f64.Payload = math.NaN()
+	assert.Equal(t, "f64(NaN)", FormatTag(f64))
+	f128 := NewStdFloat128Tag()
+	f128.Payload[0] = 0xAB
+	assert.Equal(t, "f128(0xAB000000000000000000000000000000)", FormatTag(f128))
+
+	bytesTag := NewStdBytesTag()
+	assert.Equal(t, "bytes()", FormatTag(bytesTag))
+	bytesTag.Payload = []byte{0xde, 0xad}
+	assert.Equal(t, "bytes(0xDEAD)", FormatTag(bytesTag))
+	s := NewStdStringTag()
+	s.Payload = "a\"b\n"
+	assert.Equal(t, `str("a\"b\n")`, FormatTag(s))
+
+	bi := NewStdBigIntTag()
+	bi.SetBigInt(big.NewInt(-123))
+	assert.Equal(t, "bigint(-123)", FormatTag(bi))
+	bd := NewStdBigDecTag()
+	bd.SetBigInt(big.NewInt(-12345))
+	bd.Scale = 2
+	assert.Equal(t, "bigdec(-123.45)", FormatTag(bd))
+	bd.Scale = 7
+	assert.Equal(t, "bigdec(-0.0012345)", FormatTag(bd))
+	bd.Scale = -3
+	assert.Equal(t, "bigdec(-12345E3)", FormatTag(bd))
+	bd.Scale = 0
+	assert.Equal(t, "bigdec(-12345)", FormatTag(bd))
+
+	ilints := NewStdILIntArrayTag()
+	ilints.Payload = []uint64{1, 2}
+	assert.Equal(t, "ilints[1, 2]", FormatTag(ilints))
+	oid := NewStdOIDTag()
+	oid.Payload = []uint64{1, 3, 6}
+	assert.Equal(t, "oid[1, 3, 6]", FormatTag(oid))
+	array := NewStdILTagArrayTag()
+	array.Payload = []tags.ILTag{s, nil}
+	assert.Equal(t, `array[str("a\"b\n"), null]`, FormatTag(array))
+	seq := NewStdILTagSequenceTag()
+	assert.Equal(t, "seq[]", FormatTag(seq))
+	r := NewStdRangeTag()
+	r.Start = 10
+	r.Count = 2
+	assert.Equal(t, "range(10, 2)", FormatTag(r))
+	v := NewStdVersionTag()
+	v.Major, v.Minor, v.Revision, v.Build = 1, 2, 3, -4
+	assert.Equal(t, "version(1, 2, 3, -4)", FormatTag(v))
+
+	dict := NewStdDictionaryTag()
+	dict.Map.Put("b", u8)
+	dict.Map.Put("a", NewStdNullTag())
+	assert.Equal(t, `dict{"b": u8(255), "a": null}`, FormatTag(dict))
+	strDict := NewStdStringDictionaryTag()
+	strDict.Map.Put("k", "v")
+	strDict.Map.Put("x", "")
+	assert.Equal(t, `strdict{"k": "v", "x": ""}`, FormatTag(strDict))
+}
+
+type notationBrokenTag struct {
+	*UInt16Tag
+}
+
+func (t notationBrokenTag) SerializeValue(w io.Writer) error {
+	return io.ErrShortWrite
+}
+
+func TestFormatTag_Unknown(t *testing.T) {
+	raw := tags.NewRawTag(1000)
+	raw.Payload = []byte{0xde, 0xad}
+	assert.Equal(t, "#1000 bytes(0xDEAD)", FormatTag(raw))
+
+	tag := NewUInt16Tag(1001)
+	wrapped := struct{ tags.ILTag }{tag}
+	tag.Payload = 0x1234
+	assert.Equal(t, "#1001 bytes(0x1234)", FormatTag(wrapped))
+
+	broken := notationBrokenTag{NewUInt16Tag(1002)}
+	assert.Equal(t, `#1002 error("short write")`, FormatTag(broken))
+}
+
+func TestParseTag(t *testing.T) {
+	samples := []string{
+		"null",
+		"#1000 null",
+		"bool(true)",
+		"bool(false)",
+		"i8(-128)",
+		"u8(255)",
+		"i16(-32768)",
+		"u16(65535)",
+		"i32(-2147483648)",
+		"u32(4294967295)",
+		"i64(-9223372036854775808)",
+		"u64(18446744073709551615)",
+		"ilint(18446744073709551615)",
+		"silint(-9223372036854775808)",
+		"f32(1.5)",
+		"f64(-1.25e-10)",
+		"f64(+Inf)",
+		"f64(-Inf)",
+		"f128(0x0102030405060708090A0B0C0D0E0F10)",
+		"bytes()",
+		"bytes(0xDEAD)",
+		"#1000 bytes(0xDEAD)",
+		`str("")`,
+		`str("Hello\tworld")`,
+		"bigint(0)",
+		"bigint(-123456789012345678901234567890)",
+		"bigdec(123.45)",
+		"bigdec(-0.001)",
+		"bigdec(12345E3)",
+		"ilints[]",
+		"ilints[1, 2, 3]",
+		"oid[1, 3, 6, 1]",
+		"array[]",
+		`array[str("x"), null]`,
+		"seq[array[seq[]], u8(1)]",
+		"range(10, 65535)",
+		"version(1, 2, 3, -4)",
+		"dict{}",
+		`dict{"a": u32(5), "b": array[str("x"), null]}`,
+		`#1002 dict{"a": dict{"": #1003 i8(1)}}`,
+		"strdict{}",
+		`strdict{"k": "v", "x": ""}`,
+	}
+	for _, s := range samples {
+		tag, err := ParseTag(s)
+		require.Nil(t, err, s)
+		assert.Equal(t, s, FormatTag(tag))
+		assert.Equal(t, s, tag.(interface{ String() string }).String())
+
+		// Check the serialization with the standard factory
+		bin, err := tags.ILTagToBytes(tag)
+		require.Nil(t, err)
+		decoded, err := tags.ILTagFromBytes(NewStandardTagFactory(false), bin)
+		require.Nil(t, err)
+		if decoded.Id() < 32 {
+			assert.Equal(t, s, FormatTag(decoded))
+		}
+	}
+
+	tag, err := ParseTag("f64(NaN)")
+	require.Nil(t, err)
+	assert.True(t, math.IsNaN(tag.(*Float64Tag).Payload))
+
+	tag, err = ParseTag(`
+		// Comments and blank spaces are ignored
+		dict {
+			"a" : u8( 1 ) , /* trailing comma */
+		}`)
+	require.Nil(t, err)
+	assert.Equal(t, `dict{"a": u8(1)}`, FormatTag(tag))
+
+	tag, err = ParseTag("str(`raw`)")
+	require.Nil(t, err)
+	assert.Equal(t, "raw", tag.(*StringTag).Payload)
+
+	tag, err = ParseTag("bytes(0Xdead)")
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0xde, 0xad}, tag.(*BytesTag).Payload)
+
+	tag, err = ParseTag("bigdec(1E0)")
+	require.Nil(t, err)
+	assert.Equal(t, "bigdec(1)", FormatTag(tag))
+}
+
+func TestParseTag_Errors(t *testing.T) {
+	samples := []string{
+		"",
+		"foo",
+		"1",
+		"null null",
+		"#5 str(\"x\")",
+		"#x null",
+		"#-1 null",
+		"bool",
+		"bool(1)",
+		"bool(true",
+		"i8(128)",
+		"i8(-129)",
+		"u8(-1)",
+		"u8(1.5)",
+		"i16(32768)",
+		"u16(65536)",
+		"i32(2147483648)",
+		"u32(4294967296)",
+		"i64(9223372036854775808)",
+		"u64(18446744073709551616)",
+		"ilint(-1)",
+		"silint(x)",
+		"f32(1e39)",
+		"f64(-NaN)",
+		"f64(Inf2)",
+		"f128(0x00)",
+		"f128()",
+		"bytes(1234)",
+		"bytes(0xABC)",
+		"bytes(\"x\")",
+		"str(1)",
+		"str(\"\\z\")",
+		"str(\"x",
+		"bigint(1.5)",
+		"bigint(1E3)",
+		"bigdec(1.5E3)",
+		"bigdec(1E-3)",
+		"bigdec(1E99999999999)",
+		"bigdec(x)",
+		"ilints[-1]",
+		"ilints(1)",
+		"ilints[1 2]",
+		"array[foo]",
+		"array(null)",
+		"seq[null",
+		"range(1)",
+		"range(1, 65536)",
+		"range(-1, 1)",
+		"version(1, 2, 3)",
+		"version(1, 2, 3, x)",
+		"version(1 2, 3, 4)",
+		"dict[]",
+		"dict{a: null}",
+		`dict{"a" null}`,
+		`dict{"a": foo}`,
+		`dict{"a": null, "a": null}`,
+		"strdict[]",
+		`strdict{"a": null}`,
+		`strdict{"a": "b", "a": "c"}`,
+		`strdict{"a" "b"}`,
+	}
+	for _, s := range samples {
+		tag, err := ParseTag(s)
+		assert.ErrorIs(t, err, ErrBadNotation, s)
+		assert.Nil(t, tag, s)
+	}
+}
+
+func TestMustParseTag(t *testing.T) {
+	tag := MustParseTag(`#1000 bytes(0xDEAD)`)
+	assert.Equal(t, tags.TagID(1000), tag.Id())
+	assert.Equal(t, []byte{0xde, 0xad}, tag.(*BytesTag).Payload)
+	assert.Panics(t, func() { MustParseTag("foo") })
+}
+
+func TestMustParseTagBytes(t *testing.T) {
+	assert.Equal(t, []byte{0x11, 0x01, 'x'}, MustParseTagBytes(`str("x")`))
+	assert.Equal(t, []byte{0xf9, 0x02, 0xf0, 0x00}, MustParseTagBytes(`#1000 null`))
+	assert.Panics(t, func() { MustParseTagBytes("foo") })
+	assert.Panics(t, func() { MustParseTagBytes("#1000 str(\"\\xff\")") })
+}
@@ -32,5 +32,10 @@
 
 /*
  This package contains the concrete implementation of the ILTags.
+
+ It also contains the operations on tag trees that depend on the concrete
+ tags: Diff() compares two trees and Apply() applies the changes it returns,
+ Query() selects the tags of a tree with path expressions and Transform()
+ rewrites a tree.
 */
 package impl
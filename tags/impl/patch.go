@@ -0,0 +1,265 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"fmt"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+var (
+	// The change does not match the tag tree it is being applied to.
+	ErrPatchMismatch = fmt.Errorf("patch does not match the tag tree")
+	// The tag does not contain a valid patch.
+	ErrBadPatch = fmt.Errorf("invalid patch")
+)
+
+/*
+Applies changes to a tag tree without modifying it. The containers along the
+paths of the changes are copied only once, thus a container may be modified in
+place after it has been copied.
+*/
+type patcher struct {
+	copies map[tags.ILTag]bool
+}
+
+// Returns a copy of the container that can be modified.
+func (p *patcher) writable(tag tags.ILTag) tags.ILTag {
+	if p.copies[tag] {
+		return tag
+	}
+	c := cloneContainer(tag)
+	if c != nil {
+		p.copies[c] = true
+	}
+	return c
+}
+
+// Returns an error that describes why the change could not be applied.
+func changeError(c Change, err error) error {
+	return fmt.Errorf("%s %s: %w", c.Op, c.Path, err)
+}
+
+// Applies a single change and returns the new root of the tree.
+func (p *patcher) apply(root tags.ILTag, c Change) (tags.ILTag, error) {
+	if len(c.Path) == 0 {
+		if c.Op != ChangeReplace {
+			return nil, changeError(c, ErrPatchMismatch)
+		}
+		if !EqualTags(root, c.Old) {
+			return nil, changeError(c, ErrPatchMismatch)
+		}
+		return c.New, nil
+	}
+	newRoot := p.writable(root)
+	if newRoot == nil {
+		return nil, changeError(c, ErrPathNotFound)
+	}
+	parent := newRoot
+	last := len(c.Path) - 1
+	for _, e := range c.Path[:last] {
+		child, ok := childOf(parent, e)
+		if !ok {
+			return nil, changeError(c, ErrPathNotFound)
+		}
+		if child = p.writable(child); child == nil {
+			return nil, changeError(c, ErrPathNotFound)
+		}
+		setChild(parent, e, child)
+		parent = child
+	}
+	e := c.Path[last]
+	switch c.Op {
+	case ChangeInsert:
+		if !insertChild(parent, e, c.New) {
+			return nil, changeError(c, ErrPatchMismatch)
+		}
+	case ChangeReplace, ChangeDelete:
+		current, ok := childOf(parent, e)
+		if !ok {
+			return nil, changeError(c, ErrPathNotFound)
+		}
+		if !EqualTags(current, c.Old) {
+			return nil, changeError(c, ErrPatchMismatch)
+		}
+		if c.Op == ChangeReplace {
+			setChild(parent, e, c.New)
+		} else {
+			removeChild(parent, e)
+		}
+	default:
+		return nil, changeError(c, ErrBadPatch)
+	}
+	return newRoot, nil
+}
+
+/*
+Applies the changes to the tag tree and returns the patched tree. The changes
+are applied in order and each one must find the tag described by Change.Old at
+its path, thus a patch can only be applied to the tree used to create it.
+
+The original tree is not modified. The containers along the paths of the
+changes are copied while the other tags are shared by both trees.
+
+It returns an error that wraps ErrPathNotFound if a path does not exist or
+ErrPatchMismatch if the tree does not match the change.
+*/
+func Apply(tag tags.ILTag, changes []Change) (tags.ILTag, error) {
+	p := patcher{copies: make(map[tags.ILTag]bool)}
+	var err error
+	for _, c := range changes {
+		if tag, err = p.apply(tag, c); err != nil {
+			return nil, err
+		}
+	}
+	return tag, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Returns the tag or an ILNullTag if it is nil.
+func tagOrNull(tag tags.ILTag) tags.ILTag {
+	if tags.IsILTagNil(tag) {
+		return NewStdNullTag()
+	}
+	return tag
+}
+
+/*
+Encodes the changes as a tag so they can be transferred to other nodes. The
+patch is a standard ILTagArrayTag and its ID may be changed by the caller. Each
+change is encoded as an ILTagSequenceTag with the following tags:
+
+	u8(op)            the operation: 0 replace, 1 insert or 2 delete
+	array[...]        the path, with a StringTag for each key and an ILIntTag
+	                  for each index
+	old               the original tag or null for insertions
+	new               the new tag or null for deletions
+
+The patch shares the tags of the changes.
+*/
+func EncodePatch(changes []Change) *ILTagArrayTag {
+	patch := NewStdILTagArrayTag()
+	patch.Payload = make([]tags.ILTag, 0, len(changes))
+	for _, c := range changes {
+		op := NewStdUInt8Tag()
+		op.Payload = uint8(c.Op)
+		path := NewStdILTagArrayTag()
+		path.Payload = make([]tags.ILTag, 0, len(c.Path))
+		for _, e := range c.Path {
+			if e.IsKey {
+				k := NewStdStringTag()
+				k.Payload = e.Key
+				path.Payload = append(path.Payload, k)
+			} else {
+				i := NewStdILIntTag()
+				i.Payload = uint64(e.Index)
+				path.Payload = append(path.Payload, i)
+			}
+		}
+		entry := NewStdILTagSequenceTag()
+		entry.Payload = []tags.ILTag{op, path, tagOrNull(c.Old), tagOrNull(c.New)}
+		patch.Payload = append(patch.Payload, entry)
+	}
+	return patch
+}
+
+// Decodes the path of a change.
+func decodePatchPath(tag tags.ILTag) (tags.Path, error) {
+	t, ok := tag.(*ILTagArrayTag)
+	if !ok {
+		return nil, ErrBadPatch
+	}
+	path := make(tags.Path, 0, len(t.Payload))
+	for _, e := range t.Payload {
+		switch v := e.(type) {
+		case *StringTag:
+			path = append(path, tags.KeyElement(v.Payload))
+		case *ILIntTag:
+			if v.Payload > uint64(^uint(0)>>1) {
+				return nil, ErrBadPatch
+			}
+			path = append(path, tags.IndexElement(int(v.Payload)))
+		default:
+			return nil, ErrBadPatch
+		}
+	}
+	return path, nil
+}
+
+// Decodes a single change.
+func decodeChange(tag tags.ILTag) (Change, error) {
+	var c Change
+	entry, ok := tag.(*ILTagSequenceTag)
+	if !ok || len(entry.Payload) != 4 {
+		return c, ErrBadPatch
+	}
+	op, ok := entry.Payload[0].(*UInt8Tag)
+	if !ok || int(op.Payload) >= len(changeOpNames) {
+		return c, ErrBadPatch
+	}
+	c.Op = ChangeOp(op.Payload)
+	path, err := decodePatchPath(entry.Payload[1])
+	if err != nil {
+		return c, err
+	}
+	c.Path = path
+	if c.Op != ChangeInsert {
+		c.Old = entry.Payload[2]
+	}
+	if c.Op != ChangeDelete {
+		c.New = entry.Payload[3]
+	}
+	return c, nil
+}
+
+/*
+Decodes a patch created by EncodePatch(). The tag may have any ID. It returns an
+error that wraps ErrBadPatch if the patch is invalid.
+*/
+func DecodePatch(tag tags.ILTag) ([]Change, error) {
+	patch, ok := tag.(*ILTagArrayTag)
+	if !ok {
+		return nil, fmt.Errorf("the patch must be an ILTagArrayTag: %w", ErrBadPatch)
+	}
+	changes := make([]Change, 0, len(patch.Payload))
+	for i, e := range patch.Payload {
+		c, err := decodeChange(e)
+		if err != nil {
+			return nil, fmt.Errorf("change %d: %w", i, err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
@@ -0,0 +1,177 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	root := MustParseTag(`dict{"a": array[u8(1), u8(2)], "b": seq[null], "c": u8(3)}`)
+	a := tags.Path{}.Key("a")
+	changes := []Change{
+		{Op: ChangeReplace, Path: a.Index(0), Old: MustParseTag("u8(1)"), New: MustParseTag("u8(9)")},
+		{Op: ChangeInsert, Path: a.Index(1), New: MustParseTag("u8(8)")},
+		{Op: ChangeDelete, Path: a.Index(2), Old: MustParseTag("u8(2)")},
+		{Op: ChangeInsert, Path: tags.Path{}.Key("d"), New: NewStdNullTag()},
+		{Op: ChangeDelete, Path: tags.Path{}.Key("c"), Old: MustParseTag("u8(3)")},
+	}
+	patched, err := Apply(root, changes)
+	require.Nil(t, err)
+	assert.Equal(t, `dict{"a": array[u8(9), u8(8)], "b": seq[null], "d": null}`, FormatTag(patched))
+	assert.Equal(t, `dict{"a": array[u8(1), u8(2)], "b": seq[null], "c": u8(3)}`, FormatTag(root))
+
+	// Untouched containers are shared
+	b1, _ := root.(*DictionaryTag).Map.Get("b")
+	b2, _ := patched.(*DictionaryTag).Map.Get("b")
+	assert.Same(t, b1, b2)
+
+	// Replacing the root
+	patched, err = Apply(root, []Change{
+		{Op: ChangeReplace, Old: root, New: MustParseTag("array[u8(1)]")},
+		{Op: ChangeInsert, Path: tags.Path{}.Index(1), New: MustParseTag("u8(2)")},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "array[u8(1), u8(2)]", FormatTag(patched))
+
+	// No changes
+	patched, err = Apply(root, nil)
+	require.Nil(t, err)
+	assert.Same(t, root, patched)
+}
+
+func TestApply_Errors(t *testing.T) {
+	root := MustParseTag(`dict{"a": array[u8(1)], "b": u8(2)}`)
+	samples := []struct {
+		change   Change
+		expected error
+		msg      string
+	}{
+		{Change{Op: ChangeReplace, Old: NewStdNullTag(), New: NewStdNullTag()},
+			ErrPatchMismatch, "replace $: patch does not match the tag tree"},
+		{Change{Op: ChangeDelete, Old: root},
+			ErrPatchMismatch, "delete $: patch does not match the tag tree"},
+		{Change{Op: ChangeInsert, New: root},
+			ErrPatchMismatch, "insert $: patch does not match the tag tree"},
+		{Change{Op: ChangeReplace, Path: tags.Path{}.Key("x").Index(0)},
+			ErrPathNotFound, "replace $.x[0]: path not found"},
+		{Change{Op: ChangeReplace, Path: tags.Path{}.Key("b").Index(0)},
+			ErrPathNotFound, "replace $.b[0]: path not found"},
+		{Change{Op: ChangeReplace, Path: tags.Path{}.Key("c")},
+			ErrPathNotFound, "replace $.c: path not found"},
+		{Change{Op: ChangeDelete, Path: tags.Path{}.Key("a").Index(1)},
+			ErrPathNotFound, "delete $.a[1]: path not found"},
+		{Change{Op: ChangeReplace, Path: tags.Path{}.Key("b"), Old: MustParseTag("u8(3)")},
+			ErrPatchMismatch, "replace $.b: patch does not match the tag tree"},
+		{Change{Op: ChangeDelete, Path: tags.Path{}.Key("b"), Old: MustParseTag("u8(3)")},
+			ErrPatchMismatch, "delete $.b: patch does not match the tag tree"},
+		{Change{Op: ChangeInsert, Path: tags.Path{}.Key("b"), New: MustParseTag("u8(3)")},
+			ErrPatchMismatch, "insert $.b: patch does not match the tag tree"},
+		{Change{Op: ChangeInsert, Path: tags.Path{}.Key("a").Index(2), New: MustParseTag("u8(3)")},
+			ErrPatchMismatch, "insert $.a[2]: patch does not match the tag tree"},
+		{Change{Op: ChangeOp(7), Path: tags.Path{}.Key("a")},
+			ErrBadPatch, "ChangeOp(7) $.a: invalid patch"},
+	}
+	for _, s := range samples {
+		patched, err := Apply(root, []Change{s.change})
+		assert.Nil(t, patched)
+		assert.ErrorIs(t, err, s.expected)
+		assert.EqualError(t, err, s.msg)
+	}
+
+	// Leaf root
+	_, err := Apply(MustParseTag("u8(1)"), []Change{{Op: ChangeDelete,
+		Path: tags.Path{}.Index(0)}})
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	assert.Equal(t, `dict{"a": array[u8(1)], "b": u8(2)}`, FormatTag(root))
+}
+
+func TestEncodePatch(t *testing.T) {
+	a := MustParseTag(`dict{"a": array[u8(1), u8(2)], "b": #1000 bytes(0x01), "c": u8(3)}`)
+	b := MustParseTag(`dict{"a": array[u8(1)], "b": #1000 bytes(0x02), "d e": null}`)
+	changes := Diff(a, b)
+	patch := EncodePatch(changes)
+	assert.Equal(t, `array[`+
+		`seq[u8(2), array[str("a"), ilint(1)], u8(2), null], `+
+		`seq[u8(0), array[str("b")], #1000 bytes(0x01), #1000 bytes(0x02)], `+
+		`seq[u8(2), array[str("c")], u8(3), null], `+
+		`seq[u8(1), array[str("d e")], null, null]]`, FormatTag(patch))
+
+	// Round trip through the serialization
+	bin, err := tags.ILTagToBytes(patch)
+	require.Nil(t, err)
+	decodedTag, err := tags.ILTagFromBytes(NewStandardTagFactory(false), bin)
+	require.Nil(t, err)
+	decoded, err := DecodePatch(decodedTag)
+	require.Nil(t, err)
+	require.Len(t, decoded, len(changes))
+	for i, c := range changes {
+		assert.Equal(t, c.Op, decoded[i].Op)
+		assert.True(t, c.Path.Equal(decoded[i].Path))
+		assert.True(t, EqualTags(c.Old, decoded[i].Old))
+		assert.True(t, EqualTags(c.New, decoded[i].New))
+	}
+	assert.Nil(t, decoded[3].Old)
+	assert.Nil(t, decoded[0].New)
+	patched, err := Apply(a, decoded)
+	require.Nil(t, err)
+	assert.True(t, EqualTags(b, patched))
+
+	// Empty
+	assert.Equal(t, "array[]", FormatTag(EncodePatch(nil)))
+	decoded, err = DecodePatch(MustParseTag("#1000 array[]"))
+	require.Nil(t, err)
+	assert.Empty(t, decoded)
+}
+
+func TestDecodePatch_Errors(t *testing.T) {
+	samples := []string{
+		"seq[]",
+		"array[null]",
+		"array[seq[u8(0), array[], null]]",
+		"array[seq[u16(0), array[], null, null]]",
+		"array[seq[u8(3), array[], null, null]]",
+		"array[seq[u8(0), seq[], null, null]]",
+		"array[seq[u8(0), array[null], null, null]]",
+		"array[seq[u8(0), array[ilint(18446744073709551615)], null, null]]",
+	}
+	for _, s := range samples {
+		changes, err := DecodePatch(MustParseTag(s))
+		assert.Nil(t, changes, s)
+		assert.ErrorIs(t, err, ErrBadPatch, s)
+	}
+}
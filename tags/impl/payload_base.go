@@ -34,6 +34,7 @@ package impl
 
 import (
 	"io"
+	"math/big"
 
 	"github.com/interlockledger/go-iltags/ilint"
 	"github.com/interlockledger/go-iltags/serialization"
@@ -109,6 +110,46 @@ func (p *BigIntPayload) DeserializeValue(factory tags.ILTagFactory, valueSize in
 	return serialization.ReadBytes(reader, p.Payload)
 }
 
+/*
+Sets the payload with the value of v. The value is stored as a two's complement
+big endian integer using the minimum number of bytes required to represent it.
+*/
+func (p *BigIntPayload) SetBigInt(v *big.Int) {
+	switch v.Sign() {
+	case 0:
+		p.Payload = []byte{0}
+	case 1:
+		b := v.Bytes()
+		if b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		p.Payload = b
+	default:
+		// The value is stored as 2^(8 * n) + v where n is the minimum number of
+		// bytes required to store v.
+		m := new(big.Int).Neg(v)
+		m.Sub(m, big.NewInt(1))
+		n := m.BitLen()/8 + 1
+		c := new(big.Int).Lsh(big.NewInt(1), uint(n*8))
+		c.Add(c, v)
+		b := make([]byte, n)
+		p.Payload = c.FillBytes(b)
+	}
+}
+
+/*
+Returns the payload as a big.Int. The payload is interpreted as a two's
+complement big endian integer. An empty payload is considered to be 0.
+*/
+func (p *BigIntPayload) BigInt() *big.Int {
+	v := new(big.Int).SetBytes(p.Payload)
+	if len(p.Payload) > 0 && p.Payload[0]&0x80 != 0 {
+		c := new(big.Int).Lsh(big.NewInt(1), uint(len(p.Payload)*8))
+		v.Sub(v, c)
+	}
+	return v
+}
+
 //------------------------------------------------------------------------------
 
 // Implementation of the big decimal payload.
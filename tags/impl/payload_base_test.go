@@ -34,6 +34,7 @@ package impl
 
 import (
 	"bytes"
+	"math/big"
 	"testing"
 
 	"github.com/interlockledger/go-iltags/ilint"
@@ -137,6 +138,45 @@ func TestBigIntPayload(t *testing.T) {
 	assert.ErrorIs(t, tag.DeserializeValue(f, 0, r), tags.ErrBadTagFormat)
 }
 
+func TestBigIntPayload_BigInt(t *testing.T) {
+	samples := []struct {
+		v   string
+		bin []byte
+	}{
+		{"0", []byte{0x00}},
+		{"1", []byte{0x01}},
+		{"127", []byte{0x7F}},
+		{"128", []byte{0x00, 0x80}},
+		{"255", []byte{0x00, 0xFF}},
+		{"256", []byte{0x01, 0x00}},
+		{"-1", []byte{0xFF}},
+		{"-128", []byte{0x80}},
+		{"-129", []byte{0xFF, 0x7F}},
+		{"-256", []byte{0xFF, 0x00}},
+		{"-32768", []byte{0x80, 0x00}},
+		{"-32769", []byte{0xFF, 0x7F, 0xFF}},
+		{"1234567890123456789012345678901234567890",
+			[]byte{0x03, 0xa0, 0xc9, 0x20, 0x75, 0xc0, 0xdb, 0xf3, 0xb8, 0xac,
+				0xbc, 0x5f, 0x96, 0xce, 0x3f, 0x0a, 0xd2}},
+		{"-1234567890123456789012345678901234567890",
+			[]byte{0xfc, 0x5f, 0x36, 0xdf, 0x8a, 0x3f, 0x24, 0x0c, 0x47, 0x53,
+				0x43, 0xa0, 0x69, 0x31, 0xc0, 0xf5, 0x2e}},
+	}
+	var p BigIntPayload
+	for _, s := range samples {
+		v, ok := new(big.Int).SetString(s.v, 10)
+		require.True(t, ok)
+		p.SetBigInt(v)
+		assert.Equal(t, s.bin, p.Payload, s.v)
+		assert.Equal(t, 0, v.Cmp(p.BigInt()), s.v)
+	}
+
+	p.Payload = nil
+	assert.Equal(t, 0, p.BigInt().Sign())
+	p.Payload = []byte{}
+	assert.Equal(t, 0, p.BigInt().Sign())
+}
+
 func TestBigDecPayload(t *testing.T) {
 	var _ tags.ILTagPayload = (*BigDecPayload)(nil)
 	sample := []byte("If you go to Z'ha'dum, you will die.")
@@ -0,0 +1,471 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+var (
+	// The query expression is invalid.
+	ErrBadQuery = fmt.Errorf("invalid query")
+	// The tag does not have the type required by the query.
+	ErrTypeMismatch = fmt.Errorf("type mismatch")
+)
+
+/*
+QueryError is the error returned when a query cannot be evaluated. It contains
+the path of the tag where the evaluation stopped.
+*/
+type QueryError struct {
+	// The path of the tag.
+	Path tags.Path
+	// The cause of the error.
+	Err error
+}
+
+// Implementation of error.Error().
+func (e *QueryError) Error() string {
+	return e.Path.String() + ": " + e.Err.Error()
+}
+
+// Returns the cause of the error.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+/*
+QueryResult is a tag selected by a query.
+*/
+type QueryResult struct {
+	// Location of the tag.
+	Path tags.Path
+	// The tag.
+	Tag tags.ILTag
+}
+
+// Kind of a step of a query.
+type queryStepKind int
+
+const (
+	// Selects a dictionary entry.
+	queryKey queryStepKind = iota
+	// Selects an element of an array or sequence.
+	queryIndex
+	// Selects all children of a container.
+	queryWildcard
+	// Keeps only the tags with a given ID.
+	queryFilter
+)
+
+// A single step of a query.
+type queryStep struct {
+	kind  queryStepKind
+	key   string
+	index int
+	id    tags.TagID
+}
+
+// Implementation of fmt.Stringer.
+func (s queryStep) String() string {
+	switch s.kind {
+	case queryKey:
+		return tags.KeyElement(s.key).String()
+	case queryIndex:
+		return "[" + strconv.Itoa(s.index) + "]"
+	case queryWildcard:
+		return "[*]"
+	default:
+		return fmt.Sprintf("[#%d]", s.id)
+	}
+}
+
+/*
+Selector is a compiled query. It is safe to use a Selector from multiple
+goroutines.
+*/
+type Selector struct {
+	steps []queryStep
+}
+
+// Returns true if c can be used in a key that follows a dot.
+func isQueryIdent(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(!first && c >= '0' && c <= '9')
+}
+
+// Returns an ErrBadQuery that reports the offset of the problem.
+func queryErrorf(offset int, format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s: %w", offset, fmt.Sprintf(format, args...),
+		ErrBadQuery)
+}
+
+// Parses the contents of a bracket step. It returns the step and its length.
+func parseQueryBracket(expr string, offset int) (queryStep, int, error) {
+	var step queryStep
+	s := expr[offset+1:]
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return step, 0, queryErrorf(offset+1, "invalid string")
+		}
+		step.kind = queryKey
+		step.key, _ = strconv.Unquote(q)
+		s = s[len(q):]
+		if !strings.HasPrefix(s, "]") {
+			return step, 0, queryErrorf(offset+1+len(q), "] expected")
+		}
+		return step, len(q) + 2, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return step, 0, queryErrorf(offset, "unterminated [")
+	}
+	content := s[:end]
+	switch {
+	case content == "*":
+		step.kind = queryWildcard
+	case strings.HasPrefix(content, "#"):
+		id, err := strconv.ParseUint(content[1:], 10, 64)
+		if err != nil {
+			return step, 0, queryErrorf(offset+1, "invalid tag ID %q", content[1:])
+		}
+		step.kind = queryFilter
+		step.id = tags.TagID(id)
+	default:
+		index, err := strconv.Atoi(content)
+		if err != nil {
+			return step, 0, queryErrorf(offset+1, "invalid index %q", content)
+		}
+		step.kind = queryIndex
+		step.index = index
+	}
+	return step, end + 2, nil
+}
+
+/*
+Compiles a query expression. The expression starts with $, that represents the
+root tag, followed by any number of steps:
+
+	.key     The entry of a dictionary. The key must be an identifier.
+	["key"]  The entry of a dictionary. The key is a quoted Go string.
+	[i]      The element of an array or sequence. Negative indexes count
+	         from the end, thus [-1] is the last element.
+	.* [*]   All children of a container. Other tags have no children.
+	[#id]    Keeps only the tags with the given ID.
+
+Keys select the entries of DictionaryTags and StringDictionaryTags. The values
+of StringDictionaryTags are returned as standard StringTags. Indexes select the
+elements of ILTagArrayTags and ILTagSequenceTags while wildcards select the
+children of all of them. Other tags that implement tags.Container are queried
+using the path elements of their children.
+
+For example, $.payload.signatures[*][#1000].key selects the key of all
+signatures of the payload that have the tag ID 1000.
+*/
+func ParseSelector(expr string) (*Selector, error) {
+	if !strings.HasPrefix(expr, tags.PathRoot) {
+		return nil, queryErrorf(0, "%s expected", tags.PathRoot)
+	}
+	s := &Selector{}
+	for i := len(tags.PathRoot); i < len(expr); {
+		switch expr[i] {
+		case '.':
+			j := i + 1
+			if j < len(expr) && expr[j] == '*' {
+				s.steps = append(s.steps, queryStep{kind: queryWildcard})
+				i = j + 1
+				continue
+			}
+			for j < len(expr) && isQueryIdent(expr[j], j == i+1) {
+				j++
+			}
+			if j == i+1 {
+				return nil, queryErrorf(j, "key expected")
+			}
+			s.steps = append(s.steps, queryStep{kind: queryKey, key: expr[i+1 : j]})
+			i = j
+		case '[':
+			step, n, err := parseQueryBracket(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			s.steps = append(s.steps, step)
+			i += n
+		default:
+			return nil, queryErrorf(i, "unexpected character %q", expr[i])
+		}
+	}
+	return s, nil
+}
+
+/*
+Compiles a query expression. It panics if the expression is invalid. It is
+useful to initialize global variables.
+*/
+func MustParseSelector(expr string) *Selector {
+	s, err := ParseSelector(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Returns the normalized query expression.
+func (s *Selector) String() string {
+	var sb strings.Builder
+	sb.WriteString(tags.PathRoot)
+	for _, step := range s.steps {
+		sb.WriteString(step.String())
+	}
+	return sb.String()
+}
+
+// Returns the description of a tag used in the error messages.
+func describeQueryTag(tag tags.ILTag) string {
+	if tags.IsILTagNil(tag) {
+		return "null"
+	}
+	if name := tag.Id().StdName(); name != "" {
+		return fmt.Sprintf("tag %d (%s)", tag.Id(), name)
+	}
+	return fmt.Sprintf("tag %d", tag.Id())
+}
+
+// Returns a type mismatch error.
+func queryMismatch(r QueryResult, expected string) error {
+	return &QueryError{Path: r.Path, Err: fmt.Errorf("expected %s, found %s: %w",
+		expected, describeQueryTag(r.Tag), ErrTypeMismatch)}
+}
+
+// Returns the tag as a tags.Container.
+func containerOf(tag tags.ILTag) (tags.Container, bool) {
+	if tags.IsILTagNil(tag) {
+		return nil, false
+	}
+	c, ok := tag.(tags.Container)
+	return c, ok
+}
+
+/*
+Returns the child of a custom container selected by the path element. Negative
+indexes count from the end of the children selected by indexes.
+*/
+func findChild(c tags.Container, e tags.PathElement) (tags.PathElement, tags.ILTag, bool) {
+	children := c.Children()
+	if !e.IsKey && e.Index < 0 {
+		n := 0
+		for _, child := range children {
+			if !child.Element.IsKey {
+				n++
+			}
+		}
+		e.Index += n
+	}
+	for _, child := range children {
+		if child.Element == e {
+			return e, child.Tag, true
+		}
+	}
+	return e, nil, false
+}
+
+// Applies a single step to a tag, appending the selected tags to results.
+func (step queryStep) apply(r QueryResult, results []QueryResult) ([]QueryResult, error) {
+	var e tags.PathElement
+	switch step.kind {
+	case queryKey:
+		e = tags.KeyElement(step.key)
+		switch r.Tag.(type) {
+		case *DictionaryTag, *StringDictionaryTag:
+		case *ILTagArrayTag, *ILTagSequenceTag:
+			return nil, queryMismatch(r, "dictionary")
+		}
+	case queryIndex:
+		e = tags.IndexElement(step.index)
+		switch r.Tag.(type) {
+		case *ILTagArrayTag, *ILTagSequenceTag:
+		case *DictionaryTag, *StringDictionaryTag:
+			return nil, queryMismatch(r, "array or sequence")
+		}
+	case queryWildcard:
+		c, ok := containerOf(r.Tag)
+		if !ok {
+			// Tags that are not containers have no children
+			return results, nil
+		}
+		for _, child := range c.Children() {
+			results = append(results, QueryResult{Path: r.Path.Append(child.Element),
+				Tag: child.Tag})
+		}
+		return results, nil
+	default:
+		id := tags.IL_NULL_TAG_ID
+		if !tags.IsILTagNil(r.Tag) {
+			id = r.Tag.Id()
+		}
+		if id == step.id {
+			results = append(results, r)
+		}
+		return results, nil
+	}
+	c, ok := containerOf(r.Tag)
+	if !ok {
+		if e.IsKey {
+			return nil, queryMismatch(r, "dictionary")
+		}
+		return nil, queryMismatch(r, "array or sequence")
+	}
+	var v tags.ILTag
+	found := e
+	switch r.Tag.(type) {
+	case *ILTagArrayTag, *ILTagSequenceTag, *DictionaryTag:
+		// Fast path for the standard containers
+		if l := listOf(r.Tag); l != nil && e.Index < 0 {
+			found.Index += len(*l)
+		}
+		v, ok = childOf(r.Tag, found)
+	default:
+		found, v, ok = findChild(c, e)
+	}
+	if !ok {
+		return nil, &QueryError{Path: r.Path.Append(e), Err: ErrPathNotFound}
+	}
+	return append(results, QueryResult{Path: r.Path.Append(found), Tag: v}), nil
+}
+
+/*
+Returns all tags selected by this query in the order they appear in the tree.
+Keys and indexes must exist in all tags they are applied to, otherwise a
+*QueryError that wraps ErrPathNotFound is returned. Keys and indexes applied to
+tags of the wrong type result in a *QueryError that wraps ErrTypeMismatch.
+Wildcards select nothing from tags that are not containers.
+
+The result is empty if the filters reject all tags.
+*/
+func (s *Selector) Select(tag tags.ILTag) ([]QueryResult, error) {
+	results := []QueryResult{{Path: tags.Path{}, Tag: tag}}
+	for _, step := range s.steps {
+		var next []QueryResult
+		for _, r := range results {
+			var err error
+			if next, err = step.apply(r, next); err != nil {
+				return nil, err
+			}
+		}
+		results = next
+	}
+	if results == nil {
+		results = []QueryResult{}
+	}
+	return results, nil
+}
+
+/*
+Returns the first tag selected by this query. It returns an error that wraps
+ErrPathNotFound if no tag is selected.
+*/
+func (s *Selector) SelectOne(tag tags.ILTag) (tags.ILTag, error) {
+	r, err := s.selectFirst(tag)
+	if err != nil {
+		return nil, err
+	}
+	return r.Tag, nil
+}
+
+// Returns the first result of the query.
+func (s *Selector) selectFirst(tag tags.ILTag) (QueryResult, error) {
+	results, err := s.Select(tag)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	if len(results) == 0 {
+		return QueryResult{}, fmt.Errorf("no tag matches %s: %w", s, ErrPathNotFound)
+	}
+	return results[0], nil
+}
+
+/*
+Returns all tags of the tree selected by the query expression. See
+ParseSelector() for the syntax of the expression and Selector.Select() for
+details about the results.
+*/
+func Query(tag tags.ILTag, expr string) ([]QueryResult, error) {
+	s, err := ParseSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(tag)
+}
+
+/*
+Returns the first tag of the tree selected by the query expression. See
+Selector.SelectOne() for details.
+*/
+func QueryOne(tag tags.ILTag, expr string) (tags.ILTag, error) {
+	s, err := ParseSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.SelectOne(tag)
+}
+
+/*
+Returns the first tag of the tree selected by the query expression as a T, as
+in:
+
+	key, err := QueryAs[*StringTag](tag, "$.payload.signatures[0].key")
+
+It returns a *QueryError that wraps ErrTypeMismatch if the selected tag is not
+a T.
+*/
+func QueryAs[T tags.ILTag](tag tags.ILTag, expr string) (T, error) {
+	var zero T
+	s, err := ParseSelector(expr)
+	if err != nil {
+		return zero, err
+	}
+	r, err := s.selectFirst(tag)
+	if err != nil {
+		return zero, err
+	}
+	v, ok := r.Tag.(T)
+	if !ok {
+		return zero, queryMismatch(r, fmt.Sprintf("%T", zero))
+	}
+	return v, nil
+}
@@ -0,0 +1,311 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const queryTestTree = `dict{
+	"payload": dict{
+		"signatures": array[
+			#1000 dict{"key": str("k1"), "alg": u8(1)},
+			#1001 dict{"key": str("k2")},
+			#1000 dict{"key": str("k3"), "alg": u8(2)}
+		],
+		"a b": seq[u8(1), null, ilint(3)],
+		"meta": strdict{"x": "1", "y": "2"}
+	},
+	"n": null
+}`
+
+func TestParseSelector(t *testing.T) {
+	samples := []struct {
+		expr     string
+		expected string
+	}{
+		{"$", "$"},
+		{"$.a", "$.a"},
+		{"$._a1.B_2", "$._a1.B_2"},
+		{`$["a"]`, "$.a"},
+		{`$["a b"]["A"]`, `$["a b"].A`},
+		{`$[""]`, `$[""]`},
+		{"$[0][-1][+2]", "$[0][-1][2]"},
+		{"$.*[*]", "$[*][*]"},
+		{"$[#0][#1000]", "$[#0][#1000]"},
+		{"$.payload.signatures[*][#1000].key", "$.payload.signatures[*][#1000].key"},
+	}
+	for _, s := range samples {
+		sel, err := ParseSelector(s.expr)
+		require.Nil(t, err, s.expr)
+		assert.Equal(t, s.expected, sel.String())
+		assert.Equal(t, s.expected, MustParseSelector(s.expected).String())
+	}
+}
+
+func TestParseSelector_Errors(t *testing.T) {
+	samples := []struct {
+		expr string
+		msg  string
+	}{
+		{"", "offset 0: $ expected: invalid query"},
+		{"a", "offset 0: $ expected: invalid query"},
+		{"$a", "offset 1: unexpected character 'a': invalid query"},
+		{"$.", "offset 2: key expected: invalid query"},
+		{"$.1a", "offset 2: key expected: invalid query"},
+		{"$.a-b", "offset 3: unexpected character '-': invalid query"},
+		{"$[", "offset 1: unterminated [: invalid query"},
+		{"$[1", "offset 1: unterminated [: invalid query"},
+		{"$[]", `offset 2: invalid index "": invalid query`},
+		{"$[a]", `offset 2: invalid index "a": invalid query`},
+		{"$[#]", `offset 2: invalid tag ID "": invalid query`},
+		{"$[#-1]", `offset 2: invalid tag ID "-1": invalid query`},
+		{`$["a]`, "offset 2: invalid string: invalid query"},
+		{`$["a"`, "offset 5: ] expected: invalid query"},
+		{`$["a"x]`, "offset 5: ] expected: invalid query"},
+	}
+	for _, s := range samples {
+		sel, err := ParseSelector(s.expr)
+		assert.Nil(t, sel)
+		assert.ErrorIs(t, err, ErrBadQuery)
+		assert.EqualError(t, err, s.msg, s.expr)
+	}
+	assert.Panics(t, func() { MustParseSelector("$[") })
+}
+
+// Formats the results of a query as path=tag.
+func formatQueryResults(results []QueryResult) []string {
+	l := []string{}
+	for _, r := range results {
+		l = append(l, r.Path.String()+"="+FormatTag(r.Tag))
+	}
+	return l
+}
+
+func TestQuery(t *testing.T) {
+	root := MustParseTag(queryTestTree)
+	samples := []struct {
+		expr     string
+		expected []string
+	}{
+		{"$.n", []string{"$.n=null"}},
+		{"$.payload.signatures[0].key", []string{`$.payload.signatures[0].key=str("k1")`}},
+		{"$.payload.signatures[-1].alg", []string{`$.payload.signatures[2].alg=u8(2)`}},
+		{`$.payload["a b"][2]`, []string{`$.payload["a b"][2]=ilint(3)`}},
+		{`$.payload.meta.y`, []string{`$.payload.meta.y=str("2")`}},
+		{`$.payload.meta.*`, []string{`$.payload.meta.x=str("1")`, `$.payload.meta.y=str("2")`}},
+		{"$.payload.signatures[*].key", []string{
+			`$.payload.signatures[0].key=str("k1")`,
+			`$.payload.signatures[1].key=str("k2")`,
+			`$.payload.signatures[2].key=str("k3")`}},
+		{"$.payload.signatures[*][#1000].alg", []string{
+			`$.payload.signatures[0].alg=u8(1)`,
+			`$.payload.signatures[2].alg=u8(2)`}},
+		{`$.payload["a b"][*][#0]`, []string{`$.payload["a b"][1]=null`}},
+		{"$.payload.signatures[*][#17]", []string{}},
+		{"$[#17].payload", []string{}},
+		{"$.*[#0]", []string{"$.n=null"}},
+		// Wildcards skip the tags that are not containers
+		{"$.payload.signatures[0].key[*]", []string{}},
+		{"$.*.*", []string{
+			`$.payload.signatures=array[#1000 dict{"key": str("k1"), "alg": u8(1)}, #1001 dict{"key": str("k2")}, #1000 dict{"key": str("k3"), "alg": u8(2)}]`,
+			`$.payload["a b"]=seq[u8(1), null, ilint(3)]`,
+			`$.payload.meta=strdict{"x": "1", "y": "2"}`}},
+		{`$.payload["a b"][*][*]`, []string{}},
+		{"$.payload.*.*[#0]", []string{`$.payload["a b"][1]=null`}},
+	}
+	for _, s := range samples {
+		results, err := Query(root, s.expr)
+		require.Nil(t, err, s.expr)
+		assert.Equal(t, s.expected, formatQueryResults(results), s.expr)
+	}
+
+	results, err := Query(root, "$")
+	require.Nil(t, err)
+	require.Len(t, results, 1)
+	assert.Same(t, root, results[0].Tag)
+	assert.Equal(t, tags.Path{}, results[0].Path)
+
+	// Nil tags are handled as null
+	seq := NewStdILTagSequenceTag()
+	seq.Payload = []tags.ILTag{nil}
+	results, err = Query(seq, "$[*][#0]")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"$[0]=null"}, formatQueryResults(results))
+
+	_, err = Query(root, "$[")
+	assert.ErrorIs(t, err, ErrBadQuery)
+}
+
+func TestQuery_Errors(t *testing.T) {
+	root := MustParseTag(queryTestTree)
+	samples := []struct {
+		expr     string
+		expected error
+		msg      string
+	}{
+		{"$.x", ErrPathNotFound, "$.x: path not found"},
+		{"$.payload.meta.z", ErrPathNotFound, "$.payload.meta.z: path not found"},
+		{"$.payload.signatures[3]", ErrPathNotFound, "$.payload.signatures[3]: path not found"},
+		{"$.payload.signatures[-4]", ErrPathNotFound, "$.payload.signatures[-4]: path not found"},
+		{"$.payload.signatures[*].alg", ErrPathNotFound, "$.payload.signatures[1].alg: path not found"},
+		{"$[0]", ErrTypeMismatch, "$: expected array or sequence, found tag 30 (Dictionary): type mismatch"},
+		{"$.payload.signatures.key", ErrTypeMismatch,
+			"$.payload.signatures: expected dictionary, found tag 21 (ILTagArray): type mismatch"},
+		{"$.payload.signatures[0].key.x", ErrTypeMismatch,
+			"$.payload.signatures[0].key: expected dictionary, found tag 17 (String): type mismatch"},
+		{"$.n[0]", ErrTypeMismatch, "$.n: expected array or sequence, found tag 0 (Null): type mismatch"},
+		{"$.*.*.key", ErrTypeMismatch,
+			"$.payload.signatures: expected dictionary, found tag 21 (ILTagArray): type mismatch"},
+	}
+	for _, s := range samples {
+		results, err := Query(root, s.expr)
+		assert.Nil(t, results)
+		assert.ErrorIs(t, err, s.expected, s.expr)
+		assert.EqualError(t, err, s.msg, s.expr)
+		var qe *QueryError
+		assert.ErrorAs(t, err, &qe)
+	}
+
+	_, err := Query(MustParseTag("#1000 array[]"), "$.a")
+	assert.EqualError(t, err, "$: expected dictionary, found tag 1000: type mismatch")
+	_, err = Query(nil, "$.a")
+	assert.EqualError(t, err, "$: expected dictionary, found null: type mismatch")
+}
+
+func TestQueryOne(t *testing.T) {
+	root := MustParseTag(queryTestTree)
+
+	tag, err := QueryOne(root, "$.payload.signatures[*].key")
+	require.Nil(t, err)
+	assert.Equal(t, `str("k1")`, FormatTag(tag))
+
+	tag, err = QueryOne(root, "$.payload.signatures[*][#1001]")
+	require.Nil(t, err)
+	assert.Equal(t, `#1001 dict{"key": str("k2")}`, FormatTag(tag))
+
+	tag, err = QueryOne(root, "$.payload.signatures[*][#17]")
+	assert.Nil(t, tag)
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	assert.EqualError(t, err, "no tag matches $.payload.signatures[*][#17]: path not found")
+
+	_, err = QueryOne(root, "$.x")
+	assert.EqualError(t, err, "$.x: path not found")
+	_, err = QueryOne(root, "$[")
+	assert.ErrorIs(t, err, ErrBadQuery)
+
+	sel := MustParseSelector("$.payload.signatures[1].key")
+	tag, err = sel.SelectOne(root)
+	require.Nil(t, err)
+	assert.Equal(t, `str("k2")`, FormatTag(tag))
+}
+
+func TestQueryAs(t *testing.T) {
+	root := MustParseTag(queryTestTree)
+
+	key, err := QueryAs[*StringTag](root, "$.payload.signatures[0].key")
+	require.Nil(t, err)
+	assert.Equal(t, "k1", key.Payload)
+
+	alg, err := QueryAs[*UInt8Tag](root, "$.payload.signatures[*][#1000].alg")
+	require.Nil(t, err)
+	assert.Equal(t, uint8(1), alg.Payload)
+
+	sigs, err := QueryAs[*ILTagArrayTag](root, "$.payload.signatures")
+	require.Nil(t, err)
+	assert.Len(t, sigs.Payload, 3)
+
+	_, err = QueryAs[tags.ILTag](root, "$.n")
+	require.Nil(t, err)
+
+	key, err = QueryAs[*StringTag](root, "$.payload.signatures[0].alg")
+	assert.Nil(t, key)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.EqualError(t, err, "$.payload.signatures[0].alg: expected *impl.StringTag, "+
+		"found tag 3 (UInt8): type mismatch")
+
+	_, err = QueryAs[*StringTag](root, "$.payload.signatures[0].x")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	_, err = QueryAs[*StringTag](root, "$.payload.signatures[*][#17]")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	_, err = QueryAs[*StringTag](root, "")
+	assert.ErrorIs(t, err, ErrBadQuery)
+}
+
+// Custom container used by the query tests.
+type queryTestContainer struct {
+	tags.RawTag
+	children []tags.ContainerChild
+}
+
+func (t *queryTestContainer) Children() []tags.ContainerChild {
+	return t.children
+}
+
+func TestQuery_Container(t *testing.T) {
+	c := &queryTestContainer{}
+	c.SetId(2000)
+	c.children = []tags.ContainerChild{
+		{Element: tags.KeyElement("name"), Tag: MustParseTag(`str("n")`)},
+		{Element: tags.IndexElement(0), Tag: MustParseTag("u8(1)")},
+		{Element: tags.IndexElement(1), Tag: MustParseTag("u8(2)")},
+	}
+	root := NewStdILTagArrayTag()
+	root.Payload = []tags.ILTag{c}
+
+	samples := []struct {
+		expr     string
+		expected []string
+	}{
+		{"$[0].name", []string{`$[0].name=str("n")`}},
+		{"$[0][1]", []string{`$[0][1]=u8(2)`}},
+		{"$[0][-2]", []string{`$[0][0]=u8(1)`}},
+		{"$[*][#2000][*]", []string{`$[0].name=str("n")`, `$[0][0]=u8(1)`, `$[0][1]=u8(2)`}},
+	}
+	for _, s := range samples {
+		results, err := Query(root, s.expr)
+		require.Nil(t, err, s.expr)
+		assert.Equal(t, s.expected, formatQueryResults(results), s.expr)
+	}
+
+	_, err := Query(root, "$[0].x")
+	assert.EqualError(t, err, "$[0].x: path not found")
+	_, err = Query(root, "$[0][2]")
+	assert.EqualError(t, err, "$[0][2]: path not found")
+	_, err = Query(root, "$[0][-3]")
+	assert.EqualError(t, err, "$[0][-3]: path not found")
+}
@@ -0,0 +1,158 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Returned by a TransformFunc to remove the tag from its container. It is not
+handled as an error by Transform().
+*/
+var RemoveTag = fmt.Errorf("remove tag")
+
+/*
+TransformFunc is the function called by Transform() for each tag of the tree.
+It returns the tag that will replace the given tag, the tag itself to keep it
+or RemoveTag to remove it from its container.
+*/
+type TransformFunc func(path tags.Path, tag tags.ILTag) (tags.ILTag, error)
+
+// State of Transform().
+type transformer struct {
+	fn        TransformFunc
+	rewritten []tags.Path
+}
+
+/*
+Transforms a tag and its children. It returns the new tag and true if the tag
+must be removed.
+*/
+func (t *transformer) transform(path tags.Path, tag tags.ILTag) (tags.ILTag, bool, error) {
+	if r, ok := tag.(tags.Rebuilder); ok && !tags.IsILTagNil(tag) {
+		children := r.Children()
+		kept := make([]tags.ContainerChild, 0, len(children))
+		changed := false
+		for _, c := range children {
+			n, remove, err := t.transform(path.Append(c.Element), c.Tag)
+			if err != nil {
+				return nil, false, err
+			}
+			if remove || n != c.Tag {
+				changed = true
+			}
+			if !remove {
+				c.Tag = n
+				kept = append(kept, c)
+			}
+		}
+		if changed {
+			rebuilt, err := r.Rebuild(kept)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s: %w", path, err)
+			}
+			tag = rebuilt
+		}
+	}
+	n, err := t.fn(path, tag)
+	if errors.Is(err, RemoveTag) {
+		t.rewritten = append(t.rewritten, path)
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	if n != tag {
+		t.rewritten = append(t.rewritten, path)
+	}
+	return n, false, nil
+}
+
+/*
+Rewrites a tag tree. The function fn is called for every tag of the tree, from
+the leaves to the root, thus the containers are received after their children
+were transformed. Containers with transformed children are rebuilt as new
+containers that keep the order of the entries, including the order of the keys
+of DictionaryTags. The original tree is never modified.
+
+Only the containers that implement tags.Rebuilder, like ILTagArrayTags,
+ILTagSequenceTags and DictionaryTags, are rebuilt by this function. Other tags,
+including other containers, are passed to fn as they are. Errors returned by
+Rebuild() stop the transformation like the errors returned by fn.
+
+It returns the transformed tree and the paths of all tags replaced or removed by
+fn in the order they were transformed. The paths refer to the original tree. If
+the root is removed, the result is nil. Errors returned by fn stop the
+transformation and are returned prefixed with the path of the tag.
+*/
+func Transform(tag tags.ILTag, fn TransformFunc) (tags.ILTag, []tags.Path, error) {
+	t := transformer{fn: fn, rewritten: []tags.Path{}}
+	n, _, err := t.transform(tags.Path{}, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return n, t.rewritten, nil
+}
+
+/*
+Returns a TransformFunc that replaces the RawTags by the tags created by the
+factory. RawTags with IDs unknown to the factory are kept as they are.
+*/
+func RetypeRawTags(factory tags.ILTagFactory) TransformFunc {
+	return func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		raw, ok := tag.(*tags.RawTag)
+		if !ok || raw == nil {
+			return tag, nil
+		}
+		n, err := factory.CreateTag(raw.Id())
+		if errors.Is(err, tags.ErrUnsupportedTagId) {
+			return tag, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := n.(*tags.RawTag); ok {
+			return tag, nil
+		}
+		if err := n.DeserializeValue(factory, len(raw.Payload),
+			bytes.NewReader(raw.Payload)); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+}
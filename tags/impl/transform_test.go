@@ -0,0 +1,237 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"io"
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Formats a list of paths.
+func formatPaths(paths []tags.Path) []string {
+	l := []string{}
+	for _, p := range paths {
+		l = append(l, p.String())
+	}
+	return l
+}
+
+func TestTransform(t *testing.T) {
+	src := `dict{"a": array[i32(1), u8(2), i32(3)], "b": seq[i32(4)], "c": u8(5), "d": strdict{"x": "y"}}`
+	root := MustParseTag(src)
+
+	// Converts Int32Tag into ILIntTag
+	var visited []string
+	transformed, rewritten, err := Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		visited = append(visited, path.String())
+		if i, ok := tag.(*Int32Tag); ok {
+			n := NewStdILIntTag()
+			n.Payload = uint64(i.Payload)
+			return n, nil
+		}
+		return tag, nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, `dict{"a": array[ilint(1), u8(2), ilint(3)], "b": seq[ilint(4)], `+
+		`"c": u8(5), "d": strdict{"x": "y"}}`, FormatTag(transformed))
+	assert.Equal(t, []string{"$.a[0]", "$.a[2]", "$.b[0]"}, formatPaths(rewritten))
+	assert.Equal(t, []string{"$.a[0]", "$.a[1]", "$.a[2]", "$.a", "$.b[0]", "$.b",
+		"$.c", "$.d", "$"}, visited)
+	// The original tree is not modified and unchanged tags are shared
+	assert.Equal(t, src, FormatTag(root))
+	c1, _ := root.(*DictionaryTag).Map.Get("d")
+	c2, _ := transformed.(*DictionaryTag).Map.Get("d")
+	assert.Same(t, c1, c2)
+
+	// Nothing to do
+	transformed, rewritten, err = Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		return tag, nil
+	})
+	require.Nil(t, err)
+	assert.Same(t, root, transformed)
+	assert.Equal(t, []string{}, formatPaths(rewritten))
+}
+
+func TestTransform_Remove(t *testing.T) {
+	root := MustParseTag(`dict{"a": array[u8(1), u8(2), u8(3), u8(4)], "b": u8(5), "c": null, "d": u8(6)}`)
+	transformed, rewritten, err := Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if u, ok := tag.(*UInt8Tag); ok && u.Payload%2 == 0 {
+			return nil, RemoveTag
+		}
+		if path.String() == "$.c" {
+			return nil, RemoveTag
+		}
+		if u, ok := tag.(*UInt8Tag); ok && u.Payload == 3 {
+			return MustParseTag("u8(30)"), nil
+		}
+		return tag, nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, `dict{"a": array[u8(1), u8(30)], "b": u8(5)}`, FormatTag(transformed))
+	assert.Equal(t, []string{"$.a[1]", "$.a[2]", "$.a[3]", "$.c", "$.d"}, formatPaths(rewritten))
+
+	// Removes the root
+	transformed, rewritten, err = Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if len(path) == 0 {
+			return nil, RemoveTag
+		}
+		return tag, nil
+	})
+	require.Nil(t, err)
+	assert.Nil(t, transformed)
+	assert.Equal(t, []string{"$"}, formatPaths(rewritten))
+}
+
+func TestTransform_Errors(t *testing.T) {
+	root := MustParseTag(`seq[u8(1), array[u8(2)]]`)
+	transformed, rewritten, err := Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if u, ok := tag.(*UInt8Tag); ok && u.Payload == 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return MustParseTag("null"), nil
+	})
+	assert.Nil(t, transformed)
+	assert.Nil(t, rewritten)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.EqualError(t, err, "$[1][0]: unexpected EOF")
+
+	// Nil tags
+	seq := NewStdILTagSequenceTag()
+	seq.Payload = []tags.ILTag{nil}
+	transformed, rewritten, err = Transform(seq, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if tag == nil {
+			return NewStdNullTag(), nil
+		}
+		return tag, nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "seq[null]", FormatTag(transformed))
+	assert.Equal(t, []string{"$[0]"}, formatPaths(rewritten))
+	assert.Nil(t, seq.Payload[0])
+}
+
+func TestRetypeRawTags(t *testing.T) {
+	root := MustParseTag(`array[#1000 bytes(0x01), #1001 bytes(0x02), bytes(0x03), #1002 bytes(0x0405)]`)
+	factory := NewStandardTagFactory(true)
+	factory.RegisterTag(1000, func(id tags.TagID) tags.ILTag { return NewUInt8Tag(id) })
+	factory.RegisterTag(1002, func(id tags.TagID) tags.ILTag { return tags.NewRawTag(id) })
+
+	transformed, rewritten, err := Transform(root, RetypeRawTags(factory))
+	require.Nil(t, err)
+	assert.Equal(t, `array[#1000 u8(1), #1001 bytes(0x02), bytes(0x03), #1002 bytes(0x0405)]`,
+		FormatTag(transformed))
+	assert.Equal(t, []string{"$[0]"}, formatPaths(rewritten))
+
+	// Invalid payload
+	factory.RegisterTag(1002, func(id tags.TagID) tags.ILTag { return NewUInt8Tag(id) })
+	_, _, err = Transform(root, RetypeRawTags(factory))
+	assert.ErrorIs(t, err, tags.ErrBadTagFormat)
+	assert.Contains(t, err.Error(), "$[3]: ")
+
+	// Factory errors
+	fn := RetypeRawTags(transformBrokenFactory{})
+	_, err = fn(nil, MustParseTag("#1000 bytes(0x01)"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+// Factory that always fails.
+type transformBrokenFactory struct{}
+
+func (transformBrokenFactory) CreateTag(id tags.TagID) (tags.ILTag, error) {
+	return nil, io.ErrClosedPipe
+}
+
+// Custom container with a fixed number of children used by the transform tests.
+type transformTestContainer struct {
+	queryTestContainer
+}
+
+func (t *transformTestContainer) Rebuild(children []tags.ContainerChild) (tags.ILTag, error) {
+	if len(children) != len(t.children) {
+		return nil, tags.ErrBadChildren
+	}
+	c := &transformTestContainer{}
+	c.SetId(t.Id())
+	c.children = children
+	return c, nil
+}
+
+func TestTransform_Rebuilder(t *testing.T) {
+	c := &transformTestContainer{}
+	c.SetId(2000)
+	c.children = []tags.ContainerChild{
+		{Element: tags.KeyElement("name"), Tag: MustParseTag(`str("n")`)},
+		{Element: tags.IndexElement(0), Tag: MustParseTag("i32(1)")},
+	}
+	root := NewStdILTagSequenceTag()
+	root.Payload = []tags.ILTag{c, &c.queryTestContainer}
+
+	var visited []string
+	transformed, rewritten, err := Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		visited = append(visited, path.String())
+		if i, ok := tag.(*Int32Tag); ok {
+			n := NewStdILIntTag()
+			n.Payload = uint64(i.Payload)
+			return n, nil
+		}
+		return tag, nil
+	})
+	require.Nil(t, err)
+	// Containers that are not Rebuilders are not entered
+	assert.Equal(t, []string{"$[0].name", "$[0][0]", "$[0]", "$[1]", "$"}, visited)
+	assert.Equal(t, []string{"$[0][0]"}, formatPaths(rewritten))
+	n := transformed.(*ILTagSequenceTag).Payload[0].(*transformTestContainer)
+	assert.NotSame(t, c, n)
+	assert.Equal(t, tags.TagID(2000), n.Id())
+	assert.Equal(t, []tags.ContainerChild{
+		{Element: tags.KeyElement("name"), Tag: c.children[0].Tag},
+		{Element: tags.IndexElement(0), Tag: MustParseTag("ilint(1)")},
+	}, n.children)
+	assert.Equal(t, "i32(1)", FormatTag(c.children[1].Tag))
+	assert.Same(t, &c.queryTestContainer, transformed.(*ILTagSequenceTag).Payload[1])
+
+	// Errors of Rebuild()
+	transformed, _, err = Transform(root, func(path tags.Path, tag tags.ILTag) (tags.ILTag, error) {
+		if path.String() == "$[0].name" {
+			return nil, RemoveTag
+		}
+		return tag, nil
+	})
+	assert.Nil(t, transformed)
+	assert.ErrorIs(t, err, tags.ErrBadChildren)
+	assert.EqualError(t, err, "$[0]: bad container children")
+}
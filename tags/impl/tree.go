@@ -0,0 +1,268 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// The path does not exist in the tag tree.
+var ErrPathNotFound = fmt.Errorf("path not found")
+
+/*
+Returns the child of a container selected by the path element. Keys select the
+entries of DictionaryTags while indexes select the elements of ILTagArrayTags
+and ILTagSequenceTags.
+*/
+func childOf(tag tags.ILTag, e tags.PathElement) (tags.ILTag, bool) {
+	if e.IsKey {
+		if t, ok := tag.(*DictionaryTag); ok {
+			return t.Map.Get(e.Key)
+		}
+		return nil, false
+	}
+	var l []tags.ILTag
+	switch t := tag.(type) {
+	case *ILTagArrayTag:
+		l = t.Payload
+	case *ILTagSequenceTag:
+		l = t.Payload
+	default:
+		return nil, false
+	}
+	if e.Index < 0 || e.Index >= len(l) {
+		return nil, false
+	}
+	return l[e.Index], true
+}
+
+/*
+Returns a shallow copy of a container. The copy has its own list of children,
+thus it can be modified without changing the original container. It returns nil
+if the tag is not a container.
+*/
+func cloneContainer(tag tags.ILTag) tags.ILTag {
+	switch t := tag.(type) {
+	case *ILTagArrayTag:
+		c := NewILTagArrayTag(t.Id())
+		if t.Payload != nil {
+			c.Payload = append([]tags.ILTag{}, t.Payload...)
+		}
+		return c
+	case *ILTagSequenceTag:
+		c := NewILTagSequenceTag(t.Id())
+		if t.Payload != nil {
+			c.Payload = append([]tags.ILTag{}, t.Payload...)
+		}
+		return c
+	case *DictionaryTag:
+		c := NewDictionaryTag(t.Id())
+		for _, e := range t.Map.Entries() {
+			c.Map.Put(e.Key, e.Value)
+		}
+		return c
+	}
+	return nil
+}
+
+/*
+Returns a pointer to the list of children of ILTagArrayTags and
+ILTagSequenceTags or nil for other tags.
+*/
+func listOf(tag tags.ILTag) *[]tags.ILTag {
+	switch t := tag.(type) {
+	case *ILTagArrayTag:
+		return &t.Payload
+	case *ILTagSequenceTag:
+		return &t.Payload
+	}
+	return nil
+}
+
+/*
+Replaces an existing child of a container. It returns false if the child does
+not exist.
+*/
+func setChild(tag tags.ILTag, e tags.PathElement, child tags.ILTag) bool {
+	if _, ok := childOf(tag, e); !ok {
+		return false
+	}
+	if e.IsKey {
+		tag.(*DictionaryTag).Map.Put(e.Key, child)
+	} else {
+		(*listOf(tag))[e.Index] = child
+	}
+	return true
+}
+
+/*
+Inserts a new child into a container. Keys must not exist in the dictionary
+and are added to its end. Indexes may point to any existing element or to the
+end of the list. It returns false if the child cannot be inserted.
+*/
+func insertChild(tag tags.ILTag, e tags.PathElement, child tags.ILTag) bool {
+	if e.IsKey {
+		t, ok := tag.(*DictionaryTag)
+		if !ok {
+			return false
+		}
+		if _, found := t.Map.Get(e.Key); found {
+			return false
+		}
+		t.Map.Put(e.Key, child)
+		return true
+	}
+	l := listOf(tag)
+	if l == nil || e.Index < 0 || e.Index > len(*l) {
+		return false
+	}
+	*l = append(*l, nil)
+	copy((*l)[e.Index+1:], (*l)[e.Index:])
+	(*l)[e.Index] = child
+	return true
+}
+
+/*
+Removes an existing child from a container. It returns false if the child does
+not exist.
+*/
+func removeChild(tag tags.ILTag, e tags.PathElement) bool {
+	if _, ok := childOf(tag, e); !ok {
+		return false
+	}
+	if e.IsKey {
+		t := tag.(*DictionaryTag)
+		t.Map.Remove(e.Key)
+		t.Map.Rebuild()
+	} else {
+		l := listOf(tag)
+		*l = append((*l)[:e.Index], (*l)[e.Index+1:]...)
+	}
+	return true
+}
+
+/*
+Returns true if both tags have the same serialization. Nil tags are handled as
+ILNullTags. Tags that cannot be serialized are never equal.
+*/
+func EqualTags(a, b tags.ILTag) bool {
+	var ba, bb bytes.Buffer
+	if tags.ILTagSeralizeWithNull(a, &ba) != nil ||
+		tags.ILTagSeralizeWithNull(b, &bb) != nil {
+		return false
+	}
+	return bytes.Equal(ba.Bytes(), bb.Bytes())
+}
+
+// Returns the elements of a list as children of a container.
+func listChildren(l []tags.ILTag) []tags.ContainerChild {
+	children := make([]tags.ContainerChild, len(l))
+	for i, t := range l {
+		children[i] = tags.ContainerChild{Element: tags.IndexElement(i), Tag: t}
+	}
+	return children
+}
+
+// Implementation of tags.Container.
+func (p *ILTagArrayPayload) Children() []tags.ContainerChild {
+	return listChildren(p.Payload)
+}
+
+// Implementation of tags.Container.
+func (p *ILTagSequencePayload) Children() []tags.ContainerChild {
+	return listChildren(p.Payload)
+}
+
+// Implementation of tags.Container.
+func (p *DictionaryPayload) Children() []tags.ContainerChild {
+	children := make([]tags.ContainerChild, 0, p.Map.Size())
+	for _, e := range p.Map.Entries() {
+		children = append(children, tags.ContainerChild{
+			Element: tags.KeyElement(e.Key), Tag: e.Value})
+	}
+	return children
+}
+
+// Returns the tags of the children of a container.
+func childTags(children []tags.ContainerChild) []tags.ILTag {
+	l := make([]tags.ILTag, len(children))
+	for i, c := range children {
+		l[i] = c.Tag
+	}
+	return l
+}
+
+// Implementation of tags.Rebuilder.
+func (t *ILTagArrayTag) Rebuild(children []tags.ContainerChild) (tags.ILTag, error) {
+	c := NewILTagArrayTag(t.Id())
+	c.Payload = childTags(children)
+	return c, nil
+}
+
+// Implementation of tags.Rebuilder.
+func (t *ILTagSequenceTag) Rebuild(children []tags.ContainerChild) (tags.ILTag, error) {
+	c := NewILTagSequenceTag(t.Id())
+	c.Payload = childTags(children)
+	return c, nil
+}
+
+// Implementation of tags.Rebuilder. All children must be selected by keys.
+func (t *DictionaryTag) Rebuild(children []tags.ContainerChild) (tags.ILTag, error) {
+	c := NewDictionaryTag(t.Id())
+	for _, child := range children {
+		if !child.Element.IsKey {
+			return nil, fmt.Errorf("entry %s without key: %w", child.Element,
+				tags.ErrBadChildren)
+		}
+		c.Map.Put(child.Element.Key, child.Tag)
+	}
+	return c, nil
+}
+
+/*
+Implementation of tags.Container. The values are returned as new standard
+StringTags, thus changing them does not affect the dictionary.
+*/
+func (p *StringDictionaryPayload) Children() []tags.ContainerChild {
+	children := make([]tags.ContainerChild, 0, p.Map.Size())
+	for _, e := range p.Map.Entries() {
+		t := NewStdStringTag()
+		t.Payload = e.Value
+		children = append(children, tags.ContainerChild{
+			Element: tags.KeyElement(e.Key), Tag: t})
+	}
+	return children
+}
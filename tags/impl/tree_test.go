@@ -0,0 +1,213 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildOf(t *testing.T) {
+	dict := MustParseTag(`dict{"a": u8(1), "b": null}`)
+	c, ok := childOf(dict, tags.KeyElement("a"))
+	assert.True(t, ok)
+	assert.Equal(t, "u8(1)", FormatTag(c))
+	_, ok = childOf(dict, tags.KeyElement("c"))
+	assert.False(t, ok)
+	_, ok = childOf(dict, tags.IndexElement(0))
+	assert.False(t, ok)
+
+	for _, s := range []string{"array[u8(1), u8(2)]", "seq[u8(1), u8(2)]"} {
+		list := MustParseTag(s)
+		c, ok = childOf(list, tags.IndexElement(1))
+		assert.True(t, ok)
+		assert.Equal(t, "u8(2)", FormatTag(c))
+		_, ok = childOf(list, tags.IndexElement(2))
+		assert.False(t, ok)
+		_, ok = childOf(list, tags.IndexElement(-1))
+		assert.False(t, ok)
+		_, ok = childOf(list, tags.KeyElement("a"))
+		assert.False(t, ok)
+	}
+
+	_, ok = childOf(MustParseTag("u8(1)"), tags.IndexElement(0))
+	assert.False(t, ok)
+	_, ok = childOf(MustParseTag(`strdict{"a": "b"}`), tags.KeyElement("a"))
+	assert.False(t, ok)
+}
+
+func TestCloneContainer(t *testing.T) {
+	for _, s := range []string{"array[u8(1), null]", "seq[u8(1), null]",
+		`#1000 dict{"b": u8(1), "a": null}`, "array[]", "seq[]", "dict{}"} {
+		tag := MustParseTag(s)
+		c := cloneContainer(tag)
+		assert.NotSame(t, tag, c)
+		assert.Equal(t, s, FormatTag(c))
+
+		// The copy is independent
+		if insertChild(c, tags.IndexElement(0), NewStdNullTag()) ||
+			insertChild(c, tags.KeyElement("x"), NewStdNullTag()) {
+			assert.Equal(t, s, FormatTag(tag))
+			assert.NotEqual(t, s, FormatTag(c))
+		}
+	}
+	// Nil payloads
+	c := cloneContainer(NewStdILTagArrayTag()).(*ILTagArrayTag)
+	assert.Nil(t, c.Payload)
+	c2 := cloneContainer(NewStdILTagSequenceTag()).(*ILTagSequenceTag)
+	assert.Nil(t, c2.Payload)
+
+	assert.Nil(t, cloneContainer(MustParseTag("u8(1)")))
+	assert.Nil(t, cloneContainer(MustParseTag(`strdict{}`)))
+}
+
+func TestSetChild(t *testing.T) {
+	tag := MustParseTag(`dict{"a": u8(1), "b": null}`)
+	assert.True(t, setChild(tag, tags.KeyElement("a"), NewStdNullTag()))
+	assert.False(t, setChild(tag, tags.KeyElement("c"), NewStdNullTag()))
+	assert.Equal(t, `dict{"a": null, "b": null}`, FormatTag(tag))
+
+	tag = MustParseTag(`array[u8(1), u8(2)]`)
+	assert.True(t, setChild(tag, tags.IndexElement(1), NewStdNullTag()))
+	assert.False(t, setChild(tag, tags.IndexElement(2), NewStdNullTag()))
+	assert.Equal(t, `array[u8(1), null]`, FormatTag(tag))
+}
+
+func TestInsertChild(t *testing.T) {
+	tag := MustParseTag(`dict{"a": u8(1)}`)
+	assert.True(t, insertChild(tag, tags.KeyElement("b"), NewStdNullTag()))
+	assert.False(t, insertChild(tag, tags.KeyElement("a"), NewStdNullTag()))
+	assert.False(t, insertChild(tag, tags.IndexElement(0), NewStdNullTag()))
+	assert.Equal(t, `dict{"a": u8(1), "b": null}`, FormatTag(tag))
+
+	tag = MustParseTag(`seq[u8(1), u8(2)]`)
+	assert.True(t, insertChild(tag, tags.IndexElement(2), MustParseTag("u8(4)")))
+	assert.True(t, insertChild(tag, tags.IndexElement(0), MustParseTag("u8(0)")))
+	assert.True(t, insertChild(tag, tags.IndexElement(3), MustParseTag("u8(3)")))
+	assert.False(t, insertChild(tag, tags.IndexElement(6), NewStdNullTag()))
+	assert.False(t, insertChild(tag, tags.IndexElement(-1), NewStdNullTag()))
+	assert.False(t, insertChild(tag, tags.KeyElement("a"), NewStdNullTag()))
+	assert.Equal(t, `seq[u8(0), u8(1), u8(2), u8(3), u8(4)]`, FormatTag(tag))
+
+	assert.False(t, insertChild(MustParseTag("u8(1)"), tags.IndexElement(0), NewStdNullTag()))
+}
+
+func TestRemoveChild(t *testing.T) {
+	tag := MustParseTag(`dict{"a": u8(1), "b": null, "c": u8(3)}`)
+	assert.True(t, removeChild(tag, tags.KeyElement("b")))
+	assert.False(t, removeChild(tag, tags.KeyElement("b")))
+	assert.Equal(t, `dict{"a": u8(1), "c": u8(3)}`, FormatTag(tag))
+
+	tag = MustParseTag(`array[u8(1), u8(2), u8(3)]`)
+	assert.True(t, removeChild(tag, tags.IndexElement(1)))
+	assert.False(t, removeChild(tag, tags.IndexElement(2)))
+	assert.Equal(t, `array[u8(1), u8(3)]`, FormatTag(tag))
+	assert.True(t, removeChild(tag, tags.IndexElement(1)))
+	assert.True(t, removeChild(tag, tags.IndexElement(0)))
+	assert.Equal(t, `array[]`, FormatTag(tag))
+}
+
+func TestEqualTags(t *testing.T) {
+	assert.True(t, EqualTags(nil, nil))
+	assert.True(t, EqualTags(nil, NewStdNullTag()))
+	assert.True(t, EqualTags(MustParseTag(`dict{"a": u8(1)}`), MustParseTag(`dict{"a": u8(1)}`)))
+	assert.True(t, EqualTags(MustParseTag(`#1000 bytes(0x01)`), MustParseTag(`#1000 u8(1)`)))
+	assert.False(t, EqualTags(MustParseTag(`dict{"a": u8(1)}`), MustParseTag(`dict{"a": u8(2)}`)))
+	assert.False(t, EqualTags(MustParseTag(`u8(1)`), nil))
+
+	// Tags that cannot be serialized
+	bad := notationBrokenTag{NewUInt16Tag(1000)}
+	assert.False(t, EqualTags(bad, bad))
+}
+
+func TestContainers(t *testing.T) {
+	var _ tags.Container = (*ILTagArrayTag)(nil)
+	var _ tags.Container = (*ILTagSequenceTag)(nil)
+	var _ tags.Container = (*DictionaryTag)(nil)
+	var _ tags.Container = (*StringDictionaryTag)(nil)
+
+	format := func(tag tags.ILTag) []string {
+		l := []string{}
+		for _, c := range tag.(tags.Container).Children() {
+			l = append(l, c.Element.String()+"="+FormatTag(c.Tag))
+		}
+		return l
+	}
+	assert.Equal(t, []string{"[0]=u8(1)", "[1]=null"}, format(MustParseTag("array[u8(1), null]")))
+	assert.Equal(t, []string{"[0]=u8(1)", "[1]=null"}, format(MustParseTag("seq[u8(1), null]")))
+	assert.Equal(t, []string{".a=u8(1)", `["b c"]=null`},
+		format(MustParseTag(`dict{"a": u8(1), "b c": null}`)))
+	assert.Equal(t, []string{`.a=str("1")`, `.b=str("2")`},
+		format(MustParseTag(`strdict{"a": "1", "b": "2"}`)))
+	assert.Equal(t, []string{}, format(MustParseTag("array[]")))
+	assert.Equal(t, []string{}, format(MustParseTag("dict{}")))
+
+	// Children share the tags with the container
+	array := MustParseTag("array[u8(1)]").(*ILTagArrayTag)
+	assert.Same(t, array.Payload[0], array.Children()[0].Tag)
+
+	var paths []string
+	assert.Nil(t, tags.WalkFunc(MustParseTag(`dict{"a": array[seq[u8(1)]], "b": strdict{"c": "d"}}`),
+		func(path tags.Path, tag tags.ILTag) error {
+			paths = append(paths, path.String())
+			return nil
+		}))
+	assert.Equal(t, []string{"$", "$.a", "$.a[0]", "$.a[0][0]", "$.b", "$.b.c"}, paths)
+}
+
+func TestRebuild(t *testing.T) {
+	dict := MustParseTag(`#1000 dict{"a": u8(1)}`).(*DictionaryTag)
+	n, err := dict.Rebuild([]tags.ContainerChild{
+		{Element: tags.KeyElement("b"), Tag: MustParseTag("u8(2)")},
+		{Element: tags.KeyElement("a"), Tag: nil},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, `#1000 dict{"b": u8(2), "a": null}`, FormatTag(n))
+	_, err = dict.Rebuild([]tags.ContainerChild{{Element: tags.IndexElement(0)}})
+	assert.ErrorIs(t, err, tags.ErrBadChildren)
+	assert.Equal(t, `#1000 dict{"a": u8(1)}`, FormatTag(dict))
+
+	array := MustParseTag(`#1001 array[u8(1)]`).(*ILTagArrayTag)
+	n, err = array.Rebuild(nil)
+	require.Nil(t, err)
+	assert.Equal(t, `#1001 array[]`, FormatTag(n))
+	assert.Equal(t, `#1001 array[u8(1)]`, FormatTag(array))
+
+	seq := MustParseTag(`#1002 seq[u8(1)]`).(*ILTagSequenceTag)
+	n, err = seq.Rebuild(append(seq.Children(), seq.Children()...))
+	require.Nil(t, err)
+	assert.Equal(t, `#1002 seq[u8(1), u8(1)]`, FormatTag(n))
+}
@@ -32,5 +32,11 @@
 
 /*
 This package contains the interfaces that implement the ILTags standard.
+
+Walk() visits all tags of a tree. The other operations on tag trees live in the
+package impl because they depend on the concrete tags: impl.Diff() compares two
+trees and impl.Apply() applies the changes it returns, impl.Query() selects the
+tags of a tree with path expressions and impl.Transform() rewrites a tree.
+Custom containers take part in Transform() by implementing Rebuilder.
 */
 package tags
@@ -0,0 +1,152 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Representation of the root of a Path.
+const PathRoot = "$"
+
+/*
+PathElement is a single step of a Path. It selects an entry of a dictionary by
+its key or an element of an array or sequence by its index.
+*/
+type PathElement struct {
+	// Key of the dictionary entry. Used only if IsKey is true.
+	Key string
+	// Index of the element. Used only if IsKey is false.
+	Index int
+	// If true, this element is a dictionary key, otherwise it is an index.
+	IsKey bool
+}
+
+// Creates a new PathElement that selects a dictionary key.
+func KeyElement(key string) PathElement {
+	return PathElement{Key: key, IsKey: true}
+}
+
+// Creates a new PathElement that selects the element at the given index.
+func IndexElement(index int) PathElement {
+	return PathElement{Index: index}
+}
+
+// Returns true if the key can be represented after a dot.
+func isPathIdent(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Returns the representation of this element. Keys are represented as .key when
+they are identifiers and as ["key"] otherwise. Indexes are represented as
+[index].
+*/
+func (e PathElement) String() string {
+	if !e.IsKey {
+		return "[" + strconv.Itoa(e.Index) + "]"
+	}
+	if isPathIdent(e.Key) {
+		return "." + e.Key
+	}
+	return "[" + strconv.Quote(e.Key) + "]"
+}
+
+/*
+Path is the location of a tag inside a tree of tags. The empty path selects the
+root of the tree.
+*/
+type Path []PathElement
+
+/*
+Returns a new path with the given key appended to it. The original path is not
+modified.
+*/
+func (p Path) Key(key string) Path {
+	return p.Append(KeyElement(key))
+}
+
+/*
+Returns a new path with the given index appended to it. The original path is
+not modified.
+*/
+func (p Path) Index(index int) Path {
+	return p.Append(IndexElement(index))
+}
+
+/*
+Returns a new path with the given elements appended to it. The original path
+is not modified.
+*/
+func (p Path) Append(elements ...PathElement) Path {
+	n := make(Path, len(p), len(p)+len(elements))
+	copy(n, p)
+	return append(n, elements...)
+}
+
+// Returns true if both paths are equal.
+func (p Path) Equal(other Path) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for i, e := range p {
+		if e != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Returns the representation of this path, as in $.payload.signatures[0]["a b"].
+*/
+func (p Path) String() string {
+	var sb strings.Builder
+	sb.WriteString(PathRoot)
+	for _, e := range p {
+		sb.WriteString(e.String())
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathElement(t *testing.T) {
+	assert.Equal(t, PathElement{Key: "a", IsKey: true}, KeyElement("a"))
+	assert.Equal(t, PathElement{Index: 2}, IndexElement(2))
+
+	assert.Equal(t, ".a", KeyElement("a").String())
+	assert.Equal(t, "._a1", KeyElement("_a1").String())
+	assert.Equal(t, ".Zz", KeyElement("Zz").String())
+	assert.Equal(t, `[""]`, KeyElement("").String())
+	assert.Equal(t, `["1a"]`, KeyElement("1a").String())
+	assert.Equal(t, `["a b"]`, KeyElement("a b").String())
+	assert.Equal(t, `["a\"b"]`, KeyElement(`a"b`).String())
+	assert.Equal(t, `["ç"]`, KeyElement("ç").String())
+	assert.Equal(t, "[0]", IndexElement(0).String())
+	assert.Equal(t, "[123]", IndexElement(123).String())
+}
+
+func TestPath(t *testing.T) {
+	var root Path
+	assert.Equal(t, "$", root.String())
+
+	p := root.Key("payload").Key("signatures").Index(0).Key("a b")
+	assert.Equal(t, `$.payload.signatures[0]["a b"]`, p.String())
+	assert.Equal(t, Path{KeyElement("payload"), KeyElement("signatures"),
+		IndexElement(0), KeyElement("a b")}, p)
+
+	// Appending must not modify the original path
+	base := make(Path, 1, 10)
+	base[0] = KeyElement("a")
+	p1 := base.Index(1)
+	p2 := base.Index(2)
+	assert.Equal(t, "$.a[1]", p1.String())
+	assert.Equal(t, "$.a[2]", p2.String())
+	assert.Equal(t, "$.a[1][2].b", p1.Append(IndexElement(2), KeyElement("b")).String())
+	assert.Equal(t, "$.a[1]", p1.String())
+
+	assert.True(t, root.Equal(Path{}))
+	assert.True(t, p1.Equal(Path{KeyElement("a"), IndexElement(1)}))
+	assert.False(t, p1.Equal(p2))
+	assert.False(t, p1.Equal(base))
+	assert.False(t, Path{KeyElement("1")}.Equal(Path{IndexElement(1)}))
+}
@@ -33,7 +33,10 @@
 package tags
 
 import (
+	"encoding/hex"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/interlockledger/go-iltags/serialization"
 )
@@ -87,3 +90,24 @@ func NewRawTag(id TagID) *RawTag {
 	t.SetId(id)
 	return &t
 }
+
+/*
+Returns the text notation of this tag. The payload is represented as bytes and
+the tag ID is omitted if it is the ID of the standard bytes tag, as in
+`bytes(0xCAFE)` or `#1000 bytes(0xDEAD)`.
+
+The full text notation is implemented by the impl package.
+*/
+func (t *RawTag) String() string {
+	var sb strings.Builder
+	if t.Id() != IL_BYTES_TAG_ID {
+		fmt.Fprintf(&sb, "#%d ", t.Id())
+	}
+	sb.WriteString("bytes(")
+	if len(t.Payload) > 0 {
+		sb.WriteString("0x")
+		sb.WriteString(strings.ToUpper(hex.EncodeToString(t.Payload)))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
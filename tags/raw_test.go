@@ -102,3 +102,15 @@ func TestNewRawTag(t *testing.T) {
 	var tag *RawTag = NewRawTag(id)
 	assert.Equal(t, id, tag.Id())
 }
+
+func TestRawTag_String(t *testing.T) {
+	tag := NewRawTag(IL_BYTES_TAG_ID)
+	assert.Equal(t, "bytes()", tag.String())
+	tag.Payload = []byte{0xCA, 0xFE}
+	assert.Equal(t, "bytes(0xCAFE)", tag.String())
+
+	tag = NewRawTag(1000)
+	assert.Equal(t, "#1000 bytes()", tag.String())
+	tag.Payload = []byte{0xDE, 0xAD, 0x01}
+	assert.Equal(t, "#1000 bytes(0xDEAD01)", tag.String())
+}
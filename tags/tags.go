@@ -33,6 +33,7 @@
 package tags
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 	"reflect"
@@ -179,6 +180,87 @@ func readTagHeader(reader io.Reader) (TagID, uint64, error) {
 	return tagId, size, nil
 }
 
+/*
+Maximum size of the header of a serialized tag: its ID and the size of its
+payload encoded as ILInts. It is also enough to hold the ID of an ILInt tag
+followed by the first byte of its payload.
+*/
+const MAX_TAG_HEADER_SIZE = 2 * 9
+
+/*
+Decodes the ILInt at the beginning of b. It fails with io.ErrUnexpectedEOF if b
+does not hold the complete ILInt.
+*/
+func decodeHeaderILInt(b []byte) (uint64, int, error) {
+	if len(b) == 0 || len(b) < ilint.EncodedSizeFromHeader(b[0]) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	v, n, err := ilint.Decode(b)
+	if err != nil {
+		return 0, 0, ErrBadTagFormat
+	}
+	return v, n, nil
+}
+
+/*
+Parses the header of the serialized tag at the beginning of b. It returns the
+ID of the tag, the size of its header and the size of its payload, thus the tag
+takes headerSize + payloadSize bytes. The payload size of the ILInt tags is
+taken from the first byte of their payloads. MAX_TAG_HEADER_SIZE bytes are
+always enough to parse the header.
+
+It fails with io.ErrUnexpectedEOF if b does not hold the complete header, with
+ErrBadTagFormat if it is corrupted and with ErrUnsupportedTagId if the ID is a
+reserved implicit ID. The payload size is not checked against any limit.
+*/
+func ParseTagHeader(b []byte) (id TagID, headerSize int, payloadSize uint64, err error) {
+	v, n, err := decodeHeaderILInt(b)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	id = TagID(v)
+	switch {
+	case id == IL_ILINT_TAG_ID || id == IL_SIGNED_ILINT_TAG_ID:
+		if len(b) == n {
+			return 0, 0, 0, io.ErrUnexpectedEOF
+		}
+		return id, n, uint64(ilint.EncodedSizeFromHeader(b[n])), nil
+	case id.Implicit():
+		size := implicitPayloadSize(id)
+		if size < 0 {
+			return 0, 0, 0, NewErrUnsupportedTagId(id)
+		}
+		return id, n, uint64(size), nil
+	}
+	size, m, err := decodeHeaderILInt(b[n:])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return id, n + m, size, nil
+}
+
+/*
+Parses the header of the next tag of the reader with ParseTagHeader() without
+consuming it. It waits only for the bytes of the header, thus it can be used
+with streams. It returns io.EOF if the reader ends before the tag and
+io.ErrUnexpectedEOF if it ends in the middle of the header.
+*/
+func PeekTagHeader(reader *bufio.Reader) (id TagID, headerSize int, payloadSize uint64, err error) {
+	for n := 1; ; n++ {
+		b, peekErr := reader.Peek(n)
+		id, headerSize, payloadSize, err = ParseTagHeader(b)
+		if err != io.ErrUnexpectedEOF {
+			return id, headerSize, payloadSize, err
+		}
+		if peekErr != nil {
+			if len(b) == 0 || peekErr != io.EOF {
+				return 0, 0, 0, peekErr
+			}
+			return 0, 0, 0, io.ErrUnexpectedEOF
+		}
+	}
+}
+
 /*
 Reads the payload of a tag. This function also verifies if the tag respects the
 maximum size allowed by this library.
@@ -33,6 +33,7 @@
 package tags
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 	"testing"
@@ -311,6 +312,72 @@ func TestReadTagHeader(t *testing.T) {
 	assert.Equal(t, uint64(0), s)
 }
 
+func TestParseTagHeader(t *testing.T) {
+	samples := []struct {
+		data    []byte
+		id      TagID
+		header  int
+		payload uint64
+	}{
+		{[]byte{0x00}, IL_NULL_TAG_ID, 1, 0},
+		{[]byte{0x03, 0xff}, IL_UINT8_TAG_ID, 1, 1},
+		{[]byte{0x0d}, IL_BIN128_TAG_ID, 1, 16},
+		{[]byte{0x0a, 0x05}, IL_ILINT_TAG_ID, 1, 1},
+		{[]byte{0x0e, 0xf9}, IL_SIGNED_ILINT_TAG_ID, 1, 3},
+		{[]byte{0x10, 0x05}, IL_BYTES_TAG_ID, 2, 5},
+		{[]byte{0xf9, 0x02, 0xf0, 0xf9, 0x01, 0x00}, 1000, 6, 504},
+	}
+	for _, s := range samples {
+		id, header, payload, err := ParseTagHeader(s.data)
+		assert.Nil(t, err, "%x", s.data)
+		assert.Equal(t, s.id, id, "%x", s.data)
+		assert.Equal(t, s.header, header, "%x", s.data)
+		assert.Equal(t, s.payload, payload, "%x", s.data)
+	}
+
+	// Incomplete headers
+	for _, b := range [][]byte{nil, {0x0a}, {0xf8}, {0x10}, {0x10, 0xf9, 0x01}} {
+		_, _, _, err := ParseTagHeader(b)
+		assert.Equal(t, io.ErrUnexpectedEOF, err, "%x", b)
+	}
+
+	// Corrupted headers
+	_, _, _, err := ParseTagHeader([]byte{0x0f})
+	assert.ErrorIs(t, err, ErrUnsupportedTagId)
+	_, _, _, err = ParseTagHeader([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.ErrorIs(t, err, ErrBadTagFormat)
+	_, _, _, err = ParseTagHeader([]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.ErrorIs(t, err, ErrBadTagFormat)
+}
+
+func TestPeekTagHeader(t *testing.T) {
+	r := bufio.NewReader(bytes.NewReader([]byte{0xf9, 0x02, 0xf0, 0x01, 0xaa}))
+	id, header, payload, err := PeekTagHeader(r)
+	assert.Nil(t, err)
+	assert.Equal(t, TagID(1000), id)
+	assert.Equal(t, 4, header)
+	assert.Equal(t, uint64(1), payload)
+	assert.Equal(t, 5, r.Buffered())
+
+	// Only the header is required
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte{0x10, 0x01, 0xaa})
+	id, header, payload, err = PeekTagHeader(bufio.NewReader(pr))
+	assert.Nil(t, err)
+	assert.Equal(t, IL_BYTES_TAG_ID, id)
+	assert.Equal(t, 2, header)
+	assert.Equal(t, uint64(1), payload)
+
+	// Errors
+	_, _, _, err = PeekTagHeader(bufio.NewReader(bytes.NewReader(nil)))
+	assert.Equal(t, io.EOF, err)
+	_, _, _, err = PeekTagHeader(bufio.NewReader(bytes.NewReader([]byte{0x10, 0xf9})))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, _, _, err = PeekTagHeader(bufio.NewReader(bytes.NewReader([]byte{0x0f})))
+	assert.ErrorIs(t, err, ErrUnsupportedTagId)
+}
+
 func TestReadTagPayload(t *testing.T) {
 
 	// Read ILInt
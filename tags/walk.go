@@ -0,0 +1,181 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	/*
+		Returned by Visitor.Enter() to skip the children of the tag. The walk
+		continues with the next sibling.
+	*/
+	SkipChildren = fmt.Errorf("skip children")
+	// Returned by the visitor to stop the walk. Walk() returns nil in this case.
+	SkipAll = fmt.Errorf("skip all")
+)
+
+/*
+ContainerChild is a tag held by a Container.
+*/
+type ContainerChild struct {
+	// Location of the child inside the container.
+	Element PathElement
+	// The child. It may be nil.
+	Tag ILTag
+}
+
+/*
+Container is the interface of the tags that hold other tags. It allows generic
+code, like Walk(), to descend into any composite tag, including the custom ones.
+
+Children selected by keys should use key elements while children selected by
+their position should use index elements.
+*/
+type Container interface {
+	// Returns the children of the container in the order they are serialized.
+	Children() []ContainerChild
+}
+
+/*
+Rebuilder is implemented by the containers that can be recreated with other
+children. It allows generic code, like impl.Transform(), to rewrite the
+contents of custom containers without modifying them.
+*/
+type Rebuilder interface {
+	Container
+	/*
+		Returns a new container with the same ID and the given children. The
+		children are the ones returned by Children(), in the same order, but
+		their tags may be replaced and some of them may be missing. It returns
+		an error that wraps ErrBadChildren if the container cannot hold them.
+	*/
+	Rebuild(children []ContainerChild) (ILTag, error)
+}
+
+/*
+Visitor receives the tags found by Walk().
+*/
+type Visitor interface {
+	/*
+		Called before the children of the tag are visited. It may return
+		SkipChildren to skip the children of the tag or SkipAll to stop the walk.
+		Any other error stops the walk and is returned by Walk().
+	*/
+	Enter(path Path, tag ILTag) error
+	/*
+		Called after the children of the tag are visited. It may return SkipAll
+		to stop the walk. Any other error stops the walk and is returned by
+		Walk().
+	*/
+	Leave(path Path, tag ILTag) error
+}
+
+/*
+VisitorFuncs implements Visitor using functions. Nil functions are ignored.
+*/
+type VisitorFuncs struct {
+	// Implementation of Visitor.Enter().
+	OnEnter func(path Path, tag ILTag) error
+	// Implementation of Visitor.Leave().
+	OnLeave func(path Path, tag ILTag) error
+}
+
+// Implementation of Visitor.Enter().
+func (v VisitorFuncs) Enter(path Path, tag ILTag) error {
+	if v.OnEnter == nil {
+		return nil
+	}
+	return v.OnEnter(path, tag)
+}
+
+// Implementation of Visitor.Leave().
+func (v VisitorFuncs) Leave(path Path, tag ILTag) error {
+	if v.OnLeave == nil {
+		return nil
+	}
+	return v.OnLeave(path, tag)
+}
+
+// Visits a tag and its children.
+func walk(path Path, tag ILTag, visitor Visitor) error {
+	err := visitor.Enter(path, tag)
+	if errors.Is(err, SkipChildren) {
+		err = nil
+	} else if err == nil && !IsILTagNil(tag) {
+		if c, ok := tag.(Container); ok {
+			for _, child := range c.Children() {
+				if err = walk(path.Append(child.Element), child.Tag, visitor); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err = visitor.Leave(path, tag); errors.Is(err, SkipChildren) {
+		err = nil
+	}
+	return err
+}
+
+/*
+Traverses the tag tree in depth-first order. Visitor.Enter() is called before
+the children of each tag are visited and Visitor.Leave() is called after them.
+Both receive the path of the tag, starting with the empty path of the root. The
+paths are never modified by Walk(), thus they may be retained by the visitor.
+
+Walk() descends into all tags that implement Container. Nil children are
+visited as nil tags.
+
+It returns the first error returned by the visitor, except SkipChildren and
+SkipAll, that only control the walk.
+*/
+func Walk(tag ILTag, visitor Visitor) error {
+	err := walk(Path{}, tag, visitor)
+	if errors.Is(err, SkipAll) {
+		return nil
+	}
+	return err
+}
+
+/*
+Calls fn for each tag of the tree in depth-first order. It is a shortcut to
+Walk() with a visitor that implements only Enter().
+*/
+func WalkFunc(tag ILTag, fn func(path Path, tag ILTag) error) error {
+	return Walk(tag, VisitorFuncs{OnEnter: fn})
+}
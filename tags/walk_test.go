@@ -0,0 +1,221 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Container used by the walk tests.
+type walkTestTag struct {
+	RawTag
+	children []ContainerChild
+}
+
+func (t *walkTestTag) Children() []ContainerChild {
+	return t.children
+}
+
+// Creates a tree with the structure {a: [1000, 1001], b: 1002, c: nil}.
+func createWalkTestTree() ILTag {
+	list := &walkTestTag{}
+	list.SetId(2000)
+	list.children = []ContainerChild{
+		{Element: IndexElement(0), Tag: NewRawTag(1000)},
+		{Element: IndexElement(1), Tag: NewRawTag(1001)},
+	}
+	root := &walkTestTag{}
+	root.SetId(2001)
+	root.children = []ContainerChild{
+		{Element: KeyElement("a"), Tag: list},
+		{Element: KeyElement("b"), Tag: NewRawTag(1002)},
+		{Element: KeyElement("c")},
+	}
+	return root
+}
+
+// Visitor that records the calls.
+type walkTestVisitor struct {
+	calls []string
+	enter map[string]error
+	leave map[string]error
+}
+
+func (v *walkTestVisitor) record(op string, path Path, tag ILTag) {
+	id := "nil"
+	if tag != nil {
+		id = fmt.Sprint(tag.Id())
+	}
+	v.calls = append(v.calls, fmt.Sprintf("%s %s %s", op, path, id))
+}
+
+func (v *walkTestVisitor) Enter(path Path, tag ILTag) error {
+	v.record("enter", path, tag)
+	return v.enter[path.String()]
+}
+
+func (v *walkTestVisitor) Leave(path Path, tag ILTag) error {
+	v.record("leave", path, tag)
+	return v.leave[path.String()]
+}
+
+func TestWalk(t *testing.T) {
+	root := createWalkTestTree()
+
+	v := &walkTestVisitor{}
+	assert.Nil(t, Walk(root, v))
+	assert.Equal(t, []string{
+		"enter $ 2001",
+		"enter $.a 2000",
+		"enter $.a[0] 1000",
+		"leave $.a[0] 1000",
+		"enter $.a[1] 1001",
+		"leave $.a[1] 1001",
+		"leave $.a 2000",
+		"enter $.b 1002",
+		"leave $.b 1002",
+		"enter $.c nil",
+		"leave $.c nil",
+		"leave $ 2001",
+	}, v.calls)
+
+	// Skip children
+	v = &walkTestVisitor{enter: map[string]error{"$.a": SkipChildren},
+		leave: map[string]error{"$.b": SkipChildren}}
+	assert.Nil(t, Walk(root, v))
+	assert.Equal(t, []string{
+		"enter $ 2001",
+		"enter $.a 2000",
+		"leave $.a 2000",
+		"enter $.b 1002",
+		"leave $.b 1002",
+		"enter $.c nil",
+		"leave $.c nil",
+		"leave $ 2001",
+	}, v.calls)
+
+	// Stop from enter
+	v = &walkTestVisitor{enter: map[string]error{"$.a[1]": SkipAll}}
+	assert.Nil(t, Walk(root, v))
+	assert.Equal(t, []string{
+		"enter $ 2001",
+		"enter $.a 2000",
+		"enter $.a[0] 1000",
+		"leave $.a[0] 1000",
+		"enter $.a[1] 1001",
+	}, v.calls)
+
+	// Stop from leave
+	v = &walkTestVisitor{leave: map[string]error{"$.a": fmt.Errorf("wrapped: %w", SkipAll)}}
+	assert.Nil(t, Walk(root, v))
+	assert.Len(t, v.calls, 7)
+
+	// Errors
+	v = &walkTestVisitor{enter: map[string]error{"$.b": io.ErrUnexpectedEOF}}
+	assert.ErrorIs(t, Walk(root, v), io.ErrUnexpectedEOF)
+	assert.Len(t, v.calls, 8)
+	v = &walkTestVisitor{leave: map[string]error{"$.a[0]": io.ErrUnexpectedEOF}}
+	assert.ErrorIs(t, Walk(root, v), io.ErrUnexpectedEOF)
+	assert.Len(t, v.calls, 4)
+	v = &walkTestVisitor{leave: map[string]error{"$": io.ErrUnexpectedEOF}}
+	assert.ErrorIs(t, Walk(root, v), io.ErrUnexpectedEOF)
+	assert.Len(t, v.calls, 12)
+
+	// Leaves, nil and typed nil containers
+	v = &walkTestVisitor{}
+	assert.Nil(t, Walk(NewRawTag(1), v))
+	assert.Equal(t, []string{"enter $ 1", "leave $ 1"}, v.calls)
+	v = &walkTestVisitor{}
+	assert.Nil(t, Walk(nil, v))
+	assert.Equal(t, []string{"enter $ nil", "leave $ nil"}, v.calls)
+	var typedNil *walkTestTag
+	n := 0
+	assert.Nil(t, WalkFunc(typedNil, func(path Path, tag ILTag) error {
+		n++
+		return nil
+	}))
+	assert.Equal(t, 1, n)
+}
+
+func TestWalk_Paths(t *testing.T) {
+	var paths []Path
+	assert.Nil(t, WalkFunc(createWalkTestTree(), func(path Path, tag ILTag) error {
+		paths = append(paths, path)
+		return nil
+	}))
+	// The paths may be retained by the visitor
+	assert.Equal(t, []Path{
+		{},
+		{KeyElement("a")},
+		{KeyElement("a"), IndexElement(0)},
+		{KeyElement("a"), IndexElement(1)},
+		{KeyElement("b")},
+		{KeyElement("c")},
+	}, paths)
+}
+
+func TestVisitorFuncs(t *testing.T) {
+	var v Visitor = VisitorFuncs{}
+	assert.Nil(t, v.Enter(nil, nil))
+	assert.Nil(t, v.Leave(nil, nil))
+
+	var calls []string
+	v = VisitorFuncs{
+		OnEnter: func(path Path, tag ILTag) error {
+			calls = append(calls, "enter "+path.String())
+			return SkipChildren
+		},
+		OnLeave: func(path Path, tag ILTag) error {
+			calls = append(calls, "leave "+path.String())
+			return io.EOF
+		},
+	}
+	assert.ErrorIs(t, Walk(createWalkTestTree(), v), io.EOF)
+	assert.Equal(t, []string{"enter $", "leave $"}, calls)
+}
+
+func TestWalkFunc(t *testing.T) {
+	var ids []TagID
+	assert.Nil(t, WalkFunc(createWalkTestTree(), func(path Path, tag ILTag) error {
+		if tag == nil {
+			return SkipAll
+		}
+		ids = append(ids, tag.Id())
+		return nil
+	}))
+	assert.Equal(t, []TagID{2001, 2000, 1000, 1001, 1002}, ids)
+}
@@ -0,0 +1,157 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagstream
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Decoder is an incremental tag decoder. It receives the serialized tags in
+chunks of arbitrary sizes and returns the tags as soon as they are complete,
+thus it never blocks waiting for more data. It is meant to be used by event
+loops that receive data from the network.
+
+Incomplete headers and payloads are kept buffered between calls to Feed().
+The size of the payload is verified as soon as the header is complete, thus
+the decoder never buffers more than the largest tag allowed.
+
+A Decoder must not be used by multiple goroutines at the same time.
+*/
+type Decoder struct {
+	// Maximum size of the payload of a tag. 0 means tags.MAX_TAG_SIZE.
+	MaxTagSize uint64
+	factory    tags.ILTagFactory
+	// Bytes of the incomplete tag.
+	buff []byte
+	// Offset of the first byte of buff in the stream.
+	offset int64
+	// The first error found. Once set, the decoder cannot be used anymore.
+	err error
+}
+
+// Creates a new Decoder that uses the given factory to create the tags.
+func NewDecoder(factory tags.ILTagFactory) *Decoder {
+	return &Decoder{factory: factory}
+}
+
+/*
+Returns the total size of the tag at the beginning of b. It returns 0 if the
+header of the tag is not complete.
+*/
+func (d *Decoder) tagSize(b []byte) (int, error) {
+	id, n, size, err := tags.ParseTagHeader(b)
+	if err == io.ErrUnexpectedEOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if id.Implicit() {
+		return n + int(size), nil
+	}
+	maxSize := d.MaxTagSize
+	if maxSize == 0 {
+		maxSize = tags.MAX_TAG_SIZE
+	}
+	if size > maxSize || size > uint64(math.MaxInt-n) {
+		return 0, tags.ErrTagTooLarge
+	}
+	return n + int(size), nil
+}
+
+/*
+Feeds the decoder with the next chunk of the stream. It returns the tags
+completed by this chunk, if any. The remaining bytes are kept until the next
+call.
+
+Once an error is returned, the decoder stops and all further calls return the
+same error. The error reports the offset of the tag that could not be decoded.
+The tags completed before the error are also returned.
+*/
+func (d *Decoder) Feed(b []byte) ([]tags.ILTag, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	data := b
+	if len(d.buff) > 0 {
+		d.buff = append(d.buff, b...)
+		data = d.buff
+	}
+	var result []tags.ILTag
+	for len(data) > 0 {
+		size, err := d.tagSize(data)
+		if err == nil && (size == 0 || size > len(data)) {
+			break
+		}
+		var tag tags.ILTag
+		if err == nil {
+			tag, err = tags.ILTagFromBytes(d.factory, data[:size])
+		}
+		if err != nil {
+			d.err = fmt.Errorf("offset %d: %w", d.offset, err)
+			d.buff = nil
+			return result, d.err
+		}
+		result = append(result, tag)
+		data = data[size:]
+		d.offset += int64(size)
+	}
+	// Keeps the incomplete tag in its own buffer
+	if len(data) == 0 {
+		d.buff = d.buff[:0]
+	} else if len(d.buff) == 0 || &data[0] != &d.buff[0] {
+		d.buff = append(d.buff[:0], data...)
+	}
+	return result, nil
+}
+
+/*
+Returns the number of bytes buffered by the decoder. They belong to a tag that
+is not complete yet.
+*/
+func (d *Decoder) Buffered() int {
+	return len(d.buff)
+}
+
+/*
+Returns the offset in the stream of the next tag to be decoded. It is the
+number of bytes consumed by the tags decoded so far.
+*/
+func (d *Decoder) Offset() int64 {
+	return d.offset
+}
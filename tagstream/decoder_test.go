@@ -0,0 +1,177 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagstream
+
+import (
+	"testing"
+
+	"github.com/interlockledger/go-iltags/serialization"
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Formats the tags in the text notation.
+func formatTags(l []tags.ILTag) []string {
+	s := []string{}
+	for _, t := range l {
+		s = append(s, impl.FormatTag(t))
+	}
+	return s
+}
+
+var decoderSamples = []string{
+	"null", "u8(1)", "ilint(1000)", "silint(-1)", "f128(0x000102030405060708090A0B0C0D0E0F)",
+	`str("hello")`, `array[u8(1), seq[null]]`, `#1000 bytes(0x0102)`, `dict{"a": str("b")}`,
+}
+
+func TestDecoder_Feed(t *testing.T) {
+	data := sample(decoderSamples...)
+
+	// All at once
+	d := NewDecoder(impl.NewStandardTagFactory(false))
+	l, err := d.Feed(data)
+	require.Nil(t, err)
+	assert.Equal(t, decoderSamples, formatTags(l))
+	assert.Equal(t, 0, d.Buffered())
+	assert.Equal(t, int64(len(data)), d.Offset())
+
+	// Chunks of all sizes
+	for chunk := 1; chunk <= len(data); chunk++ {
+		d = NewDecoder(impl.NewStandardTagFactory(false))
+		var decoded []tags.ILTag
+		for i := 0; i < len(data); i += chunk {
+			end := i + chunk
+			if end > len(data) {
+				end = len(data)
+			}
+			l, err := d.Feed(data[i:end])
+			require.Nil(t, err)
+			decoded = append(decoded, l...)
+			assert.Less(t, d.Buffered(), 32)
+		}
+		assert.Equal(t, decoderSamples, formatTags(decoded), "chunk %d", chunk)
+		assert.Equal(t, 0, d.Buffered())
+	}
+
+	// Empty chunks
+	d = NewDecoder(impl.NewStandardTagFactory(false))
+	l, err = d.Feed(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, l)
+	l, err = d.Feed(data[:2])
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"null"}, formatTags(l))
+	assert.Equal(t, 1, d.Buffered())
+	assert.Equal(t, int64(1), d.Offset())
+	l, err = d.Feed([]byte{})
+	assert.Nil(t, err)
+	assert.Empty(t, l)
+	assert.Equal(t, 1, d.Buffered())
+}
+
+func TestDecoder_Buffer(t *testing.T) {
+	data := sample(`#1000 bytes(0x0102030405)`, "u8(1)")
+	d := NewDecoder(impl.NewStandardTagFactory(false))
+
+	// The decoder keeps its own copy of the data
+	chunk := append([]byte{}, data[:4]...)
+	l, err := d.Feed(chunk)
+	require.Nil(t, err)
+	assert.Empty(t, l)
+	assert.Equal(t, 4, d.Buffered())
+	copy(chunk, []byte{0xff, 0xff, 0xff, 0xff})
+	l, err = d.Feed(data[4:])
+	require.Nil(t, err)
+	assert.Equal(t, []string{"#1000 bytes(0x0102030405)", "u8(1)"}, formatTags(l))
+	assert.Equal(t, 0, d.Buffered())
+}
+
+func TestDecoder_Limits(t *testing.T) {
+	data := sample(`bytes(0x01020304)`)
+	d := NewDecoder(impl.NewStandardTagFactory(false))
+	d.MaxTagSize = 4
+	l, err := d.Feed(data)
+	require.Nil(t, err)
+	assert.Len(t, l, 1)
+
+	// The size is checked before the payload arrives
+	d = NewDecoder(impl.NewStandardTagFactory(false))
+	d.MaxTagSize = 3
+	l, err = d.Feed(data[:2])
+	assert.Empty(t, l)
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	assert.Equal(t, 0, d.Buffered())
+
+	// Default limit
+	d = NewDecoder(impl.NewStandardTagFactory(false))
+	_, err = d.Feed([]byte{0x10, 0xfb, 0x20, 0x00, 0x00, 0x00})
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+	d = NewDecoder(impl.NewStandardTagFactory(false))
+	d.MaxTagSize = 0xFFFF_FFFF_FFFF_FFFF
+	_, err = d.Feed([]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x07})
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	samples := []struct {
+		data     []byte
+		expected error
+		msg      string
+	}{
+		{[]byte{0x0f}, tags.ErrUnsupportedTagId, "offset 1: unsupported tag with id 15: unsupported tag ID"},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat,
+			"offset 1: bad tag format"},
+		{[]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat,
+			"offset 1: bad tag format"},
+		{[]byte{0x01, 0x02}, serialization.ErrSerializationFormat, "offset 1: bad serialization format"},
+		{[]byte{0x15, 0x02, 0x02, 0x00}, tags.ErrBadTagFormat, "offset 1: bad tag format"},
+	}
+	for _, s := range samples {
+		d := NewDecoder(impl.NewStandardTagFactory(false))
+		l, err := d.Feed(append([]byte{0x00}, s.data...))
+		assert.Equal(t, []string{"null"}, formatTags(l), "%x", s.data)
+		assert.ErrorIs(t, err, s.expected, "%x", s.data)
+		assert.EqualError(t, err, s.msg, "%x", s.data)
+		// The error is permanent
+		l, err2 := d.Feed(sample("null"))
+		assert.Nil(t, l)
+		assert.Same(t, err, err2)
+	}
+
+	// Factory errors
+	d := NewDecoder(impl.NewStandardTagFactory(true))
+	_, err := d.Feed(sample(`#1000 bytes(0x01)`))
+	assert.ErrorIs(t, err, tags.ErrUnsupportedTagId)
+}
@@ -0,0 +1,148 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagstream
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+/*
+Type of an Event.
+*/
+type EventKind int
+
+const (
+	// Start of a container. It is followed by the events of its contents.
+	EventStartContainer EventKind = iota
+	// End of a container.
+	EventEndContainer
+	// Key of a dictionary entry. Value holds the key as a string.
+	EventKey
+	// A tag with a decoded value.
+	EventValue
+	// A tag whose payload is available in Event.Payload.
+	EventRawPayload
+)
+
+// Names of the event kinds.
+var eventKindNames = []string{"start", "end", "key", "value", "raw"}
+
+// Implementation of fmt.Stringer.
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventKindNames) {
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+	return eventKindNames[k]
+}
+
+/*
+Layout of a container.
+*/
+type ContainerKind int
+
+const (
+	// The tag is not a container.
+	NotContainer ContainerKind = iota
+	// Layout of the ILTagArrayTag: a count followed by the tags.
+	ArrayContainer
+	// Layout of the ILTagSequenceTag: tags until the end of the payload.
+	SequenceContainer
+	// Layout of the DictionaryTag: a count followed by string/tag pairs.
+	DictionaryContainer
+	// Layout of the StringDictionaryTag: a count followed by string pairs.
+	StringDictionaryContainer
+)
+
+// Names of the container kinds.
+var containerKindNames = []string{"none", "array", "sequence", "dictionary",
+	"stringdictionary"}
+
+// Implementation of fmt.Stringer.
+func (k ContainerKind) String() string {
+	if k < 0 || int(k) >= len(containerKindNames) {
+		return fmt.Sprintf("ContainerKind(%d)", int(k))
+	}
+	return containerKindNames[k]
+}
+
+/*
+Event is reported by the Parser for each element found in the stream. The
+parser reuses the same Event for all calls to the handler, thus it must not be
+retained after the handler returns.
+*/
+type Event struct {
+	// Type of the event.
+	Kind EventKind
+	// ID of the tag.
+	Id tags.TagID
+	/*
+		Offset of the first byte of the tag in the stream. For
+		EventEndContainer it is the offset of the first byte after the
+		container.
+	*/
+	Offset int64
+	// Size of the payload of the tag.
+	Size uint64
+	// Nesting level of the tag. Top level tags have depth 0.
+	Depth int
+	// Layout of the container. Used only by container events.
+	Container ContainerKind
+	/*
+		Number of entries of arrays and dictionaries. It is always 0 for
+		sequences as their number of entries is not known in advance.
+	*/
+	Count uint64
+	/*
+		Decoded value of EventKey and EventValue events. It is nil for the
+		ILNullTag, bool, int8, uint8, int16, uint16, int32, uint32, int64, uint64,
+		float32, float64, [16]byte or string according to the type of the tag.
+		ILInt and signed ILInt tags use uint64 and int64 respectively.
+	*/
+	Value any
+	/*
+		Reader of the payload of EventRawPayload events. Bytes not read by the
+		handler are skipped by the parser.
+	*/
+	Payload io.Reader
+}
+
+/*
+Handler receives the events reported by the Parser. It may return
+tags.SkipChildren on EventStartContainer to skip the contents of the container
+(EventEndContainer is still reported) or tags.SkipAll to stop the parser. Any
+other error stops the parser and is returned by Parser.Parse().
+*/
+type Handler func(e *Event) error
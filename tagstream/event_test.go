@@ -0,0 +1,59 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventKind_String(t *testing.T) {
+	assert.Equal(t, "start", EventStartContainer.String())
+	assert.Equal(t, "end", EventEndContainer.String())
+	assert.Equal(t, "key", EventKey.String())
+	assert.Equal(t, "value", EventValue.String())
+	assert.Equal(t, "raw", EventRawPayload.String())
+	assert.Equal(t, "EventKind(5)", EventKind(5).String())
+	assert.Equal(t, "EventKind(-1)", EventKind(-1).String())
+}
+
+func TestContainerKind_String(t *testing.T) {
+	assert.Equal(t, "none", NotContainer.String())
+	assert.Equal(t, "array", ArrayContainer.String())
+	assert.Equal(t, "sequence", SequenceContainer.String())
+	assert.Equal(t, "dictionary", DictionaryContainer.String())
+	assert.Equal(t, "stringdictionary", StringDictionaryContainer.String())
+	assert.Equal(t, "ContainerKind(5)", ContainerKind(5).String())
+	assert.Equal(t, "ContainerKind(-1)", ContainerKind(-1).String())
+}
@@ -0,0 +1,481 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagstream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/interlockledger/go-iltags/ilint"
+	"github.com/interlockledger/go-iltags/tags"
+)
+
+// Default maximum nesting level of the containers.
+const DefaultMaxDepth = 256
+
+// The containers are nested deeper than allowed by the parser.
+var ErrTooDeep = fmt.Errorf("maximum nesting depth exceeded")
+
+/*
+Parser is an event based parser for serialized tags. It is safe to use a
+Parser from multiple goroutines as long as it is not modified after being
+configured.
+*/
+type Parser struct {
+	// Maximum size of the payload of a tag. 0 means tags.MAX_TAG_SIZE.
+	MaxTagSize uint64
+	// Maximum nesting level of the containers. 0 means DefaultMaxDepth.
+	MaxDepth int
+	// Custom containers.
+	containers map[tags.TagID]ContainerKind
+}
+
+// Creates a new Parser with the default limits.
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+/*
+Registers a custom tag ID that uses the layout of one of the standard
+containers. Only non reserved IDs can be registered. Registering a tag with
+NotContainer removes its registration.
+*/
+func (p *Parser) RegisterContainer(id tags.TagID, kind ContainerKind) {
+	if id.Reserved() {
+		panic("Reserved tags cannot be overriden.")
+	}
+	if kind < NotContainer || kind > StringDictionaryContainer {
+		panic("Invalid container kind.")
+	}
+	if p.containers == nil {
+		p.containers = make(map[tags.TagID]ContainerKind, 8)
+	}
+	if kind == NotContainer {
+		delete(p.containers, id)
+	} else {
+		p.containers[id] = kind
+	}
+}
+
+// Returns the layout of the tag with the given ID.
+func (p *Parser) containerKind(id tags.TagID) ContainerKind {
+	switch id {
+	case tags.IL_ILTAGARRAY_TAG_ID:
+		return ArrayContainer
+	case tags.IL_ILTAGSEQ_TAG_ID:
+		return SequenceContainer
+	case tags.IL_DICTIONARY_TAG_ID:
+		return DictionaryContainer
+	case tags.IL_STRING_DICTIONARY_TAG_ID:
+		return StringDictionaryContainer
+	}
+	return p.containers[id]
+}
+
+// State of a single call to Parser.Parse().
+type parseState struct {
+	parser   *Parser
+	reader   *bufio.Reader
+	handler  Handler
+	offset   int64
+	maxSize  uint64
+	maxDepth int
+	event    Event
+	payload  io.LimitedReader
+	buff     [16]byte
+}
+
+// Implementation of io.Reader that keeps track of the offset.
+func (s *parseState) Read(b []byte) (int, error) {
+	n, err := s.reader.Read(b)
+	s.offset += int64(n)
+	return n, err
+}
+
+// Returns an error that reports the offset where the parser stopped.
+func (s *parseState) fail(offset int64, err error) error {
+	return fmt.Errorf("offset %d: %w", offset, err)
+}
+
+// Fills b with the bytes of the stream.
+func (s *parseState) read(b []byte) error {
+	if _, err := io.ReadFull(s, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// Reads an ILInt. It returns io.EOF only if the stream ends before its first byte.
+func (s *parseState) readILInt() (uint64, error) {
+	if err := s.read(s.buff[:1]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	size := ilint.EncodedSizeFromHeader(s.buff[0])
+	if size == 1 {
+		return uint64(s.buff[0]), nil
+	}
+	if err := s.read(s.buff[:size-1]); err != nil {
+		return 0, err
+	}
+	v, err := ilint.DecodeBody(s.buff[:size-1])
+	if err != nil {
+		return 0, tags.ErrBadTagFormat
+	}
+	return v, nil
+}
+
+// Reads an ILInt that must end before end. Unexpected EOFs are reported as such.
+func (s *parseState) readILIntBefore(end int64) (uint64, error) {
+	v, err := s.readILInt()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && end >= 0 && s.offset > end {
+		err = tags.ErrBadTagFormat
+	}
+	return v, err
+}
+
+/*
+Calls the handler. tags.SkipChildren is only meaningful for the start of the
+containers and is ignored for the other events.
+*/
+func (s *parseState) emit() error {
+	err := s.handler(&s.event)
+	if err != nil && s.event.Kind != EventStartContainer &&
+		errors.Is(err, tags.SkipChildren) {
+		return nil
+	}
+	return err
+}
+
+// Discards the bytes of the stream until the given offset.
+func (s *parseState) skip(end int64) error {
+	if _, err := io.CopyN(io.Discard, s, end-s.offset); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// Decodes the value of an implicit tag.
+func (s *parseState) implicitValue(id tags.TagID) (any, error) {
+	b := s.buff[:]
+	var size int
+	switch id {
+	case tags.IL_NULL_TAG_ID:
+		return nil, nil
+	case tags.IL_ILINT_TAG_ID:
+		return s.readILIntBefore(-1)
+	case tags.IL_SIGNED_ILINT_TAG_ID:
+		v, err := s.readILIntBefore(-1)
+		return ilint.SignedDecode(v), err
+	case tags.IL_BOOL_TAG_ID, tags.IL_INT8_TAG_ID, tags.IL_UINT8_TAG_ID:
+		size = 1
+	case tags.IL_INT16_TAG_ID, tags.IL_UINT16_TAG_ID:
+		size = 2
+	case tags.IL_INT32_TAG_ID, tags.IL_UINT32_TAG_ID, tags.IL_BIN32_TAG_ID:
+		size = 4
+	case tags.IL_INT64_TAG_ID, tags.IL_UINT64_TAG_ID, tags.IL_BIN64_TAG_ID:
+		size = 8
+	case tags.IL_BIN128_TAG_ID:
+		size = 16
+	default:
+		return nil, tags.NewErrUnsupportedTagId(id)
+	}
+	if err := s.read(b[:size]); err != nil {
+		return nil, err
+	}
+	switch id {
+	case tags.IL_BOOL_TAG_ID:
+		if b[0] > 1 {
+			return nil, tags.ErrBadTagFormat
+		}
+		return b[0] == 1, nil
+	case tags.IL_INT8_TAG_ID:
+		return int8(b[0]), nil
+	case tags.IL_UINT8_TAG_ID:
+		return b[0], nil
+	case tags.IL_INT16_TAG_ID:
+		return int16(binary.BigEndian.Uint16(b)), nil
+	case tags.IL_UINT16_TAG_ID:
+		return binary.BigEndian.Uint16(b), nil
+	case tags.IL_INT32_TAG_ID:
+		return int32(binary.BigEndian.Uint32(b)), nil
+	case tags.IL_UINT32_TAG_ID:
+		return binary.BigEndian.Uint32(b), nil
+	case tags.IL_BIN32_TAG_ID:
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case tags.IL_INT64_TAG_ID:
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case tags.IL_UINT64_TAG_ID:
+		return binary.BigEndian.Uint64(b), nil
+	case tags.IL_BIN64_TAG_ID:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return s.buff, nil
+	}
+}
+
+/*
+Reads a string payload with the given size. The buffer grows as the data is
+read, thus corrupted sizes do not result in large allocations.
+*/
+func (s *parseState) readString(size uint64) (string, error) {
+	var b strings.Builder
+	if _, err := io.CopyN(&b, s, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if !utf8.ValidString(b.String()) {
+		return "", tags.ErrBadTagFormat
+	}
+	return b.String(), nil
+}
+
+// Reads and reports a standard string tag used by the dictionaries.
+func (s *parseState) dictionaryString(kind EventKind, depth int, end int64) error {
+	start := s.offset
+	id, err := s.readILIntBefore(end)
+	if err != nil {
+		return s.fail(start, err)
+	}
+	if tags.TagID(id) != tags.IL_STRING_TAG_ID {
+		return s.fail(start, tags.NewErrUnexpectedTagId(tags.IL_STRING_TAG_ID, tags.TagID(id)))
+	}
+	size, err := s.readILIntBefore(end)
+	if err == nil && size > uint64(end-s.offset) {
+		err = tags.ErrBadTagFormat
+	}
+	if err != nil {
+		return s.fail(start, err)
+	}
+	v, err := s.readString(size)
+	if err != nil {
+		return s.fail(start, err)
+	}
+	s.event = Event{Kind: kind, Id: tags.IL_STRING_TAG_ID, Offset: start, Size: size,
+		Depth: depth, Value: v}
+	return s.emit()
+}
+
+// Parses the contents of a container that ends at end.
+func (s *parseState) contents(kind ContainerKind, depth int, end int64) error {
+	var count uint64
+	if kind != SequenceContainer {
+		start := s.offset
+		var err error
+		count, err = s.readILIntBefore(end)
+		if err != nil {
+			return s.fail(start, err)
+		}
+		// The smallest element is a null (1 byte). The smallest dictionary
+		// entry is an empty string key (2 bytes) followed by a null or, in
+		// string dictionaries, by another empty string.
+		var minSize uint64
+		switch kind {
+		case DictionaryContainer:
+			minSize = 3
+		case StringDictionaryContainer:
+			minSize = 4
+		default:
+			minSize = 1
+		}
+		if count > uint64(end-s.offset)/minSize {
+			return s.fail(start, tags.ErrBadTagFormat)
+		}
+	}
+	s.event.Count = count
+	if err := s.emit(); err != nil {
+		if !errors.Is(err, tags.SkipChildren) {
+			return err
+		}
+		if err := s.skip(end); err != nil {
+			return s.fail(s.offset, err)
+		}
+		return nil
+	}
+	switch kind {
+	case ArrayContainer:
+		for i := uint64(0); i < count; i++ {
+			if err := s.tag(depth, end); err != nil {
+				return err
+			}
+		}
+	case SequenceContainer:
+		for s.offset < end {
+			if err := s.tag(depth, end); err != nil {
+				return err
+			}
+		}
+	default:
+		for i := uint64(0); i < count; i++ {
+			if err := s.dictionaryString(EventKey, depth, end); err != nil {
+				return err
+			}
+			var err error
+			if kind == DictionaryContainer {
+				err = s.tag(depth, end)
+			} else {
+				err = s.dictionaryString(EventValue, depth, end)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if s.offset != end {
+		return s.fail(s.offset, tags.ErrBadTagFormat)
+	}
+	return nil
+}
+
+/*
+Parses a single tag that must end before end. If end is negative, the tag is
+not limited.
+*/
+func (s *parseState) tag(depth int, end int64) error {
+	start := s.offset
+	v, err := s.readILIntBefore(end)
+	if err != nil {
+		return s.fail(start, err)
+	}
+	id := tags.TagID(v)
+	if id.Implicit() {
+		value, err := s.implicitValue(id)
+		if err == nil && end >= 0 && s.offset > end {
+			err = tags.ErrBadTagFormat
+		}
+		if err != nil {
+			return s.fail(start, err)
+		}
+		s.event = Event{Kind: EventValue, Id: id, Offset: start,
+			Size:  uint64(s.offset - start - int64(ilint.EncodedSize(v))),
+			Depth: depth, Value: value}
+		return s.emit()
+	}
+	size, err := s.readILIntBefore(end)
+	if err == nil && size > s.maxSize {
+		err = tags.ErrTagTooLarge
+	}
+	if err == nil && end >= 0 && size > uint64(end-s.offset) {
+		err = tags.ErrBadTagFormat
+	}
+	if err != nil {
+		return s.fail(start, err)
+	}
+	payloadEnd := s.offset + int64(size)
+	s.event = Event{Id: id, Offset: start, Size: size, Depth: depth}
+	if kind := s.parser.containerKind(id); kind != NotContainer {
+		if depth >= s.maxDepth {
+			return s.fail(start, ErrTooDeep)
+		}
+		s.event.Kind = EventStartContainer
+		s.event.Container = kind
+		if err := s.contents(kind, depth+1, payloadEnd); err != nil {
+			return err
+		}
+		s.event = Event{Kind: EventEndContainer, Id: id, Offset: s.offset,
+			Size: size, Depth: depth, Container: kind}
+		return s.emit()
+	}
+	if id == tags.IL_STRING_TAG_ID {
+		if s.event.Value, err = s.readString(size); err != nil {
+			return s.fail(start, err)
+		}
+		s.event.Kind = EventValue
+		return s.emit()
+	}
+	s.payload = io.LimitedReader{R: s, N: int64(size)}
+	s.event.Kind = EventRawPayload
+	s.event.Payload = &s.payload
+	if err := s.emit(); err != nil {
+		return err
+	}
+	if err := s.skip(payloadEnd); err != nil {
+		return s.fail(s.offset, err)
+	}
+	return nil
+}
+
+/*
+Parses all tags found in the reader and reports them to the handler. It stops
+at the end of the stream, when the handler returns an error or when a
+malformed tag is found. Errors found in the stream are reported with the offset
+where they were detected.
+
+It returns nil if the whole stream was parsed or the handler returned
+tags.SkipAll.
+*/
+func (p *Parser) Parse(reader io.Reader, handler Handler) error {
+	s := parseState{parser: p, handler: handler, maxSize: p.MaxTagSize,
+		maxDepth: p.MaxDepth}
+	if s.maxSize == 0 {
+		s.maxSize = tags.MAX_TAG_SIZE
+	}
+	if s.maxDepth == 0 {
+		s.maxDepth = DefaultMaxDepth
+	}
+	if r, ok := reader.(*bufio.Reader); ok {
+		s.reader = r
+	} else {
+		s.reader = bufio.NewReader(reader)
+	}
+	for {
+		if _, err := s.reader.Peek(1); err == io.EOF {
+			return nil
+		}
+		if err := s.tag(0, -1); err != nil {
+			if errors.Is(err, tags.SkipAll) {
+				return nil
+			}
+			return err
+		}
+	}
+}
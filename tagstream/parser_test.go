@@ -0,0 +1,343 @@
+/*
+ * BSD 3-Clause License
+ *
+ * Copyright (c) 2022, InterlockLedger Network
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ *
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * * Neither the name of the copyright holder nor the names of its
+ *   contributors may be used to endorse or promote products derived from
+ *   this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tagstream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/interlockledger/go-iltags/tags"
+	"github.com/interlockledger/go-iltags/tags/impl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Handler that records the events as strings.
+type eventRecorder struct {
+	events []string
+	// Errors returned by the handler, indexed by the number of the event.
+	errors map[int]error
+	// Number of bytes of the payloads to be read.
+	read int64
+}
+
+func (r *eventRecorder) handle(e *Event) error {
+	s := fmt.Sprintf("%s%s #%d @%d", strings.Repeat("  ", e.Depth), e.Kind, e.Id, e.Offset)
+	switch e.Kind {
+	case EventStartContainer:
+		s += fmt.Sprintf(" %s size=%d count=%d", e.Container, e.Size, e.Count)
+	case EventEndContainer:
+		s += fmt.Sprintf(" %s", e.Container)
+	case EventKey, EventValue:
+		s += fmt.Sprintf(" %T(%v) size=%d", e.Value, e.Value, e.Size)
+	case EventRawPayload:
+		n := r.read
+		if n == 0 {
+			n = int64(e.Size)
+		}
+		b, err := io.ReadAll(io.LimitReader(e.Payload, n))
+		if err != nil {
+			return err
+		}
+		s += fmt.Sprintf(" size=%d %x", e.Size, b)
+	}
+	r.events = append(r.events, s)
+	return r.errors[len(r.events)-1]
+}
+
+// Parses the data and returns the events.
+func parseEvents(t *testing.T, p *Parser, data []byte) []string {
+	r := &eventRecorder{}
+	require.Nil(t, p.Parse(bytes.NewReader(data), r.handle))
+	return r.events
+}
+
+// Serializes the tags given in the text notation.
+func sample(notation ...string) []byte {
+	var b []byte
+	for _, n := range notation {
+		b = append(b, impl.MustParseTagBytes(n)...)
+	}
+	return b
+}
+
+func TestParser_Values(t *testing.T) {
+	data := sample("null", "bool(true)", "bool(false)", "i8(-1)", "u8(1)", "i16(-2)",
+		"u16(2)", "i32(-3)", "u32(3)", "i64(-4)", "u64(4)", "ilint(1000)",
+		"f32(1.5)", "f64(-2.5)", "silint(-1000)", `str("hi")`, `str("")`)
+	assert.Equal(t, []string{
+		"value #0 @0 <nil>(<nil>) size=0",
+		"value #1 @1 bool(true) size=1",
+		"value #1 @3 bool(false) size=1",
+		"value #2 @5 int8(-1) size=1",
+		"value #3 @7 uint8(1) size=1",
+		"value #4 @9 int16(-2) size=2",
+		"value #5 @12 uint16(2) size=2",
+		"value #6 @15 int32(-3) size=4",
+		"value #7 @20 uint32(3) size=4",
+		"value #8 @25 int64(-4) size=8",
+		"value #9 @34 uint64(4) size=8",
+		"value #10 @43 uint64(1000) size=3",
+		"value #11 @47 float32(1.5) size=4",
+		"value #12 @52 float64(-2.5) size=8",
+		"value #14 @61 int64(-1000) size=3",
+		"value #17 @65 string(hi) size=2",
+		"value #17 @69 string() size=0",
+	}, parseEvents(t, NewParser(), data))
+
+	var v any
+	f128 := sample("f128(0x000102030405060708090a0b0c0d0e0f)")
+	require.Nil(t, NewParser().Parse(bytes.NewReader(f128), func(e *Event) error {
+		v = e.Value
+		return nil
+	}))
+	assert.Equal(t, [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, v)
+
+	assert.Empty(t, parseEvents(t, NewParser(), nil))
+}
+
+func TestParser_Containers(t *testing.T) {
+	data := sample(`array[u8(1), seq[null, bytes(0xCAFE)]]`,
+		`dict{"a": u8(2), "b": strdict{"c": "d"}}`)
+	assert.Equal(t, []string{
+		"start #21 @0 array size=10 count=2",
+		"  value #3 @3 uint8(1) size=1",
+		"  start #22 @5 sequence size=5 count=0",
+		"    value #0 @7 <nil>(<nil>) size=0",
+		"    raw #16 @8 size=2 cafe",
+		"  end #22 @12 sequence",
+		"end #21 @12 array",
+		"start #30 @12 dictionary size=18 count=2",
+		`  key #17 @15 string(a) size=1`,
+		"  value #3 @18 uint8(2) size=1",
+		`  key #17 @20 string(b) size=1`,
+		"  start #31 @23 stringdictionary size=7 count=1",
+		"    key #17 @26 string(c) size=1",
+		"    value #17 @29 string(d) size=1",
+		"  end #31 @32 stringdictionary",
+		"end #30 @32 dictionary",
+	}, parseEvents(t, NewParser(), data))
+
+	// Empty containers
+	assert.Equal(t, []string{
+		"start #21 @0 array size=1 count=0",
+		"end #21 @3 array",
+		"start #22 @3 sequence size=0 count=0",
+		"end #22 @5 sequence",
+	}, parseEvents(t, NewParser(), sample("array[]", "seq[]")))
+
+	// Entries with an empty key and a null value take only 3 bytes
+	data = []byte{0x1e, 0x08, 0x02, 0x11, 0x00, 0x00, 0x11, 0x01, 'a', 0x00}
+	assert.Equal(t, []string{
+		"start #30 @0 dictionary size=8 count=2",
+		"  key #17 @3 string() size=0",
+		"  value #0 @5 <nil>(<nil>) size=0",
+		"  key #17 @6 string(a) size=1",
+		"  value #0 @9 <nil>(<nil>) size=0",
+		"end #30 @10 dictionary",
+	}, parseEvents(t, NewParser(), data))
+}
+
+func TestParser_CustomContainers(t *testing.T) {
+	data := sample(`#1000 seq[u8(1)]`, `#1001 array[u8(2)]`)
+	p := NewParser()
+	assert.Equal(t, []string{
+		"raw #1000 @0 size=2 0301",
+		"raw #1001 @6 size=3 010302",
+	}, parseEvents(t, p, data))
+
+	p.RegisterContainer(1000, SequenceContainer)
+	p.RegisterContainer(1001, ArrayContainer)
+	assert.Equal(t, []string{
+		"start #1000 @0 sequence size=2 count=0",
+		"  value #3 @4 uint8(1) size=1",
+		"end #1000 @6 sequence",
+		"start #1001 @6 array size=3 count=1",
+		"  value #3 @11 uint8(2) size=1",
+		"end #1001 @13 array",
+	}, parseEvents(t, p, data))
+
+	p.RegisterContainer(1001, NotContainer)
+	assert.Equal(t, "raw #1001 @6 size=3 010302", parseEvents(t, p, data)[3])
+
+	assert.Panics(t, func() { p.RegisterContainer(tags.IL_BYTES_TAG_ID, SequenceContainer) })
+	assert.Panics(t, func() { p.RegisterContainer(1000, ContainerKind(5)) })
+	assert.Panics(t, func() { p.RegisterContainer(1000, ContainerKind(-1)) })
+}
+
+func TestParser_Control(t *testing.T) {
+	data := sample(`array[u8(1), seq[u8(2)]]`, `#1000 bytes(0x0102)`, "u8(3)")
+
+	// Skips the contents of the containers
+	r := &eventRecorder{errors: map[int]error{2: tags.SkipChildren, 3: tags.SkipChildren}}
+	require.Nil(t, NewParser().Parse(bytes.NewReader(data), r.handle))
+	assert.Equal(t, []string{
+		"start #21 @0 array size=7 count=2",
+		"  value #3 @3 uint8(1) size=1",
+		"  start #22 @5 sequence size=2 count=0",
+		"  end #22 @9 sequence",
+		"end #21 @9 array",
+		"raw #1000 @9 size=2 0102",
+		"value #3 @15 uint8(3) size=1",
+	}, r.events)
+
+	// Unread payloads are skipped
+	r = &eventRecorder{read: 1}
+	require.Nil(t, NewParser().Parse(bytes.NewReader(data), r.handle))
+	assert.Equal(t, "raw #1000 @9 size=2 01", r.events[6])
+	assert.Equal(t, "value #3 @15 uint8(3) size=1", r.events[7])
+
+	// Stops
+	r = &eventRecorder{errors: map[int]error{1: tags.SkipAll}}
+	require.Nil(t, NewParser().Parse(bytes.NewReader(data), r.handle))
+	assert.Len(t, r.events, 2)
+	r = &eventRecorder{errors: map[int]error{5: fmt.Errorf("stop: %w", tags.SkipAll)}}
+	require.Nil(t, NewParser().Parse(bytes.NewReader(data), r.handle))
+	assert.Len(t, r.events, 6)
+
+	// Errors
+	for i := 0; i < 7; i++ {
+		r = &eventRecorder{errors: map[int]error{i: io.ErrNoProgress}}
+		assert.ErrorIs(t, NewParser().Parse(bytes.NewReader(data), r.handle), io.ErrNoProgress)
+		assert.Len(t, r.events, i+1)
+	}
+}
+
+func TestParser_Limits(t *testing.T) {
+	data := sample(`array[seq[array[]]]`)
+	p := NewParser()
+	p.MaxDepth = 3
+	assert.Len(t, parseEvents(t, p, data), 6)
+	p.MaxDepth = 2
+	err := p.Parse(bytes.NewReader(data), func(e *Event) error { return nil })
+	assert.ErrorIs(t, err, ErrTooDeep)
+	assert.EqualError(t, err, "offset 5: maximum nesting depth exceeded")
+
+	p = NewParser()
+	p.MaxTagSize = 3
+	assert.Len(t, parseEvents(t, p, sample("bytes(0x010203)")), 1)
+	err = p.Parse(bytes.NewReader(sample("bytes(0x01020304)")), func(e *Event) error { return nil })
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+
+	// The default limit
+	err = NewParser().Parse(bytes.NewReader([]byte{0x10, 0xff, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}),
+		func(e *Event) error { return nil })
+	assert.ErrorIs(t, err, tags.ErrTagTooLarge)
+}
+
+func TestParser_Errors(t *testing.T) {
+	samples := []struct {
+		data     []byte
+		expected error
+		msg      string
+	}{
+		// Truncated headers and payloads
+		{[]byte{0xf9}, io.ErrUnexpectedEOF, "offset 0: unexpected EOF"},
+		{[]byte{0x10}, io.ErrUnexpectedEOF, "offset 0: unexpected EOF"},
+		{[]byte{0x10, 0x02, 0x01}, io.ErrUnexpectedEOF, "offset 3: unexpected EOF"},
+		{[]byte{0x11, 0x02, 0x01}, io.ErrUnexpectedEOF, "offset 0: unexpected EOF"},
+		{[]byte{0x04, 0x01}, io.ErrUnexpectedEOF, "offset 0: unexpected EOF"},
+		{[]byte{0x0a, 0xf9}, io.ErrUnexpectedEOF, "offset 0: unexpected EOF"},
+		{[]byte{0x15, 0x02, 0x01}, io.ErrUnexpectedEOF, "offset 3: unexpected EOF"},
+		// Corrupted data
+		{[]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, tags.ErrBadTagFormat,
+			"offset 0: bad tag format"},
+		{[]byte{0x01, 0x02}, tags.ErrBadTagFormat, "offset 0: bad tag format"},
+		{[]byte{0x0f}, tags.ErrUnsupportedTagId, "offset 0: "},
+		{[]byte{0x11, 0x01, 0xff}, tags.ErrBadTagFormat, "offset 0: bad tag format"},
+		// Containers
+		{[]byte{0x15, 0x01, 0x02}, tags.ErrBadTagFormat, "offset 2: bad tag format"},
+		{[]byte{0x15, 0x02, 0x01, 0x04, 0x01, 0x00}, tags.ErrBadTagFormat, "offset 3: bad tag format"},
+		{[]byte{0x15, 0x03, 0x01, 0x00, 0x00}, tags.ErrBadTagFormat, "offset 4: bad tag format"},
+		{[]byte{0x16, 0x02, 0x10, 0x01, 0x00}, tags.ErrBadTagFormat, "offset 2: bad tag format"},
+		{[]byte{0x16, 0x01, 0x03, 0x01}, tags.ErrBadTagFormat, "offset 2: bad tag format"},
+		{[]byte{0x15, 0x02, 0x01, 0xf9, 0x00, 0x00}, tags.ErrBadTagFormat, "offset 3: bad tag format"},
+		{[]byte{0x1e, 0x01, 0x01}, tags.ErrBadTagFormat, "offset 2: bad tag format"},
+		{[]byte{0x1e, 0x03, 0x02, 0x11, 0x00}, tags.ErrBadTagFormat, "offset 2: bad tag format"},
+		{[]byte{0x1f, 0x04, 0x01, 0x11, 0x00, 0x00}, tags.ErrBadTagFormat, "offset 2: bad tag format"},
+		{[]byte{0x1e, 0x05, 0x01, 0x10, 0x00, 0x00, 0x00}, tags.ErrUnexpectedTagId,
+			"offset 3: "},
+		{[]byte{0x1e, 0x05, 0x01, 0x11, 0x05, 0x00, 0x00}, tags.ErrBadTagFormat,
+			"offset 3: bad tag format"},
+		{[]byte{0x1e, 0x05, 0x01, 0x11, 0x00, 0xf9, 0x00, 0x00}, tags.ErrBadTagFormat,
+			"offset 5: bad tag format"},
+		{[]byte{0x1e, 0x05, 0x01, 0x11, 0x01, 0xff, 0x00}, tags.ErrBadTagFormat,
+			"offset 3: bad tag format"},
+		{[]byte{0x1f, 0x05, 0x01, 0x11, 0x00, 0x11, 0x01}, tags.ErrBadTagFormat,
+			"offset 5: bad tag format"},
+	}
+	for _, s := range samples {
+		r := &eventRecorder{}
+		err := NewParser().Parse(bytes.NewReader(s.data), r.handle)
+		require.Error(t, err, "%x", s.data)
+		assert.ErrorIs(t, err, s.expected, "%x", s.data)
+		assert.Contains(t, err.Error(), s.msg, "%x", s.data)
+	}
+}
+
+func TestParser_Reader(t *testing.T) {
+	data := sample(`array[u8(1), bytes(0x0102), str("abc")]`, "u8(2)")
+	expected := parseEvents(t, NewParser(), data)
+
+	// Reads one byte at a time
+	r := &eventRecorder{}
+	require.Nil(t, NewParser().Parse(iotest.OneByteReader(bytes.NewReader(data)), r.handle))
+	assert.Equal(t, expected, r.events)
+
+	// Read errors
+	for i := 0; i < len(data); i++ {
+		reader := io.MultiReader(bytes.NewReader(data[:i]), iotest.ErrReader(io.ErrClosedPipe))
+		err := NewParser().Parse(reader, func(e *Event) error {
+			if e.Kind == EventRawPayload {
+				_, err := io.ReadAll(e.Payload)
+				return err
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, io.ErrClosedPipe, "%d", i)
+	}
+	// Truncated skipped container
+	err := NewParser().Parse(bytes.NewReader(data[:5]), func(e *Event) error {
+		return tags.SkipChildren
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	// Truncated skipped payload
+	err = NewParser().Parse(bytes.NewReader(sample("bytes(0x0102)")[:3]), func(e *Event) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}